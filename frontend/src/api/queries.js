@@ -1,15 +1,17 @@
 export const GET_PRODUCTS = `
   query GetProducts {
-    products {
-      id
-      title
-      description
-      sum
-      score
-      time
-      subjectLimit
-      productType
-      dateCreated
+    products(first: 500) {
+      nodes {
+        id
+        title
+        description
+        sum
+        score
+        time
+        subjectLimit
+        productType
+        dateCreated
+      }
     }
   }
 `;
@@ -32,30 +34,34 @@ export const GET_PRODUCT = `
 
 export const GET_TESTS = `
   query GetTests {
-    tests {
-      id
-      title
-      numberOfQuestions
-      time
-      score
-      grade
-      dateCreated
-      isRequired
+    tests(first: 500) {
+      nodes {
+        id
+        title
+        numberOfQuestions
+        time
+        score
+        grade
+        dateCreated
+        isRequired
+      }
     }
   }
 `;
 
 export const GET_TESTS_BY_PRODUCT = `
   query GetTestsByProduct($productId: UUID!) {
-    tests(productId: $productId) {
-      id
-      title
-      numberOfQuestions
-      time
-      score
-      grade
-      dateCreated
-      isRequired
+    tests(filter: { productId: $productId }, first: 500) {
+      nodes {
+        id
+        title
+        numberOfQuestions
+        time
+        score
+        grade
+        dateCreated
+        isRequired
+      }
     }
   }
 `;
@@ -77,7 +83,36 @@ export const GET_TEST = `
 
 export const GET_QUESTIONS = `
   query GetQuestions($testId: UUID!) {
-    questions(testId: $testId) {
+    questions(testId: $testId, first: 500) {
+      nodes {
+        id
+        text
+        text2
+        text3
+        imgPath
+        taskType
+        level
+        status
+        category
+        subcategory
+        theme
+        subtheme
+        target
+        source
+        options {
+          id
+          text
+          imgPath
+          isCorrect
+        }
+      }
+    }
+  }
+`;
+
+export const GET_ATTEMPT_QUESTIONS = `
+  query GetAttemptQuestions($completedTestId: UUID!, $testId: UUID) {
+    attemptQuestions(completedTestId: $completedTestId, testId: $testId) {
       id
       text
       text2
@@ -85,7 +120,6 @@ export const GET_QUESTIONS = `
       imgPath
       taskType
       level
-      status
       category
       subcategory
       theme
@@ -96,7 +130,6 @@ export const GET_QUESTIONS = `
         id
         text
         imgPath
-        isCorrect
       }
     }
   }
@@ -104,18 +137,20 @@ export const GET_QUESTIONS = `
 
 export const GET_COMPLETED_TESTS = `
   query GetCompletedTests($userId: UUID!) {
-    completedTests(userId: $userId) {
-      id
-      completedDate
-      startTestTime
-      timeSpent
-      product {
+    completedTests(userId: $userId, first: 500) {
+      nodes {
         id
-        title
-      }
-      tests {
-        id
-        title
+        completedDate
+        startTestTime
+        timeSpent
+        product {
+          id
+          title
+        }
+        tests {
+          id
+          title
+        }
       }
     }
   }
@@ -34,7 +34,7 @@ const AdminPage = () => {
           });
           if (!response.ok) throw new Error('Failed to fetch users');
           const data = await response.json();
-          setUsers(data);
+          setUsers(data.nodes);
         } else if (activeTab() === 'products') {
           const response = await fetch('/api/admin/products', {
             headers: {
@@ -43,7 +43,7 @@ const AdminPage = () => {
           });
           if (!response.ok) throw new Error('Failed to fetch products');
           const data = await response.json();
-          setProducts(data);
+          setProducts(data.nodes);
         } else if (activeTab() === 'tests') {
           const response = await fetch('/api/admin/tests', {
             headers: {
@@ -52,7 +52,7 @@ const AdminPage = () => {
           });
           if (!response.ok) throw new Error('Failed to fetch tests');
           const data = await response.json();
-          setTests(data);
+          setTests(data.nodes);
         }
         setError(null);
       } catch (err) {
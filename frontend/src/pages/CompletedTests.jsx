@@ -16,12 +16,12 @@ function CompletedTests() {
   });
   
   const filteredTests = () => {
-    if (!completedTests.data?.completedTests) return [];
+    if (!completedTests.data?.completedTests?.nodes) return [];
     
     const term = searchTerm().toLowerCase();
-    if (!term) return completedTests.data.completedTests;
+    if (!term) return completedTests.data.completedTests.nodes;
     
-    return completedTests.data.completedTests.filter(test => 
+    return completedTests.data.completedTests.nodes.filter(test => 
       test.product.title.toLowerCase().includes(term)
     );
   };
@@ -22,7 +22,7 @@ function Dashboard() {
 
   createEffect(() => {
     if (completedTests.data) {
-      const tests = completedTests.data.completedTests || [];
+      const tests = completedTests.data.completedTests?.nodes || [];
       
       // Calculate stats
       setStats({
@@ -115,7 +115,7 @@ function Dashboard() {
         
         <Show when={!completedTests.fetching} fallback={<LoadingSpinner />}>
           <Show 
-            when={completedTests.data?.completedTests?.length > 0} 
+            when={completedTests.data?.completedTests?.nodes?.length > 0} 
             fallback={
               <div class="px-4 py-5 text-center text-gray-500">
                 <p>You haven't completed any tests yet.</p>
@@ -129,7 +129,7 @@ function Dashboard() {
             }
           >
             <ul class="divide-y divide-gray-200">
-              {completedTests.data?.completedTests?.slice(0, 5).map((test) => (
+              {completedTests.data?.completedTests?.nodes?.slice(0, 5).map((test) => (
                 <li>
                   <a href={`/results/${test.id}`} class="block hover:bg-gray-50">
                     <div class="px-4 py-4 sm:px-6">
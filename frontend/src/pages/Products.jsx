@@ -13,12 +13,12 @@ function Products() {
   });
   
   const filteredProducts = () => {
-    if (!products.data?.products) return [];
+    if (!products.data?.products?.nodes) return [];
     
     const term = searchTerm().toLowerCase();
-    if (!term) return products.data.products;
+    if (!term) return products.data.products.nodes;
     
-    return products.data.products.filter(product => 
+    return products.data.products.nodes.filter(product => 
       product.title.toLowerCase().includes(term) || 
       product.description.toLowerCase().includes(term)
     );
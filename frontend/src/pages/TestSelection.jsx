@@ -136,7 +136,7 @@ function TestSelection() {
             </div>
           </div>
           <ul class="divide-y divide-gray-200">
-            {testsQuery.data?.tests?.map(test => (
+            {testsQuery.data?.tests?.nodes?.map(test => (
               <li class="px-4 py-4 sm:px-6">
                 <div class="flex items-center justify-between">
                   <div class="flex items-center">
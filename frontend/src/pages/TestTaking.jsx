@@ -2,7 +2,7 @@ import { createSignal, createEffect, onCleanup, Show } from 'solid-js';
 import { useParams, useNavigate } from '@solidjs/router';
 import { createQuery } from '@urql/solid';
 import { useAuth, useTest } from '../App';
-import { GET_QUESTIONS, GET_TEST } from '../api/queries';
+import { GET_ATTEMPT_QUESTIONS, GET_TEST } from '../api/queries';
 import LoadingSpinner from '../components/LoadingSpinner';
 
 function TestTaking() {
@@ -31,8 +31,8 @@ function TestTaking() {
   
   // Query for questions in the current test
   const [questionsQuery] = createQuery({
-    query: GET_QUESTIONS,
-    variables: { testId: currentTestId() },
+    query: GET_ATTEMPT_QUESTIONS,
+    variables: { completedTestId: test.activeTest.id, testId: currentTestId() },
     pause: !currentTestId()
   });
   
@@ -58,8 +58,8 @@ function TestTaking() {
   
   // Update current question when questions are loaded or navigation happens
   createEffect(() => {
-    if (questionsQuery.data?.questions) {
-      const questions = questionsQuery.data.questions;
+    if (questionsQuery.data?.attemptQuestions) {
+      const questions = questionsQuery.data.attemptQuestions;
       if (questions.length > 0 && test.activeTest.currentQuestionIndex < questions.length) {
         setCurrentQuestion(questions[test.activeTest.currentQuestionIndex]);
         
@@ -146,9 +146,9 @@ function TestTaking() {
   };
   
   const isLastQuestion = () => {
-    if (!questionsQuery.data?.questions) return false;
+    if (!questionsQuery.data?.attemptQuestions) return false;
     
-    const isLastQuestionInTest = test.activeTest.currentQuestionIndex === questionsQuery.data.questions.length - 1;
+    const isLastQuestionInTest = test.activeTest.currentQuestionIndex === questionsQuery.data.attemptQuestions.length - 1;
     const isLastTest = test.activeTest.currentTestIndex === test.activeTest.testIds.length - 1;
     
     return isLastQuestionInTest && isLastTest;
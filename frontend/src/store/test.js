@@ -2,7 +2,7 @@ import { createSignal } from 'solid-js';
 import { createStore } from 'solid-js/store';
 import { createMutation, createQuery } from '@urql/solid';
 import { START_TEST, ANSWER_QUESTION, COMPLETE_TEST } from '../api/mutations';
-import { GET_QUESTIONS } from '../api/queries';
+import { GET_ATTEMPT_QUESTIONS } from '../api/queries';
 import { useNavigate } from '@solidjs/router';
 
 export const createTestStore = () => {
@@ -105,11 +105,11 @@ export const createTestStore = () => {
   const nextQuestion = () => {
     const currentTest = activeTest.testIds[activeTest.currentTestIndex];
     const [questionsResult] = createQuery({
-      query: GET_QUESTIONS,
-      variables: { testId: currentTest }
+      query: GET_ATTEMPT_QUESTIONS,
+      variables: { completedTestId: activeTest.id, testId: currentTest }
     });
     
-    const questions = questionsResult.data?.questions || [];
+    const questions = questionsResult.data?.attemptQuestions || [];
     
     if (activeTest.currentQuestionIndex < questions.length - 1) {
       // Move to the next question in the current test
@@ -141,11 +141,11 @@ export const createTestStore = () => {
       // Move to the last question of the previous test
       const previousTestId = activeTest.testIds[activeTest.currentTestIndex - 1];
       const [questionsResult] = createQuery({
-        query: GET_QUESTIONS,
-        variables: { testId: previousTestId }
+        query: GET_ATTEMPT_QUESTIONS,
+        variables: { completedTestId: activeTest.id, testId: previousTestId }
       });
       
-      const questions = questionsResult.data?.questions || [];
+      const questions = questionsResult.data?.attemptQuestions || [];
       
       setActiveTest({
         ...activeTest,
@@ -0,0 +1,193 @@
+// Package aiken reads and writes questions in the Moodle Aiken format: a
+// question line, options lettered "A." or "A)" and an "ANSWER: X" line
+// naming the single correct option.
+package aiken
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Entry is a question read from an Aiken file
+type Entry struct {
+	// Line is the line the question starts on
+	Line     int
+	Question *models.Question
+}
+
+// Error is a question of an Aiken file that could not be read
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+var (
+	optionLine = regexp.MustCompile(`^([A-Z])[.)]\s+(.*)$`)
+	answerLine = regexp.MustCompile(`^ANSWER:\s*(\S*)\s*$`)
+)
+
+// Parse reads the questions of an Aiken file. Questions that cannot be
+// read are reported as errors and left out; reading resumes after the next
+// ANSWER line or blank line.
+func Parse(r io.Reader) ([]Entry, []Error, error) {
+	var entries []Entry
+	var errs []Error
+
+	var (
+		start    int
+		text     []string
+		options  []models.Option
+		letters  map[string]int
+		skipping bool
+	)
+	reset := func() {
+		start, text, options, letters = 0, nil, nil, map[string]int{}
+	}
+	fail := func(line int, message string) {
+		if start > 0 {
+			line = start
+		}
+		errs = append(errs, Error{Line: line, Message: message})
+		reset()
+		skipping = true
+	}
+	reset()
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if skipping {
+			if line == "" || answerLine.MatchString(line) {
+				skipping = false
+			}
+			continue
+		}
+
+		switch m := answerLine.FindStringSubmatch(line); {
+		case line == "":
+			if len(options) > 0 {
+				fail(n, "question has no ANSWER line")
+				skipping = false
+			}
+		case m != nil:
+			if len(options) < 2 {
+				fail(n, "ANSWER line before at least two options")
+				skipping = false
+				continue
+			}
+			i, ok := letters[strings.ToUpper(m[1])]
+			if !ok {
+				fail(n, fmt.Sprintf("answer %q is not one of the options", m[1]))
+				skipping = false
+				continue
+			}
+			options[i].IsCorrect = true
+			questionText := strings.Join(text, "\n")
+			entries = append(entries, Entry{Line: start, Question: &models.Question{Text: &questionText, Options: options}})
+			reset()
+		case len(text) > 0 && optionLine.MatchString(line):
+			om := optionLine.FindStringSubmatch(line)
+			if _, dup := letters[om[1]]; dup {
+				fail(n, fmt.Sprintf("option %s appears twice", om[1]))
+				continue
+			}
+			letters[om[1]] = len(options)
+			options = append(options, models.Option{Text: strings.TrimSpace(om[2])})
+		case len(options) > 0:
+			fail(n, fmt.Sprintf("unexpected line %d after the options", n))
+		default:
+			if start == 0 {
+				start = n
+			}
+			text = append(text, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	if start > 0 {
+		fail(start, "question has no ANSWER line")
+	}
+	return entries, errs, nil
+}
+
+// Write writes questions in the Aiken format and returns the questions it
+// left out: those without exactly one correct option, with more than 26
+// options, without text or with an option without text, and those whose
+// text reads as an ANSWER line. Line breaks in the text are written as
+// spaces.
+func Write(w io.Writer, questions []*models.Question) ([]*models.Question, error) {
+	bw := bufio.NewWriter(w)
+	var skipped []*models.Question
+	written := 0
+	for _, q := range questions {
+		text := questionText(q)
+		correct := -1
+		for i, o := range q.Options {
+			if o.IsCorrect {
+				if correct >= 0 {
+					correct = -2
+					break
+				}
+				correct = i
+			}
+		}
+		if text == "" || answerLine.MatchString(text) || correct < 0 || len(q.Options) < 2 || len(q.Options) > 26 || hasEmptyOption(q) {
+			skipped = append(skipped, q)
+			continue
+		}
+
+		if written > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintln(bw, text)
+		for i, o := range q.Options {
+			fmt.Fprintf(bw, "%c. %s\n", 'A'+i, singleLine(o.Text))
+		}
+		fmt.Fprintf(bw, "ANSWER: %c\n", 'A'+correct)
+		written++
+	}
+	return skipped, bw.Flush()
+}
+
+// hasEmptyOption reports whether an option of a question has no text, which
+// Aiken cannot tell apart from a line of question text
+func hasEmptyOption(q *models.Question) bool {
+	for _, o := range q.Options {
+		if singleLine(o.Text) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// questionText returns the text fields of a question on one line
+func questionText(q *models.Question) string {
+	var parts []string
+	for _, t := range []*string{q.Text, q.Text2, q.Text3} {
+		if t != nil {
+			if s := singleLine(*t); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// singleLine collapses the whitespace and line breaks of text
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
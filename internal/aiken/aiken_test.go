@@ -0,0 +1,68 @@
+package aiken
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+func str(s string) *string { return &s }
+
+func TestWriteRoundTrip(t *testing.T) {
+	questions := []*models.Question{
+		{
+			Text:    str("What is 2 + 2?"),
+			Options: []models.Option{{Text: "3"}, {Text: "4", IsCorrect: true}, {Text: "5"}},
+		},
+		{
+			Text:    str("A. looks like an option"),
+			Options: []models.Option{{Text: "yes", IsCorrect: true}, {Text: "no"}},
+		},
+		{
+			Text:    str("Line breaks\nare   collapsed"),
+			Options: []models.Option{{Text: "first\nline"}, {Text: "second", IsCorrect: true}},
+		},
+	}
+	unwritable := []*models.Question{
+		{Text: str("Empty option"), Options: []models.Option{{Text: "", IsCorrect: true}, {Text: "b"}}},
+		{Text: str("ANSWER: B"), Options: []models.Option{{Text: "a", IsCorrect: true}, {Text: "b"}}},
+		{Text: str("Two correct"), Options: []models.Option{{Text: "a", IsCorrect: true}, {Text: "b", IsCorrect: true}}},
+		{ImgPath: str("diagram.png"), Options: []models.Option{{Text: "a", IsCorrect: true}, {Text: "b"}}},
+	}
+
+	var buf bytes.Buffer
+	skipped, err := Write(&buf, append(append([]*models.Question{}, questions...), unwritable...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != len(unwritable) {
+		t.Errorf("skipped %d questions, want %d", len(skipped), len(unwritable))
+	}
+
+	entries, errs, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("reading back the export failed: %v\n%s", errs, buf.String())
+	}
+	if len(entries) != len(questions) {
+		t.Fatalf("read back %d questions, want %d\n%s", len(entries), len(questions), buf.String())
+	}
+	for i, e := range entries {
+		want := questions[i]
+		if got, wantText := *e.Question.Text, singleLine(*want.Text); got != wantText {
+			t.Errorf("question %d text = %q, want %q", i, got, wantText)
+		}
+		if len(e.Question.Options) != len(want.Options) {
+			t.Errorf("question %d has %d options, want %d", i, len(e.Question.Options), len(want.Options))
+			continue
+		}
+		for j, o := range e.Question.Options {
+			if o.Text != singleLine(want.Options[j].Text) || o.IsCorrect != want.Options[j].IsCorrect {
+				t.Errorf("question %d option %d = %+v, want %+v", i, j, o, want.Options[j])
+			}
+		}
+	}
+}
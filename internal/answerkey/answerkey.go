@@ -0,0 +1,90 @@
+// Package answerkey holds the rules a question must follow for its answers to
+// be graded: a known question type, a numeric key with a tolerance that is
+// not negative, option positions that order or number the blanks, and rubric
+// criteria that can be scored. Essays are scored on their rubric and only
+// asked in teacher products.
+package answerkey
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Answer key errors
+var (
+	ErrInvalidQuestionType = errors.New("question type must be CHOICE, MATCHING, ORDERING, NUMERIC, SHORT_TEXT, FILL_BLANK or ESSAY")
+	ErrNegativeTolerance   = errors.New("tolerance cannot be negative")
+	ErrEssayProduct        = errors.New("essay questions are only asked in teacher products")
+	ErrInvalidRubric       = errors.New("rubric criteria need a title and positive maximum points")
+	ErrEssayRubric         = errors.New("essay questions need a rubric")
+	ErrOrderingPositions   = errors.New("the options of ordering questions need distinct positive positions")
+	ErrBlankPositions      = errors.New("the correct options of fill blank questions need positions numbering the blanks from 1 without gaps")
+)
+
+// Check checks the type of a question, its numeric key, its options and its
+// rubric. Essays need a rubric.
+func Check(question *models.Question) error {
+	if !question.QuestionType.Valid() {
+		return ErrInvalidQuestionType
+	}
+	if question.Tolerance != nil && *question.Tolerance < 0 {
+		return ErrNegativeTolerance
+	}
+	for _, c := range question.Rubric {
+		if strings.TrimSpace(c.Title) == "" || c.MaxPoints <= 0 {
+			return ErrInvalidRubric
+		}
+	}
+	if question.QuestionType == models.QuestionEssay && len(question.Rubric) == 0 {
+		return ErrEssayRubric
+	}
+	return CheckOptions(question)
+}
+
+// CheckOptions checks the positions of the options of a question: the
+// options of ordering questions are ordered by distinct positions, and the
+// correct options of fill blank questions number the blanks 1 to n, several
+// options accepted for one blank sharing its position
+func CheckOptions(question *models.Question) error {
+	switch question.QuestionType {
+	case models.QuestionOrdering:
+		seen := make(map[int]bool, len(question.Options))
+		for _, option := range question.Options {
+			if option.Position == nil || *option.Position < 1 || seen[*option.Position] {
+				return ErrOrderingPositions
+			}
+			seen[*option.Position] = true
+		}
+
+	case models.QuestionFillBlank:
+		blanks := make(map[int]bool, len(question.Options))
+		last := 0
+		for _, option := range question.Options {
+			if !option.IsCorrect {
+				continue
+			}
+			if option.Position == nil || *option.Position < 1 {
+				return ErrBlankPositions
+			}
+			blanks[*option.Position] = true
+			if *option.Position > last {
+				last = *option.Position
+			}
+		}
+		if len(blanks) != last {
+			return ErrBlankPositions
+		}
+	}
+	return nil
+}
+
+// CheckProduct checks that a question may be asked in a product of the
+// given type
+func CheckProduct(question *models.Question, productType models.ProductType) error {
+	if question.QuestionType == models.QuestionEssay && productType != models.ProductTypeTeacher {
+		return ErrEssayProduct
+	}
+	return nil
+}
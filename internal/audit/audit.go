@@ -0,0 +1,92 @@
+// Package audit builds the snapshots and diffs stored in the audit log and
+// exports audit entries as CSV
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// Change is the before and after value of a single changed field
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Snapshot encodes v as JSON for the audit log. A nil v gives a nil snapshot.
+func Snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// NewEntry builds the audit entry of a change to an entity, with the
+// snapshots of before and after and their diff
+func NewEntry(actorID *uuid.UUID, action models.AuditAction, entity models.AuditEntity, id uuid.UUID, before, after interface{}) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entity,
+		EntityID:   id,
+	}
+	var err error
+	if entry.Before, err = Snapshot(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = Snapshot(after); err != nil {
+		return nil, err
+	}
+	if entry.Diff, err = Diff(entry.Before, entry.After); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Diff compares two JSON object snapshots field by field and returns the
+// changed fields as a JSON object of Change values keyed by field name. A nil
+// snapshot is treated as an empty object, so creates and deletes list every
+// field.
+func Diff(before, after json.RawMessage) (json.RawMessage, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for key, bv := range b {
+		av, ok := a[key]
+		if !ok || !reflect.DeepEqual(bv, av) {
+			changes[key] = Change{Before: bv, After: av}
+		}
+	}
+	for key, av := range a {
+		if _, ok := b[key]; !ok {
+			changes[key] = Change{After: av}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+// fields decodes a JSON object snapshot into its top-level fields
+func fields(snapshot json.RawMessage) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	if len(snapshot) == 0 {
+		return m, nil
+	}
+	if err := json.Unmarshal(snapshot, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// csvHeader is the header row of the CSV export
+var csvHeader = []string{"id", "created_at", "actor_id", "action", "entity_type", "entity_id", "before", "after", "diff"}
+
+// WriteCSV writes audit entries as CSV with a header row. The entries are
+// streamed: each calls write with them one at a time.
+func WriteCSV(w io.Writer, each func(write func(e *models.AuditEntry) error) error) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	err := each(func(e *models.AuditEntry) error {
+		var actorID string
+		if e.ActorID != nil {
+			actorID = e.ActorID.String()
+		}
+		record := []string{
+			e.ID.String(),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			actorID,
+			string(e.Action),
+			string(e.EntityType),
+			e.EntityID.String(),
+			string(e.Before),
+			string(e.After),
+			string(e.Diff),
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,105 @@
+// Package bundle moves a whole product with its tests, questions, options,
+// source passages and images between environments as a versioned JSON
+// document or a zip holding the document and the images
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/events"
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
+	"go.uber.org/zap"
+)
+
+// FormatVersion is the version of the bundle document written by Export.
+// Import reads bundles up to this version.
+const FormatVersion = 1
+
+// documentFile is the name of the bundle document in a zip bundle and
+// imageDir the folder holding the images, stored under their image paths
+const (
+	documentFile = "bundle.json"
+	imageDir     = "images/"
+)
+
+// ErrUnsupportedVersion is returned for bundles written by a newer version
+var ErrUnsupportedVersion = fmt.Errorf("unsupported bundle format version, expected at most %d", FormatVersion)
+
+// Bundle is a product with all its content
+type Bundle struct {
+	FormatVersion int              `json:"format_version"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Product       models.Product   `json:"product"`
+	Tests         []*Test          `json:"tests"`
+	Sources       []*models.Source `json:"sources"`
+}
+
+// Test is a test of a bundle with its questions and their options, and the
+// passage groups of its questions
+type Test struct {
+	models.Test
+	Questions     []*models.Question     `json:"questions"`
+	PassageGroups []*models.PassageGroup `json:"passage_groups,omitempty"`
+}
+
+// Bundler exports and imports bundles
+type Bundler struct {
+	repos     *repository.Repositories
+	images    media.Store
+	publisher events.Publisher
+	logger    *zap.SugaredLogger
+}
+
+// New creates a bundler reading and storing images in the media store.
+// Imported questions are published as created through publisher.
+func New(repos *repository.Repositories, images media.Store, publisher events.Publisher, logger *zap.SugaredLogger) *Bundler {
+	return &Bundler{repos: repos, images: images, publisher: publisher, logger: logger}
+}
+
+// imageEntry returns the zip entry of an image path
+func imageEntry(imgPath string) string {
+	return imageDir + strings.TrimPrefix(path.Clean("/"+imgPath), "/")
+}
+
+// decode reads a JSON or zip bundle of the given size. The zip reader is
+// nil for JSON bundles.
+func decode(r io.ReaderAt, size int64) (*Bundle, *zip.Reader, error) {
+	var zr *zip.Reader
+	var document io.Reader = io.NewSectionReader(r, 0, size)
+	magic := make([]byte, 4)
+	if n, _ := r.ReadAt(magic, 0); bytes.Equal(magic[:n], []byte("PK\x03\x04")) {
+		var err error
+		zr, err = zip.NewReader(r, size)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		f, err := zr.Open(documentFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		defer f.Close()
+		document = f
+	}
+
+	var b Bundle
+	if err := json.NewDecoder(document).Decode(&b); err != nil {
+		return nil, nil, fmt.Errorf("read bundle: %w", err)
+	}
+	if b.FormatVersion < 1 || b.FormatVersion > FormatVersion {
+		return nil, nil, ErrUnsupportedVersion
+	}
+	if b.Product.Title == "" {
+		return nil, nil, errors.New("read bundle: bundle has no product")
+	}
+	return &b, zr, nil
+}
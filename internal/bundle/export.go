@@ -0,0 +1,127 @@
+package bundle
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// Export writes a product with all its content to w. Without images the
+// bundle is a JSON document; with images it is a zip holding the document
+// and every image found in the media store.
+func (b *Bundler) Export(ctx context.Context, productID uuid.UUID, withImages bool, w io.Writer) error {
+	bundle, err := b.load(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if !withImages {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(bundle)
+	}
+
+	zw := zip.NewWriter(w)
+	f, err := zw.Create(documentFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bundle); err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	for _, t := range bundle.Tests {
+		for _, q := range t.Questions {
+			paths := []*string{q.ImgPath}
+			for _, o := range q.Options {
+				paths = append(paths, o.ImgPath)
+			}
+			for _, p := range paths {
+				if p == nil || *p == "" || written[*p] {
+					continue
+				}
+				written[*p] = true
+				if err := b.writeImage(ctx, zw, *p); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// writeImage copies an image into a zip bundle. Images missing from the
+// media store, such as external URLs, are left out.
+func (b *Bundler) writeImage(ctx context.Context, zw *zip.Writer, imgPath string) error {
+	r, err := b.images.Open(ctx, imgPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("image %s: %w", imgPath, err)
+	}
+	defer r.Close()
+
+	f, err := zw.Create(imageEntry(imgPath))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("image %s: %w", imgPath, err)
+	}
+	return nil
+}
+
+// load reads a product with all its content
+func (b *Bundler) load(ctx context.Context, productID uuid.UUID) (*Bundle, error) {
+	product, err := b.repos.Products.Get(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("product %s: %w", productID, err)
+	}
+	tests, err := b.repos.Tests.ListByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Product:       *product,
+		Tests:         []*Test{},
+		Sources:       []*models.Source{},
+	}
+	sources := map[uuid.UUID]bool{}
+	for _, t := range tests {
+		questions, err := b.repos.Questions.ListByTest(ctx, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range questions {
+			if q.SourceTextID == nil || sources[*q.SourceTextID] {
+				continue
+			}
+			source, err := b.repos.Questions.GetSource(ctx, *q.SourceTextID)
+			if err != nil {
+				return nil, fmt.Errorf("source of question %s: %w", q.ID, err)
+			}
+			sources[source.ID] = true
+			bundle.Sources = append(bundle.Sources, source)
+		}
+		groups, err := b.repos.Passages.ListByTest(ctx, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Tests = append(bundle.Tests, &Test{Test: *t, Questions: questions, PassageGroups: groups})
+	}
+	return bundle, nil
+}
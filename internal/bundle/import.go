@@ -0,0 +1,462 @@
+package bundle
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/answerkey"
+	"github.com/Alan69/ayatest/internal/audit"
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
+	"github.com/Alan69/ayatest/internal/richtext"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IDMode decides the IDs content is imported under
+type IDMode string
+
+const (
+	// IDsPreserve keeps the IDs of the bundle
+	IDsPreserve IDMode = "preserve"
+	// IDsRemap derives new IDs from the IDs of the bundle. The same bundle
+	// ID always maps to the same new ID, so importing a bundle again finds
+	// the content of the earlier import.
+	IDsRemap IDMode = "remap"
+)
+
+// Conflict decides what happens to content whose ID already exists
+type Conflict string
+
+const (
+	// ConflictSkip keeps the existing content
+	ConflictSkip Conflict = "skip"
+	// ConflictOverwrite replaces the existing content, restoring it if it
+	// was deleted
+	ConflictOverwrite Conflict = "overwrite"
+	// ConflictDuplicate imports a copy under a new random ID
+	ConflictDuplicate Conflict = "duplicate"
+)
+
+// ErrInvalidOptions is returned for unknown ID modes and conflict strategies
+var ErrInvalidOptions = errors.New("ids must be preserve or remap and on_conflict skip, overwrite or duplicate")
+
+// remapNamespace is the UUID namespace remapped IDs are derived in
+var remapNamespace = uuid.MustParse("6f1d0a52-8a57-4c3e-9b0e-5a3c2f7e4d11")
+
+// ImportOptions control how a bundle is imported
+type ImportOptions struct {
+	IDs        IDMode
+	OnConflict Conflict
+	// DryRun reports what an import would do without writing anything
+	DryRun   bool
+	AuthorID *uuid.UUID
+	// MaxImageSize limits the size of each image of a zip bundle in bytes
+	MaxImageSize int64
+}
+
+// Validate checks the ID mode and conflict strategy, defaulting to
+// preserving IDs, skipping existing content and the default image size
+// limit of uploads
+func (o *ImportOptions) Validate() error {
+	if o.IDs == "" {
+		o.IDs = IDsPreserve
+	}
+	if o.OnConflict == "" {
+		o.OnConflict = ConflictSkip
+	}
+	if o.MaxImageSize <= 0 {
+		o.MaxImageSize = media.DefaultMaxUploadSize
+	}
+	if o.IDs != IDsPreserve && o.IDs != IDsRemap {
+		return ErrInvalidOptions
+	}
+	switch o.OnConflict {
+	case ConflictSkip, ConflictOverwrite, ConflictDuplicate:
+		return nil
+	default:
+		return ErrInvalidOptions
+	}
+}
+
+// Counts counts what happened to the content of one kind
+type Counts struct {
+	Created     int `json:"created"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+	Duplicated  int `json:"duplicated"`
+}
+
+// add counts an action
+func (c *Counts) add(a action) {
+	switch a {
+	case actionCreate:
+		c.Created++
+	case actionOverwrite:
+		c.Overwritten++
+	case actionSkip:
+		c.Skipped++
+	case actionDuplicate:
+		c.Duplicated++
+	}
+}
+
+// ImportReport is the outcome of importing a bundle. Options are counted
+// with their questions.
+type ImportReport struct {
+	ProductID uuid.UUID `json:"product_id"`
+	DryRun    bool      `json:"dry_run"`
+	Products  Counts    `json:"products"`
+	Tests     Counts    `json:"tests"`
+	Questions Counts    `json:"questions"`
+	Sources   Counts    `json:"sources"`
+	Images    int       `json:"images"`
+
+	PassageGroups Counts `json:"passage_groups"`
+}
+
+// action is what an import does with an entity
+type action int
+
+const (
+	actionCreate action = iota
+	actionOverwrite
+	actionSkip
+	actionDuplicate
+)
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// Import reads a JSON or zip bundle of the given size and writes its
+// product with all its content in one transaction. Every entity is looked
+// up under its target ID, soft deleted content included, and the conflict
+// strategy decides what happens to the ones that exist. Images of a zip
+// bundle are checked and stored as uploads are, with their thumbnails, but
+// named after their content: they never replace another stored image, and
+// importing a bundle again finds the images of the earlier import.
+// Questions are checked, audited and, once the transaction commits,
+// published as created.
+func (b *Bundler) Import(ctx context.Context, r io.ReaderAt, size int64, opts ImportOptions) (*ImportReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	bundle, zr, err := decode(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun}
+	im := &importer{opts: opts, imgPaths: map[string]string{}}
+	if zr != nil {
+		for _, f := range zr.File {
+			if !strings.HasPrefix(f.Name, imageDir) || strings.HasSuffix(f.Name, "/") {
+				continue
+			}
+			report.Images++
+			if err := b.storeImage(ctx, im, f); err != nil {
+				return nil, fmt.Errorf("image %s: %w", f.Name, err)
+			}
+		}
+	}
+
+	err = b.repos.Transaction(ctx, func(tx *repository.Repositories) error {
+		im.tx, im.created = tx, nil
+		if err := im.run(ctx, bundle, report); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	if !opts.DryRun {
+		for _, question := range im.created {
+			if err := b.publisher.PublishQuestionCreated(question); err != nil {
+				// Log the error but don't fail the import
+				b.logger.Errorw("Failed to publish question created event", "questionID", question.ID, "error", err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// storeImage checks an image of a zip bundle and, unless the import is a
+// dry run, uploads it to the media store
+func (b *Bundler) storeImage(ctx context.Context, im *importer, f *zip.File) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if im.opts.DryRun {
+		return media.Validate(r, im.opts.MaxImageSize)
+	}
+	image, err := media.UploadByContent(ctx, b.images, r, im.opts.MaxImageSize)
+	if err != nil {
+		return err
+	}
+	im.imgPaths[f.Name] = image.Path
+	return nil
+}
+
+// importer writes the content of a bundle within a transaction
+type importer struct {
+	tx   *repository.Repositories
+	opts ImportOptions
+	// imgPaths maps zip entries to the image paths they were stored under
+	imgPaths map[string]string
+	// created holds the questions created or duplicated by the import
+	created []*models.Question
+}
+
+// run imports the sources, the product, its tests and their questions
+func (im *importer) run(ctx context.Context, bundle *Bundle, report *ImportReport) error {
+	sourceIDs := map[uuid.UUID]uuid.UUID{}
+	for _, s := range bundle.Sources {
+		source := *s
+		a, err := im.resolve(ctx, &models.Source{}, &source.ID)
+		if err != nil {
+			return err
+		}
+		sourceIDs[s.ID] = source.ID
+		report.Sources.add(a)
+		if err := im.write(ctx, a, &source); err != nil {
+			return err
+		}
+	}
+
+	product := bundle.Product
+	product.DeletedAt = gorm.DeletedAt{}
+	a, err := im.resolve(ctx, &models.Product{}, &product.ID)
+	if err != nil {
+		return err
+	}
+	// Owners of the exporting platform may not exist here, so products
+	// belong to the importing user; overwritten ones keep their owner
+	product.OwnerID = im.opts.AuthorID
+	if a == actionOverwrite {
+		existing, err := im.tx.Products.Get(ctx, product.ID)
+		switch {
+		case err == nil:
+			product.OwnerID = existing.OwnerID
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+	}
+	report.ProductID = product.ID
+	report.Products.add(a)
+	if err := im.write(ctx, a, &product); err != nil {
+		return err
+	}
+	// Essays are only asked in teacher products, so the type of the
+	// product the questions end up in decides whether they may be essays
+	productType := product.ProductType
+	if a == actionSkip {
+		existing, err := im.tx.Products.Get(ctx, product.ID)
+		if err != nil {
+			return fmt.Errorf("product %s: %w", product.ID, err)
+		}
+		productType = existing.ProductType
+	}
+
+	for _, t := range bundle.Tests {
+		test := t.Test
+		test.ProductID = product.ID
+		test.DeletedAt = gorm.DeletedAt{}
+		a, err := im.resolve(ctx, &models.Test{}, &test.ID)
+		if err != nil {
+			return err
+		}
+		report.Tests.add(a)
+		if err := im.write(ctx, a, &test); err != nil {
+			return err
+		}
+
+		groupIDs := map[uuid.UUID]uuid.UUID{}
+		for _, g := range t.PassageGroups {
+			group := *g
+			group.TestID = test.ID
+			id, ok := sourceIDs[g.SourceID]
+			if !ok {
+				return fmt.Errorf("passage group %s refers to source %s missing from the bundle", g.ID, g.SourceID)
+			}
+			group.SourceID = id
+			a, err := im.resolve(ctx, &models.PassageGroup{}, &group.ID)
+			if err != nil {
+				return err
+			}
+			groupIDs[g.ID] = group.ID
+			report.PassageGroups.add(a)
+			if err := im.write(ctx, a, &group); err != nil {
+				return err
+			}
+		}
+
+		for _, q := range t.Questions {
+			question := *q
+			question.TestID = test.ID
+			question.Revision = 0
+			question.DeletedAt = gorm.DeletedAt{}
+			// Authors of the exporting platform may not exist here
+			question.AuthorID = im.opts.AuthorID
+			if question.SourceTextID != nil {
+				id, ok := sourceIDs[*question.SourceTextID]
+				if !ok {
+					return fmt.Errorf("question %s refers to source %s missing from the bundle", q.ID, *q.SourceTextID)
+				}
+				question.SourceTextID = &id
+			}
+			// Bundles without passage groups leave the questions ungrouped
+			if question.PassageGroupID != nil {
+				if id, ok := groupIDs[*question.PassageGroupID]; ok {
+					question.PassageGroupID = &id
+				} else {
+					question.PassageGroupID, question.PassagePosition = nil, nil
+				}
+			}
+			a, err := im.resolve(ctx, &models.Question{}, &question.ID)
+			if err != nil {
+				return err
+			}
+			report.Questions.add(a)
+			if a == actionSkip {
+				continue
+			}
+
+			question.ImgPath = im.imgPath(question.ImgPath)
+			question.Options = make([]models.Option, len(q.Options))
+			for i, o := range q.Options {
+				o.ImgPath = im.imgPath(o.ImgPath)
+				o.DeletedAt = gorm.DeletedAt{}
+				if a == actionDuplicate {
+					o.ID = uuid.New()
+				} else {
+					o.ID = im.mapID(o.ID)
+				}
+				question.Options[i] = o
+			}
+			// Bundles may come from anywhere, so their content is checked as
+			// if it was written here
+			if err := answerkey.Check(&question); err != nil {
+				return fmt.Errorf("question %s: %w", q.ID, err)
+			}
+			if err := answerkey.CheckProduct(&question, productType); err != nil {
+				return fmt.Errorf("question %s: %w", q.ID, err)
+			}
+			if err := richtext.SanitizeQuestion(&question); err != nil {
+				return fmt.Errorf("question %s: %w", q.ID, err)
+			}
+			// The review status of the exporting platform does not carry
+			// over: new questions are drafts, and overwritten ones keep
+			// their status here, approved ones going back to review
+			var before *models.Question
+			question.Status = models.QuestionDraft
+			if a == actionOverwrite {
+				if before, err = im.current(ctx, question.ID); err != nil {
+					return err
+				}
+				if before != nil {
+					question.Status = before.Status
+				}
+			}
+			if err := im.tx.Bundles.UpsertQuestion(ctx, &question); err != nil {
+				return err
+			}
+			if _, err := im.tx.Reviews.Revise(ctx, &question, im.opts.AuthorID); err != nil {
+				return err
+			}
+			if _, err := im.tx.Questions.SaveRevision(ctx, question.ID, im.opts.AuthorID); err != nil {
+				return err
+			}
+			if err := im.recordAudit(ctx, a, before, &question); err != nil {
+				return err
+			}
+			if a != actionOverwrite {
+				im.created = append(im.created, &question)
+			}
+		}
+	}
+	return nil
+}
+
+// current returns the question an overwrite replaces with its options, nil
+// when it was deleted
+func (im *importer) current(ctx context.Context, id uuid.UUID) (*models.Question, error) {
+	question, err := im.tx.Questions.Get(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return question, err
+}
+
+// recordAudit writes the audit entry of a question the import created or
+// overwrote
+func (im *importer) recordAudit(ctx context.Context, a action, before, after *models.Question) error {
+	auditAction := models.AuditCreate
+	if a == actionOverwrite {
+		auditAction = models.AuditUpdate
+	}
+	entry, err := audit.NewEntry(im.opts.AuthorID, auditAction, models.AuditEntityQuestion, after.ID, before, after)
+	if err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	return im.tx.Audit.Create(ctx, entry)
+}
+
+// resolve maps the ID of an entity to its target ID and decides what to do
+// with it. Duplicated entities get a new random ID.
+func (im *importer) resolve(ctx context.Context, model interface{}, id *uuid.UUID) (action, error) {
+	*id = im.mapID(*id)
+	existing, err := im.tx.Bundles.Existing(ctx, model, []uuid.UUID{*id})
+	if err != nil {
+		return 0, err
+	}
+	if !existing[*id] {
+		return actionCreate, nil
+	}
+	switch im.opts.OnConflict {
+	case ConflictOverwrite:
+		return actionOverwrite, nil
+	case ConflictDuplicate:
+		*id = uuid.New()
+		return actionDuplicate, nil
+	default:
+		return actionSkip, nil
+	}
+}
+
+// write creates or overwrites an entity unless it is skipped
+func (im *importer) write(ctx context.Context, a action, value interface{}) error {
+	if a == actionSkip {
+		return nil
+	}
+	return im.tx.Bundles.Upsert(ctx, value)
+}
+
+// mapID returns the target ID of a bundle ID
+func (im *importer) mapID(id uuid.UUID) uuid.UUID {
+	if im.opts.IDs == IDsRemap {
+		return uuid.NewSHA1(remapNamespace, id[:])
+	}
+	return id
+}
+
+// imgPath returns the image path an image of the bundle was stored under
+func (im *importer) imgPath(imgPath *string) *string {
+	if imgPath == nil {
+		return nil
+	}
+	if stored, ok := im.imgPaths[imageEntry(*imgPath)]; ok {
+		return &stored
+	}
+	return imgPath
+}
@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Alan69/ayatest/internal/bundle"
+	"github.com/Alan69/ayatest/internal/database"
+	"github.com/Alan69/ayatest/internal/events"
+	"github.com/Alan69/ayatest/internal/importer"
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/qti"
+	"github.com/Alan69/ayatest/internal/repository"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+const usage = `Usage: content <command> [flags] [args]
+
+Commands:
+  import-questions -test ID [-format F] [-batch N] [-dry-run] FILE
+                 import questions from a CSV or XLSX spreadsheet or a GIFT or
+                 Aiken file into a test; the format defaults to the extension
+  export-questions -test ID -format gift|aiken -o FILE
+                 export the questions of a test in the GIFT or Aiken format
+  legacy-sync -test ID [-format F] [-batch N] [-dry-run] FILE
+                 upsert questions exported from the legacy platform by
+                 detail_id and lng_id; new questions are created in the test
+  export-bundle -product ID [-images] -o FILE
+                 export a product with all its content as a JSON bundle, or a
+                 zip bundle with the images
+  import-bundle [-ids preserve|remap] [-on-conflict skip|overwrite|duplicate] [-dry-run] FILE
+                 import a JSON or zip bundle
+  export-qti -test ID [-version 2.1|3.0] -o FILE
+                 export a test as a QTI content package
+  import-qti -product ID FILE
+                 import a QTI 2.1 or 3.0 content package as a test of a product
+
+Images are read from and stored below MEDIA_DIR (default media).
+`
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Connect to the database
+	dbConfig, err := database.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	db, err := database.Connect(context.Background(), dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close(db)
+
+	ctx := context.Background()
+	repos := repository.New(db)
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "import-questions":
+		os.Exit(importQuestions(ctx, repos, args))
+	case "export-questions":
+		os.Exit(exportQuestions(ctx, repos, args))
+	case "legacy-sync":
+		os.Exit(legacySync(ctx, repos, args))
+	case "export-bundle":
+		os.Exit(exportBundle(ctx, repos, args))
+	case "import-bundle":
+		os.Exit(importBundle(ctx, repos, args))
+	case "export-qti":
+		os.Exit(exportQTI(ctx, repos, args))
+	case "import-qti":
+		os.Exit(importQTI(ctx, repos, args))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// newImporter creates an importer publishing the events of the questions it
+// creates to the publisher selected by EVENT_PUBLISHER. The returned function
+// closes the publisher.
+func newImporter(repos *repository.Repositories, batchSize int) (*importer.Importer, func(), error) {
+	publisher, sugar, closePublisher, err := newPublisher()
+	if err != nil {
+		return nil, nil, err
+	}
+	return importer.New(repos, publisher, sugar, batchSize), closePublisher, nil
+}
+
+// newPublisher creates the publisher configured by the environment with a
+// production logger
+func newPublisher() (events.Publisher, *zap.SugaredLogger, func(), error) {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sugar := logger.Sugar()
+	publisher, closePublisher, err := events.FromEnv(sugar)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return publisher, sugar, closePublisher, nil
+}
+
+// newBundler creates a bundler publishing the imported questions to the
+// publisher configured by the environment
+func newBundler(repos *repository.Repositories, images media.Store) (*bundle.Bundler, func(), error) {
+	publisher, sugar, closePublisher, err := newPublisher()
+	if err != nil {
+		return nil, nil, err
+	}
+	return bundle.New(repos, images, publisher, sugar), closePublisher, nil
+}
+
+// newPackager creates a QTI packager publishing the imported questions to
+// the publisher configured by the environment
+func newPackager(repos *repository.Repositories, images media.Store) (*qti.Packager, func(), error) {
+	publisher, sugar, closePublisher, err := newPublisher()
+	if err != nil {
+		return nil, nil, err
+	}
+	return qti.New(repos, images, publisher, sugar), closePublisher, nil
+}
+
+// importQuestions runs the import-questions command and returns the exit code
+func importQuestions(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("import-questions", flag.ExitOnError)
+	testFlag := fs.String("test", "", "ID of the test to import into")
+	formatFlag := fs.String("format", "", "csv, xlsx, gift or aiken instead of the file extension")
+	batch := fs.Int("batch", importer.DefaultBatchSize, "questions per transaction")
+	dryRun := fs.Bool("dry-run", false, "only validate the spreadsheet")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	testID, err := uuid.Parse(*testFlag)
+	if err != nil {
+		log.Printf("Invalid test ID %q", *testFlag)
+		return 2
+	}
+
+	path := fs.Arg(0)
+	var format importer.Format
+	if *formatFlag != "" {
+		format, err = importer.ParseFormat(*formatFlag)
+	} else {
+		format, err = importer.FormatFromFilename(path)
+	}
+	if err != nil {
+		log.Printf("%s: %v", path, err)
+		return 2
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open file: %v", err)
+		return 1
+	}
+	defer file.Close()
+
+	questions, err := importer.Load(file, format)
+	if err != nil {
+		log.Printf("Failed to read file: %v", err)
+		return 1
+	}
+
+	im, closeImporter, err := newImporter(repos, *batch)
+	if err != nil {
+		log.Printf("Failed to create importer: %v", err)
+		return 1
+	}
+	defer closeImporter()
+
+	report, err := im.ImportQuestions(ctx, testID, questions, *dryRun, nil)
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		return 1
+	}
+	printReport(report)
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printReport prints the row errors and a summary of an import
+func printReport(report *importer.Report) {
+	if len(report.Errors) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "LINE\tCOLUMN\tERROR")
+		for _, e := range report.Errors {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", e.Line, e.Column, e.Message)
+		}
+		w.Flush()
+	}
+	if report.DryRun {
+		log.Printf("Validated %d rows, %d errors", report.Rows, len(report.Errors))
+		return
+	}
+	log.Printf("Imported %d of %d rows, %d errors", report.Imported, report.Rows, len(report.Errors))
+}
+
+// exportQuestions runs the export-questions command and returns the exit code
+func exportQuestions(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("export-questions", flag.ExitOnError)
+	testFlag := fs.String("test", "", "ID of the test to export")
+	formatFlag := fs.String("format", "", "gift or aiken")
+	out := fs.String("o", "", "file to write")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	testID, err := uuid.Parse(*testFlag)
+	if err != nil {
+		log.Printf("Invalid test ID %q", *testFlag)
+		return 2
+	}
+	format, err := importer.ParseFormat(*formatFlag)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Printf("Failed to create file: %v", err)
+		return 1
+	}
+	skipped, err := importer.New(repos, events.NewNoopPublisher(), zap.NewNop().Sugar(), 0).ExportQuestions(ctx, testID, format, file)
+	if err != nil {
+		file.Close()
+		os.Remove(*out)
+		log.Printf("Export failed: %v", err)
+		return 1
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Failed to write file: %v", err)
+		return 1
+	}
+	for _, q := range skipped {
+		log.Printf("Skipped question %s, %s cannot represent it", q.ID, format)
+	}
+	log.Printf("Exported test %s as %s to %s", testID, format, *out)
+	return 0
+}
+
+// legacySync runs the legacy-sync command and returns the exit code
+func legacySync(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("legacy-sync", flag.ExitOnError)
+	testFlag := fs.String("test", "", "ID of the test new questions are created in")
+	formatFlag := fs.String("format", "", "csv or xlsx instead of the file extension")
+	batch := fs.Int("batch", importer.DefaultBatchSize, "questions per transaction")
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	testID, err := uuid.Parse(*testFlag)
+	if err != nil {
+		log.Printf("Invalid test ID %q", *testFlag)
+		return 2
+	}
+
+	path := fs.Arg(0)
+	var format importer.Format
+	if *formatFlag != "" {
+		format, err = importer.ParseFormat(*formatFlag)
+	} else {
+		format, err = importer.FormatFromFilename(path)
+	}
+	if err != nil {
+		log.Printf("%s: %v", path, err)
+		return 2
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open file: %v", err)
+		return 1
+	}
+	defer file.Close()
+
+	questions, err := importer.Load(file, format)
+	if err != nil {
+		log.Printf("Failed to read file: %v", err)
+		return 1
+	}
+	im, closeImporter, err := newImporter(repos, *batch)
+	if err != nil {
+		log.Printf("Failed to create importer: %v", err)
+		return 1
+	}
+	defer closeImporter()
+
+	report, err := im.SyncLegacy(ctx, testID, questions, *dryRun, nil)
+	if err != nil {
+		log.Printf("Sync failed: %v", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(report.Changes) > 0 {
+		fmt.Fprintln(w, "LINE\tDETAIL_ID\tLNG_ID\tACTION\tQUESTION\tCHANGED\tMESSAGE")
+		for _, c := range report.Changes {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\t%s\n",
+				c.Line, c.DetailID, c.LngID, c.Action, c.QuestionID, changedFields(c.Diff), c.Message)
+		}
+		w.Flush()
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(w, "LINE\tCOLUMN\tERROR")
+		for _, e := range report.Errors {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", e.Line, e.Column, e.Message)
+		}
+		w.Flush()
+	}
+
+	summary := "Synced"
+	if report.DryRun {
+		summary = "Dry run, would have synced"
+	}
+	log.Printf("%s %d rows: %d created, %d updated, %d unchanged, %d skipped, %d errors",
+		summary, report.Rows, report.Created, report.Updated, report.Unchanged, report.Skipped, len(report.Errors))
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// changedFields lists the fields of a diff, sorted
+func changedFields(diff json.RawMessage) string {
+	var fields map[string]json.RawMessage
+	if len(diff) == 0 || json.Unmarshal(diff, &fields) != nil {
+		return "-"
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// exportBundle runs the export-bundle command and returns the exit code
+func exportBundle(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("export-bundle", flag.ExitOnError)
+	productFlag := fs.String("product", "", "ID of the product to export")
+	withImages := fs.Bool("images", false, "write a zip bundle with the images")
+	out := fs.String("o", "", "bundle file to write")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	productID, err := uuid.Parse(*productFlag)
+	if err != nil {
+		log.Printf("Invalid product ID %q", *productFlag)
+		return 2
+	}
+
+	images, err := openMedia(ctx)
+	if err != nil {
+		log.Printf("Failed to open media store: %v", err)
+		return 1
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Printf("Failed to create bundle: %v", err)
+		return 1
+	}
+	if err := bundle.New(repos, images, events.NewNoopPublisher(), zap.NewNop().Sugar()).Export(ctx, productID, *withImages, file); err != nil {
+		file.Close()
+		os.Remove(*out)
+		log.Printf("Export failed: %v", err)
+		return 1
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Failed to write bundle: %v", err)
+		return 1
+	}
+	log.Printf("Exported product %s to %s", productID, *out)
+	return 0
+}
+
+// importBundle runs the import-bundle command and returns the exit code
+func importBundle(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("import-bundle", flag.ExitOnError)
+	ids := fs.String("ids", string(bundle.IDsPreserve), "preserve or remap the IDs of the bundle")
+	onConflict := fs.String("on-conflict", string(bundle.ConflictSkip), "skip, overwrite or duplicate existing content")
+	dryRun := fs.Bool("dry-run", false, "only report what would be imported")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	opts := bundle.ImportOptions{IDs: bundle.IDMode(*ids), OnConflict: bundle.Conflict(*onConflict), DryRun: *dryRun}
+	if err := opts.Validate(); err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	cfg, err := media.ConfigFromEnv()
+	if err != nil {
+		log.Printf("Failed to open media store: %v", err)
+		return 1
+	}
+	images, err := media.Open(ctx, cfg)
+	if err != nil {
+		log.Printf("Failed to open media store: %v", err)
+		return 1
+	}
+	opts.MaxImageSize = cfg.MaxUploadSize
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Printf("Failed to read bundle: %v", err)
+		return 1
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		log.Printf("Failed to read bundle: %v", err)
+		return 1
+	}
+
+	bundler, closePublisher, err := newBundler(repos, images)
+	if err != nil {
+		log.Printf("Failed to create bundler: %v", err)
+		return 1
+	}
+	defer closePublisher()
+	report, err := bundler.Import(ctx, file, info.Size(), opts)
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\tCREATED\tOVERWRITTEN\tSKIPPED\tDUPLICATED")
+	for _, row := range []struct {
+		name   string
+		counts bundle.Counts
+	}{
+		{"products", report.Products},
+		{"tests", report.Tests},
+		{"questions", report.Questions},
+		{"sources", report.Sources},
+	} {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", row.name, row.counts.Created, row.counts.Overwritten, row.counts.Skipped, row.counts.Duplicated)
+	}
+	w.Flush()
+	if report.DryRun {
+		log.Printf("Dry run of product %s, %d images, nothing written", report.ProductID, report.Images)
+		return 0
+	}
+	log.Printf("Imported product %s with %d images", report.ProductID, report.Images)
+	return 0
+}
+
+// exportQTI runs the export-qti command and returns the exit code
+func exportQTI(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("export-qti", flag.ExitOnError)
+	testFlag := fs.String("test", "", "ID of the test to export")
+	versionFlag := fs.String("version", string(qti.Version21), "QTI version, 2.1 or 3.0")
+	out := fs.String("o", "", "package file to write")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	testID, err := uuid.Parse(*testFlag)
+	if err != nil {
+		log.Printf("Invalid test ID %q", *testFlag)
+		return 2
+	}
+	version, err := qti.ParseVersion(*versionFlag)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	images, err := openMedia(ctx)
+	if err != nil {
+		log.Printf("Failed to open media store: %v", err)
+		return 1
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Printf("Failed to create package: %v", err)
+		return 1
+	}
+	if err := qti.New(repos, images, events.NewNoopPublisher(), zap.NewNop().Sugar()).Export(ctx, testID, version, file); err != nil {
+		file.Close()
+		os.Remove(*out)
+		log.Printf("Export failed: %v", err)
+		return 1
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Failed to write package: %v", err)
+		return 1
+	}
+	log.Printf("Exported test %s as QTI %s to %s", testID, version, *out)
+	return 0
+}
+
+// importQTI runs the import-qti command and returns the exit code
+func importQTI(ctx context.Context, repos *repository.Repositories, args []string) int {
+	fs := flag.NewFlagSet("import-qti", flag.ExitOnError)
+	productFlag := fs.String("product", "", "ID of the product to import into")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	productID, err := uuid.Parse(*productFlag)
+	if err != nil {
+		log.Printf("Invalid product ID %q", *productFlag)
+		return 2
+	}
+
+	images, err := openMedia(ctx)
+	if err != nil {
+		log.Printf("Failed to open media store: %v", err)
+		return 1
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Printf("Failed to open package: %v", err)
+		return 1
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		log.Printf("Failed to open package: %v", err)
+		return 1
+	}
+
+	packager, closePublisher, err := newPackager(repos, images)
+	if err != nil {
+		log.Printf("Failed to create packager: %v", err)
+		return 1
+	}
+	defer closePublisher()
+	report, err := packager.Import(ctx, productID, file, info.Size(), nil)
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		return 1
+	}
+	if len(report.Skipped) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ITEM\tSKIPPED")
+		for _, s := range report.Skipped {
+			fmt.Fprintf(w, "%s\t%s\n", s.Item, s.Reason)
+		}
+		w.Flush()
+	}
+	log.Printf("Imported QTI %s test %q as %s with %d questions, %d items skipped",
+		report.Version, report.Title, report.TestID, report.Questions, len(report.Skipped))
+	return 0
+}
+
+// openMedia opens the image store configured by the environment
+func openMedia(ctx context.Context) (media.Store, error) {
+	cfg, err := media.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return media.Open(ctx, cfg)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Alan69/ayatest/internal/database"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: migrate <command> [args]
+
+Commands:
+  up             apply all pending migrations
+  down [N]       roll back the last N applied migrations (default 1)
+  status         list migrations and whether they are applied
+  to VERSION     migrate up or down to VERSION (0 rolls back everything)
+`
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Connect to the database
+	dbConfig, err := database.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	// Migrations only ever talk to the primary
+	dbConfig.ReplicaDSNs = nil
+	db, err := database.Connect(context.Background(), dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close(db)
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		report("Applied", applied)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps %q", args[0])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		report("Rolled back", rolledBack)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+	case "to":
+		if len(args) != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid version %q", args[0])
+		}
+		changed, err := migrator.To(ctx, version)
+		report("Migrated", changed)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// report logs the migrations affected by a command
+func report(verb string, migrations []database.Migration) {
+	if len(migrations) == 0 {
+		log.Println("Database schema is up to date")
+		return
+	}
+	for _, migration := range migrations {
+		log.Printf("%s %04d_%s", verb, migration.Version, migration.Name)
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
 	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,12 +18,24 @@ func main() {
 	}
 
 	// Connect to the database
-	database.Connect()
-	database.Migrate()
+	dbConfig, err := database.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	db, err := database.Connect(context.Background(), dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close(db)
+
+	ctx := context.Background()
+	users := repository.NewUserRepo(db)
 
 	// Create admin user if it doesn't exist
-	var count int64
-	database.DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&count)
+	count, err := users.CountByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		log.Fatalf("Failed to count admin users: %v", err)
+	}
 	if count == 0 {
 		// Hash the password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.DefaultCost)
@@ -37,9 +51,8 @@ func main() {
 			Role:     models.RoleAdmin,
 		}
 
-		result := database.DB.Create(admin)
-		if result.Error != nil {
-			log.Fatalf("Failed to create admin user: %v", result.Error)
+		if err := users.Create(ctx, admin); err != nil {
+			log.Fatalf("Failed to create admin user: %v", err)
 		}
 
 		log.Println("Admin user created successfully")
@@ -48,7 +61,10 @@ func main() {
 	}
 
 	// Create test user if it doesn't exist
-	database.DB.Model(&models.User{}).Where("username = ?", "test").Count(&count)
+	count, err = users.CountByUsername(ctx, "test")
+	if err != nil {
+		log.Fatalf("Failed to count test users: %v", err)
+	}
 	if count == 0 {
 		// Hash the password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
@@ -64,9 +80,8 @@ func main() {
 			Role:     models.RoleUser,
 		}
 
-		result := database.DB.Create(testUser)
-		if result.Error != nil {
-			log.Fatalf("Failed to create test user: %v", result.Error)
+		if err := users.Create(ctx, testUser); err != nil {
+			log.Fatalf("Failed to create test user: %v", err)
 		}
 
 		log.Println("Test user created successfully")
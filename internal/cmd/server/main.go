@@ -1,28 +1,51 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/Alan69/ayatest/internal/audit"
+	"github.com/Alan69/ayatest/internal/bundle"
 	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/events"
 	"github.com/Alan69/ayatest/internal/graph/resolvers"
+	"github.com/Alan69/ayatest/internal/importer"
+	"github.com/Alan69/ayatest/internal/media"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/Alan69/ayatest/internal/qti"
+	"github.com/Alan69/ayatest/internal/repository"
 	"github.com/Alan69/ayatest/internal/workflows"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	"github.com/nats-io/nats.go"
 	"go.temporal.io/sdk/client"
 	"go.uber.org/zap"
 )
 
+// maxUploadSize limits the size of uploaded files and maxBundleSize the
+// size of uploaded bundles, which carry the images of a whole product.
+// Bundles get bundleTimeout to be uploaded or exported and imported instead
+// of the timeouts of the server.
+const (
+	maxUploadSize = 32 << 20
+	maxBundleSize = 512 << 20
+	bundleTimeout = 10 * time.Minute
+)
+
 // GraphQLRequest represents a GraphQL request
 type GraphQLRequest struct {
 	Query         string                 `json:"query"`
@@ -100,24 +123,60 @@ func main() {
 	}
 	sugar := logger.Sugar()
 
+	// Stop gracefully on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Connect to the database
-	database.Connect()
-	database.Migrate()
+	dbConfig, err := database.ConfigFromEnv()
+	if err != nil {
+		sugar.Fatalw("Invalid database configuration", "error", err)
+	}
+	db, err := database.Connect(ctx, dbConfig)
+	if err != nil {
+		sugar.Fatalw("Failed to connect to database", "error", err)
+	}
+	defer func() {
+		if err := database.Close(db); err != nil {
+			sugar.Errorw("Failed to close database", "error", err)
+		}
+	}()
+	if os.Getenv("DB_AUTO_MIGRATE") == "true" {
+		if err := database.Migrate(db); err != nil {
+			sugar.Fatalw("Failed to migrate database", "error", err)
+		}
+	} else {
+		migrator, err := database.NewMigrator(db)
+		if err != nil {
+			sugar.Fatalw("Failed to load migrations", "error", err)
+		}
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			sugar.Fatalw("Failed to check migrations", "error", err)
+		}
+		if len(pending) > 0 {
+			sugar.Warnw("Database has pending migrations, run the migrate command", "pending", len(pending))
+		}
+	}
+	repos := repository.New(db)
 
-	// Connect to NATS
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = nats.DefaultURL
+	// Open the store of question and option images
+	mediaConfig, err := media.ConfigFromEnv()
+	if err != nil {
+		sugar.Fatalw("Invalid media configuration", "error", err)
 	}
-	nc, err := nats.Connect(natsURL)
+	images, err := media.Open(ctx, mediaConfig)
 	if err != nil {
-		sugar.Fatalw("Failed to connect to NATS", "error", err)
+		sugar.Fatalw("Failed to open media store", "error", err)
 	}
-	defer nc.Close()
-	sugar.Infow("Connected to NATS", "url", natsURL)
+	sugar.Infow("Opened media store", "storage", mediaConfig.Storage)
 
 	// Create event publisher
-	publisher := events.NewNATSPublisher(nc, sugar)
+	publisher, closePublisher, err := events.FromEnv(sugar)
+	if err != nil {
+		sugar.Fatalw("Failed to create event publisher", "error", err)
+	}
+	defer closePublisher()
 
 	// Connect to Temporal
 	temporalURL := os.Getenv("TEMPORAL_URL")
@@ -134,24 +193,53 @@ func main() {
 	sugar.Infow("Connected to Temporal", "url", temporalURL)
 
 	// Start Temporal worker
-	worker := workflows.NewWorker(temporalClient, sugar)
+	activities := &workflows.Activities{
+		Attempts:  repos.Attempts,
+		Trash:     repos.Trash,
+		Regrades:  repos.Regrades,
+		Users:     repos.Users,
+		Grading:   repos.Grading,
+		Publisher: publisher,
+		Logger:    sugar,
+	}
+	worker := workflows.NewWorker(temporalClient, activities, sugar)
 	err = worker.Start()
 	if err != nil {
 		sugar.Fatalw("Failed to start Temporal worker", "error", err)
 	}
 	defer worker.Stop()
 
+	// Schedule the purge of soft deleted content
+	schedulePurgeTrash(ctx, temporalClient, sugar)
+
 	// Create resolver
 	resolver := &resolvers.Resolver{
 		Logger:         sugar,
 		EventPublisher: publisher,
 		TemporalClient: temporalClient,
+		ProductRepo:    repos.Products,
+		TestRepo:       repos.Tests,
+		QuestionRepo:   repos.Questions,
+		AttemptRepo:    repos.Attempts,
+		UserRepo:       repos.Users,
+		TrashRepo:      repos.Trash,
+		DeletionRepo:   repos.Deletions,
+		AuditRepo:      repos.Audit,
+		RegradeRepo:    repos.Regrades,
+		TaxonomyRepo:   repos.Taxonomy,
+		DuplicateRepo:  repos.Duplicates,
+		ReviewRepo:     repos.Reviews,
+		PassageRepo:    repos.Passages,
+		GradingRepo:    repos.Grading,
+		Repos:          repos,
+		Media:          images,
+		MediaURLTTL:    mediaConfig.URLTTL,
 	}
 
 	// No need to manually initialize the resolver, it has methods to return the resolvers
 
 	// Set up a simple GraphQL endpoint
-	http.HandleFunc("/query", corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/query", corsMiddleware(authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		// Only handle POST requests
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -255,7 +343,7 @@ func main() {
 		// Return the response
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
-	}))
+	})))
 
 	// Set up GraphQL playground
 	http.HandleFunc("/playground", func(w http.ResponseWriter, r *http.Request) {
@@ -330,11 +418,33 @@ func main() {
 			return
 		}
 
-		// Get all users
-		var users []models.User
-		result := database.DB.Find(&users)
-		if result.Error != nil {
-			sugar.Errorw("Failed to get users", "error", result.Error)
+		// Get a page of users
+		args, field, direction, err := pageFromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		var filter models.UserFilter
+		if v := r.URL.Query().Get("q"); v != "" {
+			filter.Query = &v
+		}
+		if v := r.URL.Query().Get("role"); v != "" {
+			role := models.UserRole(strings.ToUpper(v))
+			filter.Role = &role
+		}
+		var sort *models.UserSort
+		if field != "" {
+			sort = &models.UserSort{Field: models.UserSortField(field), Direction: direction}
+		}
+		users, err := repos.Users.Page(r.Context(), filter, sort, args)
+		if isPageError(err) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			sugar.Errorw("Failed to get users", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get users"})
 			return
@@ -354,11 +464,33 @@ func main() {
 			return
 		}
 
-		// Get all products
-		var products []models.Product
-		result := database.DB.Find(&products)
-		if result.Error != nil {
-			sugar.Errorw("Failed to get products", "error", result.Error)
+		// Get a page of products
+		args, field, direction, err := pageFromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		var filter models.ProductFilter
+		if v := r.URL.Query().Get("title"); v != "" {
+			filter.Title = &v
+		}
+		if v := r.URL.Query().Get("product_type"); v != "" {
+			productType := models.ProductType(strings.ToUpper(v))
+			filter.ProductType = &productType
+		}
+		var sort *models.ProductSort
+		if field != "" {
+			sort = &models.ProductSort{Field: models.ProductSortField(field), Direction: direction}
+		}
+		products, err := repos.Products.Page(r.Context(), filter, sort, args)
+		if isPageError(err) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			sugar.Errorw("Failed to get products", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get products"})
 			return
@@ -378,11 +510,31 @@ func main() {
 			return
 		}
 
-		// Get all tests
-		var tests []models.Test
-		result := database.DB.Preload("Product").Find(&tests)
-		if result.Error != nil {
-			sugar.Errorw("Failed to get tests", "error", result.Error)
+		// Get a page of tests
+		args, field, direction, err := pageFromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		filter, err := testFilterFromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		var sort *models.TestSort
+		if field != "" {
+			sort = &models.TestSort{Field: models.TestSortField(field), Direction: direction}
+		}
+		tests, err := repos.Tests.Page(r.Context(), filter, sort, args)
+		if isPageError(err) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			sugar.Errorw("Failed to get tests", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get tests"})
 			return
@@ -393,6 +545,419 @@ func main() {
 		json.NewEncoder(w).Encode(tests)
 	}))
 
+	http.HandleFunc("/api/admin/audit.csv", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Parse the filter from the query string
+		filter, err := auditLogFilterFromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Stream the matching audit entries as CSV. The log only grows, so
+		// it is never loaded at once; a failure midway truncates the file.
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+		err = audit.WriteCSV(w, func(write func(e *models.AuditEntry) error) error {
+			return repos.Audit.Each(r.Context(), filter, write)
+		})
+		if err != nil {
+			sugar.Errorw("Failed to write audit log", "error", err)
+		}
+	}))
+
+	questionImporter := importer.New(repos, publisher, sugar, importer.DefaultBatchSize)
+	http.HandleFunc("/api/admin/import/questions", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle POST requests
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		testID, err := uuid.Parse(r.URL.Query().Get("test_id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid test_id"})
+			return
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		// Read the uploaded spreadsheet
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+			return
+		}
+		defer file.Close()
+
+		// The format parameter overrides the format of the file extension
+		var format importer.Format
+		if f := r.URL.Query().Get("format"); f != "" {
+			format, err = importer.ParseFormat(f)
+		} else {
+			format, err = importer.FormatFromFilename(header.Filename)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		questions, err := importer.Load(file, format)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Failed to read file: %v", err)})
+			return
+		}
+
+		// Import the questions
+		var authorID *uuid.UUID
+		if id, err := uuid.Parse(fmt.Sprint(r.Context().Value("userID"))); err == nil {
+			authorID = &id
+		}
+		report, err := questionImporter.ImportQuestions(r.Context(), testID, questions, dryRun, authorID)
+		if err != nil {
+			sugar.Errorw("Failed to import questions", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Return the row by row report
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}))
+
+	http.HandleFunc("/api/admin/export/questions", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle GET requests
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		testID, err := uuid.Parse(r.URL.Query().Get("test_id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid test_id"})
+			return
+		}
+		format, err := importer.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		var buf bytes.Buffer
+		skipped, err := questionImporter.ExportQuestions(r.Context(), testID, format, &buf)
+		if errors.Is(err, importer.ErrNotWritable) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			sugar.Errorw("Failed to export questions", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to export questions"})
+			return
+		}
+
+		// Questions the format cannot represent are counted in a header
+		ext := "gift"
+		if format == importer.FormatAiken {
+			ext = "txt"
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"test-%s.%s\"", testID, ext))
+		w.Header().Set("X-Skipped-Questions", strconv.Itoa(len(skipped)))
+		w.Write(buf.Bytes())
+	}))
+
+	qtiPackager := qti.New(repos, images, publisher, sugar)
+	http.HandleFunc("/api/admin/qti/export", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle GET requests
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		testID, err := uuid.Parse(r.URL.Query().Get("test_id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid test_id"})
+			return
+		}
+		version, err := qti.ParseVersion(r.URL.Query().Get("version"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Build the package before writing so errors can still be reported
+		var buf bytes.Buffer
+		if err := qtiPackager.Export(r.Context(), testID, version, &buf); err != nil {
+			sugar.Errorw("Failed to export QTI package", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to export QTI package"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"test-%s-qti%s.zip\"", testID, version))
+		w.Write(buf.Bytes())
+	}))
+
+	http.HandleFunc("/api/admin/qti/import", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle POST requests
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		productID, err := uuid.Parse(r.URL.Query().Get("product_id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid product_id"})
+			return
+		}
+
+		// Read the uploaded package
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+			return
+		}
+		defer file.Close()
+
+		var authorID *uuid.UUID
+		if id, err := uuid.Parse(fmt.Sprint(r.Context().Value("userID"))); err == nil {
+			authorID = &id
+		}
+		report, err := qtiPackager.Import(r.Context(), productID, file, header.Size, authorID)
+		if err != nil {
+			sugar.Errorw("Failed to import QTI package", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}))
+
+	contentBundler := bundle.New(repos, images, publisher, sugar)
+	http.HandleFunc("/api/admin/bundle/export", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle GET requests
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		productID, err := uuid.Parse(r.URL.Query().Get("product_id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid product_id"})
+			return
+		}
+		withImages := r.URL.Query().Get("images") == "true"
+		extendDeadlines(w, sugar)
+
+		var buf bytes.Buffer
+		if err := contentBundler.Export(r.Context(), productID, withImages, &buf); err != nil {
+			sugar.Errorw("Failed to export bundle", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to export bundle"})
+			return
+		}
+
+		if withImages {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"product-%s.zip\"", productID))
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"product-%s.json\"", productID))
+		}
+		w.Write(buf.Bytes())
+	}))
+
+	http.HandleFunc("/api/admin/bundle/import", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle POST requests
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		opts := bundle.ImportOptions{
+			IDs:        bundle.IDMode(r.URL.Query().Get("ids")),
+			OnConflict: bundle.Conflict(r.URL.Query().Get("on_conflict")),
+			DryRun:     r.URL.Query().Get("dry_run") == "true",
+		}
+		if err := opts.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if id, err := uuid.Parse(fmt.Sprint(r.Context().Value("userID"))); err == nil {
+			opts.AuthorID = &id
+		}
+
+		opts.MaxImageSize = mediaConfig.MaxUploadSize
+		extendDeadlines(w, sugar)
+
+		// Read the uploaded bundle. Bundles larger than the memory of the
+		// multipart reader are spooled to a temporary file and read from it.
+		r.Body = http.MaxBytesReader(w, r.Body, maxBundleSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+			return
+		}
+		defer file.Close()
+		defer r.MultipartForm.RemoveAll()
+
+		report, err := contentBundler.Import(r.Context(), file, header.Size, opts)
+		if err != nil {
+			sugar.Errorw("Failed to import bundle", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}))
+
+	// Images are uploaded by admins and linked to questions and options
+	// through their imgPath
+	http.HandleFunc("/api/admin/media/upload", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		// Check if user is admin
+		userRole, ok := r.Context().Value("userRole").(string)
+		if !ok || userRole != string(models.RoleAdmin) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		// Only handle POST requests
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Leave room for the multipart envelope around the image
+		r.Body = http.MaxBytesReader(w, r.Body, mediaConfig.MaxUploadSize+1<<20)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+			return
+		}
+		defer file.Close()
+
+		image, err := media.Upload(r.Context(), images, file, mediaConfig.MaxUploadSize)
+		if errors.Is(err, media.ErrTooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, media.ErrUnsupportedType) {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			sugar.Errorw("Failed to upload image", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to upload image"})
+			return
+		}
+
+		// Signed URLs let the admin preview the image right away
+		response := struct {
+			*media.Image
+			URL          string `json:"url,omitempty"`
+			ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+		}{Image: image}
+		response.URL, _ = images.URL(r.Context(), image.Path, mediaConfig.URLTTL)
+		response.ThumbnailURL, _ = images.URL(r.Context(), image.ThumbnailPath, mediaConfig.URLTTL)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	// Local images are served through the signed URLs handed out with
+	// questions and options
+	if local, ok := images.(*media.Local); ok {
+		base, err := url.Parse(mediaConfig.BaseURL)
+		if err != nil {
+			sugar.Fatalw("Invalid MEDIA_BASE_URL", "error", err)
+		}
+		prefix := strings.TrimRight(base.Path, "/") + "/"
+		http.Handle(prefix, http.StripPrefix(prefix, local))
+	}
+
 	// Set up login and register API endpoints
 	http.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
 		// Only handle POST requests
@@ -481,12 +1046,180 @@ func main() {
 	sugar.Infow("Starting server", "port", port)
 	server := &http.Server{
 		Addr:         ":" + port,
+		Handler:      readYourWritesMiddleware(http.DefaultServeMux),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
-	if err := server.ListenAndServe(); err != nil {
-		sugar.Fatalw("Failed to start server", "error", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			sugar.Errorw("Failed to start server", "error", err)
+		}
+	case <-ctx.Done():
+		sugar.Infow("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			sugar.Errorw("Failed to shut down server", "error", err)
+		}
+	}
+}
+
+// schedulePurgeTrash starts the cron workflow that purges soft deleted content.
+// The schedule and retention come from TRASH_PURGE_SCHEDULE and TRASH_RETENTION.
+func schedulePurgeTrash(ctx context.Context, c client.Client, sugar *zap.SugaredLogger) {
+	schedule := os.Getenv("TRASH_PURGE_SCHEDULE")
+	if schedule == "" {
+		schedule = "0 3 * * *"
+	}
+	retention := 30 * 24 * time.Hour
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			sugar.Fatalw("Invalid TRASH_RETENTION", "value", v, "error", err)
+		}
+		retention = d
+	}
+
+	options := client.StartWorkflowOptions{
+		ID:           workflows.PurgeTrashWorkflowID,
+		TaskQueue:    workflows.TestTaskQueue,
+		CronSchedule: schedule,
+	}
+	params := workflows.PurgeTrashParams{Retention: retention}
+	if _, err := c.ExecuteWorkflow(ctx, options, workflows.PurgeTrashWorkflow, params); err != nil {
+		sugar.Warnw("Failed to schedule trash purge", "error", err)
+		return
 	}
+	sugar.Infow("Scheduled trash purge", "schedule", schedule, "retention", retention)
+}
+
+// auditLogFilterFromQuery builds an audit log filter from the actor_id,
+// action, entity_type, entity_id, from and to query parameters. Times are
+// RFC 3339.
+func auditLogFilterFromQuery(q url.Values) (models.AuditLogFilter, error) {
+	var filter models.AuditLogFilter
+	if v := q.Get("actor_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid actor_id: %w", err)
+		}
+		filter.ActorID = &id
+	}
+	if v := q.Get("action"); v != "" {
+		action := models.AuditAction(strings.ToUpper(v))
+		filter.Action = &action
+	}
+	if v := q.Get("entity_type"); v != "" {
+		entity := models.AuditEntity(strings.ToUpper(v))
+		filter.EntityType = &entity
+	}
+	if v := q.Get("entity_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid entity_id: %w", err)
+		}
+		filter.EntityID = &id
+	}
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+		filter.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+		filter.To = &t
+	}
+	return filter, nil
+}
+
+// pageFromQuery reads the page of a list endpoint from the first, after,
+// last and before query parameters, and its order from the sort parameter:
+// a field name such as date_created, prefixed with - for descending order
+func pageFromQuery(q url.Values) (args pagination.Args, field string, direction *models.SortDirection, err error) {
+	for _, p := range []struct {
+		name string
+		dst  **int
+	}{{"first", &args.First}, {"last", &args.Last}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return args, "", nil, fmt.Errorf("invalid %s: %w", p.name, err)
+			}
+			*p.dst = &n
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		args.After = &v
+	}
+	if v := q.Get("before"); v != "" {
+		args.Before = &v
+	}
+
+	if v := q.Get("sort"); v != "" {
+		d := models.SortAsc
+		if strings.HasPrefix(v, "-") {
+			v, d = v[1:], models.SortDesc
+		}
+		field, direction = strings.ToUpper(v), &d
+	}
+	return args, field, direction, nil
+}
+
+// isPageError reports whether a list failed because of invalid page or sort
+// parameters
+func isPageError(err error) bool {
+	return errors.Is(err, pagination.ErrInvalidCursor) || errors.Is(err, pagination.ErrFirstAndLast) ||
+		errors.Is(err, pagination.ErrNegativeLimit) || errors.Is(err, repository.ErrInvalidSort)
+}
+
+// testFilterFromQuery reads a test filter from the query parameters of the
+// tests endpoint
+func testFilterFromQuery(q url.Values) (models.TestFilter, error) {
+	var filter models.TestFilter
+	if v := q.Get("product_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid product_id: %w", err)
+		}
+		filter.ProductID = &id
+	}
+	if v := q.Get("title"); v != "" {
+		filter.Title = &v
+	}
+	if v := q.Get("grade"); v != "" {
+		grade, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid grade: %w", err)
+		}
+		filter.Grade = &grade
+	}
+	if v := q.Get("is_required"); v != "" {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid is_required: %w", err)
+		}
+		filter.IsRequired = &required
+	}
+	return filter, nil
+}
+
+// readYourWritesMiddleware makes reads that follow a write within the same
+// request go to the primary database instead of a replica
+func readYourWritesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(database.WithReadYourWrites(r.Context())))
+	})
 }
 
 // corsMiddleware adds CORS headers to responses
@@ -507,3 +1240,16 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// extendDeadlines gives a bundle request bundleTimeout to be read and
+// answered, as whole products take longer than the timeouts of the server
+func extendDeadlines(w http.ResponseWriter, logger *zap.SugaredLogger) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(bundleTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		logger.Warnw("Failed to extend read deadline", "error", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		logger.Warnw("Failed to extend write deadline", "error", err)
+	}
+}
@@ -1,56 +1,265 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/Alan69/ayatest/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// DB is the database connection
-var DB *gorm.DB
+// Config configures the database connection
+type Config struct {
+	// DSN is a complete connection string. When set, the discrete connection
+	// fields below are ignored.
+	DSN string
 
-// Connect establishes a connection to the database
-func Connect() {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+
+	// SSLMode is the libpq sslmode (disable, allow, prefer, require, verify-ca, verify-full)
+	SSLMode string
+	// SSLRootCert is the path to the CA certificate used by verify-ca and verify-full
+	SSLRootCert string
+
+	// ReplicaDSNs are connection strings of read replicas. When set, plain
+	// reads are spread across the replicas and writes stay on the primary.
+	ReplicaDSNs []string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// StatementTimeout aborts statements running longer than this; zero disables it
+	StatementTimeout time.Duration
+
+	// LogLevel is the GORM log level (silent, error, warn, info)
+	LogLevel string
+
+	// ConnectRetries is the number of readiness pings attempted before giving up
+	ConnectRetries int
+	// ConnectRetryInterval is the pause between readiness pings
+	ConnectRetryInterval time.Duration
+}
+
+// ConfigFromEnv reads the database configuration from environment variables
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		DSN:                  os.Getenv("DB_DSN"),
+		Host:                 getEnv("DB_HOST", "localhost"),
+		Port:                 getEnv("DB_PORT", "5432"),
+		User:                 os.Getenv("DB_USER"),
+		Password:             os.Getenv("DB_PASSWORD"),
+		Name:                 os.Getenv("DB_NAME"),
+		SSLMode:              getEnv("DB_SSLMODE", "prefer"),
+		SSLRootCert:          os.Getenv("DB_SSLROOTCERT"),
+		LogLevel:             getEnv("DB_LOG_LEVEL", "warn"),
+		MaxOpenConns:         25,
+		MaxIdleConns:         5,
+		ConnMaxLifetime:      30 * time.Minute,
+		ConnMaxIdleTime:      5 * time.Minute,
+		StatementTimeout:     30 * time.Second,
+		ConnectRetries:       10,
+		ConnectRetryInterval: 2 * time.Second,
+	}
+
+	for _, dsn := range strings.Split(os.Getenv("DB_REPLICA_DSNS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			cfg.ReplicaDSNs = append(cfg.ReplicaDSNs, dsn)
+		}
+	}
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	if cfg.MaxOpenConns, err = envInt("DB_MAX_OPEN_CONNS", cfg.MaxOpenConns); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIdleConns, err = envInt("DB_MAX_IDLE_CONNS", cfg.MaxIdleConns); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxLifetime, err = envDuration("DB_CONN_MAX_LIFETIME", cfg.ConnMaxLifetime); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxIdleTime, err = envDuration("DB_CONN_MAX_IDLE_TIME", cfg.ConnMaxIdleTime); err != nil {
+		return cfg, err
+	}
+	if cfg.StatementTimeout, err = envDuration("DB_STATEMENT_TIMEOUT", cfg.StatementTimeout); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnectRetries, err = envInt("DB_CONNECT_RETRIES", cfg.ConnectRetries); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnectRetryInterval, err = envDuration("DB_CONNECT_RETRY_INTERVAL", cfg.ConnectRetryInterval); err != nil {
+		return cfg, err
+	}
+	if _, err := cfg.gormLogLevel(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// ConnectionString returns the DSN, building it from the discrete fields if needed
+func (c Config) ConnectionString() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	params := []string{
+		"host=" + quoteDSNValue(c.Host),
+		"port=" + quoteDSNValue(c.Port),
+		"user=" + quoteDSNValue(c.User),
+		"password=" + quoteDSNValue(c.Password),
+		"dbname=" + quoteDSNValue(c.Name),
+		"sslmode=" + quoteDSNValue(c.SSLMode),
+		"TimeZone=UTC",
+	}
+	if c.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteDSNValue(c.SSLRootCert))
+	}
+	return strings.Join(params, " ")
+}
+
+// gormLogLevel converts the configured log level to a GORM log level
+func (c Config) gormLogLevel() (logger.LogLevel, error) {
+	switch strings.ToLower(c.LogLevel) {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "", "warn":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	default:
+		return 0, fmt.Errorf("invalid database log level %q", c.LogLevel)
+	}
+}
+
+// Connect opens a connection pool and waits until the database answers a ping
+func Connect(ctx context.Context, cfg Config) (*gorm.DB, error) {
+	pgxConfig, err := pgx.ParseConfig(cfg.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("parse database config: %w", err)
+	}
+	if cfg.StatementTimeout > 0 {
+		pgxConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	logLevel, err := cfg.gormLogLevel()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB := stdlib.OpenDB(*pgxConfig)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
 	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		sqlDB.Close()
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	// Wait for the database to become ready
+	attempts := cfg.ConnectRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = sqlDB.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt >= attempts {
+			sqlDB.Close()
+			return nil, fmt.Errorf("database not ready after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("Database not ready (attempt %d/%d): %v", attempt, attempts, err)
+		select {
+		case <-ctx.Done():
+			sqlDB.Close()
+			return nil, ctx.Err()
+		case <-time.After(cfg.ConnectRetryInterval):
+		}
+	}
+
+	if len(cfg.ReplicaDSNs) > 0 {
+		if err := useReplicas(db, cfg); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+		log.Printf("Routing reads to %d replica(s)", len(cfg.ReplicaDSNs))
 	}
 
 	log.Println("Connected to database")
+	return db, nil
 }
 
-// Migrate runs database migrations
-func Migrate() {
-	err := DB.AutoMigrate(
-		&models.Product{},
-		&models.Test{},
-		&models.Source{},
-		&models.Question{},
-		&models.Option{},
-		&models.User{},
-		&models.CompletedTest{},
-		&models.CompletedQuestion{},
-	)
+// Close closes the connection pool, waiting for in-flight queries to finish
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return err
 	}
+	return sqlDB.Close()
+}
+
+// quoteDSNValue quotes a value for a keyword/value connection string
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// getEnv returns the environment variable or the fallback if it is unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt parses an integer environment variable
+func envInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
 
-	log.Println("Database migration completed")
+// envDuration parses a duration environment variable such as "30s"
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
 }
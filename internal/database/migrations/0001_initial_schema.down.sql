@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS completed_question_selected_options;
+DROP TABLE IF EXISTS completed_questions;
+DROP TABLE IF EXISTS completed_test_tests;
+DROP TABLE IF EXISTS completed_tests;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS options;
+DROP TABLE IF EXISTS questions;
+DROP TABLE IF EXISTS sources;
+DROP TABLE IF EXISTS tests;
+DROP TABLE IF EXISTS products;
@@ -0,0 +1,100 @@
+-- Initial schema matching the tables previously created by GORM AutoMigrate.
+-- IF NOT EXISTS lets databases created by AutoMigrate adopt versioned migrations.
+
+CREATE TABLE IF NOT EXISTS products (
+    id            uuid PRIMARY KEY,
+    title         varchar(200),
+    description   text,
+    sum           bigint,
+    score         bigint,
+    time          bigint,
+    subject_limit bigint,
+    product_type  varchar(10) DEFAULT 'STUDENT',
+    date_created  timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS tests (
+    id                  uuid PRIMARY KEY,
+    title               varchar(200),
+    number_of_questions bigint,
+    time                bigint,
+    score               bigint,
+    product_id          uuid,
+    grade               bigint,
+    date_created        timestamptz,
+    is_required         boolean DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS sources (
+    id   uuid PRIMARY KEY,
+    text text
+);
+
+CREATE TABLE IF NOT EXISTS questions (
+    id             uuid PRIMARY KEY,
+    test_id        uuid,
+    text           text,
+    text2          text,
+    text3          text,
+    img_path       text,
+    task_type      bigint,
+    level          bigint,
+    status         bigint,
+    category       varchar(2000),
+    subcategory    varchar(2000),
+    theme          varchar(2000),
+    subtheme       varchar(2000),
+    target         text,
+    source         varchar(2000),
+    source_text_id uuid,
+    detail_id      bigint,
+    lng_id         bigint,
+    lng_title      varchar(100),
+    subject_id     bigint,
+    subject_title  varchar(2000),
+    class_number   bigint
+);
+
+CREATE TABLE IF NOT EXISTS options (
+    id          uuid PRIMARY KEY,
+    question_id uuid,
+    text        varchar(2000),
+    img_path    text,
+    is_correct  boolean DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id       uuid PRIMARY KEY,
+    username varchar(100) CONSTRAINT uni_users_username UNIQUE,
+    email    varchar(100) CONSTRAINT uni_users_email UNIQUE,
+    password varchar(100),
+    role     varchar(10) DEFAULT 'USER'
+);
+
+CREATE TABLE IF NOT EXISTS completed_tests (
+    id              uuid PRIMARY KEY,
+    user_id         uuid,
+    product_id      uuid,
+    completed_date  timestamptz,
+    start_test_time timestamptz,
+    time_spent      bigint
+);
+
+CREATE TABLE IF NOT EXISTS completed_test_tests (
+    completed_test_id uuid NOT NULL,
+    test_id           uuid NOT NULL,
+    PRIMARY KEY (completed_test_id, test_id)
+);
+
+CREATE TABLE IF NOT EXISTS completed_questions (
+    id                uuid PRIMARY KEY,
+    completed_test_id uuid,
+    test_id           uuid,
+    question_id       uuid
+);
+
+CREATE TABLE IF NOT EXISTS completed_question_selected_options (
+    completed_question_id uuid NOT NULL,
+    option_id             uuid NOT NULL,
+    PRIMARY KEY (completed_question_id, option_id)
+);
@@ -0,0 +1,25 @@
+DROP INDEX IF EXISTS idx_completed_question_selected_options_option_id;
+DROP INDEX IF EXISTS idx_completed_questions_question_id;
+DROP INDEX IF EXISTS idx_completed_questions_test_id;
+DROP INDEX IF EXISTS idx_completed_questions_completed_test_id;
+DROP INDEX IF EXISTS idx_completed_test_tests_test_id;
+DROP INDEX IF EXISTS idx_completed_tests_product_id;
+DROP INDEX IF EXISTS idx_completed_tests_user_id;
+DROP INDEX IF EXISTS idx_options_question_id;
+DROP INDEX IF EXISTS idx_questions_source_text_id;
+DROP INDEX IF EXISTS idx_questions_test_id;
+DROP INDEX IF EXISTS idx_tests_product_id;
+
+ALTER TABLE completed_question_selected_options DROP CONSTRAINT IF EXISTS fk_completed_question_selected_options_option_id;
+ALTER TABLE completed_question_selected_options DROP CONSTRAINT IF EXISTS fk_completed_question_selected_options_completed_question_id;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_questions_question_id;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_questions_test_id;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_questions_completed_test_id;
+ALTER TABLE completed_test_tests DROP CONSTRAINT IF EXISTS fk_completed_test_tests_test_id;
+ALTER TABLE completed_test_tests DROP CONSTRAINT IF EXISTS fk_completed_test_tests_completed_test_id;
+ALTER TABLE completed_tests DROP CONSTRAINT IF EXISTS fk_completed_tests_product_id;
+ALTER TABLE completed_tests DROP CONSTRAINT IF EXISTS fk_completed_tests_user_id;
+ALTER TABLE options DROP CONSTRAINT IF EXISTS fk_options_question_id;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_source_text_id;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_test_id;
+ALTER TABLE tests DROP CONSTRAINT IF EXISTS fk_tests_product_id;
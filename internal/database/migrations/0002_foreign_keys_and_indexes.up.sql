@@ -0,0 +1,90 @@
+-- Replace the constraints GORM AutoMigrate may have created with explicitly
+-- named foreign keys that define ON DELETE behaviour.
+--
+-- Content cascades down the product -> test -> question -> option hierarchy.
+-- Attempts reference content with RESTRICT so answered content cannot be
+-- removed from under a historical result.
+--
+-- The constraints are added NOT VALID so databases that already contain
+-- orphaned rows can be migrated; new and updated rows are still checked.
+-- Run ALTER TABLE ... VALIDATE CONSTRAINT once the orphans are cleaned up.
+
+ALTER TABLE tests DROP CONSTRAINT IF EXISTS fk_tests_product;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_test;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_source_text;
+ALTER TABLE options DROP CONSTRAINT IF EXISTS fk_options_question;
+ALTER TABLE options DROP CONSTRAINT IF EXISTS fk_questions_options;
+ALTER TABLE completed_tests DROP CONSTRAINT IF EXISTS fk_completed_tests_user;
+ALTER TABLE completed_tests DROP CONSTRAINT IF EXISTS fk_completed_tests_product;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_tests_questions;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_questions_completed_test;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_questions_test;
+ALTER TABLE completed_questions DROP CONSTRAINT IF EXISTS fk_completed_questions_question;
+ALTER TABLE completed_test_tests DROP CONSTRAINT IF EXISTS fk_completed_test_tests_completed_test;
+ALTER TABLE completed_test_tests DROP CONSTRAINT IF EXISTS fk_completed_test_tests_test;
+ALTER TABLE completed_question_selected_options DROP CONSTRAINT IF EXISTS fk_completed_question_selected_options_completed_question;
+ALTER TABLE completed_question_selected_options DROP CONSTRAINT IF EXISTS fk_completed_question_selected_options_option;
+
+ALTER TABLE tests
+    ADD CONSTRAINT fk_tests_product_id FOREIGN KEY (product_id)
+    REFERENCES products (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE questions
+    ADD CONSTRAINT fk_questions_test_id FOREIGN KEY (test_id)
+    REFERENCES tests (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE questions
+    ADD CONSTRAINT fk_questions_source_text_id FOREIGN KEY (source_text_id)
+    REFERENCES sources (id) ON DELETE SET NULL NOT VALID;
+
+ALTER TABLE options
+    ADD CONSTRAINT fk_options_question_id FOREIGN KEY (question_id)
+    REFERENCES questions (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE completed_tests
+    ADD CONSTRAINT fk_completed_tests_user_id FOREIGN KEY (user_id)
+    REFERENCES users (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE completed_tests
+    ADD CONSTRAINT fk_completed_tests_product_id FOREIGN KEY (product_id)
+    REFERENCES products (id) ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE completed_test_tests
+    ADD CONSTRAINT fk_completed_test_tests_completed_test_id FOREIGN KEY (completed_test_id)
+    REFERENCES completed_tests (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE completed_test_tests
+    ADD CONSTRAINT fk_completed_test_tests_test_id FOREIGN KEY (test_id)
+    REFERENCES tests (id) ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE completed_questions
+    ADD CONSTRAINT fk_completed_questions_completed_test_id FOREIGN KEY (completed_test_id)
+    REFERENCES completed_tests (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE completed_questions
+    ADD CONSTRAINT fk_completed_questions_test_id FOREIGN KEY (test_id)
+    REFERENCES tests (id) ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE completed_questions
+    ADD CONSTRAINT fk_completed_questions_question_id FOREIGN KEY (question_id)
+    REFERENCES questions (id) ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE completed_question_selected_options
+    ADD CONSTRAINT fk_completed_question_selected_options_completed_question_id FOREIGN KEY (completed_question_id)
+    REFERENCES completed_questions (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE completed_question_selected_options
+    ADD CONSTRAINT fk_completed_question_selected_options_option_id FOREIGN KEY (option_id)
+    REFERENCES options (id) ON DELETE RESTRICT NOT VALID;
+
+CREATE INDEX IF NOT EXISTS idx_tests_product_id ON tests (product_id);
+CREATE INDEX IF NOT EXISTS idx_questions_test_id ON questions (test_id);
+CREATE INDEX IF NOT EXISTS idx_questions_source_text_id ON questions (source_text_id);
+CREATE INDEX IF NOT EXISTS idx_options_question_id ON options (question_id);
+CREATE INDEX IF NOT EXISTS idx_completed_tests_user_id ON completed_tests (user_id);
+CREATE INDEX IF NOT EXISTS idx_completed_tests_product_id ON completed_tests (product_id);
+CREATE INDEX IF NOT EXISTS idx_completed_test_tests_test_id ON completed_test_tests (test_id);
+CREATE INDEX IF NOT EXISTS idx_completed_questions_completed_test_id ON completed_questions (completed_test_id);
+CREATE INDEX IF NOT EXISTS idx_completed_questions_test_id ON completed_questions (test_id);
+CREATE INDEX IF NOT EXISTS idx_completed_questions_question_id ON completed_questions (question_id);
+CREATE INDEX IF NOT EXISTS idx_completed_question_selected_options_option_id ON completed_question_selected_options (option_id);
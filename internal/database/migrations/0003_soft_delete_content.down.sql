@@ -0,0 +1,11 @@
+-- Soft deleted rows become visible again once the columns are dropped
+
+DROP INDEX IF EXISTS idx_options_deleted_at;
+DROP INDEX IF EXISTS idx_questions_deleted_at;
+DROP INDEX IF EXISTS idx_tests_deleted_at;
+DROP INDEX IF EXISTS idx_products_deleted_at;
+
+ALTER TABLE options DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE questions DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE tests DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,11 @@
+-- Content is soft deleted so historical attempts keep referencing it
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE tests ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE options ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_tests_deleted_at ON tests (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_questions_deleted_at ON questions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_options_deleted_at ON options (deleted_at);
@@ -0,0 +1,18 @@
+-- Audit trail of changes made through mutations. actor_id has no foreign key
+-- so entries outlive the users that made them.
+
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id          uuid PRIMARY KEY,
+    actor_id    uuid,
+    action      varchar(20) NOT NULL,
+    entity_type varchar(30) NOT NULL,
+    entity_id   uuid NOT NULL,
+    before      jsonb,
+    after       jsonb,
+    diff        jsonb,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_entries_actor_id ON audit_entries (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_entity ON audit_entries (entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_created_at ON audit_entries (created_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS question_revisions;
+
+ALTER TABLE completed_questions DROP COLUMN IF EXISTS question_revision;
+ALTER TABLE questions DROP COLUMN IF EXISTS revision;
@@ -0,0 +1,33 @@
+-- Questions are versioned: every change stores a snapshot of the question and
+-- its options, and answers pin the revision they were given against
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS revision integer NOT NULL DEFAULT 0;
+ALTER TABLE completed_questions ADD COLUMN IF NOT EXISTS question_revision integer;
+
+CREATE TABLE IF NOT EXISTS question_revisions (
+    id          uuid PRIMARY KEY,
+    question_id uuid NOT NULL,
+    revision    integer NOT NULL,
+    snapshot    jsonb NOT NULL,
+    author_id   uuid,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_question_revisions_question_id FOREIGN KEY (question_id)
+        REFERENCES questions (id) ON DELETE CASCADE,
+    CONSTRAINT uq_question_revisions_question_id_revision UNIQUE (question_id, revision)
+);
+
+-- The current content of every question becomes its first revision. Column
+-- names match the JSON field names of the models.
+INSERT INTO question_revisions (id, question_id, revision, snapshot)
+SELECT gen_random_uuid(), q.id, 1,
+       to_jsonb(q) || jsonb_build_object(
+           'revision', 1,
+           'options', COALESCE((
+               SELECT jsonb_agg(to_jsonb(o) ORDER BY o.id)
+               FROM options o
+               WHERE o.question_id = q.id AND o.deleted_at IS NULL
+           ), '[]'::jsonb))
+FROM questions q
+WHERE q.revision = 0;
+
+UPDATE questions SET revision = 1 WHERE revision = 0;
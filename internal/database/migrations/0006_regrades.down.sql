@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS regrade_results;
+DROP TABLE IF EXISTS regrades;
+
+ALTER TABLE completed_tests DROP COLUMN IF EXISTS checked_at;
+ALTER TABLE completed_tests DROP COLUMN IF EXISTS total_questions;
+ALTER TABLE completed_tests DROP COLUMN IF EXISTS correct_answers;
+ALTER TABLE completed_tests DROP COLUMN IF EXISTS score;
@@ -0,0 +1,35 @@
+-- Attempts store their score so regrades can compare old and new results
+
+ALTER TABLE completed_tests ADD COLUMN IF NOT EXISTS score integer;
+ALTER TABLE completed_tests ADD COLUMN IF NOT EXISTS correct_answers integer;
+ALTER TABLE completed_tests ADD COLUMN IF NOT EXISTS total_questions integer;
+ALTER TABLE completed_tests ADD COLUMN IF NOT EXISTS checked_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS regrades (
+    id                uuid PRIMARY KEY,
+    test_id           uuid,
+    question_id       uuid,
+    completed_test_id uuid,
+    requested_by      uuid,
+    workflow_id       varchar(100),
+    attempts          integer NOT NULL DEFAULT 0,
+    changed           integer NOT NULL DEFAULT 0,
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    completed_at      timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS regrade_results (
+    id                uuid PRIMARY KEY,
+    regrade_id        uuid NOT NULL,
+    completed_test_id uuid NOT NULL,
+    old_score         integer,
+    new_score         integer NOT NULL,
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_regrade_results_regrade_id FOREIGN KEY (regrade_id)
+        REFERENCES regrades (id) ON DELETE CASCADE,
+    CONSTRAINT fk_regrade_results_completed_test_id FOREIGN KEY (completed_test_id)
+        REFERENCES completed_tests (id) ON DELETE CASCADE,
+    CONSTRAINT uq_regrade_results_regrade_id_completed_test_id UNIQUE (regrade_id, completed_test_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_regrade_results_completed_test_id ON regrade_results (completed_test_id);
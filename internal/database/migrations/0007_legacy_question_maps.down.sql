@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_questions_detail_id_lng_id;
+DROP TABLE IF EXISTS legacy_question_maps;
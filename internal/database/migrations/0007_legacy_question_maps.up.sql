@@ -0,0 +1,17 @@
+-- Questions synchronized from the legacy platform are mapped by their legacy
+-- detail and language IDs, so repeated syncs update them instead of creating
+-- duplicates. The checksum is taken over the legacy row last synchronized.
+
+CREATE TABLE IF NOT EXISTS legacy_question_maps (
+    detail_id   integer NOT NULL,
+    lng_id      integer NOT NULL,
+    question_id uuid NOT NULL,
+    checksum    varchar(64) NOT NULL,
+    synced_at   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (detail_id, lng_id),
+    CONSTRAINT fk_legacy_question_maps_question_id FOREIGN KEY (question_id)
+        REFERENCES questions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_legacy_question_maps_question_id ON legacy_question_maps (question_id);
+CREATE INDEX IF NOT EXISTS idx_questions_detail_id_lng_id ON questions (detail_id, lng_id);
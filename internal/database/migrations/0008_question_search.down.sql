@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_questions_search_document;
+
+DROP TRIGGER IF EXISTS trg_sources_search_document ON sources;
+DROP TRIGGER IF EXISTS trg_options_search_document ON options;
+DROP TRIGGER IF EXISTS trg_questions_search_document ON questions;
+DROP FUNCTION IF EXISTS sources_search_document_trigger();
+DROP FUNCTION IF EXISTS options_search_document_trigger();
+DROP FUNCTION IF EXISTS refresh_question_search_documents(uuid[]);
+DROP FUNCTION IF EXISTS questions_search_document_trigger();
+
+ALTER TABLE questions DROP COLUMN IF EXISTS search_document;
+
+DROP FUNCTION IF EXISTS question_search_document(uuid, text, text, text, uuid, text);
+DROP FUNCTION IF EXISTS question_search_text(uuid, text, text, text, uuid);
+DROP FUNCTION IF EXISTS question_search_config(text);
@@ -0,0 +1,94 @@
+-- Questions are searched through a tsvector over their texts, the texts of
+-- their options and their source passage. The text search configuration is
+-- chosen from the language title of the question; languages without a
+-- stemming configuration, Kazakh among them, use the simple configuration.
+
+CREATE OR REPLACE FUNCTION question_search_config(q_lng_title text) RETURNS regconfig AS $$
+    SELECT CASE
+        WHEN lower(q_lng_title) LIKE 'рус%' OR lower(q_lng_title) LIKE 'russ%' OR lower(q_lng_title) = 'ru' THEN 'russian'::regconfig
+        WHEN lower(q_lng_title) LIKE 'англ%' OR lower(q_lng_title) LIKE 'engl%' OR lower(q_lng_title) = 'en' THEN 'english'::regconfig
+        ELSE 'simple'::regconfig
+    END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- question_search_text joins the searchable texts of a question: the question
+-- texts, the texts of its options and its source passage, in that order
+CREATE OR REPLACE FUNCTION question_search_text(q_id uuid, q_text text, q_text2 text, q_text3 text, q_source_text_id uuid)
+RETURNS text[] AS $$
+    SELECT ARRAY[
+        concat_ws(' ', q_text, q_text2, q_text3),
+        COALESCE((
+            SELECT string_agg(o.text, ' ' ORDER BY o.id)
+            FROM options o
+            WHERE o.question_id = q_id AND o.deleted_at IS NULL
+        ), ''),
+        COALESCE((SELECT s.text FROM sources s WHERE s.id = q_source_text_id), '')
+    ]
+$$ LANGUAGE sql STABLE;
+
+-- Question texts weigh most, then options, then the source passage
+CREATE OR REPLACE FUNCTION question_search_document(q_id uuid, q_text text, q_text2 text, q_text3 text, q_source_text_id uuid, q_lng_title text)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector(question_search_config(q_lng_title), t[1]), 'A')
+        || setweight(to_tsvector(question_search_config(q_lng_title), t[2]), 'B')
+        || setweight(to_tsvector(question_search_config(q_lng_title), t[3]), 'C')
+    FROM question_search_text(q_id, q_text, q_text2, q_text3, q_source_text_id) AS t
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS search_document tsvector;
+
+CREATE OR REPLACE FUNCTION questions_search_document_trigger() RETURNS trigger AS $$
+BEGIN
+    NEW.search_document := question_search_document(NEW.id, NEW.text, NEW.text2, NEW.text3, NEW.source_text_id, NEW.lng_title);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_questions_search_document ON questions;
+CREATE TRIGGER trg_questions_search_document
+    BEFORE INSERT OR UPDATE OF text, text2, text3, source_text_id, lng_title ON questions
+    FOR EACH ROW EXECUTE FUNCTION questions_search_document_trigger();
+
+-- Changes to options and sources refresh the documents of their questions
+CREATE OR REPLACE FUNCTION refresh_question_search_documents(ids uuid[]) RETURNS void AS $$
+    UPDATE questions q
+    SET search_document = question_search_document(q.id, q.text, q.text2, q.text3, q.source_text_id, q.lng_title)
+    WHERE q.id = ANY(ids)
+$$ LANGUAGE sql;
+
+CREATE OR REPLACE FUNCTION options_search_document_trigger() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM refresh_question_search_documents(ARRAY[OLD.question_id]);
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM refresh_question_search_documents(ARRAY[OLD.question_id, NEW.question_id]);
+    ELSE
+        PERFORM refresh_question_search_documents(ARRAY[NEW.question_id]);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_options_search_document ON options;
+CREATE TRIGGER trg_options_search_document
+    AFTER INSERT OR DELETE OR UPDATE OF text, question_id, deleted_at ON options
+    FOR EACH ROW EXECUTE FUNCTION options_search_document_trigger();
+
+CREATE OR REPLACE FUNCTION sources_search_document_trigger() RETURNS trigger AS $$
+BEGIN
+    PERFORM refresh_question_search_documents(ARRAY(
+        SELECT q.id FROM questions q WHERE q.source_text_id = NEW.id
+    ));
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_sources_search_document ON sources;
+CREATE TRIGGER trg_sources_search_document
+    AFTER UPDATE OF text ON sources
+    FOR EACH ROW EXECUTE FUNCTION sources_search_document_trigger();
+
+UPDATE questions
+SET search_document = question_search_document(id, text, text2, text3, source_text_id, lng_title);
+
+CREATE INDEX IF NOT EXISTS idx_questions_search_document ON questions USING gin (search_document);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS trg_questions_taxonomy ON questions;
+DROP FUNCTION IF EXISTS questions_taxonomy_trigger();
+DROP FUNCTION IF EXISTS taxonomy_path_titles(uuid);
+DROP FUNCTION IF EXISTS taxonomy_path_for(text, text, text, text);
+DROP FUNCTION IF EXISTS taxonomy_node_for(uuid, text, text);
+
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_taxonomy_node_id;
+DROP INDEX IF EXISTS idx_questions_taxonomy_node_id;
+ALTER TABLE questions DROP COLUMN IF EXISTS taxonomy_node_id;
+
+DROP TABLE IF EXISTS taxonomy_nodes;
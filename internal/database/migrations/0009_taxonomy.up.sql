@@ -0,0 +1,124 @@
+-- Subjects, categories, themes and subthemes of questions form a taxonomy
+-- tree. Nodes are matched by their normalized title, lower case with
+-- collapsed whitespace, so differently spelled copies of the same topic end
+-- up as one node. Questions reference the deepest node of their taxonomy; the
+-- subject_title, category, theme and subtheme columns stay as a readable copy
+-- of the path of that node and are kept in sync by a trigger.
+
+CREATE TABLE IF NOT EXISTS taxonomy_nodes (
+    id               uuid PRIMARY KEY,
+    parent_id        uuid,
+    level            varchar(20) NOT NULL,
+    title            varchar(2000) NOT NULL,
+    normalized_title varchar(2000) NOT NULL,
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_taxonomy_nodes_parent_id FOREIGN KEY (parent_id)
+        REFERENCES taxonomy_nodes (id) ON DELETE RESTRICT
+);
+
+-- Siblings of a level are unique by normalized title; roots are siblings too
+CREATE UNIQUE INDEX IF NOT EXISTS uq_taxonomy_nodes_parent_id_level_normalized_title ON taxonomy_nodes (
+    COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), level, normalized_title
+);
+CREATE INDEX IF NOT EXISTS idx_taxonomy_nodes_parent_id ON taxonomy_nodes (parent_id);
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS taxonomy_node_id uuid;
+ALTER TABLE questions ADD CONSTRAINT fk_questions_taxonomy_node_id FOREIGN KEY (taxonomy_node_id)
+    REFERENCES taxonomy_nodes (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_questions_taxonomy_node_id ON questions (taxonomy_node_id);
+
+-- taxonomy_node_for finds or creates the node titled p_title below p_parent.
+-- Empty titles skip the level and return the parent.
+CREATE OR REPLACE FUNCTION taxonomy_node_for(p_parent uuid, p_level text, p_title text) RETURNS uuid AS $$
+DECLARE
+    v_title text := regexp_replace(btrim(COALESCE(p_title, '')), '\s+', ' ', 'g');
+    v_node  uuid;
+BEGIN
+    IF v_title = '' THEN
+        RETURN p_parent;
+    END IF;
+    SELECT id INTO v_node FROM taxonomy_nodes
+    WHERE parent_id IS NOT DISTINCT FROM p_parent AND level = p_level AND normalized_title = lower(v_title);
+    IF v_node IS NOT NULL THEN
+        RETURN v_node;
+    END IF;
+
+    -- A concurrent transaction may create the same node first
+    INSERT INTO taxonomy_nodes (id, parent_id, level, title, normalized_title)
+    VALUES (gen_random_uuid(), p_parent, p_level, v_title, lower(v_title))
+    ON CONFLICT DO NOTHING;
+    SELECT id INTO v_node FROM taxonomy_nodes
+    WHERE parent_id IS NOT DISTINCT FROM p_parent AND level = p_level AND normalized_title = lower(v_title);
+    RETURN v_node;
+END
+$$ LANGUAGE plpgsql;
+
+-- taxonomy_path_for returns the deepest node of a subject, category, theme
+-- and subtheme, creating missing nodes on the way
+CREATE OR REPLACE FUNCTION taxonomy_path_for(p_subject text, p_category text, p_theme text, p_subtheme text) RETURNS uuid AS $$
+    SELECT taxonomy_node_for(taxonomy_node_for(taxonomy_node_for(taxonomy_node_for(
+        NULL, 'SUBJECT', p_subject), 'CATEGORY', p_category), 'THEME', p_theme), 'SUBTHEME', p_subtheme)
+$$ LANGUAGE sql;
+
+-- taxonomy_path_titles returns the titles of the path of a node by level
+CREATE OR REPLACE FUNCTION taxonomy_path_titles(p_node uuid, OUT subject text, OUT category text, OUT theme text, OUT subtheme text) AS $$
+    WITH RECURSIVE path AS (
+        SELECT id, parent_id, level, title FROM taxonomy_nodes WHERE id = p_node
+        UNION ALL
+        SELECT n.id, n.parent_id, n.level, n.title
+        FROM taxonomy_nodes n
+        JOIN path p ON n.id = p.parent_id
+    )
+    SELECT max(title) FILTER (WHERE level = 'SUBJECT'),
+           max(title) FILTER (WHERE level = 'CATEGORY'),
+           max(title) FILTER (WHERE level = 'THEME'),
+           max(title) FILTER (WHERE level = 'SUBTHEME')
+    FROM path
+$$ LANGUAGE sql STABLE;
+
+-- The most common combinations are created first, so a node keeps the
+-- spelling used by most of its questions
+DO $$
+DECLARE
+    r record;
+BEGIN
+    FOR r IN
+        SELECT subject_title, category, theme, subtheme
+        FROM questions
+        GROUP BY subject_title, category, theme, subtheme
+        ORDER BY count(*) DESC, subject_title, category, theme, subtheme
+    LOOP
+        PERFORM taxonomy_path_for(r.subject_title, r.category, r.theme, r.subtheme);
+    END LOOP;
+END
+$$;
+
+UPDATE questions SET taxonomy_node_id = taxonomy_path_for(subject_title, category, theme, subtheme);
+
+-- Setting the node of a question copies the titles of its path into the
+-- readable columns. Otherwise, changed titles link the question to their
+-- node, creating it if needed; so do nodes that no longer exist, such as the
+-- nodes of a bundle from another environment or of an old revision.
+CREATE OR REPLACE FUNCTION questions_taxonomy_trigger() RETURNS trigger AS $$
+BEGIN
+    IF NEW.taxonomy_node_id IS NOT NULL
+        AND (TG_OP = 'INSERT' OR NEW.taxonomy_node_id IS DISTINCT FROM OLD.taxonomy_node_id)
+        AND EXISTS (SELECT 1 FROM taxonomy_nodes WHERE id = NEW.taxonomy_node_id) THEN
+        SELECT t.subject, t.category, t.theme, t.subtheme
+        INTO NEW.subject_title, NEW.category, NEW.theme, NEW.subtheme
+        FROM taxonomy_path_titles(NEW.taxonomy_node_id) AS t;
+    ELSIF TG_OP = 'INSERT'
+        OR NEW.taxonomy_node_id IS DISTINCT FROM OLD.taxonomy_node_id
+        OR (NEW.subject_title, NEW.category, NEW.theme, NEW.subtheme)
+            IS DISTINCT FROM (OLD.subject_title, OLD.category, OLD.theme, OLD.subtheme) THEN
+        NEW.taxonomy_node_id := taxonomy_path_for(NEW.subject_title, NEW.category, NEW.theme, NEW.subtheme);
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_questions_taxonomy ON questions;
+CREATE TRIGGER trg_questions_taxonomy
+    BEFORE INSERT OR UPDATE OF taxonomy_node_id, subject_title, category, theme, subtheme ON questions
+    FOR EACH ROW EXECUTE FUNCTION questions_taxonomy_trigger();
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS attempt_questions;
+DROP TABLE IF EXISTS question_reviews;
+
+DROP INDEX IF EXISTS idx_questions_author_id;
+DROP INDEX IF EXISTS idx_questions_status;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_author_id;
+ALTER TABLE questions DROP COLUMN IF EXISTS author_id;
+
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS ck_questions_status;
+ALTER TABLE questions ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE questions ALTER COLUMN status DROP DEFAULT;
@@ -0,0 +1,54 @@
+-- Questions go through an editorial review: draft (0), in review (1),
+-- approved (2) and retired (3). Questions written before the review existed
+-- are already served, so they all start out approved, whatever their legacy
+-- status was.
+
+UPDATE questions SET status = 2;
+ALTER TABLE questions ALTER COLUMN status SET DEFAULT 0;
+ALTER TABLE questions ALTER COLUMN status SET NOT NULL;
+ALTER TABLE questions
+    ADD CONSTRAINT ck_questions_status CHECK (status BETWEEN 0 AND 3);
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS author_id uuid;
+ALTER TABLE questions
+    ADD CONSTRAINT fk_questions_author_id FOREIGN KEY (author_id)
+    REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_questions_status ON questions (status);
+CREATE INDEX IF NOT EXISTS idx_questions_author_id ON questions (author_id);
+
+-- Every transition and comment of a review is kept as its history
+CREATE TABLE IF NOT EXISTS question_reviews (
+    id          uuid PRIMARY KEY,
+    question_id uuid NOT NULL,
+    actor_id    uuid,
+    action      varchar(20) NOT NULL,
+    from_status integer NOT NULL,
+    to_status   integer NOT NULL,
+    comment     text,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_question_reviews_question_id FOREIGN KEY (question_id)
+        REFERENCES questions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_question_reviews_actor_id FOREIGN KEY (actor_id)
+        REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_question_reviews_question_id_created_at ON question_reviews (question_id, created_at);
+
+-- Questions drawn from the approved questions of each test when an attempt
+-- starts, in the order they are delivered
+CREATE TABLE IF NOT EXISTS attempt_questions (
+    completed_test_id uuid NOT NULL,
+    question_id       uuid NOT NULL,
+    test_id           uuid NOT NULL,
+    position          integer NOT NULL,
+    PRIMARY KEY (completed_test_id, question_id),
+    CONSTRAINT fk_attempt_questions_completed_test_id FOREIGN KEY (completed_test_id)
+        REFERENCES completed_tests (id) ON DELETE CASCADE,
+    CONSTRAINT fk_attempt_questions_question_id FOREIGN KEY (question_id)
+        REFERENCES questions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_attempt_questions_test_id FOREIGN KEY (test_id)
+        REFERENCES tests (id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_attempt_questions_question_id ON attempt_questions (question_id);
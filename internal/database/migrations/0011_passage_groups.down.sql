@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_questions_passage_group_id_passage_position;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS fk_questions_passage_group_id;
+ALTER TABLE questions DROP COLUMN IF EXISTS passage_position;
+ALTER TABLE questions DROP COLUMN IF EXISTS passage_group_id;
+
+DROP TABLE IF EXISTS passage_groups;
@@ -0,0 +1,43 @@
+-- Questions of a test sharing a reading passage form a passage group. The
+-- questions of a group are drawn together and delivered in their passage
+-- order, after the passage shown once. Questions already sharing a source
+-- within a test are grouped, in no particular order until an admin orders
+-- them.
+
+CREATE TABLE IF NOT EXISTS passage_groups (
+    id         uuid PRIMARY KEY,
+    test_id    uuid NOT NULL,
+    source_id  uuid NOT NULL,
+    title      varchar(200),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_passage_groups_test_id FOREIGN KEY (test_id)
+        REFERENCES tests (id) ON DELETE CASCADE,
+    CONSTRAINT fk_passage_groups_source_id FOREIGN KEY (source_id)
+        REFERENCES sources (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_passage_groups_test_id ON passage_groups (test_id);
+CREATE INDEX IF NOT EXISTS idx_passage_groups_source_id ON passage_groups (source_id);
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS passage_group_id uuid;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS passage_position integer;
+ALTER TABLE questions
+    ADD CONSTRAINT fk_questions_passage_group_id FOREIGN KEY (passage_group_id)
+    REFERENCES passage_groups (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_questions_passage_group_id_passage_position ON questions (passage_group_id, passage_position);
+
+INSERT INTO passage_groups (id, test_id, source_id)
+SELECT gen_random_uuid(), test_id, source_text_id
+FROM questions
+WHERE source_text_id IS NOT NULL
+GROUP BY test_id, source_text_id;
+
+UPDATE questions q
+SET passage_group_id = g.id, passage_position = p.position
+FROM passage_groups g,
+    (SELECT id, row_number() OVER (PARTITION BY test_id, source_text_id ORDER BY id) AS position
+     FROM questions
+     WHERE source_text_id IS NOT NULL) p
+WHERE p.id = q.id AND g.test_id = q.test_id AND g.source_id = q.source_text_id;
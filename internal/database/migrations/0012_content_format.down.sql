@@ -0,0 +1,2 @@
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS ck_questions_content_format;
+ALTER TABLE questions DROP COLUMN IF EXISTS content_format;
@@ -0,0 +1,7 @@
+-- The texts of a question and its options are plain text, Markdown or a safe
+-- subset of HTML; Markdown and HTML may hold LaTeX math. Existing questions
+-- are plain text.
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS content_format varchar(20) NOT NULL DEFAULT 'PLAIN';
+ALTER TABLE questions
+    ADD CONSTRAINT ck_questions_content_format CHECK (content_format IN ('PLAIN', 'MARKDOWN', 'HTML'));
@@ -0,0 +1,14 @@
+ALTER TABLE completed_questions DROP COLUMN IF EXISTS blank_answers;
+ALTER TABLE completed_questions DROP COLUMN IF EXISTS text_answer;
+ALTER TABLE completed_questions DROP COLUMN IF EXISTS numeric_answer;
+ALTER TABLE completed_questions DROP COLUMN IF EXISTS ordered_option_ids;
+ALTER TABLE completed_questions DROP COLUMN IF EXISTS matched_pairs;
+
+ALTER TABLE options DROP COLUMN IF EXISTS position;
+ALTER TABLE options DROP COLUMN IF EXISTS match_text;
+
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS ck_questions_tolerance;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS ck_questions_question_type;
+ALTER TABLE questions DROP COLUMN IF EXISTS tolerance;
+ALTER TABLE questions DROP COLUMN IF EXISTS numeric_answer;
+ALTER TABLE questions DROP COLUMN IF EXISTS question_type;
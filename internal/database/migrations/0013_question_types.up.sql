@@ -0,0 +1,22 @@
+-- Questions are answered by selecting options, matching, ordering, a number,
+-- a short text or texts for blanks. Existing questions are choice questions.
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS question_type varchar(20) NOT NULL DEFAULT 'CHOICE';
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS numeric_answer double precision;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS tolerance double precision;
+ALTER TABLE questions
+    ADD CONSTRAINT ck_questions_question_type
+        CHECK (question_type IN ('CHOICE', 'MATCHING', 'ORDERING', 'NUMERIC', 'SHORT_TEXT', 'FILL_BLANK')),
+    ADD CONSTRAINT ck_questions_tolerance CHECK (tolerance >= 0);
+
+-- The match text of an option in a matching question, and its place in the
+-- order of an ordering question or the blank it fills
+ALTER TABLE options ADD COLUMN IF NOT EXISTS match_text varchar(2000);
+ALTER TABLE options ADD COLUMN IF NOT EXISTS position integer;
+
+-- Answers other than selected options
+ALTER TABLE completed_questions ADD COLUMN IF NOT EXISTS matched_pairs jsonb;
+ALTER TABLE completed_questions ADD COLUMN IF NOT EXISTS ordered_option_ids jsonb;
+ALTER TABLE completed_questions ADD COLUMN IF NOT EXISTS numeric_answer double precision;
+ALTER TABLE completed_questions ADD COLUMN IF NOT EXISTS text_answer text;
+ALTER TABLE completed_questions ADD COLUMN IF NOT EXISTS blank_answers jsonb;
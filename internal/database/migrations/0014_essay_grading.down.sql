@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS essay_grades;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS fk_products_owner_id;
+ALTER TABLE products DROP COLUMN IF EXISTS owner_id;
+
+-- Essays are kept as short text questions, which have no rubric
+UPDATE questions SET question_type = 'SHORT_TEXT' WHERE question_type = 'ESSAY';
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS ck_questions_question_type;
+ALTER TABLE questions
+    ADD CONSTRAINT ck_questions_question_type
+        CHECK (question_type IN ('CHOICE', 'MATCHING', 'ORDERING', 'NUMERIC', 'SHORT_TEXT', 'FILL_BLANK'));
+ALTER TABLE questions DROP COLUMN IF EXISTS rubric;
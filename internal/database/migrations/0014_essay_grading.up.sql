@@ -0,0 +1,36 @@
+-- Essay questions of teacher products are answered by a free text that a
+-- grader scores on the rubric of the question. An attempt is only scored
+-- once all its essays are graded.
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS rubric jsonb;
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS ck_questions_question_type;
+ALTER TABLE questions
+    ADD CONSTRAINT ck_questions_question_type
+        CHECK (question_type IN ('CHOICE', 'MATCHING', 'ORDERING', 'NUMERIC', 'SHORT_TEXT', 'FILL_BLANK', 'ESSAY'));
+
+-- Teachers grade the essays of the products they created
+ALTER TABLE products ADD COLUMN IF NOT EXISTS owner_id uuid;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS fk_products_owner_id;
+ALTER TABLE products
+    ADD CONSTRAINT fk_products_owner_id FOREIGN KEY (owner_id)
+        REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS essay_grades (
+    id                    uuid PRIMARY KEY,
+    completed_question_id uuid NOT NULL,
+    grader_id             uuid,
+    scores                jsonb NOT NULL,
+    points                integer NOT NULL,
+    max_points            integer NOT NULL,
+    comment               text,
+    created_at            timestamptz NOT NULL DEFAULT now(),
+    updated_at            timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_essay_grades_completed_question_id FOREIGN KEY (completed_question_id)
+        REFERENCES completed_questions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_essay_grades_grader_id FOREIGN KEY (grader_id)
+        REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT ck_essay_grades_points CHECK (points >= 0 AND points <= max_points)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_essay_grades_completed_question_id
+    ON essay_grades (completed_question_id);
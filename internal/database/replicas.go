@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// routingKey is the context key holding the request routing state
+type routingKey struct{}
+
+// routing tracks whether queries made with a context must use the primary
+type routing struct {
+	primary atomic.Bool
+}
+
+// WithReadYourWrites returns a context that remembers writes made with it.
+// Once a write has gone through, later reads using the same context are sent
+// to the primary so a request always sees its own changes despite replica lag.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(routingKey{}).(*routing); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, routingKey{}, &routing{})
+}
+
+// WithPrimary returns a context whose queries always go to the primary
+func WithPrimary(ctx context.Context) context.Context {
+	state := &routing{}
+	state.primary.Store(true)
+	return context.WithValue(ctx, routingKey{}, state)
+}
+
+// usesPrimary reports whether reads made with ctx must go to the primary
+func usesPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	state, ok := ctx.Value(routingKey{}).(*routing)
+	return ok && state.primary.Load()
+}
+
+// markWrite records that a write was made with ctx
+func markWrite(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if state, ok := ctx.Value(routingKey{}).(*routing); ok {
+		state.primary.Store(true)
+	}
+}
+
+// primaryOnly returns a handle on the connection pool of the primary of db
+// without the replica routing, whose queries never leave the primary nor the
+// connection they were given. The handle shares the pool of db and must not
+// be closed on its own.
+func primaryOnly(db *gorm.DB) (*gorm.DB, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	primary, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: db.Logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open primary: %w", err)
+	}
+	return primary, nil
+}
+
+// useReplicas routes reads to the given replicas and keeps writes, and reads
+// that follow a write in the same request, on the primary
+func useReplicas(db *gorm.DB, cfg Config) error {
+	replicas := make([]gorm.Dialector, 0, len(cfg.ReplicaDSNs))
+	for _, dsn := range cfg.ReplicaDSNs {
+		pgxConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return fmt.Errorf("parse replica config: %w", err)
+		}
+		if cfg.StatementTimeout > 0 {
+			pgxConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+		}
+		replicas = append(replicas, postgres.New(postgres.Config{Conn: stdlib.OpenDB(*pgxConfig)}))
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxOpenConns(cfg.MaxOpenConns).
+		SetMaxIdleConns(cfg.MaxIdleConns).
+		SetConnMaxLifetime(cfg.ConnMaxLifetime).
+		SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	if err := db.Use(resolver); err != nil {
+		return fmt.Errorf("register replicas: %w", err)
+	}
+
+	// Send reads to the primary when the context requires it
+	forcePrimary := func(tx *gorm.DB) {
+		if usesPrimary(tx.Statement.Context) {
+			dbresolver.Write.ModifyStatement(tx.Statement)
+		}
+	}
+	if err := db.Callback().Query().Before("gorm:db_resolver").Register("ayatest:read_your_writes", forcePrimary); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:db_resolver").Register("ayatest:read_your_writes", forcePrimary); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:db_resolver").Register("ayatest:read_your_writes", forcePrimary); err != nil {
+		return err
+	}
+
+	// Remember successful writes
+	recordWrite := func(tx *gorm.DB) {
+		if tx.Error == nil {
+			markWrite(tx.Statement.Context)
+		}
+	}
+	if err := db.Callback().Create().After("*").Register("ayatest:record_write", recordWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("*").Register("ayatest:record_write", recordWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("*").Register("ayatest:record_write", recordWrite); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("*").Register("ayatest:record_write", func(tx *gorm.DB) {
+		sql := strings.TrimSpace(tx.Statement.SQL.String())
+		if len(sql) < 6 || !strings.EqualFold(sql[:6], "select") {
+			recordWrite(tx)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+// Package dedup finds duplicate and near-duplicate questions. Questions are
+// compared by the character shingles of their normalized text and option set,
+// estimated through MinHash signatures and paired up by locality sensitive
+// hashing, so a bank is scanned without comparing every pair of questions.
+package dedup
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+const (
+	// ShingleSize is the number of characters in a shingle
+	ShingleSize = 5
+	// NumHashes is the length of a MinHash signature
+	NumHashes = 128
+	// Bands is the number of LSH bands a signature is split into. With 4
+	// rows per band, pairs from about 0.5 similarity up are found reliably.
+	Bands = 32
+	rows  = NumHashes / Bands
+
+	// DefaultThreshold is the similarity from which questions are reported
+	DefaultThreshold = 0.8
+)
+
+// ErrInvalidThreshold is returned for thresholds outside (0, 1]
+var ErrInvalidThreshold = errors.New("duplicate threshold must be above 0 and at most 1")
+
+// seeds of the hash functions of a signature, fixed so signatures are
+// comparable across runs
+var seeds = func() [NumHashes]uint64 {
+	var s [NumHashes]uint64
+	x := uint64(0x2545f4914f6cdd1d)
+	for i := range s {
+		x += 0x9e3779b97f4a7c15
+		s[i] = mix(x)
+	}
+	return s
+}()
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Normalize lowercases text and reduces everything but letters and digits to
+// single spaces
+func Normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// Content returns the normalized text a question is compared by: its texts
+// followed by the texts of its live options in sorted order, so reordered
+// options do not make a difference
+func Content(q *models.Question) string {
+	var parts []string
+	for _, text := range []*string{q.Text, q.Text2, q.Text3} {
+		if text != nil {
+			if n := Normalize(*text); n != "" {
+				parts = append(parts, n)
+			}
+		}
+	}
+
+	options := make([]string, 0, len(q.Options))
+	for _, o := range q.Options {
+		if o.DeletedAt.Valid {
+			continue
+		}
+		options = append(options, Normalize(o.Text))
+	}
+	sort.Strings(options)
+	parts = append(parts, options...)
+	return strings.Join(parts, " | ")
+}
+
+// Signature is the MinHash signature of a content
+type Signature [NumHashes]uint32
+
+// Sign returns the MinHash signature of the shingles of a content. Contents
+// shorter than a shingle are a single shingle.
+func Sign(content string) Signature {
+	var sig Signature
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+
+	runes := []rune(content)
+	n := len(runes) - ShingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		end := i + ShingleSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		h := fnv.New64a()
+		h.Write([]byte(string(runes[i:end])))
+		shingle := h.Sum64()
+		for j := range sig {
+			if v := uint32(mix(shingle ^ seeds[j])); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity of the shingles of two
+// signatures
+func (s *Signature) Similarity(o *Signature) float64 {
+	same := 0
+	for i := range s {
+		if s[i] == o[i] {
+			same++
+		}
+	}
+	return float64(same) / NumHashes
+}
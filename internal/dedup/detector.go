@@ -0,0 +1,160 @@
+package dedup
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// Group is a set of questions linked by pairs at least as similar as the
+// threshold of the detector
+type Group struct {
+	IDs []uuid.UUID
+	// Similarity is the lowest similarity among the linking pairs
+	Similarity float64
+}
+
+// bucket is a band of a signature, hashed together with the band number
+type bucket uint64
+
+// pair is a similar pair of questions by their index in the detector
+type pair struct {
+	a, b       int
+	similarity float64
+}
+
+// Detector collects the signatures of questions and groups the similar ones
+type Detector struct {
+	threshold float64
+	ids       []uuid.UUID
+	sigs      []Signature
+	// exact maps the hash of a content to the first question with it;
+	// copies are paired with that question instead of being signed
+	exact   map[uint64]int
+	copies  []pair
+	buckets map[bucket][]int
+}
+
+// NewDetector creates a detector reporting questions at least as similar as
+// the threshold
+func NewDetector(threshold float64) (*Detector, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, ErrInvalidThreshold
+	}
+	return &Detector{
+		threshold: threshold,
+		exact:     make(map[uint64]int),
+		buckets:   make(map[bucket][]int),
+	}, nil
+}
+
+// Add adds a question to the detector. Questions without text or options
+// are not compared and false is returned for them.
+func (d *Detector) Add(q *models.Question) bool {
+	content := Content(q)
+	if content == "" {
+		return false
+	}
+
+	i := len(d.ids)
+	d.ids = append(d.ids, q.ID)
+	h := fnv.New64a()
+	h.Write([]byte(content))
+	key := h.Sum64()
+	if first, ok := d.exact[key]; ok {
+		d.sigs = append(d.sigs, d.sigs[first])
+		d.copies = append(d.copies, pair{a: first, b: i, similarity: 1})
+		return true
+	}
+	d.exact[key] = i
+
+	sig := Sign(content)
+	d.sigs = append(d.sigs, sig)
+	var band [rows * 4]byte
+	for b := 0; b < Bands; b++ {
+		for r := 0; r < rows; r++ {
+			binary.LittleEndian.PutUint32(band[r*4:], sig[b*rows+r])
+		}
+		h := fnv.New64a()
+		h.Write([]byte{byte(b)})
+		h.Write(band[:])
+		k := bucket(h.Sum64())
+		d.buckets[k] = append(d.buckets[k], i)
+	}
+	return true
+}
+
+// Len returns the number of questions compared
+func (d *Detector) Len() int {
+	return len(d.ids)
+}
+
+// Groups returns the groups of similar questions, largest first. Questions
+// of a group are in the order they were added.
+func (d *Detector) Groups() []Group {
+	pairs := append([]pair(nil), d.copies...)
+	seen := make(map[[2]int]bool)
+	for _, members := range d.buckets {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				a, b := members[x], members[y]
+				if seen[[2]int{a, b}] {
+					continue
+				}
+				seen[[2]int{a, b}] = true
+				if s := d.sigs[a].Similarity(&d.sigs[b]); s >= d.threshold {
+					pairs = append(pairs, pair{a: a, b: b, similarity: s})
+				}
+			}
+		}
+	}
+
+	parent := make([]int, len(d.ids))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, p := range pairs {
+		if ra, rb := find(p.a), find(p.b); ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	lowest := make(map[int]float64)
+	for _, p := range pairs {
+		root := find(p.a)
+		if s, ok := lowest[root]; !ok || p.similarity < s {
+			lowest[root] = p.similarity
+		}
+	}
+	members := make(map[int][]uuid.UUID)
+	for i, id := range d.ids {
+		if _, ok := lowest[find(i)]; ok {
+			members[find(i)] = append(members[find(i)], id)
+		}
+	}
+
+	groups := make([]Group, 0, len(members))
+	for root, ids := range members {
+		groups = append(groups, Group{IDs: ids, Similarity: lowest[root]})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].IDs) != len(groups[j].IDs) {
+			return len(groups[i].IDs) > len(groups[j].IDs)
+		}
+		if groups[i].Similarity != groups[j].Similarity {
+			return groups[i].Similarity > groups[j].Similarity
+		}
+		return groups[i].IDs[0].String() < groups[j].IDs[0].String()
+	})
+	return groups
+}
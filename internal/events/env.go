@@ -0,0 +1,36 @@
+package events
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// FromEnv creates the publisher selected by the EVENT_PUBLISHER environment
+// variable, connecting to NATS_URL for the NATS driver. The returned close
+// function releases the connection of the publisher.
+func FromEnv(logger *zap.SugaredLogger) (Publisher, func(), error) {
+	switch driver := os.Getenv("EVENT_PUBLISHER"); driver {
+	case "", DriverNATS:
+		natsURL := os.Getenv("NATS_URL")
+		if natsURL == "" {
+			natsURL = nats.DefaultURL
+		}
+		nc, err := nats.Connect(natsURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("connect to NATS: %w", err)
+		}
+		logger.Infow("Connected to NATS", "url", natsURL)
+		return NewNATSPublisher(nc, logger), nc.Close, nil
+	case DriverMemory:
+		logger.Infow("Using in-memory event publisher")
+		return NewMemoryPublisher(), func() {}, nil
+	case DriverNoop:
+		logger.Infow("Event publishing disabled")
+		return NewNoopPublisher(), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown event publisher %q", driver)
+	}
+}
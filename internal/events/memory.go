@@ -0,0 +1,167 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// Event is an event recorded by the in-memory publisher
+type Event struct {
+	Subject string
+	Data    []byte
+}
+
+// Decode unmarshals the event payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Handler receives the events published on a subject it subscribed to
+type Handler func(Event)
+
+// MemoryPublisher implements the Publisher interface by recording events in
+// process and passing them to the handlers subscribed to their subject
+type MemoryPublisher struct {
+	mu          sync.Mutex
+	events      []Event
+	subscribers map[string][]Handler
+}
+
+// NewMemoryPublisher creates a new in-memory publisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Events returns a copy of all recorded events in publish order
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events := make([]Event, len(p.events))
+	copy(events, p.events)
+	return events
+}
+
+// EventsFor returns the recorded events published on the given subject
+func (p *MemoryPublisher) EventsFor(subject string) []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var events []Event
+	for _, event := range p.events {
+		if event.Subject == subject {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Subscribe calls handler with every event published on subject from now on,
+// in publish order, like a NATS subscription. An empty subject receives the
+// events of every subject.
+func (p *MemoryPublisher) Subscribe(subject string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.subscribers == nil {
+		p.subscribers = make(map[string][]Handler)
+	}
+	p.subscribers[subject] = append(p.subscribers[subject], handler)
+}
+
+// Reset discards all recorded events
+func (p *MemoryPublisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = nil
+}
+
+// record marshals the payload the same way the NATS publisher does, stores
+// it and passes it to the subscribers of its subject
+func (p *MemoryPublisher) record(subject string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	event := Event{Subject: subject, Data: data}
+
+	p.mu.Lock()
+	p.events = append(p.events, event)
+	var handlers []Handler
+	handlers = append(handlers, p.subscribers[subject]...)
+	handlers = append(handlers, p.subscribers[""]...)
+	p.mu.Unlock()
+
+	// Handlers run outside the lock so they may publish themselves
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return nil
+}
+
+// PublishProductCreated records a product created event
+func (p *MemoryPublisher) PublishProductCreated(product *models.Product) error {
+	return p.record(EventProductCreated, product)
+}
+
+// PublishProductUpdated records a product updated event
+func (p *MemoryPublisher) PublishProductUpdated(product *models.Product) error {
+	return p.record(EventProductUpdated, product)
+}
+
+// PublishProductDeleted records a product deleted event
+func (p *MemoryPublisher) PublishProductDeleted(id uuid.UUID) error {
+	return p.record(EventProductDeleted, map[string]string{"id": id.String()})
+}
+
+// PublishTestCreated records a test created event
+func (p *MemoryPublisher) PublishTestCreated(test *models.Test) error {
+	return p.record(EventTestCreated, test)
+}
+
+// PublishTestUpdated records a test updated event
+func (p *MemoryPublisher) PublishTestUpdated(test *models.Test) error {
+	return p.record(EventTestUpdated, test)
+}
+
+// PublishTestDeleted records a test deleted event
+func (p *MemoryPublisher) PublishTestDeleted(id uuid.UUID) error {
+	return p.record(EventTestDeleted, map[string]string{"id": id.String()})
+}
+
+// PublishQuestionCreated records a question created event
+func (p *MemoryPublisher) PublishQuestionCreated(question *models.Question) error {
+	return p.record(EventQuestionCreated, question)
+}
+
+// PublishTestStarted records a test started event
+func (p *MemoryPublisher) PublishTestStarted(completedTest *models.CompletedTest) error {
+	return p.record(EventTestStarted, completedTest)
+}
+
+// PublishQuestionAnswered records a question answered event
+func (p *MemoryPublisher) PublishQuestionAnswered(completedQuestion *models.CompletedQuestion) error {
+	return p.record(EventQuestionAnswered, completedQuestion)
+}
+
+// PublishTestCompleted records a test completed event
+func (p *MemoryPublisher) PublishTestCompleted(completedTest *models.CompletedTest) error {
+	return p.record(EventTestCompleted, completedTest)
+}
+
+// PublishResultRegraded records a result regraded event
+func (p *MemoryPublisher) PublishResultRegraded(result *models.RegradeResult) error {
+	return p.record(EventResultRegraded, result)
+}
+
+// PublishReviewRequested records a review requested event
+func (p *MemoryPublisher) PublishReviewRequested(notice *models.ReviewNotice) error {
+	return p.record(EventReviewRequested, notice)
+}
+
+// PublishQuestionReviewed records a question reviewed event
+func (p *MemoryPublisher) PublishQuestionReviewed(notice *models.ReviewNotice) error {
+	return p.record(EventQuestionReviewed, notice)
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestMemoryPublisherDeliversToSubscribers(t *testing.T) {
+	p := NewMemoryPublisher()
+
+	var deleted, all []Event
+	p.Subscribe(EventProductDeleted, func(e Event) { deleted = append(deleted, e) })
+	p.Subscribe("", func(e Event) { all = append(all, e) })
+
+	id := uuid.New()
+	if err := p.PublishProductCreated(&models.Product{ID: uuid.New()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishProductDeleted(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 1 {
+		t.Fatalf("subscriber of %s got %d events, want 1", EventProductDeleted, len(deleted))
+	}
+	var payload map[string]string
+	if err := deleted[0].Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["id"] != id.String() {
+		t.Errorf("deleted id = %q, want %q", payload["id"], id)
+	}
+
+	if len(all) != 2 || all[0].Subject != EventProductCreated || all[1].Subject != EventProductDeleted {
+		t.Errorf("subscriber of every subject got %v, want both events in publish order", all)
+	}
+}
+
+func TestMemoryPublisherRecordsEvents(t *testing.T) {
+	p := NewMemoryPublisher()
+
+	result := &models.RegradeResult{ID: uuid.New(), NewScore: 7}
+	if err := p.PublishResultRegraded(result); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishTestDeleted(uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(p.Events()); got != 2 {
+		t.Fatalf("recorded %d events, want 2", got)
+	}
+	regraded := p.EventsFor(EventResultRegraded)
+	if len(regraded) != 1 {
+		t.Fatalf("recorded %d %s events, want 1", len(regraded), EventResultRegraded)
+	}
+	var decoded models.RegradeResult
+	if err := regraded[0].Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != result.ID || decoded.NewScore != result.NewScore {
+		t.Errorf("decoded %+v, want %+v", decoded, result)
+	}
+
+	p.Reset()
+	if got := len(p.Events()); got != 0 {
+		t.Errorf("recorded %d events after Reset, want 0", got)
+	}
+}
+
+func TestMemoryPublisherHandlerMayPublish(t *testing.T) {
+	p := NewMemoryPublisher()
+
+	p.Subscribe(EventTestCompleted, func(e Event) {
+		if err := p.PublishResultRegraded(&models.RegradeResult{}); err != nil {
+			t.Error(err)
+		}
+	})
+	if err := p.PublishTestCompleted(&models.CompletedTest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(p.EventsFor(EventResultRegraded)); got != 1 {
+		t.Errorf("handler published %d events, want 1", got)
+	}
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// NoopPublisher implements the Publisher interface by discarding every event
+type NoopPublisher struct{}
+
+// NewNoopPublisher creates a new publisher that discards events
+func NewNoopPublisher() Publisher {
+	return NoopPublisher{}
+}
+
+// PublishProductCreated discards a product created event
+func (NoopPublisher) PublishProductCreated(product *models.Product) error { return nil }
+
+// PublishProductUpdated discards a product updated event
+func (NoopPublisher) PublishProductUpdated(product *models.Product) error { return nil }
+
+// PublishProductDeleted discards a product deleted event
+func (NoopPublisher) PublishProductDeleted(id uuid.UUID) error { return nil }
+
+// PublishTestCreated discards a test created event
+func (NoopPublisher) PublishTestCreated(test *models.Test) error { return nil }
+
+// PublishTestUpdated discards a test updated event
+func (NoopPublisher) PublishTestUpdated(test *models.Test) error { return nil }
+
+// PublishTestDeleted discards a test deleted event
+func (NoopPublisher) PublishTestDeleted(id uuid.UUID) error { return nil }
+
+// PublishQuestionCreated discards a question created event
+func (NoopPublisher) PublishQuestionCreated(question *models.Question) error { return nil }
+
+// PublishTestStarted discards a test started event
+func (NoopPublisher) PublishTestStarted(completedTest *models.CompletedTest) error { return nil }
+
+// PublishQuestionAnswered discards a question answered event
+func (NoopPublisher) PublishQuestionAnswered(completedQuestion *models.CompletedQuestion) error {
+	return nil
+}
+
+// PublishTestCompleted discards a test completed event
+func (NoopPublisher) PublishTestCompleted(completedTest *models.CompletedTest) error { return nil }
+
+// PublishResultRegraded discards a result regraded event
+func (NoopPublisher) PublishResultRegraded(result *models.RegradeResult) error { return nil }
+
+// PublishReviewRequested discards a review requested event
+func (NoopPublisher) PublishReviewRequested(notice *models.ReviewNotice) error { return nil }
+
+// PublishQuestionReviewed discards a question reviewed event
+func (NoopPublisher) PublishQuestionReviewed(notice *models.ReviewNotice) error { return nil }
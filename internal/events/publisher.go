@@ -17,9 +17,20 @@ const (
 	EventTestCreated      = "test.created"
 	EventTestUpdated      = "test.updated"
 	EventTestDeleted      = "test.deleted"
+	EventQuestionCreated  = "question.created"
 	EventTestStarted      = "test.started"
 	EventQuestionAnswered = "question.answered"
 	EventTestCompleted    = "test.completed"
+	EventResultRegraded   = "result.regraded"
+	EventReviewRequested  = "question.review_requested"
+	EventQuestionReviewed = "question.reviewed"
+)
+
+// Publisher drivers selectable via the EVENT_PUBLISHER environment variable
+const (
+	DriverNATS   = "nats"
+	DriverMemory = "memory"
+	DriverNoop   = "noop"
 )
 
 // Publisher defines the interface for publishing events
@@ -30,9 +41,13 @@ type Publisher interface {
 	PublishTestCreated(test *models.Test) error
 	PublishTestUpdated(test *models.Test) error
 	PublishTestDeleted(id uuid.UUID) error
+	PublishQuestionCreated(question *models.Question) error
 	PublishTestStarted(completedTest *models.CompletedTest) error
 	PublishQuestionAnswered(completedQuestion *models.CompletedQuestion) error
 	PublishTestCompleted(completedTest *models.CompletedTest) error
+	PublishResultRegraded(result *models.RegradeResult) error
+	PublishReviewRequested(notice *models.ReviewNotice) error
+	PublishQuestionReviewed(notice *models.ReviewNotice) error
 }
 
 // NATSPublisher implements the Publisher interface using NATS
@@ -103,6 +118,15 @@ func (p *NATSPublisher) PublishTestDeleted(id uuid.UUID) error {
 	return p.nc.Publish(EventTestDeleted, data)
 }
 
+// PublishQuestionCreated publishes a question created event
+func (p *NATSPublisher) PublishQuestionCreated(question *models.Question) error {
+	data, err := json.Marshal(question)
+	if err != nil {
+		return err
+	}
+	return p.nc.Publish(EventQuestionCreated, data)
+}
+
 // PublishTestStarted publishes a test started event
 func (p *NATSPublisher) PublishTestStarted(completedTest *models.CompletedTest) error {
 	data, err := json.Marshal(completedTest)
@@ -129,3 +153,30 @@ func (p *NATSPublisher) PublishTestCompleted(completedTest *models.CompletedTest
 	}
 	return p.nc.Publish(EventTestCompleted, data)
 }
+
+// PublishResultRegraded publishes a result regraded event
+func (p *NATSPublisher) PublishResultRegraded(result *models.RegradeResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return p.nc.Publish(EventResultRegraded, data)
+}
+
+// PublishReviewRequested publishes a review requested event
+func (p *NATSPublisher) PublishReviewRequested(notice *models.ReviewNotice) error {
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	return p.nc.Publish(EventReviewRequested, data)
+}
+
+// PublishQuestionReviewed publishes a question reviewed event
+func (p *NATSPublisher) PublishQuestionReviewed(notice *models.ReviewNotice) error {
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	return p.nc.Publish(EventQuestionReviewed, data)
+}
@@ -0,0 +1,341 @@
+// Package gift reads and writes questions in the Moodle GIFT format.
+//
+// Multiple choice questions (one or several correct answers) and true/false
+// questions are supported. Titles and feedback are ignored, $CATEGORY lines
+// set the category of the questions that follow and text after the answer
+// block of a missing word question is kept with a blank in its place.
+package gift
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Entry is a question read from a GIFT file
+type Entry struct {
+	// Line is the line the question starts on
+	Line     int
+	Question *models.Question
+}
+
+// Error is a question of a GIFT file that could not be read
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// blank replaces the answer block of a missing word question
+const blank = "_____"
+
+// Parse reads the questions of a GIFT file. Questions that cannot be read
+// are reported as errors and left out.
+func Parse(r io.Reader) ([]Entry, []Error, error) {
+	var entries []Entry
+	var errs []Error
+	var category *string
+
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, b := range blocks {
+		if strings.HasPrefix(b.text, "$CATEGORY:") {
+			c := parseCategory(strings.TrimPrefix(b.text, "$CATEGORY:"))
+			category = &c
+			if c == "" {
+				category = nil
+			}
+			continue
+		}
+		q, err := parseQuestion(b.text)
+		if err != nil {
+			errs = append(errs, Error{Line: b.line, Message: err.Error()})
+			continue
+		}
+		q.Category = category
+		entries = append(entries, Entry{Line: b.line, Question: q})
+	}
+	return entries, errs, nil
+}
+
+// block is the text of a question or command with its first line
+type block struct {
+	line int
+	text string
+}
+
+// readBlocks splits a GIFT file into blocks separated by blank lines,
+// dropping comment lines. Blank lines inside an answer block do not end the
+// block.
+func readBlocks(r io.Reader) ([]block, error) {
+	var blocks []block
+	var cur []string
+	start, depth := 0, 0
+	flush := func() {
+		if len(cur) > 0 {
+			blocks = append(blocks, block{line: start, text: strings.Join(cur, "\n")})
+		}
+		cur = nil
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "//"):
+			continue
+		case trimmed == "" && depth == 0:
+			flush()
+			continue
+		case strings.HasPrefix(trimmed, "$CATEGORY:") && depth == 0:
+			// A category applies to the blocks after it even without a blank line
+			flush()
+			blocks = append(blocks, block{line: n, text: trimmed})
+			continue
+		}
+		if len(cur) == 0 {
+			start = n
+		}
+		cur = append(cur, line)
+		depth += braceDepth(line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return blocks, nil
+}
+
+// braceDepth returns the change in answer block nesting over a line
+func braceDepth(line string) int {
+	depth := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// parseCategory returns the category of a $CATEGORY path without the
+// $course$ and top levels Moodle adds
+func parseCategory(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$course$/")
+	path = strings.TrimPrefix(path, "top/")
+	return strings.TrimSpace(path)
+}
+
+// markupFormat matches the format marker before the question text
+var markupFormat = regexp.MustCompile(`^\[(html|moodle|plain|markdown)\]`)
+
+// parseQuestion parses the text of a question block
+func parseQuestion(text string) (*models.Question, error) {
+	text = strings.TrimSpace(text)
+
+	// Skip the ::title::
+	if strings.HasPrefix(text, "::") {
+		end := indexUnescaped(text[2:], "::")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated question title")
+		}
+		text = strings.TrimSpace(text[end+4:])
+	}
+	isHTML := false
+	if m := markupFormat.FindStringSubmatch(text); m != nil {
+		isHTML = m[1] == "html"
+		text = text[len(m[0]):]
+	}
+
+	open := indexUnescaped(text, "{")
+	if open < 0 {
+		return nil, fmt.Errorf("question has no answer block")
+	}
+	closing := indexUnescaped(text[open:], "}")
+	if closing < 0 {
+		return nil, fmt.Errorf("answer block is not closed")
+	}
+	closing += open
+	answers := strings.TrimSpace(text[open+1 : closing])
+
+	questionText := strings.TrimSpace(text[:open])
+	if after := strings.TrimSpace(text[closing+1:]); after != "" {
+		questionText += " " + blank + " " + after
+	}
+	questionText = strings.TrimSpace(unescape(questionText))
+	if isHTML {
+		questionText = stripHTML(questionText)
+	}
+	if questionText == "" {
+		return nil, fmt.Errorf("question has no text")
+	}
+
+	options, err := parseAnswers(answers, isHTML)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Question{Text: &questionText, Options: options}, nil
+}
+
+// parseAnswers parses the content of an answer block into options
+func parseAnswers(answers string, isHTML bool) ([]models.Option, error) {
+	if answers == "" {
+		return nil, fmt.Errorf("essay questions are not supported")
+	}
+	if strings.HasPrefix(answers, "#") {
+		return nil, fmt.Errorf("numerical questions are not supported")
+	}
+	key := strings.TrimSpace(answers)
+	if i := indexUnescaped(key, "#"); i >= 0 {
+		key = strings.TrimSpace(key[:i])
+	}
+	switch strings.ToUpper(key) {
+	case "T", "TRUE":
+		return []models.Option{{Text: "True", IsCorrect: true}, {Text: "False"}}, nil
+	case "F", "FALSE":
+		return []models.Option{{Text: "True"}, {Text: "False", IsCorrect: true}}, nil
+	}
+
+	var options []models.Option
+	wrong := 0
+	for _, a := range splitAnswers(answers) {
+		if indexUnescaped(a.text, "->") >= 0 {
+			return nil, fmt.Errorf("matching questions are not supported")
+		}
+		text := a.text
+		if i := indexUnescaped(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		correct := a.marker == '='
+		if m := weight.FindStringSubmatch(text); m != nil {
+			w, _ := strconv.ParseFloat(m[1], 64)
+			correct = w > 0
+			text = text[len(m[0]):]
+		}
+		text = strings.TrimSpace(unescape(text))
+		if isHTML {
+			text = stripHTML(text)
+		}
+		if a.marker == '~' {
+			wrong++
+		}
+		options = append(options, models.Option{Text: text, IsCorrect: correct})
+	}
+	switch {
+	case len(options) == 0:
+		return nil, fmt.Errorf("answer block has no answers")
+	case wrong == 0:
+		return nil, fmt.Errorf("short answer questions are not supported")
+	case len(options) < 2:
+		return nil, fmt.Errorf("question needs at least two answers")
+	}
+	for _, o := range options {
+		if o.IsCorrect {
+			return options, nil
+		}
+	}
+	return nil, fmt.Errorf("question has no correct answer")
+}
+
+// weight matches the %percent% weight of an answer
+var weight = regexp.MustCompile(`^\s*%(-?[0-9]+(?:\.[0-9]+)?)%`)
+
+// answer is an answer of an answer block with its = or ~ marker
+type answer struct {
+	marker byte
+	text   string
+}
+
+// splitAnswers splits an answer block at its unescaped = and ~ markers
+func splitAnswers(answers string) []answer {
+	var list []answer
+	for i := 0; i < len(answers); i++ {
+		switch c := answers[i]; c {
+		case '\\':
+			if len(list) > 0 {
+				list[len(list)-1].text += answers[i : i+min(2, len(answers)-i)]
+			}
+			i++
+		case '=', '~':
+			list = append(list, answer{marker: c})
+		default:
+			if len(list) > 0 {
+				list[len(list)-1].text += string(c)
+			}
+		}
+	}
+	return list
+}
+
+// indexUnescaped returns the index of the first occurrence of sub in s that
+// is not preceded by a backslash, or -1
+func indexUnescaped(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape resolves the backslash escapes of GIFT text
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+var (
+	lineBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+	tag       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// stripHTML converts [html] text to plain text
+func stripHTML(s string) string {
+	s = lineBreak.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(tag.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
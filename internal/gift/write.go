@@ -0,0 +1,108 @@
+package gift
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Write writes questions in the GIFT format and returns the questions it
+// left out: those without text, such as questions with only an image,
+// without a correct option or with fewer than two options, which GIFT
+// cannot read back as choice questions. Text2 and Text3 are appended to the question text as
+// further lines. Questions with several correct options are written with
+// answer weights that share the full mark between the correct options and
+// take it all for a wrong one.
+func Write(w io.Writer, questions []*models.Question) ([]*models.Question, error) {
+	bw := bufio.NewWriter(w)
+	var skipped []*models.Question
+	var category string
+	written := 0
+	for _, q := range questions {
+		text := questionText(q)
+		correct := 0
+		for _, o := range q.Options {
+			if o.IsCorrect {
+				correct++
+			}
+		}
+		if text == "" || correct == 0 || len(q.Options) < 2 {
+			skipped = append(skipped, q)
+			continue
+		}
+
+		if written > 0 {
+			bw.WriteString("\n")
+		}
+		if c := deref(q.Category); c != category {
+			fmt.Fprintf(bw, "$CATEGORY: %s\n\n", c)
+			category = c
+		}
+		fmt.Fprintf(bw, "%s {\n", escapeQuestion(text))
+		for _, o := range q.Options {
+			switch {
+			case correct == 1 && o.IsCorrect:
+				fmt.Fprintf(bw, "\t=%s\n", escape(o.Text))
+			case correct <= 1:
+				fmt.Fprintf(bw, "\t~%s\n", escape(o.Text))
+			case o.IsCorrect:
+				fmt.Fprintf(bw, "\t~%%%s%%%s\n", formatWeight(100/float64(correct)), escape(o.Text))
+			default:
+				fmt.Fprintf(bw, "\t~%%-100%%%s\n", escape(o.Text))
+			}
+		}
+		bw.WriteString("}\n")
+		written++
+	}
+	return skipped, bw.Flush()
+}
+
+// formatWeight formats an answer weight with the five decimals Moodle
+// matches its grades against
+func formatWeight(w float64) string {
+	s := strconv.FormatFloat(w, 'f', 5, 64)
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
+
+// questionText returns the text fields of a question as one text
+func questionText(q *models.Question) string {
+	var parts []string
+	for _, t := range []*string{q.Text, q.Text2, q.Text3} {
+		if s := strings.TrimSpace(deref(t)); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// escaper escapes the characters that have a meaning in GIFT
+var escaper = strings.NewReplacer(
+	`\`, `\\`, `~`, `\~`, `=`, `\=`, `#`, `\#`, `{`, `\{`, `}`, `\}`, `:`, `\:`,
+	"\r\n", `\n`, "\n", `\n`,
+)
+
+// escape escapes text for GIFT, keeping line breaks as \n
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// escapeQuestion escapes question text for GIFT. A leading // would be read
+// as a comment and a leading [ as a format marker, so they are escaped too.
+func escapeQuestion(s string) string {
+	s = escape(s)
+	if strings.HasPrefix(s, "//") || strings.HasPrefix(s, "[") {
+		s = `\` + s
+	}
+	return s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,88 @@
+package gift
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+func str(s string) *string { return &s }
+
+func TestWriteRoundTrip(t *testing.T) {
+	questions := []*models.Question{
+		{
+			Text: str("What is 2 + 2?"),
+			Options: []models.Option{
+				{Text: "3"}, {Text: "4", IsCorrect: true}, {Text: "5"},
+			},
+		},
+		{
+			Text:     str("Pick the primes"),
+			Category: str("Maths"),
+			Options: []models.Option{
+				{Text: "2", IsCorrect: true}, {Text: "3", IsCorrect: true}, {Text: "4"},
+			},
+		},
+		{
+			Text:     str("Escape {this} = ~that~ #1: a\\b\nsecond line"),
+			Category: str("Maths"),
+			Options: []models.Option{
+				{Text: "a = b", IsCorrect: true}, {Text: "{c} ~ #d"},
+			},
+		},
+		{
+			Text:     str("// not a comment"),
+			Category: str("Tricky"),
+			Options:  []models.Option{{Text: "yes", IsCorrect: true}, {Text: "no"}},
+		},
+		{
+			Text:     str("[html] is not a format here"),
+			Category: str("Tricky"),
+			Options:  []models.Option{{Text: "yes", IsCorrect: true}, {Text: "no"}},
+		},
+	}
+	unwritable := []*models.Question{
+		{ImgPath: str("diagram.png"), Options: []models.Option{{Text: "a", IsCorrect: true}, {Text: "b"}}},
+		{Text: str("No correct option"), Options: []models.Option{{Text: "a"}, {Text: "b"}}},
+		{Text: str("One option"), Options: []models.Option{{Text: "a", IsCorrect: true}}},
+	}
+
+	var buf bytes.Buffer
+	skipped, err := Write(&buf, append(append([]*models.Question{}, questions...), unwritable...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != len(unwritable) {
+		t.Errorf("skipped %d questions, want %d", len(skipped), len(unwritable))
+	}
+
+	entries, errs, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("reading back the export failed: %v\n%s", errs, buf.String())
+	}
+	if len(entries) != len(questions) {
+		t.Fatalf("read back %d questions, want %d\n%s", len(entries), len(questions), buf.String())
+	}
+	for i, e := range entries {
+		want := questions[i]
+		if *e.Question.Text != *want.Text {
+			t.Errorf("question %d text = %q, want %q", i, *e.Question.Text, *want.Text)
+		}
+		if deref(e.Question.Category) != deref(want.Category) {
+			t.Errorf("question %d category = %q, want %q", i, deref(e.Question.Category), deref(want.Category))
+		}
+		if len(e.Question.Options) != len(want.Options) {
+			t.Errorf("question %d has %d options, want %d", i, len(e.Question.Options), len(want.Options))
+			continue
+		}
+		for j, o := range e.Question.Options {
+			if o.Text != want.Options[j].Text || o.IsCorrect != want.Options[j].IsCorrect {
+				t.Errorf("question %d option %d = %+v, want %+v", i, j, o, want.Options[j])
+			}
+		}
+	}
+}
@@ -6,33 +6,31 @@ import (
 
 	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
 	"github.com/Alan69/ayatest/internal/workflows"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
 )
 
-// GetCompletedTests returns all completed tests for a user
-func (r *queryResolver) CompletedTests(ctx context.Context, userID uuid.UUID) ([]*models.CompletedTest, error) {
-	var completedTests []*models.CompletedTest
-	result := database.DB.Where("user_id = ?", userID).Find(&completedTests)
-	if result.Error != nil {
-		return nil, result.Error
+// CompletedTests returns a page of the completed tests of a user
+func (r *queryResolver) CompletedTests(ctx context.Context, userID uuid.UUID, filter *models.CompletedTestFilter, sort *models.CompletedTestSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.CompletedTest], error) {
+	var f models.CompletedTestFilter
+	if filter != nil {
+		f = *filter
 	}
-	return completedTests, nil
+	return r.AttemptRepo.PageByUser(ctx, userID, f, sort, pagination.Args{First: first, After: after, Last: last, Before: before})
 }
 
 // CompletedTest returns a completed test by ID
 func (r *queryResolver) CompletedTest(ctx context.Context, id uuid.UUID) (*models.CompletedTest, error) {
-	var completedTest models.CompletedTest
-	result := database.DB.First(&completedTest, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return &completedTest, nil
+	return r.AttemptRepo.Get(ctx, id)
 }
 
 // StartTest starts a new test for a user
 func (r *mutationResolver) StartTest(ctx context.Context, input models.StartTestInput) (*models.CompletedTest, error) {
+	// Attempts are always read from and written to the primary
+	ctx = database.WithPrimary(ctx)
+
 	// Create a new completed test
 	now := time.Now()
 	completedTest := &models.CompletedTest{
@@ -41,28 +39,14 @@ func (r *mutationResolver) StartTest(ctx context.Context, input models.StartTest
 		StartTestTime: &now,
 	}
 
-	// Start a transaction
-	tx := database.DB.Begin()
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-
-	// Create the completed test
-	if err := tx.Create(completedTest).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
-	// Add the tests to the completed test
-	for _, testID := range input.TestIDs {
-		if err := tx.Model(completedTest).Association("Tests").Append(&models.Test{ID: testID}); err != nil {
-			tx.Rollback()
-			return nil, err
+	// Create the completed test and add the tests to it
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.AttemptRepo.Start(ctx, completedTest, input.TestIDs); err != nil {
+			return err
 		}
-	}
-
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
+		return tx.recordAudit(ctx, models.AuditStart, models.AuditEntityCompletedTest, completedTest.ID, nil, completedTest)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -74,8 +58,8 @@ func (r *mutationResolver) StartTest(ctx context.Context, input models.StartTest
 
 	// Get the maximum time from all tests
 	var maxTime int = 0
-	var tests []*models.Test
-	if err := database.DB.Where("id IN ?", input.TestIDs).Find(&tests).Error; err != nil {
+	tests, err := r.TestRepo.GetMany(ctx, input.TestIDs)
+	if err != nil {
 		return nil, err
 	}
 	for _, test := range tests {
@@ -90,7 +74,7 @@ func (r *mutationResolver) StartTest(ctx context.Context, input models.StartTest
 	}
 
 	// Start the workflow
-	_, err := r.TemporalClient.ExecuteWorkflow(
+	_, err = r.TemporalClient.ExecuteWorkflow(
 		context.Background(),
 		workflowOptions,
 		workflows.TestTimerWorkflow,
@@ -115,35 +99,36 @@ func (r *mutationResolver) StartTest(ctx context.Context, input models.StartTest
 
 // AnswerQuestion records a user's answer to a question
 func (r *mutationResolver) AnswerQuestion(ctx context.Context, input models.AnswerQuestionInput) (*models.CompletedQuestion, error) {
+	// Attempts are always read from and written to the primary
+	ctx = database.WithPrimary(ctx)
+
 	// Create a new completed question
 	completedQuestion := &models.CompletedQuestion{
 		CompletedTestID: input.CompletedTestID,
 		TestID:          input.TestID,
 		QuestionID:      &input.QuestionID,
+		NumericAnswer:   input.NumericAnswer,
+		TextAnswer:      input.TextAnswer,
 	}
-
-	// Start a transaction
-	tx := database.DB.Begin()
-	if tx.Error != nil {
-		return nil, tx.Error
+	if input.MatchedPairs != nil {
+		completedQuestion.MatchedPairs = models.MatchPairs(input.MatchedPairs)
 	}
-
-	// Create the completed question
-	if err := tx.Create(completedQuestion).Error; err != nil {
-		tx.Rollback()
-		return nil, err
+	if input.OrderedOptionIDs != nil {
+		completedQuestion.OrderedOptionIDs = models.OptionIDs(input.OrderedOptionIDs)
 	}
-
-	// Add the selected options to the completed question
-	for _, optionID := range input.SelectedOptionIDs {
-		if err := tx.Model(completedQuestion).Association("SelectedOptions").Append(&models.Option{ID: optionID}); err != nil {
-			tx.Rollback()
-			return nil, err
-		}
+	if input.BlankAnswers != nil {
+		completedQuestion.BlankAnswers = models.BlankAnswers(input.BlankAnswers)
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
+	// Create the completed question with its answer and add the selected
+	// options to it
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.AttemptRepo.RecordAnswer(ctx, completedQuestion, input.SelectedOptionIDs); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditAnswer, models.AuditEntityCompletedQuestion, completedQuestion.ID, nil, input)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -157,28 +142,38 @@ func (r *mutationResolver) AnswerQuestion(ctx context.Context, input models.Answ
 
 // CompleteTest completes a test
 func (r *mutationResolver) CompleteTest(ctx context.Context, input models.CompleteTestInput) (*models.CompletedTest, error) {
+	// Attempts are always read from and written to the primary
+	ctx = database.WithPrimary(ctx)
+
 	// Get the completed test
-	var completedTest models.CompletedTest
-	if err := database.DB.First(&completedTest, "id = ?", input.CompletedTestID).Error; err != nil {
+	completedTest, err := r.AttemptRepo.Get(ctx, input.CompletedTestID)
+	if err != nil {
 		return nil, err
 	}
+	before := *completedTest
 
 	// Update the time spent
 	completedTest.TimeSpent = &input.TimeSpent
 
 	// Save the completed test
-	if err := database.DB.Save(&completedTest).Error; err != nil {
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.AttemptRepo.Update(ctx, completedTest); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditComplete, models.AuditEntityCompletedTest, completedTest.ID, &before, completedTest)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// Start the auto-check workflow
 	workflowOptions := client.StartWorkflowOptions{
-		ID:        "test-autocheck-" + completedTest.ID.String(),
+		ID:        workflows.AutoCheckWorkflowID(completedTest.ID),
 		TaskQueue: workflows.TestTaskQueue,
 	}
 
 	// Start the workflow
-	_, err := r.TemporalClient.ExecuteWorkflow(
+	_, err = r.TemporalClient.ExecuteWorkflow(
 		context.Background(),
 		workflowOptions,
 		workflows.AutoCheckTestWorkflow,
@@ -192,9 +187,17 @@ func (r *mutationResolver) CompleteTest(ctx context.Context, input models.Comple
 	}
 
 	// Publish event to NATS
-	if err := r.EventPublisher.PublishTestCompleted(&completedTest); err != nil {
+	if err := r.EventPublisher.PublishTestCompleted(completedTest); err != nil {
 		r.Logger.Error("Failed to publish test completed event", "error", err)
 	}
 
-	return &completedTest, nil
+	return completedTest, nil
+}
+
+// AttemptQuestions returns the questions drawn for an attempt when it was
+// started, in the order they are delivered, optionally only those of one test
+func (r *queryResolver) AttemptQuestions(ctx context.Context, completedTestID uuid.UUID, testID *uuid.UUID) ([]*models.Question, error) {
+	// The draw was just written when a test is opened
+	ctx = database.WithPrimary(ctx)
+	return r.AttemptRepo.ListQuestions(ctx, completedTestID, testID)
 }
@@ -0,0 +1,32 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Audit log page sizes
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// AuditLog returns a page of the audit log, newest first (admin only)
+func (r *queryResolver) AuditLog(ctx context.Context, filter *models.AuditLogFilter, page *models.PageInput) (*models.AuditLogPage, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	var f models.AuditLogFilter
+	if filter != nil {
+		f = *filter
+	}
+	limit, offset := pageBounds(page, defaultAuditPageSize, maxAuditPageSize)
+
+	entries, total, err := r.AuditRepo.List(ctx, f, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuditLogPage{Entries: entries, Total: total}, nil
+}
@@ -0,0 +1,42 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/richtext"
+)
+
+// Rendered resolves the texts of a question and its options with their math
+// rendered to MathML
+func (r *questionResolver) Rendered(ctx context.Context, obj *models.Question) (*models.RenderedContent, error) {
+	rendered := &models.RenderedContent{
+		Text:    r.render(obj, obj.Text),
+		Text2:   r.render(obj, obj.Text2),
+		Text3:   r.render(obj, obj.Text3),
+		Options: make([]*models.RenderedOption, len(obj.Options)),
+	}
+	for i := range obj.Options {
+		o := &obj.Options[i]
+		rendered.Options[i] = &models.RenderedOption{
+			ID:        o.ID,
+			Text:      *r.render(obj, &o.Text),
+			MatchText: r.render(obj, o.MatchText),
+		}
+	}
+	return rendered, nil
+}
+
+// render renders a text of a question in its format. Texts written before
+// they were checked may not render; they are returned as they are.
+func (r *Resolver) render(q *models.Question, text *string) *string {
+	if text == nil {
+		return nil
+	}
+	rendered, err := richtext.Render(q.ContentFormat, *text)
+	if err != nil {
+		r.Logger.Warnw("Failed to render question content", "questionID", q.ID, "error", err)
+		return text
+	}
+	return &rendered
+}
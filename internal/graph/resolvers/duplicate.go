@@ -0,0 +1,82 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/dedup"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// duplicateBatchSize is the number of questions loaded at a time while
+// scanning for duplicates
+const duplicateBatchSize = 500
+
+// DuplicateQuestions reports groups of duplicate and near-duplicate questions
+// among the live questions matching the filter (admin only). The threshold
+// defaults to dedup.DefaultThreshold.
+func (r *queryResolver) DuplicateQuestions(ctx context.Context, threshold *float64, filter *models.DuplicateFilter) (*models.DuplicateReport, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	t := dedup.DefaultThreshold
+	if threshold != nil {
+		t = *threshold
+	}
+	detector, err := dedup.NewDetector(t)
+	if err != nil {
+		return nil, err
+	}
+	var f models.DuplicateFilter
+	if filter != nil {
+		f = *filter
+	}
+
+	err = r.DuplicateRepo.Scan(ctx, f, duplicateBatchSize, func(batch []*models.Question) error {
+		for _, q := range batch {
+			detector.Add(q)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.DuplicateReport{Groups: []*models.DuplicateGroup{}, Scanned: detector.Len()}
+	for _, g := range detector.Groups() {
+		members, err := r.DuplicateRepo.Members(ctx, g.IDs)
+		if err != nil {
+			return nil, err
+		}
+		report.Groups = append(report.Groups, &models.DuplicateGroup{Members: members, Similarity: g.Similarity})
+	}
+	return report, nil
+}
+
+// MergeQuestions merges duplicate questions into a surviving question,
+// repointing their answers to it (admin only)
+func (r *mutationResolver) MergeQuestions(ctx context.Context, survivorID uuid.UUID, duplicateIDs []uuid.UUID) (*models.MergeReport, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	var report *models.MergeReport
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if report, err = tx.DuplicateRepo.Merge(ctx, survivorID, duplicateIDs); err != nil {
+			return err
+		}
+		for _, duplicate := range report.Merged {
+			if err := tx.recordAudit(ctx, models.AuditMerge, models.AuditEntityQuestion, duplicate.ID, duplicate, report.Survivor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
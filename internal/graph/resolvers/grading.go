@@ -0,0 +1,170 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/database"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/Alan69/ayatest/internal/workflows"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// Essay grading errors
+var (
+	ErrNotEssay           = errors.New("only answers to essay questions are graded by hand")
+	ErrAttemptNotFinished = errors.New("essays are graded once their attempt is finished")
+	ErrNoRubric           = errors.New("the essay question has no rubric")
+	ErrRubricScores       = errors.New("scores must give each criterion of the rubric points up to its maximum, once")
+)
+
+// GradingQueue returns a page of the essay answers of finished attempts
+// waiting for a grade, oldest attempt first (teachers only, of the teacher
+// products they own; admins see all of them)
+func (r *queryResolver) GradingQueue(ctx context.Context, filter *models.GradingQueueFilter, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.CompletedQuestion], error) {
+	grader, err := r.requireGrader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ownerID *uuid.UUID
+	if grader.Role != models.RoleAdmin {
+		ownerID = &grader.ID
+	}
+
+	var f models.GradingQueueFilter
+	if filter != nil {
+		f = *filter
+	}
+	return r.GradingRepo.Queue(ctx, ownerID, f, pagination.Args{First: first, After: after, Last: last, Before: before})
+}
+
+// GradeEssay scores an essay answer on the rubric of the question it was
+// given on, replacing an earlier grade. The auto-check workflow of the
+// attempt is signalled, and started again when it already finished, so the
+// attempt is scored once all its essays are graded (teachers only, of the
+// teacher products they own).
+func (r *mutationResolver) GradeEssay(ctx context.Context, input models.GradeEssayInput) (*models.EssayGrade, error) {
+	grader, err := r.requireGrader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The grade is counted right after it is written
+	ctx = database.WithPrimary(ctx)
+
+	answer, err := r.GradingRepo.GetAnswer(ctx, input.CompletedQuestionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkGrader(ctx, grader, answer.CompletedTest.ProductID); err != nil {
+		return nil, err
+	}
+	question := answer.Question
+	if answer.PinnedQuestion != nil {
+		question = answer.PinnedQuestion
+	}
+	if question == nil || question.QuestionType != models.QuestionEssay {
+		return nil, ErrNotEssay
+	}
+	if answer.CompletedTest.TimeSpent == nil {
+		return nil, ErrAttemptNotFinished
+	}
+	points, err := scoreRubric(question.Rubric, input.Scores)
+	if err != nil {
+		return nil, err
+	}
+
+	before := answer.EssayGrade
+	grade := &models.EssayGrade{
+		CompletedQuestionID: answer.ID,
+		GraderID:            &grader.ID,
+		Scores:              models.RubricScores(input.Scores),
+		Points:              points,
+		MaxPoints:           question.Rubric.MaxPoints(),
+		Comment:             input.Comment,
+	}
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.GradingRepo.SaveGrade(ctx, grade); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditGrade, models.AuditEntityEssayGrade, grade.ID, before, grade)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.TemporalClient.SignalWithStartWorkflow(
+		context.Background(),
+		workflows.AutoCheckWorkflowID(answer.CompletedTestID),
+		workflows.EssayGradedSignal,
+		answer.ID,
+		client.StartWorkflowOptions{
+			ID:        workflows.AutoCheckWorkflowID(answer.CompletedTestID),
+			TaskQueue: workflows.TestTaskQueue,
+		},
+		workflows.AutoCheckTestWorkflow,
+		workflows.AutoCheckTestParams{CompletedTestID: answer.CompletedTestID},
+	)
+	if err != nil {
+		// The grade is saved; grading the essay again scores the attempt
+		r.Logger.Errorw("Failed to signal auto-check workflow", "completedTestID", answer.CompletedTestID, "error", err)
+		return nil, fmt.Errorf("signal auto-check workflow: %w", err)
+	}
+
+	return grade, nil
+}
+
+// requireGrader returns the caller when they may grade essays: teachers and
+// admins
+func (r *Resolver) requireGrader(ctx context.Context) (*models.User, error) {
+	caller, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role != models.RoleTeacher && caller.Role != models.RoleAdmin {
+		return nil, ErrUnauthorized
+	}
+	return caller, nil
+}
+
+// checkGrader checks that a grader may grade the essays of a product: admins
+// grade all of them, teachers those of the teacher products they own
+func (r *Resolver) checkGrader(ctx context.Context, grader *models.User, productID uuid.UUID) error {
+	if grader.Role == models.RoleAdmin {
+		return nil
+	}
+	product, err := r.ProductRepo.Get(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if product.ProductType != models.ProductTypeTeacher || product.OwnerID == nil || *product.OwnerID != grader.ID {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// scoreRubric checks that scores give each criterion of a rubric points up
+// to its maximum, once, and returns the points in total
+func scoreRubric(rubric models.Rubric, scores []models.RubricScore) (int, error) {
+	if len(rubric) == 0 {
+		return 0, ErrNoRubric
+	}
+	if len(scores) != len(rubric) {
+		return 0, ErrRubricScores
+	}
+	scored := make([]bool, len(rubric))
+	points := 0
+	for _, s := range scores {
+		if s.Criterion < 0 || s.Criterion >= len(rubric) || scored[s.Criterion] {
+			return 0, ErrRubricScores
+		}
+		if s.Points < 0 || s.Points > rubric[s.Criterion].MaxPoints {
+			return 0, ErrRubricScores
+		}
+		scored[s.Criterion] = true
+		points += s.Points
+	}
+	return points, nil
+}
@@ -0,0 +1,55 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// ImgURL returns a signed URL of the question image
+func (r *questionResolver) ImgURL(ctx context.Context, obj *models.Question) (*string, error) {
+	return r.imageURL(ctx, obj.ImgPath, false)
+}
+
+// ThumbnailURL returns a signed URL of the thumbnail of the question image
+func (r *questionResolver) ThumbnailURL(ctx context.Context, obj *models.Question) (*string, error) {
+	return r.imageURL(ctx, obj.ImgPath, true)
+}
+
+// ImgURL returns a signed URL of the option image
+func (r *optionResolver) ImgURL(ctx context.Context, obj *models.Option) (*string, error) {
+	return r.imageURL(ctx, obj.ImgPath, false)
+}
+
+// ThumbnailURL returns a signed URL of the thumbnail of the option image
+func (r *optionResolver) ThumbnailURL(ctx context.Context, obj *models.Option) (*string, error) {
+	return r.imageURL(ctx, obj.ImgPath, true)
+}
+
+// imageURL signs the URL of an image path, or of its thumbnail. Image paths
+// that already are URLs are returned as they are and have no thumbnail.
+func (r *Resolver) imageURL(ctx context.Context, imgPath *string, thumbnail bool) (*string, error) {
+	if imgPath == nil || *imgPath == "" || r.Media == nil {
+		return nil, nil
+	}
+	name := *imgPath
+	if media.IsURL(name) {
+		if thumbnail {
+			return nil, nil
+		}
+		return &name, nil
+	}
+	if thumbnail {
+		if name = media.ThumbnailPath(name); name == "" {
+			return nil, nil
+		}
+	}
+
+	url, err := r.Media.URL(ctx, name, r.MediaURLTTL)
+	if err != nil {
+		r.Logger.Errorw("Failed to sign image URL", "path", name, "error", err)
+		return nil, nil
+	}
+	return &url, nil
+}
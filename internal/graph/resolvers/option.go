@@ -3,56 +3,163 @@ package resolvers
 import (
 	"context"
 
-	"github.com/Alan69/ayatest/internal/database"
+	"github.com/Alan69/ayatest/internal/answerkey"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/richtext"
 	"github.com/google/uuid"
 )
 
 // CreateOption creates a new option
 func (r *mutationResolver) CreateOption(ctx context.Context, input models.OptionInput) (*models.Option, error) {
+	question, err := r.QuestionRepo.Get(ctx, input.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+
 	option := &models.Option{
 		QuestionID: input.QuestionID,
 		Text:       input.Text,
 		ImgPath:    input.ImgPath,
 		IsCorrect:  input.IsCorrect,
+		MatchText:  input.MatchText,
+		Position:   input.Position,
+	}
+	if err := checkOptions(question, option); err != nil {
+		return nil, err
+	}
+	if err := richtext.SanitizeOption(question.ContentFormat, option); err != nil {
+		return nil, err
 	}
 
-	result := database.DB.Create(option)
-	if result.Error != nil {
-		return nil, result.Error
+	var revised *models.QuestionReview
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.CreateOption(ctx, option); err != nil {
+			return err
+		}
+		if revised, err = tx.reviseApproved(ctx, question); err != nil {
+			return err
+		}
+		if err := tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityOption, option.ID, nil, option); err != nil {
+			return err
+		}
+		return tx.saveRevision(ctx, option.QuestionID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	if revised != nil {
+		r.notifyReview(question, revised)
+	}
 	return option, nil
 }
 
 // UpdateOption updates an existing option
 func (r *mutationResolver) UpdateOption(ctx context.Context, id uuid.UUID, input models.OptionInput) (*models.Option, error) {
-	var option models.Option
-	result := database.DB.First(&option, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
+	option, err := r.QuestionRepo.GetOption(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	before := *option
 
 	option.Text = input.Text
 	if input.ImgPath != nil {
 		option.ImgPath = input.ImgPath
 	}
 	option.IsCorrect = input.IsCorrect
+	option.MatchText = input.MatchText
+	option.Position = input.Position
+
+	question, err := r.QuestionRepo.Get(ctx, option.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptions(question, option); err != nil {
+		return nil, err
+	}
+	if err := richtext.SanitizeOption(question.ContentFormat, option); err != nil {
+		return nil, err
+	}
 
-	result = database.DB.Save(&option)
-	if result.Error != nil {
-		return nil, result.Error
+	var revised *models.QuestionReview
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.UpdateOption(ctx, option); err != nil {
+			return err
+		}
+		if optionChanged(&before, option) {
+			if revised, err = tx.reviseApproved(ctx, question); err != nil {
+				return err
+			}
+		}
+		if err := tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityOption, option.ID, &before, option); err != nil {
+			return err
+		}
+		return tx.saveRevision(ctx, option.QuestionID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &option, nil
+	if revised != nil {
+		r.notifyReview(question, revised)
+	}
+	return option, nil
 }
 
 // DeleteOption deletes an option
-func (r *mutationResolver) DeleteOption(ctx context.Context, id uuid.UUID) (bool, error) {
-	result := database.DB.Delete(&models.Option{}, "id = ?", id)
-	if result.Error != nil {
-		return false, result.Error
+func (r *mutationResolver) DeleteOption(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error) {
+	option, err := r.QuestionRepo.GetOption(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	question, err := r.QuestionRepo.Get(ctx, option.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var revised *models.QuestionReview
+	report, err := r.deleteContent(ctx, models.ContentOption, id, dryRun, force, option, func(tx *Resolver) error {
+		var err error
+		if revised, err = tx.reviseApproved(ctx, question); err != nil {
+			return err
+		}
+		return tx.saveRevision(ctx, option.QuestionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if revised != nil {
+		r.notifyReview(question, revised)
+	}
+	return report, nil
+}
+
+// optionChanged reports whether an edit of an option changed the question
+// students see or how their answers are graded
+func optionChanged(before, after *models.Option) bool {
+	return before.Text != after.Text || !equalText(before.ImgPath, after.ImgPath) || before.IsCorrect != after.IsCorrect ||
+		!equalText(before.MatchText, after.MatchText) || !equalInt(before.Position, after.Position)
+}
+
+func equalInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
+}
 
-	return true, nil
+// checkOptions checks the option positions a question would have with an
+// option created or updated
+func checkOptions(question *models.Question, option *models.Option) error {
+	with := *question
+	with.Options = make([]models.Option, 0, len(question.Options)+1)
+	for _, o := range question.Options {
+		if o.ID != option.ID {
+			with.Options = append(with.Options, o)
+		}
+	}
+	with.Options = append(with.Options, *option)
+	return answerkey.CheckOptions(&with)
 }
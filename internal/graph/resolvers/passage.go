@@ -0,0 +1,253 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/database"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
+	"github.com/google/uuid"
+)
+
+// PassageGroups returns the passage groups of a test
+func (r *queryResolver) PassageGroups(ctx context.Context, testID uuid.UUID) ([]*models.PassageGroup, error) {
+	return r.PassageRepo.ListByTest(ctx, testID)
+}
+
+// PassageGroup returns a passage group by ID
+func (r *queryResolver) PassageGroup(ctx context.Context, id uuid.UUID) (*models.PassageGroup, error) {
+	return r.PassageRepo.Get(ctx, id)
+}
+
+// AttemptItems returns the questions drawn for an attempt as they are
+// delivered: the questions of a passage group follow their passage, which is
+// given once, and other questions come on their own
+func (r *queryResolver) AttemptItems(ctx context.Context, completedTestID uuid.UUID, testID *uuid.UUID) ([]*models.AttemptItem, error) {
+	// The draw was just written when a test is opened
+	ctx = database.WithPrimary(ctx)
+	questions, err := r.AttemptRepo.ListQuestions(ctx, completedTestID, testID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*models.AttemptItem{}
+	var last *models.AttemptItem
+	for _, q := range questions {
+		if q.PassageGroupID == nil {
+			items = append(items, &models.AttemptItem{Questions: []*models.Question{q}})
+			last = nil
+			continue
+		}
+		if last != nil && last.PassageGroup.ID == *q.PassageGroupID {
+			last.Questions = append(last.Questions, q)
+			continue
+		}
+
+		group, err := r.PassageRepo.Get(ctx, *q.PassageGroupID)
+		if err != nil {
+			return nil, err
+		}
+		passage, err := r.QuestionRepo.GetSource(ctx, group.SourceID)
+		if err != nil {
+			return nil, err
+		}
+		last = &models.AttemptItem{PassageGroup: group, Passage: passage, Questions: []*models.Question{q}}
+		items = append(items, last)
+	}
+	return items, nil
+}
+
+// CreatePassageGroup creates an empty passage group for a passage of a test
+// (admin only)
+func (r *mutationResolver) CreatePassageGroup(ctx context.Context, input models.PassageGroupInput) (*models.PassageGroup, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	group := &models.PassageGroup{
+		TestID:   input.TestID,
+		SourceID: input.SourceID,
+		Title:    input.Title,
+	}
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.PassageRepo.Create(ctx, group); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityPassageGroup, group.ID, nil, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// UpdatePassageGroup updates a passage group; a new passage becomes the
+// source text of its questions (admin only)
+func (r *mutationResolver) UpdatePassageGroup(ctx context.Context, id uuid.UUID, input models.PassageGroupInput) (*models.PassageGroup, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	group, err := r.PassageRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *group
+
+	group.TestID = input.TestID
+	group.SourceID = input.SourceID
+	group.Title = input.Title
+
+	var revised map[*models.Question]*models.QuestionReview
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.PassageRepo.Update(ctx, group); err != nil {
+			return err
+		}
+		if group.SourceID != before.SourceID {
+			questions, err := tx.PassageRepo.Questions(ctx, id)
+			if err != nil {
+				return err
+			}
+			if revised, err = tx.reviseSources(ctx, questions); err != nil {
+				return err
+			}
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityPassageGroup, group.ID, &before, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for question, entry := range revised {
+		r.notifyReview(question, entry)
+	}
+	return group, nil
+}
+
+// DeletePassageGroup deletes a passage group; its questions keep their
+// source text but are no longer drawn together (admin only)
+func (r *mutationResolver) DeletePassageGroup(ctx context.Context, id uuid.UUID) (bool, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return false, err
+	}
+
+	group, err := r.PassageRepo.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.PassageRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditDelete, models.AuditEntityPassageGroup, id, group, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetPassageGroupQuestions sets the questions of a passage group and their
+// order. Questions of the group left out leave it (admin only).
+func (r *mutationResolver) SetPassageGroupQuestions(ctx context.Context, id uuid.UUID, questionIDs []uuid.UUID) (*models.PassageGroup, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	group, err := r.PassageRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before, err := r.PassageRepo.Questions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var revised map[*models.Question]*models.QuestionReview
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		after, moved, err := tx.PassageRepo.SetQuestions(ctx, id, questionIDs)
+		if err != nil {
+			return err
+		}
+		changed := make([]*models.Question, 0, len(moved))
+		for _, question := range after {
+			for _, movedID := range moved {
+				if question.ID == movedID {
+					changed = append(changed, question)
+				}
+			}
+		}
+		if revised, err = tx.reviseSources(ctx, changed); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityPassageGroup, id,
+			map[string]interface{}{"question_ids": questionIDsOf(before)},
+			map[string]interface{}{"question_ids": questionIDsOf(after)})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for question, entry := range revised {
+		r.notifyReview(question, entry)
+	}
+	return group, nil
+}
+
+// reviseSources saves a revision of each question whose source text a passage
+// group changed and sends the approved ones back to review. It is called on
+// the resolver of the transaction and returns the review entries by question.
+func (r *Resolver) reviseSources(ctx context.Context, questions []*models.Question) (map[*models.Question]*models.QuestionReview, error) {
+	revised := make(map[*models.Question]*models.QuestionReview)
+	for _, question := range questions {
+		if err := r.saveRevision(ctx, question.ID); err != nil {
+			return nil, err
+		}
+		entry, err := r.reviseApproved(ctx, question)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			revised[question] = entry
+		}
+	}
+	return revised, nil
+}
+
+// Source resolves the passage of a passage group
+func (r *passageGroupResolver) Source(ctx context.Context, obj *models.PassageGroup) (*models.Source, error) {
+	return r.QuestionRepo.GetSource(ctx, obj.SourceID)
+}
+
+// Questions resolves the questions of a passage group in their order
+func (r *passageGroupResolver) Questions(ctx context.Context, obj *models.PassageGroup) ([]*models.Question, error) {
+	return r.PassageRepo.Questions(ctx, obj.ID)
+}
+
+// checkPassage checks that a question of a passage group keeps the passage
+// of its group as its source text
+func (r *Resolver) checkPassage(ctx context.Context, question *models.Question) error {
+	if question.PassageGroupID == nil {
+		return nil
+	}
+	group, err := r.PassageRepo.Get(ctx, *question.PassageGroupID)
+	if err != nil {
+		return err
+	}
+	if question.SourceTextID == nil || *question.SourceTextID != group.SourceID {
+		return repository.ErrPassageSource
+	}
+	return nil
+}
+
+// questionIDsOf returns the IDs of questions in their order
+func questionIDsOf(questions []*models.Question) []uuid.UUID {
+	ids := make([]uuid.UUID, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+	return ids
+}
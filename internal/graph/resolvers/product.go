@@ -3,29 +3,23 @@ package resolvers
 import (
 	"context"
 
-	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
 	"github.com/google/uuid"
 )
 
-// GetProducts returns all products
-func (r *queryResolver) Products(ctx context.Context) ([]*models.Product, error) {
-	var products []*models.Product
-	result := database.DB.Find(&products)
-	if result.Error != nil {
-		return nil, result.Error
+// Products returns a page of products
+func (r *queryResolver) Products(ctx context.Context, filter *models.ProductFilter, sort *models.ProductSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Product], error) {
+	var f models.ProductFilter
+	if filter != nil {
+		f = *filter
 	}
-	return products, nil
+	return r.ProductRepo.Page(ctx, f, sort, pagination.Args{First: first, After: after, Last: last, Before: before})
 }
 
 // GetProduct returns a product by ID
 func (r *queryResolver) Product(ctx context.Context, id uuid.UUID) (*models.Product, error) {
-	var product models.Product
-	result := database.DB.First(&product, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return &product, nil
+	return r.ProductRepo.Get(ctx, id)
 }
 
 // CreateProduct creates a new product
@@ -39,10 +33,18 @@ func (r *mutationResolver) CreateProduct(ctx context.Context, input models.Produ
 		SubjectLimit: input.SubjectLimit,
 		ProductType:  input.ProductType,
 	}
+	if ownerID, ok := callerID(ctx); ok {
+		product.OwnerID = &ownerID
+	}
 
-	result := database.DB.Create(product)
-	if result.Error != nil {
-		return nil, result.Error
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.ProductRepo.Create(ctx, product); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityProduct, product.ID, nil, product)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Publish event to NATS
@@ -56,11 +58,11 @@ func (r *mutationResolver) CreateProduct(ctx context.Context, input models.Produ
 
 // UpdateProduct updates an existing product
 func (r *mutationResolver) UpdateProduct(ctx context.Context, id uuid.UUID, input models.ProductInput) (*models.Product, error) {
-	var product models.Product
-	result := database.DB.First(&product, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
+	product, err := r.ProductRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	before := *product
 
 	product.Title = input.Title
 	if input.Description != nil {
@@ -82,25 +84,35 @@ func (r *mutationResolver) UpdateProduct(ctx context.Context, id uuid.UUID, inpu
 		product.ProductType = input.ProductType
 	}
 
-	result = database.DB.Save(&product)
-	if result.Error != nil {
-		return nil, result.Error
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.ProductRepo.Update(ctx, product); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityProduct, product.ID, &before, product)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Publish event to NATS
-	if err := r.EventPublisher.PublishProductUpdated(&product); err != nil {
+	if err := r.EventPublisher.PublishProductUpdated(product); err != nil {
 		// Log the error but don't fail the request
 		r.Logger.Error("Failed to publish product updated event", "error", err)
 	}
 
-	return &product, nil
+	return product, nil
 }
 
-// DeleteProduct deletes a product
-func (r *mutationResolver) DeleteProduct(ctx context.Context, id uuid.UUID) (bool, error) {
-	result := database.DB.Delete(&models.Product{}, "id = ?", id)
-	if result.Error != nil {
-		return false, result.Error
+// DeleteProduct deletes a product with its tests, questions and options
+func (r *mutationResolver) DeleteProduct(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error) {
+	product, err := r.ProductRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := r.deleteContent(ctx, models.ContentProduct, id, dryRun, force, product, nil)
+	if err != nil || !report.Deleted {
+		return report, err
 	}
 
 	// Publish event to NATS
@@ -109,5 +121,5 @@ func (r *mutationResolver) DeleteProduct(ctx context.Context, id uuid.UUID) (boo
 		r.Logger.Error("Failed to publish product deleted event", "error", err)
 	}
 
-	return true, nil
+	return report, nil
 }
@@ -2,34 +2,37 @@ package resolvers
 
 import (
 	"context"
+	"encoding/json"
+	"reflect"
 
-	"github.com/Alan69/ayatest/internal/database"
+	"github.com/Alan69/ayatest/internal/answerkey"
+	"github.com/Alan69/ayatest/internal/audit"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/Alan69/ayatest/internal/richtext"
 	"github.com/google/uuid"
 )
 
-// Questions returns all questions for a test
-func (r *queryResolver) Questions(ctx context.Context, testID uuid.UUID) ([]*models.Question, error) {
-	var questions []*models.Question
-	result := database.DB.Where("test_id = ?", testID).Preload("Options").Find(&questions)
-	if result.Error != nil {
-		return nil, result.Error
+// Questions returns a page of the questions of a test
+func (r *queryResolver) Questions(ctx context.Context, testID uuid.UUID, filter *models.QuestionFilter, sort *models.QuestionSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Question], error) {
+	var f models.QuestionFilter
+	if filter != nil {
+		f = *filter
 	}
-	return questions, nil
+	return r.QuestionRepo.PageByTest(ctx, testID, f, sort, pagination.Args{First: first, After: after, Last: last, Before: before})
 }
 
 // Question returns a question by ID
 func (r *queryResolver) Question(ctx context.Context, id uuid.UUID) (*models.Question, error) {
-	var question models.Question
-	result := database.DB.Preload("Options").First(&question, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return &question, nil
+	return r.QuestionRepo.Get(ctx, id)
 }
 
 // CreateQuestion creates a new question
 func (r *mutationResolver) CreateQuestion(ctx context.Context, input models.QuestionInput) (*models.Question, error) {
+	if err := r.checkTaxonomyNode(ctx, input.TaxonomyNodeID); err != nil {
+		return nil, err
+	}
+
 	question := &models.Question{
 		TestID:       input.TestID,
 		Text:         input.Text,
@@ -38,7 +41,6 @@ func (r *mutationResolver) CreateQuestion(ctx context.Context, input models.Ques
 		ImgPath:      input.ImgPath,
 		TaskType:     input.TaskType,
 		Level:        input.Level,
-		Status:       input.Status,
 		Category:     input.Category,
 		Subcategory:  input.Subcategory,
 		Theme:        input.Theme,
@@ -52,11 +54,48 @@ func (r *mutationResolver) CreateQuestion(ctx context.Context, input models.Ques
 		SubjectID:    input.SubjectID,
 		SubjectTitle: input.SubjectTitle,
 		ClassNumber:  input.ClassNumber,
+
+		TaxonomyNodeID: input.TaxonomyNodeID,
+		ContentFormat:  models.ContentPlain,
+		QuestionType:   models.QuestionChoice,
+		NumericAnswer:  input.NumericAnswer,
+		Tolerance:      input.Tolerance,
+		Rubric:         input.Rubric,
+	}
+	if input.ContentFormat != nil {
+		question.ContentFormat = *input.ContentFormat
+	}
+	if input.QuestionType != nil {
+		question.QuestionType = *input.QuestionType
+	}
+	if err := r.checkQuestionType(ctx, question); err != nil {
+		return nil, err
+	}
+	if err := richtext.SanitizeQuestion(question); err != nil {
+		return nil, err
+	}
+	// New questions are drafts of the caller until they pass review
+	if authorID, ok := callerID(ctx); ok {
+		question.AuthorID = &authorID
+	}
+
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.Create(ctx, question); err != nil {
+			return err
+		}
+		if err := tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityQuestion, question.ID, nil, question); err != nil {
+			return err
+		}
+		return tx.saveRevision(ctx, question.ID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	result := database.DB.Create(question)
-	if result.Error != nil {
-		return nil, result.Error
+	// Publish event to NATS
+	if err := r.EventPublisher.PublishQuestionCreated(question); err != nil {
+		// Log the error but don't fail the request
+		r.Logger.Error("Failed to publish question created event", "error", err)
 	}
 
 	return question, nil
@@ -64,11 +103,12 @@ func (r *mutationResolver) CreateQuestion(ctx context.Context, input models.Ques
 
 // UpdateQuestion updates an existing question
 func (r *mutationResolver) UpdateQuestion(ctx context.Context, id uuid.UUID, input models.QuestionInput) (*models.Question, error) {
-	var question models.Question
-	result := database.DB.First(&question, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
+	question, err := r.QuestionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	before := *question
+	before.Options = append([]models.Option(nil), question.Options...)
 
 	if input.Text != nil {
 		question.Text = input.Text
@@ -88,9 +128,6 @@ func (r *mutationResolver) UpdateQuestion(ctx context.Context, id uuid.UUID, inp
 	if input.Level != nil {
 		question.Level = input.Level
 	}
-	if input.Status != nil {
-		question.Status = input.Status
-	}
 	if input.Category != nil {
 		question.Category = input.Category
 	}
@@ -130,21 +167,187 @@ func (r *mutationResolver) UpdateQuestion(ctx context.Context, id uuid.UUID, inp
 	if input.ClassNumber != nil {
 		question.ClassNumber = input.ClassNumber
 	}
+	if input.TaxonomyNodeID != nil {
+		if err := r.checkTaxonomyNode(ctx, input.TaxonomyNodeID); err != nil {
+			return nil, err
+		}
+		question.TaxonomyNodeID = input.TaxonomyNodeID
+	}
+	if err := r.checkPassage(ctx, question); err != nil {
+		return nil, err
+	}
+	if input.QuestionType != nil {
+		question.QuestionType = *input.QuestionType
+	}
+	if input.NumericAnswer != nil {
+		question.NumericAnswer = input.NumericAnswer
+	}
+	if input.Tolerance != nil {
+		question.Tolerance = input.Tolerance
+	}
+	if input.Rubric != nil {
+		question.Rubric = input.Rubric
+	}
+	if err := r.checkQuestionType(ctx, question); err != nil {
+		return nil, err
+	}
+	if input.ContentFormat != nil {
+		question.ContentFormat = *input.ContentFormat
+	}
+	// The options are sanitized again, as the format may have changed
+	if err := richtext.SanitizeQuestion(question); err != nil {
+		return nil, err
+	}
+
+	var revised *models.QuestionReview
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.Update(ctx, question); err != nil {
+			return err
+		}
+		for i := range question.Options {
+			option := &question.Options[i]
+			if option.Text == before.Options[i].Text && equalText(option.MatchText, before.Options[i].MatchText) {
+				continue
+			}
+			if err := tx.QuestionRepo.UpdateOption(ctx, option); err != nil {
+				return err
+			}
+		}
+		if contentChanged(&before, question) {
+			var err error
+			if revised, err = tx.reviseApproved(ctx, question); err != nil {
+				return err
+			}
+		}
+		if err := tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityQuestion, question.ID, &before, question); err != nil {
+			return err
+		}
+		return tx.saveRevision(ctx, question.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	result = database.DB.Save(&question)
-	if result.Error != nil {
-		return nil, result.Error
+	if revised != nil {
+		r.notifyReview(question, revised)
 	}
+	return question, nil
+}
 
-	return &question, nil
+// DeleteQuestion deletes a question with its options
+func (r *mutationResolver) DeleteQuestion(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error) {
+	question, err := r.QuestionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deleteContent(ctx, models.ContentQuestion, id, dryRun, force, question, nil)
 }
 
-// DeleteQuestion deletes a question
-func (r *mutationResolver) DeleteQuestion(ctx context.Context, id uuid.UUID) (bool, error) {
-	result := database.DB.Delete(&models.Question{}, "id = ?", id)
-	if result.Error != nil {
-		return false, result.Error
+// QuestionHistory returns the revisions of a question, oldest first, each with
+// its diff from the previous revision
+func (r *queryResolver) QuestionHistory(ctx context.Context, id uuid.UUID) ([]*models.QuestionRevision, error) {
+	revisions, err := r.QuestionRepo.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	return true, nil
+	var previous json.RawMessage
+	for _, revision := range revisions {
+		revision.Diff, err = audit.Diff(previous, revision.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+		previous = revision.Snapshot
+	}
+	return revisions, nil
+}
+
+// RevertQuestion restores a question and its options to a previous revision
+// (admin only). The revert is saved as a new revision.
+func (r *mutationResolver) RevertQuestion(ctx context.Context, id uuid.UUID, revision int) (*models.Question, error) {
+	admin, err := r.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := r.QuestionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var question *models.Question
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if question, err = tx.QuestionRepo.Revert(ctx, id, revision, &admin.ID); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityQuestion, question.ID, before, question)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return question, nil
+}
+
+// checkQuestionType checks the answer key of a question. Essays belong to
+// tests of teacher products.
+func (r *Resolver) checkQuestionType(ctx context.Context, question *models.Question) error {
+	if err := answerkey.Check(question); err != nil {
+		return err
+	}
+	if question.QuestionType != models.QuestionEssay {
+		return nil
+	}
+
+	test, err := r.TestRepo.Get(ctx, question.TestID)
+	if err != nil {
+		return err
+	}
+	product, err := r.ProductRepo.Get(ctx, test.ProductID)
+	if err != nil {
+		return err
+	}
+	return answerkey.CheckProduct(question, product.ProductType)
+}
+
+// contentChanged reports whether an edit changed what students see or how
+// their answers are graded, rather than only how the question is filed
+func contentChanged(before, after *models.Question) bool {
+	if !equalText(before.Text, after.Text) || !equalText(before.Text2, after.Text2) || !equalText(before.Text3, after.Text3) ||
+		!equalText(before.ImgPath, after.ImgPath) || !equalUUID(before.SourceTextID, after.SourceTextID) ||
+		before.ContentFormat != after.ContentFormat || before.QuestionType != after.QuestionType ||
+		!equalFloat(before.NumericAnswer, after.NumericAnswer) || !equalFloat(before.Tolerance, after.Tolerance) ||
+		!reflect.DeepEqual(before.Rubric, after.Rubric) {
+		return true
+	}
+	for i := range after.Options {
+		if after.Options[i].Text != before.Options[i].Text || !equalText(after.Options[i].MatchText, before.Options[i].MatchText) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalUUID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// equalText reports whether two optional texts are the same
+func equalText(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
@@ -0,0 +1,94 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/workflows"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// ErrInvalidRegradeScope is returned when a regrade does not name exactly one
+// of a test, a question or a completed test
+var ErrInvalidRegradeScope = errors.New("regrade requires exactly one of testId, questionId or completedTestId")
+
+// Regrade returns a regrade by ID with its results (admin only)
+func (r *queryResolver) Regrade(ctx context.Context, id uuid.UUID) (*models.Regrade, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.RegradeRepo.Get(ctx, id)
+}
+
+// Regrade rescores the attempts that include a test or a question, or a single
+// attempt, against the current answer key (admin only). The attempts are
+// regraded in the background by a Temporal workflow.
+func (r *mutationResolver) Regrade(ctx context.Context, testID *uuid.UUID, questionID *uuid.UUID, completedTestID *uuid.UUID) (*models.Regrade, error) {
+	admin, err := r.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	regrade := &models.Regrade{
+		TestID:          testID,
+		QuestionID:      questionID,
+		CompletedTestID: completedTestID,
+		RequestedBy:     &admin.ID,
+	}
+	var entity models.AuditEntity
+	var entityID uuid.UUID
+	scopes := 0
+	if testID != nil {
+		entity, entityID = models.AuditEntityTest, *testID
+		scopes++
+	}
+	if questionID != nil {
+		entity, entityID = models.AuditEntityQuestion, *questionID
+		scopes++
+	}
+	if completedTestID != nil {
+		entity, entityID = models.AuditEntityCompletedTest, *completedTestID
+		scopes++
+	}
+	if scopes != 1 {
+		return nil, ErrInvalidRegradeScope
+	}
+
+	if err := r.RegradeRepo.Create(ctx, regrade); err != nil {
+		return nil, err
+	}
+
+	// Start the regrade workflow
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        "regrade-" + regrade.ID.String(),
+		TaskQueue: workflows.TestTaskQueue,
+	}
+	run, err := r.TemporalClient.ExecuteWorkflow(
+		context.Background(),
+		workflowOptions,
+		workflows.RegradeWorkflow,
+		workflows.RegradeParams{
+			RegradeID: regrade.ID,
+			BatchSize: workflows.DefaultRegradeBatchSize,
+		},
+	)
+	if err != nil {
+		r.Logger.Errorw("Failed to start regrade workflow", "regradeID", regrade.ID, "error", err)
+		return nil, err
+	}
+
+	regrade.WorkflowID = run.GetID()
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.RegradeRepo.Update(ctx, regrade); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditRegrade, entity, entityID, nil, regrade)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return regrade, nil
+}
@@ -3,9 +3,15 @@ package resolvers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/Alan69/ayatest/internal/audit"
 	"github.com/Alan69/ayatest/internal/events"
+	"github.com/Alan69/ayatest/internal/media"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/Alan69/ayatest/internal/repository"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
 	"go.uber.org/zap"
@@ -21,6 +27,9 @@ type ResolverRoot interface {
 	Query() QueryResolver
 	Mutation() MutationResolver
 	Subscription() SubscriptionResolver
+	Question() QuestionResolver
+	Option() OptionResolver
+	PassageGroup() PassageGroupResolver
 }
 
 // Resolver is the root resolver
@@ -28,42 +37,260 @@ type Resolver struct {
 	Logger         *zap.SugaredLogger
 	EventPublisher events.Publisher
 	TemporalClient client.Client
+
+	ProductRepo   repository.ProductRepo
+	TestRepo      repository.TestRepo
+	QuestionRepo  repository.QuestionRepo
+	AttemptRepo   repository.AttemptRepo
+	UserRepo      repository.UserRepo
+	TrashRepo     repository.TrashRepo
+	DeletionRepo  repository.DeletionRepo
+	AuditRepo     repository.AuditRepo
+	RegradeRepo   repository.RegradeRepo
+	TaxonomyRepo  repository.TaxonomyRepo
+	DuplicateRepo repository.DuplicateRepo
+	ReviewRepo    repository.ReviewRepo
+	PassageRepo   repository.PassageRepo
+	GradingRepo   repository.GradingRepo
+
+	// Repos opens the transactions mutations write their changes and audit
+	// entries in; the repositories above are those of Repos
+	Repos *repository.Repositories
+
+	// Media signs the URLs of question and option images, which stay valid
+	// for MediaURLTTL
+	Media       media.Store
+	MediaURLTTL time.Duration
 }
 
 // Query returns the query resolver
 func (r *Resolver) Query() QueryResolver {
-	// We're not actually returning an implementation yet, just nil to compile
-	return nil
+	return &queryResolver{r}
 }
 
 // Mutation returns the mutation resolver
 func (r *Resolver) Mutation() MutationResolver {
-	// We're not actually returning an implementation yet, just nil to compile
-	return nil
+	return &mutationResolver{r}
 }
 
 // Subscription returns the subscription resolver
 func (r *Resolver) Subscription() SubscriptionResolver {
-	// We're not actually returning an implementation yet, just nil to compile
+	return &subscriptionResolver{r}
+}
+
+// Question returns the question field resolver
+func (r *Resolver) Question() QuestionResolver {
+	return &questionResolver{r}
+}
+
+// Option returns the option field resolver
+func (r *Resolver) Option() OptionResolver {
+	return &optionResolver{r}
+}
+
+// PassageGroup returns the passage group field resolver
+func (r *Resolver) PassageGroup() PassageGroupResolver {
+	return &passageGroupResolver{r}
+}
+
+// callerID returns the ID of the authenticated user (the JWT sub claim)
+func callerID(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// requireUser returns the calling user
+func (r *Resolver) requireUser(ctx context.Context) (*models.User, error) {
+	id, ok := callerID(ctx)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+
+	caller, err := r.UserRepo.Get(ctx, id)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	return caller, nil
+}
+
+// requireAdmin returns the calling user if they are an admin
+func (r *Resolver) requireAdmin(ctx context.Context) (*models.User, error) {
+	caller, err := r.requireUser(ctx)
+	if err != nil || caller.Role != models.RoleAdmin {
+		return nil, ErrUnauthorized
+	}
+	return caller, nil
+}
+
+// pageBounds returns the limit and offset of a requested page, with the limit
+// defaulting to defaultSize and capped at maxSize
+func pageBounds(page *models.PageInput, defaultSize, maxSize int) (limit, offset int) {
+	limit = defaultSize
+	if page != nil {
+		if page.Limit != nil && *page.Limit > 0 {
+			limit = *page.Limit
+		}
+		if page.Offset != nil && *page.Offset > 0 {
+			offset = *page.Offset
+		}
+	}
+	if limit > maxSize {
+		limit = maxSize
+	}
+	return limit, offset
+}
+
+// transaction runs fn with a resolver whose repositories share one database
+// transaction, so a mutation and its audit entry are written together or not
+// at all. The transaction is committed if fn returns nil and rolled back
+// otherwise.
+func (r *Resolver) transaction(ctx context.Context, fn func(tx *Resolver) error) error {
+	return r.Repos.Transaction(ctx, func(repos *repository.Repositories) error {
+		tx := *r
+		tx.ProductRepo = repos.Products
+		tx.TestRepo = repos.Tests
+		tx.QuestionRepo = repos.Questions
+		tx.AttemptRepo = repos.Attempts
+		tx.UserRepo = repos.Users
+		tx.TrashRepo = repos.Trash
+		tx.DeletionRepo = repos.Deletions
+		tx.AuditRepo = repos.Audit
+		tx.RegradeRepo = repos.Regrades
+		tx.TaxonomyRepo = repos.Taxonomy
+		tx.DuplicateRepo = repos.Duplicates
+		tx.ReviewRepo = repos.Reviews
+		tx.PassageRepo = repos.Passages
+		tx.GradingRepo = repos.Grading
+		tx.Repos = repos
+		return fn(&tx)
+	})
+}
+
+// recordAudit writes an audit entry for a mutation made by the caller. before
+// and after are the entity before and after the change, nil for creates and
+// deletes. It is called on the resolver of the transaction of the mutation,
+// which fails when the entry cannot be written.
+func (r *Resolver) recordAudit(ctx context.Context, action models.AuditAction, entity models.AuditEntity, id uuid.UUID, before, after interface{}) error {
+	entry := &models.AuditEntry{
+		Action:     action,
+		EntityType: entity,
+		EntityID:   id,
+	}
+	if actorID, ok := callerID(ctx); ok {
+		entry.ActorID = &actorID
+	}
+
+	var err error
+	if entry.Before, err = audit.Snapshot(before); err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	if entry.After, err = audit.Snapshot(after); err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	if entry.Diff, err = audit.Diff(entry.Before, entry.After); err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	if err := r.AuditRepo.Create(ctx, entry); err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
 	return nil
 }
 
+// saveRevision snapshots a question after it or one of its options changed.
+// It is called on the resolver of the transaction of the change, so content
+// never changes without a revision to revert to or grade against.
+func (r *Resolver) saveRevision(ctx context.Context, questionID uuid.UUID) error {
+	var authorID *uuid.UUID
+	if id, ok := callerID(ctx); ok {
+		authorID = &id
+	}
+	if _, err := r.QuestionRepo.SaveRevision(ctx, questionID, authorID); err != nil {
+		return fmt.Errorf("save question revision: %w", err)
+	}
+	return nil
+}
+
+// deleteContent reports what deleting an entity would affect when dryRun is
+// set, and otherwise deletes it together with everything below it. Content
+// referenced by attempts is only deleted when force is set. before is the
+// entity as it was, for the audit log. then, when given, runs in the
+// transaction of the deletion after it.
+func (r *Resolver) deleteContent(ctx context.Context, kind models.ContentKind, id uuid.UUID, dryRun *bool, force *bool, before interface{}, then func(tx *Resolver) error) (*models.DeletionReport, error) {
+	if dryRun != nil && *dryRun {
+		return r.DeletionRepo.Report(ctx, kind, id)
+	}
+	var report *models.DeletionReport
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if report, err = tx.DeletionRepo.Delete(ctx, kind, id, force != nil && *force); err != nil {
+			return err
+		}
+		// Content kinds share their names with the audit entities
+		if err := tx.recordAudit(ctx, models.AuditDelete, models.AuditEntity(kind), id, before, nil); err != nil {
+			return err
+		}
+		if then != nil {
+			return then(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
 type queryResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
+type questionResolver struct{ *Resolver }
+type optionResolver struct{ *Resolver }
+type passageGroupResolver struct{ *Resolver }
+
+var (
+	_ QueryResolver        = (*queryResolver)(nil)
+	_ MutationResolver     = (*mutationResolver)(nil)
+	_ SubscriptionResolver = (*subscriptionResolver)(nil)
+	_ QuestionResolver     = (*questionResolver)(nil)
+	_ OptionResolver       = (*optionResolver)(nil)
+	_ PassageGroupResolver = (*passageGroupResolver)(nil)
+)
 
 // QueryResolver is the resolver for the Query type
 type QueryResolver interface {
 	// Define only methods we have implementations for
-	Products(ctx context.Context) ([]*models.Product, error)
+	Products(ctx context.Context, filter *models.ProductFilter, sort *models.ProductSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Product], error)
 	Product(ctx context.Context, id uuid.UUID) (*models.Product, error)
-	Tests(ctx context.Context) ([]*models.Test, error)
+	Tests(ctx context.Context, filter *models.TestFilter, sort *models.TestSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Test], error)
 	Test(ctx context.Context, id uuid.UUID) (*models.Test, error)
-	Questions(ctx context.Context, testID uuid.UUID) ([]*models.Question, error)
+	Questions(ctx context.Context, testID uuid.UUID, filter *models.QuestionFilter, sort *models.QuestionSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Question], error)
 	Question(ctx context.Context, id uuid.UUID) (*models.Question, error)
-	CompletedTests(ctx context.Context, userID uuid.UUID) ([]*models.CompletedTest, error)
+	CompletedTests(ctx context.Context, userID uuid.UUID, filter *models.CompletedTestFilter, sort *models.CompletedTestSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.CompletedTest], error)
 	CompletedTest(ctx context.Context, id uuid.UUID) (*models.CompletedTest, error)
 	User(ctx context.Context, id uuid.UUID) (*models.User, error)
+	Trash(ctx context.Context) (*models.Trash, error)
+	AuditLog(ctx context.Context, filter *models.AuditLogFilter, page *models.PageInput) (*models.AuditLogPage, error)
+	QuestionHistory(ctx context.Context, id uuid.UUID) ([]*models.QuestionRevision, error)
+	Regrade(ctx context.Context, id uuid.UUID) (*models.Regrade, error)
+	SearchQuestions(ctx context.Context, query string, filter *models.QuestionSearchFilter, page *models.PageInput) (*models.QuestionSearchPage, error)
+	Taxonomy(ctx context.Context, parentID *uuid.UUID) ([]*models.TaxonomyNode, error)
+	TaxonomyNode(ctx context.Context, id uuid.UUID) (*models.TaxonomyNode, error)
+	TaxonomyPath(ctx context.Context, id uuid.UUID) ([]*models.TaxonomyNode, error)
+	DuplicateQuestions(ctx context.Context, threshold *float64, filter *models.DuplicateFilter) (*models.DuplicateReport, error)
+	ReviewQueue(ctx context.Context, filter *models.ReviewQueueFilter, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Question], error)
+	ReviewHistory(ctx context.Context, questionID uuid.UUID) ([]*models.QuestionReview, error)
+	AttemptQuestions(ctx context.Context, completedTestID uuid.UUID, testID *uuid.UUID) ([]*models.Question, error)
+	PassageGroups(ctx context.Context, testID uuid.UUID) ([]*models.PassageGroup, error)
+	PassageGroup(ctx context.Context, id uuid.UUID) (*models.PassageGroup, error)
+	AttemptItems(ctx context.Context, completedTestID uuid.UUID, testID *uuid.UUID) ([]*models.AttemptItem, error)
+	GradingQueue(ctx context.Context, filter *models.GradingQueueFilter, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.CompletedQuestion], error)
 }
 
 // MutationResolver is the resolver for the Mutation type
@@ -71,16 +298,22 @@ type MutationResolver interface {
 	// Define only methods we have implementations for
 	CreateProduct(ctx context.Context, input models.ProductInput) (*models.Product, error)
 	UpdateProduct(ctx context.Context, id uuid.UUID, input models.ProductInput) (*models.Product, error)
-	DeleteProduct(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteProduct(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error)
 	CreateTest(ctx context.Context, input models.TestInput) (*models.Test, error)
 	UpdateTest(ctx context.Context, id uuid.UUID, input models.TestInput) (*models.Test, error)
-	DeleteTest(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteTest(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error)
 	CreateQuestion(ctx context.Context, input models.QuestionInput) (*models.Question, error)
 	UpdateQuestion(ctx context.Context, id uuid.UUID, input models.QuestionInput) (*models.Question, error)
-	DeleteQuestion(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteQuestion(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error)
 	CreateOption(ctx context.Context, input models.OptionInput) (*models.Option, error)
 	UpdateOption(ctx context.Context, id uuid.UUID, input models.OptionInput) (*models.Option, error)
-	DeleteOption(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteOption(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error)
+	RestoreProduct(ctx context.Context, id uuid.UUID) (*models.Product, error)
+	RestoreTest(ctx context.Context, id uuid.UUID) (*models.Test, error)
+	RestoreQuestion(ctx context.Context, id uuid.UUID) (*models.Question, error)
+	RestoreOption(ctx context.Context, id uuid.UUID) (*models.Option, error)
+	RevertQuestion(ctx context.Context, id uuid.UUID, revision int) (*models.Question, error)
+	Regrade(ctx context.Context, testID *uuid.UUID, questionID *uuid.UUID, completedTestID *uuid.UUID) (*models.Regrade, error)
 	CreateUser(ctx context.Context, input models.UserInput) (*models.User, error)
 	Login(ctx context.Context, username string, password string) (string, error)
 	StartTest(ctx context.Context, input models.StartTestInput) (*models.CompletedTest, error)
@@ -89,6 +322,18 @@ type MutationResolver interface {
 	CreateSource(ctx context.Context, input models.SourceInput) (*models.Source, error)
 	UpdateSource(ctx context.Context, id uuid.UUID, input models.SourceInput) (*models.Source, error)
 	DeleteSource(ctx context.Context, id uuid.UUID) (bool, error)
+	CreateTaxonomyNode(ctx context.Context, input models.TaxonomyNodeInput) (*models.TaxonomyNode, error)
+	UpdateTaxonomyNode(ctx context.Context, id uuid.UUID, input models.TaxonomyNodeInput) (*models.TaxonomyNode, error)
+	DeleteTaxonomyNode(ctx context.Context, id uuid.UUID) (bool, error)
+	MergeTaxonomyNodes(ctx context.Context, id uuid.UUID, into uuid.UUID) (*models.TaxonomyNode, error)
+	MergeQuestions(ctx context.Context, survivorID uuid.UUID, duplicateIDs []uuid.UUID) (*models.MergeReport, error)
+	ReviewQuestion(ctx context.Context, id uuid.UUID, action models.ReviewAction, comment *string) (*models.Question, error)
+	SetUserRole(ctx context.Context, id uuid.UUID, role models.UserRole) (*models.User, error)
+	CreatePassageGroup(ctx context.Context, input models.PassageGroupInput) (*models.PassageGroup, error)
+	UpdatePassageGroup(ctx context.Context, id uuid.UUID, input models.PassageGroupInput) (*models.PassageGroup, error)
+	DeletePassageGroup(ctx context.Context, id uuid.UUID) (bool, error)
+	SetPassageGroupQuestions(ctx context.Context, id uuid.UUID, questionIDs []uuid.UUID) (*models.PassageGroup, error)
+	GradeEssay(ctx context.Context, input models.GradeEssayInput) (*models.EssayGrade, error)
 }
 
 // SubscriptionResolver is the resolver for the Subscription type
@@ -98,3 +343,23 @@ type SubscriptionResolver interface {
 	QuestionAnswered(ctx context.Context, completedTestID uuid.UUID) (<-chan *models.CompletedQuestion, error)
 	TestCompleted(ctx context.Context, userID uuid.UUID) (<-chan *models.CompletedTest, error)
 }
+
+// QuestionResolver resolves the computed fields of the Question type
+type QuestionResolver interface {
+	ImgURL(ctx context.Context, obj *models.Question) (*string, error)
+	ThumbnailURL(ctx context.Context, obj *models.Question) (*string, error)
+	TaxonomyNode(ctx context.Context, obj *models.Question) (*models.TaxonomyNode, error)
+	Rendered(ctx context.Context, obj *models.Question) (*models.RenderedContent, error)
+}
+
+// OptionResolver resolves the computed fields of the Option type
+type OptionResolver interface {
+	ImgURL(ctx context.Context, obj *models.Option) (*string, error)
+	ThumbnailURL(ctx context.Context, obj *models.Option) (*string, error)
+}
+
+// PassageGroupResolver resolves the computed fields of the PassageGroup type
+type PassageGroupResolver interface {
+	Source(ctx context.Context, obj *models.PassageGroup) (*models.Source, error)
+	Questions(ctx context.Context, obj *models.PassageGroup) ([]*models.Question, error)
+}
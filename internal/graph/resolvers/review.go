@@ -0,0 +1,152 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/Alan69/ayatest/internal/review"
+	"github.com/Alan69/ayatest/internal/workflows"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// ReviewQueue returns a page of the questions waiting for review, or in
+// another review status (reviewers only)
+func (r *queryResolver) ReviewQueue(ctx context.Context, filter *models.ReviewQueueFilter, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Question], error) {
+	caller, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !review.IsReviewer(caller) {
+		return nil, ErrUnauthorized
+	}
+
+	var f models.ReviewQueueFilter
+	if filter != nil {
+		f = *filter
+	}
+	return r.ReviewRepo.Queue(ctx, f, pagination.Args{First: first, After: after, Last: last, Before: before})
+}
+
+// ReviewHistory returns the review transitions and comments of a question,
+// oldest first (its author and reviewers only)
+func (r *queryResolver) ReviewHistory(ctx context.Context, questionID uuid.UUID) ([]*models.QuestionReview, error) {
+	caller, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	question, err := r.QuestionRepo.Get(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if !review.IsReviewer(caller) && !review.IsAuthor(caller, question) {
+		return nil, ErrUnauthorized
+	}
+
+	return r.ReviewRepo.History(ctx, questionID)
+}
+
+// ReviewQuestion takes a review action on a question: submitting, approving,
+// rejecting, retiring or reopening it, or commenting on it. Submissions start
+// a review workflow that notifies the reviewers; decisions are signalled to
+// it so the author is told.
+func (r *mutationResolver) ReviewQuestion(ctx context.Context, id uuid.UUID, action models.ReviewAction, comment *string) (*models.Question, error) {
+	caller, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	question, err := r.QuestionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *question
+
+	if err := review.Authorize(action, caller, question, comment); err != nil {
+		return nil, err
+	}
+	var entry *models.QuestionReview
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if entry, err = tx.ReviewRepo.Transition(ctx, id, action, &caller.ID, comment); err != nil {
+			return err
+		}
+		question.Status = entry.ToStatus
+		return tx.recordAudit(ctx, models.AuditReview, models.AuditEntityQuestion, id, &before, question)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.notifyReview(question, entry)
+	return question, nil
+}
+
+// reviseApproved sends an approved question back to review after the caller
+// edited its content. It is called on the resolver of the transaction of the
+// edit and returns the review entry, nil when the question was not approved.
+func (r *Resolver) reviseApproved(ctx context.Context, question *models.Question) (*models.QuestionReview, error) {
+	var actorID *uuid.UUID
+	if id, ok := callerID(ctx); ok {
+		actorID = &id
+	}
+	return r.ReviewRepo.Revise(ctx, question, actorID)
+}
+
+// notifyReview starts, signals or bypasses the review workflow of a question
+// after a review action. Failures are logged and do not fail the request.
+func (r *Resolver) notifyReview(question *models.Question, entry *models.QuestionReview) {
+	switch entry.Action {
+	case models.ReviewSubmit, models.ReviewRevise:
+		_, err := r.TemporalClient.ExecuteWorkflow(
+			context.Background(),
+			client.StartWorkflowOptions{
+				ID:        workflows.ReviewWorkflowID(question.ID),
+				TaskQueue: workflows.TestTaskQueue,
+			},
+			workflows.QuestionReviewWorkflow,
+			workflows.ReviewParams{
+				QuestionID:  question.ID,
+				AuthorID:    question.AuthorID,
+				SubmittedBy: entry.ActorID,
+				Comment:     entry.Comment,
+			},
+		)
+		if err != nil {
+			r.Logger.Errorw("Failed to start question review workflow", "questionID", question.ID, "error", err)
+		}
+		return
+
+	case models.ReviewApprove, models.ReviewReject:
+		err := r.TemporalClient.SignalWorkflow(
+			context.Background(),
+			workflows.ReviewWorkflowID(question.ID),
+			"",
+			workflows.ReviewDecisionSignal,
+			workflows.ReviewDecision{Action: entry.Action, ActorID: entry.ActorID, Comment: entry.Comment},
+		)
+		if err == nil {
+			return
+		}
+		// Questions submitted without a running workflow are decided all
+		// the same, so the author is told directly
+		r.Logger.Warnw("Failed to signal question review workflow", "questionID", question.ID, "error", err)
+	}
+
+	// Other steps are only of interest to the author, when someone else
+	// took them
+	if question.AuthorID == nil || (entry.ActorID != nil && *entry.ActorID == *question.AuthorID) {
+		return
+	}
+	notice := &models.ReviewNotice{
+		QuestionID: question.ID,
+		Action:     entry.Action,
+		Status:     entry.ToStatus,
+		ActorID:    entry.ActorID,
+		Comment:    entry.Comment,
+		Recipients: []uuid.UUID{*question.AuthorID},
+	}
+	if err := r.EventPublisher.PublishQuestionReviewed(notice); err != nil {
+		r.Logger.Errorw("Failed to publish question reviewed event", "questionID", question.ID, "error", err)
+	}
+}
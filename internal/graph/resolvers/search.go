@@ -0,0 +1,42 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Search page sizes
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// ErrEmptySearch is returned for a search without a query
+var ErrEmptySearch = errors.New("search query is required")
+
+// SearchQuestions searches the texts, options and source passages of the
+// question bank, best match first (admin only)
+func (r *queryResolver) SearchQuestions(ctx context.Context, query string, filter *models.QuestionSearchFilter, page *models.PageInput) (*models.QuestionSearchPage, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearch
+	}
+	var f models.QuestionSearchFilter
+	if filter != nil {
+		f = *filter
+	}
+	limit, offset := pageBounds(page, defaultSearchPageSize, maxSearchPageSize)
+
+	hits, total, err := r.QuestionRepo.Search(ctx, query, f, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &models.QuestionSearchPage{Hits: hits, Total: total}, nil
+}
@@ -3,7 +3,6 @@ package resolvers
 import (
 	"context"
 
-	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/models"
 	"github.com/google/uuid"
 )
@@ -14,9 +13,14 @@ func (r *mutationResolver) CreateSource(ctx context.Context, input models.Source
 		Text: input.Text,
 	}
 
-	result := database.DB.Create(source)
-	if result.Error != nil {
-		return nil, result.Error
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.CreateSource(ctx, source); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntitySource, source.ID, nil, source)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return source, nil
@@ -24,27 +28,42 @@ func (r *mutationResolver) CreateSource(ctx context.Context, input models.Source
 
 // UpdateSource updates an existing source
 func (r *mutationResolver) UpdateSource(ctx context.Context, id uuid.UUID, input models.SourceInput) (*models.Source, error) {
-	var source models.Source
-	result := database.DB.First(&source, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
+	source, err := r.QuestionRepo.GetSource(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	before := *source
 
 	source.Text = input.Text
 
-	result = database.DB.Save(&source)
-	if result.Error != nil {
-		return nil, result.Error
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.UpdateSource(ctx, source); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntitySource, source.ID, &before, source)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &source, nil
+	return source, nil
 }
 
 // DeleteSource deletes a source
 func (r *mutationResolver) DeleteSource(ctx context.Context, id uuid.UUID) (bool, error) {
-	result := database.DB.Delete(&models.Source{}, "id = ?", id)
-	if result.Error != nil {
-		return false, result.Error
+	source, err := r.QuestionRepo.GetSource(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.QuestionRepo.DeleteSource(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditDelete, models.AuditEntitySource, id, source, nil)
+	})
+	if err != nil {
+		return false, err
 	}
 
 	return true, nil
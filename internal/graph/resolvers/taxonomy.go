@@ -0,0 +1,149 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// Taxonomy returns the children of a taxonomy node, or the subjects at the
+// root of the taxonomy when no parent is given
+func (r *queryResolver) Taxonomy(ctx context.Context, parentID *uuid.UUID) ([]*models.TaxonomyNode, error) {
+	return r.TaxonomyRepo.List(ctx, parentID)
+}
+
+// TaxonomyNode returns a taxonomy node by ID
+func (r *queryResolver) TaxonomyNode(ctx context.Context, id uuid.UUID) (*models.TaxonomyNode, error) {
+	return r.TaxonomyRepo.Get(ctx, id)
+}
+
+// TaxonomyPath returns a taxonomy node with its ancestors, from the root down
+func (r *queryResolver) TaxonomyPath(ctx context.Context, id uuid.UUID) ([]*models.TaxonomyNode, error) {
+	return r.TaxonomyRepo.Path(ctx, id)
+}
+
+// CreateTaxonomyNode creates a new taxonomy node (admin only)
+func (r *mutationResolver) CreateTaxonomyNode(ctx context.Context, input models.TaxonomyNodeInput) (*models.TaxonomyNode, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	node := &models.TaxonomyNode{
+		ParentID: input.ParentID,
+		Level:    input.Level,
+		Title:    input.Title,
+	}
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.TaxonomyRepo.Create(ctx, node); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityTaxonomyNode, node.ID, nil, node)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// UpdateTaxonomyNode renames or moves a taxonomy node; the questions below it
+// take over its new path (admin only)
+func (r *mutationResolver) UpdateTaxonomyNode(ctx context.Context, id uuid.UUID, input models.TaxonomyNodeInput) (*models.TaxonomyNode, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	node, err := r.TaxonomyRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *node
+
+	node.ParentID = input.ParentID
+	node.Level = input.Level
+	node.Title = input.Title
+
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.TaxonomyRepo.Update(ctx, node); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityTaxonomyNode, node.ID, &before, node)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// DeleteTaxonomyNode deletes a taxonomy node without children or questions
+// (admin only)
+func (r *mutationResolver) DeleteTaxonomyNode(ctx context.Context, id uuid.UUID) (bool, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return false, err
+	}
+
+	node, err := r.TaxonomyRepo.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.TaxonomyRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditDelete, models.AuditEntityTaxonomyNode, id, node, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MergeTaxonomyNodes merges a taxonomy node into another node of the same
+// level, such as a misspelled copy into the original (admin only)
+func (r *mutationResolver) MergeTaxonomyNodes(ctx context.Context, id uuid.UUID, into uuid.UUID) (*models.TaxonomyNode, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	node, err := r.TaxonomyRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *models.TaxonomyNode
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if target, err = tx.TaxonomyRepo.Merge(ctx, id, into); err != nil {
+			return err
+		}
+		// The target keeps its own fields, so only the merged node is recorded
+		return tx.recordAudit(ctx, models.AuditDelete, models.AuditEntityTaxonomyNode, id, node, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// TaxonomyNode resolves the taxonomy node of a question
+func (r *questionResolver) TaxonomyNode(ctx context.Context, obj *models.Question) (*models.TaxonomyNode, error) {
+	if obj.TaxonomyNodeID == nil {
+		return nil, nil
+	}
+	return r.TaxonomyRepo.Get(ctx, *obj.TaxonomyNodeID)
+}
+
+// checkTaxonomyNode checks that a taxonomy node given for a question exists.
+// The database would otherwise link the question by its titles instead.
+func (r *Resolver) checkTaxonomyNode(ctx context.Context, id *uuid.UUID) error {
+	if id == nil {
+		return nil
+	}
+	_, err := r.TaxonomyRepo.Get(ctx, *id)
+	return err
+}
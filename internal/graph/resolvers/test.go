@@ -3,29 +3,23 @@ package resolvers
 import (
 	"context"
 
-	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
 	"github.com/google/uuid"
 )
 
-// GetTests returns all tests
-func (r *queryResolver) Tests(ctx context.Context) ([]*models.Test, error) {
-	var tests []*models.Test
-	result := database.DB.Find(&tests)
-	if result.Error != nil {
-		return nil, result.Error
+// Tests returns a page of tests
+func (r *queryResolver) Tests(ctx context.Context, filter *models.TestFilter, sort *models.TestSort, first *int, after *string, last *int, before *string) (*pagination.Connection[*models.Test], error) {
+	var f models.TestFilter
+	if filter != nil {
+		f = *filter
 	}
-	return tests, nil
+	return r.TestRepo.Page(ctx, f, sort, pagination.Args{First: first, After: after, Last: last, Before: before})
 }
 
 // GetTest returns a test by ID
 func (r *queryResolver) Test(ctx context.Context, id uuid.UUID) (*models.Test, error) {
-	var test models.Test
-	result := database.DB.First(&test, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return &test, nil
+	return r.TestRepo.Get(ctx, id)
 }
 
 // CreateTest creates a new test
@@ -40,9 +34,14 @@ func (r *mutationResolver) CreateTest(ctx context.Context, input models.TestInpu
 		IsRequired:        input.IsRequired != nil && *input.IsRequired,
 	}
 
-	result := database.DB.Create(test)
-	if result.Error != nil {
-		return nil, result.Error
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.TestRepo.Create(ctx, test); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityTest, test.ID, nil, test)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Publish event to NATS
@@ -56,11 +55,11 @@ func (r *mutationResolver) CreateTest(ctx context.Context, input models.TestInpu
 
 // UpdateTest updates an existing test
 func (r *mutationResolver) UpdateTest(ctx context.Context, id uuid.UUID, input models.TestInput) (*models.Test, error) {
-	var test models.Test
-	result := database.DB.First(&test, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
+	test, err := r.TestRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	before := *test
 
 	test.Title = input.Title
 	if input.NumberOfQuestions != nil {
@@ -79,25 +78,35 @@ func (r *mutationResolver) UpdateTest(ctx context.Context, id uuid.UUID, input m
 		test.IsRequired = *input.IsRequired
 	}
 
-	result = database.DB.Save(&test)
-	if result.Error != nil {
-		return nil, result.Error
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.TestRepo.Update(ctx, test); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityTest, test.ID, &before, test)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Publish event to NATS
-	if err := r.EventPublisher.PublishTestUpdated(&test); err != nil {
+	if err := r.EventPublisher.PublishTestUpdated(test); err != nil {
 		// Log the error but don't fail the request
 		r.Logger.Error("Failed to publish test updated event", "error", err)
 	}
 
-	return &test, nil
+	return test, nil
 }
 
-// DeleteTest deletes a test
-func (r *mutationResolver) DeleteTest(ctx context.Context, id uuid.UUID) (bool, error) {
-	result := database.DB.Delete(&models.Test{}, "id = ?", id)
-	if result.Error != nil {
-		return false, result.Error
+// DeleteTest deletes a test with its questions and options
+func (r *mutationResolver) DeleteTest(ctx context.Context, id uuid.UUID, dryRun *bool, force *bool) (*models.DeletionReport, error) {
+	test, err := r.TestRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := r.deleteContent(ctx, models.ContentTest, id, dryRun, force, test, nil)
+	if err != nil || !report.Deleted {
+		return report, err
 	}
 
 	// Publish event to NATS
@@ -106,5 +115,5 @@ func (r *mutationResolver) DeleteTest(ctx context.Context, id uuid.UUID) (bool,
 		r.Logger.Error("Failed to publish test deleted event", "error", err)
 	}
 
-	return true, nil
+	return report, nil
 }
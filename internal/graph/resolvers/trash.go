@@ -0,0 +1,95 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// Trash lists soft deleted content (admin only)
+func (r *queryResolver) Trash(ctx context.Context) (*models.Trash, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TrashRepo.List(ctx)
+}
+
+// RestoreProduct restores a soft deleted product (admin only)
+func (r *mutationResolver) RestoreProduct(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var product *models.Product
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if product, err = tx.ProductRepo.Restore(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditRestore, models.AuditEntityProduct, id, nil, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// RestoreTest restores a soft deleted test (admin only)
+func (r *mutationResolver) RestoreTest(ctx context.Context, id uuid.UUID) (*models.Test, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var test *models.Test
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if test, err = tx.TestRepo.Restore(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditRestore, models.AuditEntityTest, id, nil, test)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return test, nil
+}
+
+// RestoreQuestion restores a soft deleted question (admin only)
+func (r *mutationResolver) RestoreQuestion(ctx context.Context, id uuid.UUID) (*models.Question, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var question *models.Question
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if question, err = tx.QuestionRepo.Restore(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditRestore, models.AuditEntityQuestion, id, nil, question)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return question, nil
+}
+
+// RestoreOption restores a soft deleted option (admin only)
+func (r *mutationResolver) RestoreOption(ctx context.Context, id uuid.UUID) (*models.Option, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var option *models.Option
+	err := r.transaction(ctx, func(tx *Resolver) error {
+		var err error
+		if option, err = tx.QuestionRepo.RestoreOption(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.recordAudit(ctx, models.AuditRestore, models.AuditEntityOption, id, nil, option); err != nil {
+			return err
+		}
+		return tx.saveRevision(ctx, option.QuestionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return option, nil
+}
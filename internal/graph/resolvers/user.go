@@ -2,24 +2,25 @@ package resolvers
 
 import (
 	"context"
+	"errors"
 	"os"
 	"time"
 
-	"github.com/Alan69/ayatest/internal/database"
 	"github.com/Alan69/ayatest/internal/models"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Role errors
+var (
+	ErrInvalidRole = errors.New("role must be USER, ADMIN, REVIEWER or TEACHER")
+	ErrOwnRole     = errors.New("admins cannot change their own role")
+)
+
 // User returns a user by ID
 func (r *queryResolver) User(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	var user models.User
-	result := database.DB.First(&user, "id = ?", id)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return &user, nil
+	return r.UserRepo.Get(ctx, id)
 }
 
 // CreateUser creates a new user
@@ -37,9 +38,14 @@ func (r *mutationResolver) CreateUser(ctx context.Context, input models.UserInpu
 		Role:     models.RoleUser, // Default role is user
 	}
 
-	result := database.DB.Create(user)
-	if result.Error != nil {
-		return nil, result.Error
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.UserRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return user, nil
@@ -47,15 +53,14 @@ func (r *mutationResolver) CreateUser(ctx context.Context, input models.UserInpu
 
 // Login authenticates a user
 func (r *mutationResolver) Login(ctx context.Context, username string, password string) (string, error) {
-	var user models.User
-	result := database.DB.Where("username = ?", username).First(&user)
-	if result.Error != nil {
-		r.Logger.Errorw("Login failed: user not found", "username", username, "error", result.Error)
-		return "", result.Error
+	user, err := r.UserRepo.GetByUsername(ctx, username)
+	if err != nil {
+		r.Logger.Errorw("Login failed: user not found", "username", username, "error", err)
+		return "", err
 	}
 
 	// Verify the password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		r.Logger.Errorw("Login failed: invalid password", "username", username)
 		return "", err
@@ -83,6 +88,12 @@ func (r *mutationResolver) Login(ctx context.Context, username string, password
 		return "", err
 	}
 
+	// The user is not authenticated yet, so record them as the actor
+	err = r.recordAudit(context.WithValue(ctx, "userID", user.ID.String()), models.AuditLogin, models.AuditEntityUser, user.ID, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
 	r.Logger.Infow("User logged in successfully", "username", username)
 	return tokenString, nil
 }
@@ -90,15 +101,8 @@ func (r *mutationResolver) Login(ctx context.Context, username string, password
 // CreateAdmin creates a new admin user (only callable by existing admins)
 func (r *mutationResolver) CreateAdmin(ctx context.Context, input models.UserInput) (*models.User, error) {
 	// Check if the caller is an admin
-	userID, ok := ctx.Value("userID").(string)
-	if !ok {
-		return nil, ErrUnauthorized
-	}
-
-	var caller models.User
-	result := database.DB.First(&caller, "id = ?", userID)
-	if result.Error != nil || caller.Role != models.RoleAdmin {
-		return nil, ErrUnauthorized
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
 	}
 
 	// Hash the password
@@ -114,9 +118,51 @@ func (r *mutationResolver) CreateAdmin(ctx context.Context, input models.UserInp
 		Role:     models.RoleAdmin,
 	}
 
-	result = database.DB.Create(user)
-	if result.Error != nil {
-		return nil, result.Error
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.UserRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditCreate, models.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetUserRole changes the role of a user, such as making them a reviewer or
+// a teacher (admin only)
+func (r *mutationResolver) SetUserRole(ctx context.Context, id uuid.UUID, role models.UserRole) (*models.User, error) {
+	caller, err := r.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch role {
+	case models.RoleUser, models.RoleAdmin, models.RoleReviewer, models.RoleTeacher:
+	default:
+		return nil, ErrInvalidRole
+	}
+	// Keeps at least one admin around
+	if id == caller.ID {
+		return nil, ErrOwnRole
+	}
+
+	user, err := r.UserRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *user
+
+	user.Role = role
+	err = r.transaction(ctx, func(tx *Resolver) error {
+		if err := tx.UserRepo.UpdateRole(ctx, id, role); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, models.AuditUpdate, models.AuditEntityUser, id, &before, user)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return user, nil
@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Alan69/ayatest/internal/aiken"
+	"github.com/Alan69/ayatest/internal/gift"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrNotWritable is returned when exporting to a format that can only be
+// imported
+var ErrNotWritable = errors.New("questions can only be exported as gift or aiken")
+
+// ExportQuestions writes the choice questions of a test in the GIFT or Aiken
+// format and returns the questions the format cannot represent, which are
+// left out
+func (im *Importer) ExportQuestions(ctx context.Context, testID uuid.UUID, format Format, w io.Writer) ([]*models.Question, error) {
+	if format != FormatGIFT && format != FormatAiken {
+		return nil, ErrNotWritable
+	}
+	if _, err := im.repos.Tests.Get(ctx, testID); err != nil {
+		return nil, fmt.Errorf("test %s: %w", testID, err)
+	}
+	all, err := im.repos.Questions.ListByTest(ctx, testID)
+	if err != nil {
+		return nil, err
+	}
+	var questions, skipped []*models.Question
+	for _, q := range all {
+		if q.QuestionType != models.QuestionChoice && q.QuestionType != "" {
+			skipped = append(skipped, q)
+			continue
+		}
+		questions = append(questions, q)
+	}
+	// Group the questions by category, which GIFT writes once per group
+	sort.SliceStable(questions, func(i, j int) bool {
+		return deref(questions[i].Category) < deref(questions[j].Category)
+	})
+	var unwritable []*models.Question
+	if format == FormatGIFT {
+		unwritable, err = gift.Write(w, questions)
+	} else {
+		unwritable, err = aiken.Write(w, questions)
+	}
+	return append(skipped, unwritable...), err
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
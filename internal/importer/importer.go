@@ -0,0 +1,218 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/audit"
+	"github.com/Alan69/ayatest/internal/events"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DefaultBatchSize is the number of questions imported per transaction
+const DefaultBatchSize = 100
+
+// Report is the outcome of an import
+type Report struct {
+	Rows     int        `json:"rows"`
+	Imported int        `json:"imported"`
+	DryRun   bool       `json:"dry_run"`
+	Errors   []RowError `json:"errors"`
+}
+
+// Importer imports spreadsheet questions into a test
+type Importer struct {
+	repos     *repository.Repositories
+	publisher events.Publisher
+	logger    *zap.SugaredLogger
+	batchSize int
+}
+
+// New creates an importer writing batchSize questions per transaction and
+// publishing an event for every question it creates
+func New(repos *repository.Repositories, publisher events.Publisher, logger *zap.SugaredLogger, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Importer{repos: repos, publisher: publisher, logger: logger, batchSize: batchSize}
+}
+
+// ImportQuestions creates the valid questions read from a file, with their
+// options and source passages, in the test. Questions with the same passage
+// share one source. Each batch is written in one transaction; a failed batch
+// is rolled back and its rows reported. With dryRun set the questions are
+// only validated.
+func (im *Importer) ImportQuestions(ctx context.Context, testID uuid.UUID, qs *Questions, dryRun bool, authorID *uuid.UUID) (*Report, error) {
+	if _, err := im.repos.Tests.Get(ctx, testID); err != nil {
+		return nil, fmt.Errorf("test %s: %w", testID, err)
+	}
+
+	report := &Report{Rows: qs.Rows, DryRun: dryRun, Errors: append([]RowError{}, qs.Errors...)}
+	if dryRun {
+		return report, nil
+	}
+
+	sources := newPassages()
+	report.Imported, report.Errors = im.inBatches(ctx, qs.Items, false, report.Errors, func(tx *repository.Repositories, item *Item) error {
+		sources.begin(tx)
+		return createItem(ctx, tx, sources, testID, item, authorID)
+	}, func(batch []*Item) {
+		sources.commit()
+		for _, item := range batch {
+			im.publishCreated(item.Question)
+		}
+	})
+	return report, nil
+}
+
+// publishCreated publishes the event of a question created by a committed
+// batch
+func (im *Importer) publishCreated(question *models.Question) {
+	if err := im.publisher.PublishQuestionCreated(question); err != nil {
+		// Log the error but don't fail the import
+		im.logger.Errorw("Failed to publish question created event", "questionID", question.ID, "error", err)
+	}
+}
+
+// errRollback rolls back the transaction of a batch that only simulates
+// its writes
+var errRollback = errors.New("rollback")
+
+// inBatches runs fn for the items, one transaction per batch, and returns
+// the number of items in committed batches with errs extended by the rows
+// of failed batches. committed, if set, is called with every batch that
+// succeeds. With rollback set every batch is rolled back once fn has run for
+// all its items, and the batch counts as succeeded.
+func (im *Importer) inBatches(ctx context.Context, items []*Item, rollback bool, errs []RowError, fn func(tx *repository.Repositories, item *Item) error, committed func(batch []*Item)) (int, []RowError) {
+	done := 0
+	for start := 0; start < len(items); start += im.batchSize {
+		end := start + im.batchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		batch := items[start:end]
+
+		var failed *Item
+		err := im.repos.Transaction(ctx, func(tx *repository.Repositories) error {
+			for _, item := range batch {
+				failed = item
+				if err := fn(tx, item); err != nil {
+					return err
+				}
+			}
+			if rollback {
+				return errRollback
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errRollback) {
+			for _, item := range batch {
+				message := fmt.Sprintf("not imported, line %d failed", failed.Line)
+				if item == failed {
+					message = err.Error()
+				}
+				errs = append(errs, RowError{Line: item.Line, Message: message})
+			}
+			continue
+		}
+		done += len(batch)
+		if committed != nil {
+			committed(batch)
+		}
+	}
+	return done, errs
+}
+
+// createItem creates a question with its options, its source passage if any,
+// its first revision and its audit entry
+func createItem(ctx context.Context, tx *repository.Repositories, sources *passages, testID uuid.UUID, item *Item, authorID *uuid.UUID) error {
+	item.Question.TestID = testID
+	if item.SourceText != nil {
+		sourceID, err := sources.source(ctx, tx, *item.SourceText)
+		if err != nil {
+			return err
+		}
+		item.Question.SourceTextID = &sourceID
+	}
+	if err := tx.Questions.Create(ctx, item.Question); err != nil {
+		return err
+	}
+	if _, err := tx.Questions.SaveRevision(ctx, item.Question.ID, authorID); err != nil {
+		return err
+	}
+	after, err := audit.Snapshot(item.Question)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, models.AuditCreate, item.Question.ID, authorID, nil, after)
+}
+
+// recordAudit writes the audit entry of a question created or updated by an
+// import in the transaction of its batch
+func recordAudit(ctx context.Context, tx *repository.Repositories, action models.AuditAction, questionID uuid.UUID, authorID *uuid.UUID, before, after json.RawMessage) error {
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	return tx.Audit.Create(ctx, &models.AuditEntry{
+		ActorID:    authorID,
+		Action:     action,
+		EntityType: models.AuditEntityQuestion,
+		EntityID:   questionID,
+		Before:     before,
+		After:      after,
+		Diff:       diff,
+	})
+}
+
+// passages reuses one source per passage text within an import. Sources
+// created in the transaction of a batch are only reused by later batches
+// once it commits, as a rolled back batch takes them with it.
+type passages struct {
+	committed map[string]uuid.UUID
+	pending   map[string]uuid.UUID
+	tx        *repository.Repositories
+}
+
+func newPassages() *passages {
+	return &passages{committed: make(map[string]uuid.UUID)}
+}
+
+// begin switches to the transaction of the batch being written, dropping the
+// sources of a batch that was rolled back
+func (p *passages) begin(tx *repository.Repositories) {
+	if p.tx != tx {
+		p.tx, p.pending = tx, make(map[string]uuid.UUID)
+	}
+}
+
+// source returns the ID of the source of a passage, creating it in tx the
+// first time the passage is seen
+func (p *passages) source(ctx context.Context, tx *repository.Repositories, text string) (uuid.UUID, error) {
+	if id, ok := p.committed[text]; ok {
+		return id, nil
+	}
+	if id, ok := p.pending[text]; ok {
+		return id, nil
+	}
+	source := &models.Source{Text: text}
+	if err := tx.Questions.CreateSource(ctx, source); err != nil {
+		return uuid.Nil, err
+	}
+	p.pending[text] = source.ID
+	return source.ID, nil
+}
+
+// commit makes the sources created by the batch that just committed
+// available to later batches
+func (p *passages) commit() {
+	for text, id := range p.pending {
+		p.committed[text] = id
+	}
+	p.tx, p.pending = nil, nil
+}
@@ -0,0 +1,391 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/audit"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SyncAction is what a legacy sync did with a row
+type SyncAction string
+
+const (
+	SyncCreated   SyncAction = "created"
+	SyncUpdated   SyncAction = "updated"
+	SyncUnchanged SyncAction = "unchanged"
+	SyncSkipped   SyncAction = "skipped"
+)
+
+// SyncReport is the outcome of a legacy sync
+type SyncReport struct {
+	Rows      int          `json:"rows"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Skipped   int          `json:"skipped"`
+	DryRun    bool         `json:"dry_run"`
+	Errors    []RowError   `json:"errors"`
+	Changes   []SyncChange `json:"changes"`
+}
+
+// SyncChange is a row of a legacy sync that created, updated or skipped a
+// question
+type SyncChange struct {
+	Line       int        `json:"line"`
+	DetailID   int        `json:"detail_id"`
+	LngID      int        `json:"lng_id"`
+	QuestionID uuid.UUID  `json:"question_id"`
+	Action     SyncAction `json:"action"`
+	Message    string     `json:"message,omitempty"`
+	// Diff lists the changed fields of an updated question
+	Diff json.RawMessage `json:"diff,omitempty"`
+}
+
+// SyncLegacy upserts questions exported from the legacy platform by their
+// detail_id and lng_id. A row is matched to the question it was synchronized
+// to before, or else to an existing question with the same legacy IDs, and
+// new rows are created in the test. Matched questions are only updated when
+// the legacy row changed since the last sync, so local edits to questions
+// whose legacy row did not change are kept. Questions deleted locally are
+// skipped. With dryRun set every batch is rolled back, so the report shows
+// what a sync would do.
+func (im *Importer) SyncLegacy(ctx context.Context, testID uuid.UUID, qs *Questions, dryRun bool, authorID *uuid.UUID) (*SyncReport, error) {
+	if _, err := im.repos.Tests.Get(ctx, testID); err != nil {
+		return nil, fmt.Errorf("test %s: %w", testID, err)
+	}
+
+	report := &SyncReport{Rows: qs.Rows, DryRun: dryRun, Errors: append([]RowError{}, qs.Errors...), Changes: []SyncChange{}}
+	var items []*Item
+	for _, item := range qs.Items {
+		q := item.Question
+		if q.DetailID == nil || q.LngID == nil {
+			report.Errors = append(report.Errors, RowError{Line: item.Line, Message: "detail_id and lng_id are required"})
+			continue
+		}
+		items = append(items, item)
+	}
+
+	// Changes are reported once their batch commits
+	pending := make(map[*Item]*SyncChange)
+	sources := newPassages()
+	_, report.Errors = im.inBatches(ctx, items, dryRun, report.Errors, func(tx *repository.Repositories, item *Item) error {
+		sources.begin(tx)
+		change, err := syncItem(ctx, tx, sources, testID, item, authorID)
+		if err != nil {
+			return err
+		}
+		pending[item] = change
+		return nil
+	}, func(batch []*Item) {
+		if !dryRun {
+			sources.commit()
+		}
+		for _, item := range batch {
+			change := pending[item]
+			switch change.Action {
+			case SyncCreated:
+				report.Created++
+				if !dryRun {
+					im.publishCreated(item.Question)
+				}
+			case SyncUpdated:
+				report.Updated++
+			case SyncSkipped:
+				report.Skipped++
+			default:
+				report.Unchanged++
+				continue
+			}
+			report.Changes = append(report.Changes, *change)
+		}
+	})
+	return report, nil
+}
+
+// syncItem creates or updates the question of a legacy row and records its
+// mapping
+func syncItem(ctx context.Context, tx *repository.Repositories, sources *passages, testID uuid.UUID, item *Item, authorID *uuid.UUID) (*SyncChange, error) {
+	q := item.Question
+	change := &SyncChange{Line: item.Line, DetailID: *q.DetailID, LngID: *q.LngID}
+	checksum, err := newLegacyView(q, item.SourceText).checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	var current *models.Question
+	m, err := tx.Legacy.GetMap(ctx, change.DetailID, change.LngID)
+	switch {
+	case err == nil:
+		change.QuestionID = m.QuestionID
+		current, err = tx.Questions.Get(ctx, m.QuestionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			change.Action = SyncSkipped
+			change.Message = "question was deleted"
+			return change, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m.Checksum == checksum {
+			change.Action = SyncUnchanged
+			return change, nil
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Questions imported before syncing was introduced are adopted
+		found, err := tx.Legacy.FindQuestions(ctx, change.DetailID, change.LngID)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) > 0 {
+			current = found[0]
+		}
+		if len(found) > 1 {
+			change.Message = fmt.Sprintf("%d other questions have the same detail_id and lng_id", len(found)-1)
+		}
+	default:
+		return nil, err
+	}
+
+	if current == nil {
+		if err := createItem(ctx, tx, sources, testID, item, authorID); err != nil {
+			return nil, err
+		}
+		change.QuestionID = q.ID
+		change.Action = SyncCreated
+	} else {
+		change.QuestionID = current.ID
+		diff, err := updateQuestion(ctx, tx, sources, current, item, authorID)
+		if err != nil {
+			return nil, err
+		}
+		change.Action = SyncUnchanged
+		if diff != nil {
+			change.Action = SyncUpdated
+			change.Diff = diff
+		}
+	}
+
+	err = tx.Legacy.SaveMap(ctx, &models.LegacyQuestionMap{
+		DetailID:   change.DetailID,
+		LngID:      change.LngID,
+		QuestionID: change.QuestionID,
+		Checksum:   checksum,
+		SyncedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// updateQuestion brings a question in line with a legacy row, audits the
+// change and returns the diff of the changed fields, nil when nothing changed
+func updateQuestion(ctx context.Context, tx *repository.Repositories, sources *passages, current *models.Question, item *Item, authorID *uuid.UUID) (json.RawMessage, error) {
+	var currentSource *string
+	if current.SourceTextID != nil {
+		source, err := tx.Questions.GetSource(ctx, *current.SourceTextID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if source != nil {
+			currentSource = &source.Text
+		}
+	}
+
+	before, err := audit.Snapshot(newLegacyView(current, currentSource).sorted())
+	if err != nil {
+		return nil, err
+	}
+	after, err := audit.Snapshot(newLegacyView(item.Question, item.SourceText).sorted())
+	if err != nil {
+		return nil, err
+	}
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	if string(diff) == "{}" {
+		return nil, nil
+	}
+
+	// Sources may be shared, so a changed passage gets a source of its own
+	// text; the question leaves the passage group of the old one
+	q := item.Question
+	if !equalText(currentSource, item.SourceText) {
+		current.SourceTextID = nil
+		current.PassageGroupID, current.PassagePosition = nil, nil
+		if item.SourceText != nil {
+			sourceID, err := sources.source(ctx, tx, *item.SourceText)
+			if err != nil {
+				return nil, err
+			}
+			current.SourceTextID = &sourceID
+		}
+	}
+	current.Text, current.Text2, current.Text3, current.ImgPath = q.Text, q.Text2, q.Text3, q.ImgPath
+	current.TaskType, current.Level = q.TaskType, q.Level
+	current.Category, current.Subcategory, current.Theme, current.Subtheme = q.Category, q.Subcategory, q.Theme, q.Subtheme
+	current.Target, current.Source = q.Target, q.Source
+	current.LngTitle, current.SubjectID, current.SubjectTitle, current.ClassNumber = q.LngTitle, q.SubjectID, q.SubjectTitle, q.ClassNumber
+
+	options := current.Options
+	current.Options = nil
+	current.SourceText = nil
+	if err := tx.Questions.Update(ctx, current); err != nil {
+		return nil, err
+	}
+	if err := syncOptions(ctx, tx, current.ID, options, q.Options); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Reviews.Revise(ctx, current, authorID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Questions.SaveRevision(ctx, current.ID, authorID); err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditUpdate, current.ID, authorID, before, after); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// syncOptions updates the options of a question to the wanted ones. Options
+// are matched by text first so unchanged options keep their IDs; the rest
+// are reused in order, created or deleted.
+func syncOptions(ctx context.Context, tx *repository.Repositories, questionID uuid.UUID, current, wanted []models.Option) error {
+	used := make([]bool, len(current))
+	var unmatched []models.Option
+	for _, w := range wanted {
+		match := -1
+		for j, c := range current {
+			if !used[j] && c.Text == w.Text {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			unmatched = append(unmatched, w)
+			continue
+		}
+		used[match] = true
+		if current[match].IsCorrect != w.IsCorrect {
+			option := current[match]
+			option.IsCorrect = w.IsCorrect
+			if err := tx.Questions.UpdateOption(ctx, &option); err != nil {
+				return err
+			}
+		}
+	}
+
+	var free []models.Option
+	for j, c := range current {
+		if !used[j] {
+			free = append(free, c)
+		}
+	}
+	for k, w := range unmatched {
+		if k < len(free) {
+			option := free[k]
+			option.Text, option.IsCorrect = w.Text, w.IsCorrect
+			if err := tx.Questions.UpdateOption(ctx, &option); err != nil {
+				return err
+			}
+			continue
+		}
+		option := &models.Option{QuestionID: questionID, Text: w.Text, IsCorrect: w.IsCorrect}
+		if err := tx.Questions.CreateOption(ctx, option); err != nil {
+			return err
+		}
+	}
+	for k := len(unmatched); k < len(free); k++ {
+		if _, err := tx.Deletions.Delete(ctx, models.ContentOption, free[k].ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyView is the content of a question that comes from the legacy
+// platform, used to detect changed rows and to diff questions against them
+type legacyView struct {
+	Text         *string        `json:"text"`
+	Text2        *string        `json:"text2"`
+	Text3        *string        `json:"text3"`
+	ImgPath      *string        `json:"img_path"`
+	TaskType     *int           `json:"task_type"`
+	Level        *int           `json:"level"`
+	Category     *string        `json:"category"`
+	Subcategory  *string        `json:"subcategory"`
+	Theme        *string        `json:"theme"`
+	Subtheme     *string        `json:"subtheme"`
+	Target       *string        `json:"target"`
+	Source       *string        `json:"source"`
+	SourceText   *string        `json:"source_text"`
+	LngTitle     *string        `json:"lng_title"`
+	SubjectID    *int           `json:"subject_id"`
+	SubjectTitle *string        `json:"subject_title"`
+	ClassNumber  *int           `json:"class_number"`
+	Options      []legacyOption `json:"options"`
+}
+
+type legacyOption struct {
+	Text      string `json:"text"`
+	IsCorrect bool   `json:"is_correct"`
+}
+
+func newLegacyView(q *models.Question, sourceText *string) *legacyView {
+	v := &legacyView{
+		Text: q.Text, Text2: q.Text2, Text3: q.Text3, ImgPath: q.ImgPath,
+		TaskType: q.TaskType, Level: q.Level,
+		Category: q.Category, Subcategory: q.Subcategory, Theme: q.Theme, Subtheme: q.Subtheme,
+		Target: q.Target, Source: q.Source, SourceText: sourceText,
+		LngTitle: q.LngTitle, SubjectID: q.SubjectID, SubjectTitle: q.SubjectTitle, ClassNumber: q.ClassNumber,
+		Options: []legacyOption{},
+	}
+	for _, o := range q.Options {
+		v.Options = append(v.Options, legacyOption{Text: o.Text, IsCorrect: o.IsCorrect})
+	}
+	return v
+}
+
+// sorted returns a copy with the options in a fixed order, as stored
+// options have none
+func (v *legacyView) sorted() *legacyView {
+	c := *v
+	c.Options = append([]legacyOption{}, v.Options...)
+	sort.SliceStable(c.Options, func(i, j int) bool {
+		if c.Options[i].Text != c.Options[j].Text {
+			return c.Options[i].Text < c.Options[j].Text
+		}
+		return !c.Options[i].IsCorrect && c.Options[j].IsCorrect
+	})
+	return &c
+}
+
+// checksum identifies the content of a legacy row
+func (v *legacyView) checksum() (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func equalText(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,57 @@
+package importer
+
+import (
+	"io"
+
+	"github.com/Alan69/ayatest/internal/aiken"
+	"github.com/Alan69/ayatest/internal/gift"
+)
+
+// Questions are the questions read from a file
+type Questions struct {
+	// Rows is the number of rows or questions in the file
+	Rows   int
+	Items  []*Item
+	Errors []RowError
+}
+
+// Load reads the questions of a file in any of the supported formats
+func Load(r io.Reader, format Format) (*Questions, error) {
+	switch format {
+	case FormatCSV, FormatXLSX:
+		t, err := Read(r, format)
+		if err != nil {
+			return nil, err
+		}
+		items, errs := ParseQuestions(t)
+		return &Questions{Rows: len(t.Rows), Items: items, Errors: errs}, nil
+	case FormatGIFT:
+		entries, errs, err := gift.Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		qs := &Questions{Rows: len(entries) + len(errs)}
+		for _, e := range entries {
+			qs.Items = append(qs.Items, &Item{Line: e.Line, Question: e.Question})
+		}
+		for _, e := range errs {
+			qs.Errors = append(qs.Errors, RowError{Line: e.Line, Message: e.Message})
+		}
+		return qs, nil
+	case FormatAiken:
+		entries, errs, err := aiken.Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		qs := &Questions{Rows: len(entries) + len(errs)}
+		for _, e := range entries {
+			qs.Items = append(qs.Items, &Item{Line: e.Line, Question: e.Question})
+		}
+		for _, e := range errs {
+			qs.Errors = append(qs.Errors, RowError{Line: e.Line, Message: e.Message})
+		}
+		return qs, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
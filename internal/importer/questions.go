@@ -0,0 +1,239 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Question columns. Options are given in option_1 … option_N columns and the
+// correct ones are listed in the correct column by number or letter, e.g.
+// "2" or "A,C". source_text creates a Source passage for the question. The
+// status column of legacy spreadsheets is accepted but ignored, as imported
+// questions are drafts until they pass review.
+const (
+	ColumnText         = "text"
+	ColumnText2        = "text2"
+	ColumnText3        = "text3"
+	ColumnImgPath      = "img_path"
+	ColumnTaskType     = "task_type"
+	ColumnLevel        = "level"
+	ColumnStatus       = "status"
+	ColumnCategory     = "category"
+	ColumnSubcategory  = "subcategory"
+	ColumnTheme        = "theme"
+	ColumnSubtheme     = "subtheme"
+	ColumnTarget       = "target"
+	ColumnSource       = "source"
+	ColumnSourceText   = "source_text"
+	ColumnDetailID     = "detail_id"
+	ColumnLngID        = "lng_id"
+	ColumnLngTitle     = "lng_title"
+	ColumnSubjectID    = "subject_id"
+	ColumnSubjectTitle = "subject_title"
+	ColumnClassNumber  = "class_number"
+	ColumnCorrect      = "correct"
+)
+
+// optionColumn matches the option_N columns
+var optionColumn = regexp.MustCompile(`^option_([1-9][0-9]*)$`)
+
+// Column size limits, matching the database columns
+const (
+	maxLongText  = 2000
+	maxLngTitle  = 100
+	maxOptionLen = 2000
+)
+
+// Item is a validated question ready to be imported
+type Item struct {
+	Line       int
+	Question   *models.Question
+	SourceText *string
+}
+
+// RowError is a problem with a row (or the header, line 1) of a spreadsheet
+type RowError struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("line %d, column %s: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ParseQuestions validates every row of a table and converts the valid ones
+// into questions. Rows with errors are left out.
+func ParseQuestions(t *Table) ([]*Item, []RowError) {
+	var errs []RowError
+
+	// Find the option columns in order and reject unknown columns
+	known := map[string]bool{
+		ColumnText: true, ColumnText2: true, ColumnText3: true, ColumnImgPath: true,
+		ColumnTaskType: true, ColumnLevel: true, ColumnStatus: true,
+		ColumnCategory: true, ColumnSubcategory: true, ColumnTheme: true, ColumnSubtheme: true,
+		ColumnTarget: true, ColumnSource: true, ColumnSourceText: true,
+		ColumnDetailID: true, ColumnLngID: true, ColumnLngTitle: true,
+		ColumnSubjectID: true, ColumnSubjectTitle: true, ColumnClassNumber: true,
+		ColumnCorrect: true,
+	}
+	var optionNumbers []int
+	for _, column := range t.Columns {
+		if m := optionColumn.FindStringSubmatch(column); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			optionNumbers = append(optionNumbers, n)
+			continue
+		}
+		if !known[column] {
+			errs = append(errs, RowError{Line: 1, Column: column, Message: "unknown column"})
+		}
+	}
+	sort.Ints(optionNumbers)
+	if len(optionNumbers) == 0 {
+		errs = append(errs, RowError{Line: 1, Message: "no option_N columns"})
+		return nil, errs
+	}
+
+	var items []*Item
+	for _, row := range t.Rows {
+		item, rowErrs := parseRow(row, optionNumbers)
+		if len(rowErrs) > 0 {
+			errs = append(errs, rowErrs...)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, errs
+}
+
+// parseRow validates a row and converts it into a question with options
+func parseRow(row Row, optionNumbers []int) (*Item, []RowError) {
+	p := rowParser{row: row}
+	q := &models.Question{
+		Text:         p.text(ColumnText, 0),
+		Text2:        p.text(ColumnText2, 0),
+		Text3:        p.text(ColumnText3, 0),
+		ImgPath:      p.text(ColumnImgPath, 0),
+		TaskType:     p.int(ColumnTaskType),
+		Level:        p.int(ColumnLevel),
+		Status:       models.QuestionDraft,
+		Category:     p.text(ColumnCategory, maxLongText),
+		Subcategory:  p.text(ColumnSubcategory, maxLongText),
+		Theme:        p.text(ColumnTheme, maxLongText),
+		Subtheme:     p.text(ColumnSubtheme, maxLongText),
+		Target:       p.text(ColumnTarget, 0),
+		Source:       p.text(ColumnSource, maxLongText),
+		DetailID:     p.int(ColumnDetailID),
+		LngID:        p.int(ColumnLngID),
+		LngTitle:     p.text(ColumnLngTitle, maxLngTitle),
+		SubjectID:    p.int(ColumnSubjectID),
+		SubjectTitle: p.text(ColumnSubjectTitle, maxLongText),
+		ClassNumber:  p.int(ColumnClassNumber),
+	}
+	if q.Text == nil && q.Text2 == nil && q.Text3 == nil && q.ImgPath == nil {
+		p.fail("", "question has no text or image")
+	}
+
+	// Options keep their column number so the correct column can refer to them
+	numbers := make(map[int]int)
+	for _, n := range optionNumbers {
+		column := fmt.Sprintf("option_%d", n)
+		text := p.text(column, maxOptionLen)
+		if text == nil {
+			continue
+		}
+		numbers[n] = len(q.Options)
+		q.Options = append(q.Options, models.Option{Text: *text})
+	}
+	if len(q.Options) < 2 {
+		p.fail("", "question needs at least two options")
+	}
+
+	correct := row.Get(ColumnCorrect)
+	if correct == "" {
+		p.fail(ColumnCorrect, "no correct option given")
+	}
+	for _, ref := range strings.FieldsFunc(correct, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		n, ok := optionRef(ref)
+		if !ok {
+			p.fail(ColumnCorrect, fmt.Sprintf("%q is not an option number or letter", ref))
+			continue
+		}
+		i, ok := numbers[n]
+		if !ok {
+			p.fail(ColumnCorrect, fmt.Sprintf("option %s is empty or missing", ref))
+			continue
+		}
+		q.Options[i].IsCorrect = true
+	}
+
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return &Item{Line: row.Line, Question: q, SourceText: p.text(ColumnSourceText, 0)}, nil
+}
+
+// optionRef parses an option reference: a number from 1 or a letter from A
+func optionRef(ref string) (int, bool) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		return n, n > 0
+	}
+	if len(ref) == 1 {
+		c := strings.ToUpper(ref)[0]
+		if c >= 'A' && c <= 'Z' {
+			return int(c-'A') + 1, true
+		}
+	}
+	return 0, false
+}
+
+// rowParser reads typed cells from a row, collecting errors
+type rowParser struct {
+	row  Row
+	errs []RowError
+}
+
+func (p *rowParser) fail(column, message string) {
+	p.errs = append(p.errs, RowError{Line: p.row.Line, Column: column, Message: message})
+}
+
+// text returns a cell as a string, nil if empty. maxLen limits the length in
+// characters, 0 means unlimited.
+func (p *rowParser) text(column string, maxLen int) *string {
+	value := p.row.Get(column)
+	if value == "" {
+		return nil
+	}
+	if maxLen > 0 && utf8.RuneCountInString(value) > maxLen {
+		p.fail(column, fmt.Sprintf("longer than %d characters", maxLen))
+	}
+	return &value
+}
+
+// int returns a cell as an integer, nil if empty
+func (p *rowParser) int(column string) *int {
+	value := p.row.Get(column)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		// Spreadsheets often store whole numbers as 3.0
+		f, ferr := strconv.ParseFloat(value, 64)
+		if ferr != nil || f != float64(int(f)) {
+			p.fail(column, fmt.Sprintf("%q is not a whole number", value))
+			return nil
+		}
+		n = int(f)
+	}
+	return &n
+}
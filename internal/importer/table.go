@@ -0,0 +1,181 @@
+// Package importer reads questions authored in CSV and XLSX spreadsheets or
+// in the Moodle GIFT and Aiken formats and imports them into a test. Tests
+// can be written back out in the Moodle formats.
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format is a question file format
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatXLSX  Format = "xlsx"
+	FormatGIFT  Format = "gift"
+	FormatAiken Format = "aiken"
+)
+
+// ErrUnknownFormat is returned for files in none of the supported formats
+var ErrUnknownFormat = errors.New("unknown format, expected csv, xlsx, gift or aiken")
+
+// ParseFormat parses a format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatCSV, FormatXLSX, FormatGIFT, FormatAiken:
+		return f, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// FormatFromFilename returns the format of a file from its extension. Plain
+// .txt files are taken to be Aiken, as Moodle exports them.
+func FormatFromFilename(name string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	case ".gift":
+		return FormatGIFT, nil
+	case ".txt":
+		return FormatAiken, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Table is a spreadsheet with a header row. Column names are normalized to
+// lower case with spaces replaced by underscores.
+type Table struct {
+	Columns []string
+	Rows    []Row
+}
+
+// Row is a data row of a table keyed by column name
+type Row struct {
+	// Line is the 1-based line (CSV) or row number (XLSX) in the file
+	Line  int
+	Cells map[string]string
+}
+
+// Get returns the trimmed value of a cell, empty if the column is missing
+func (r Row) Get(column string) string {
+	return strings.TrimSpace(r.Cells[column])
+}
+
+// Read reads a spreadsheet in the given format. XLSX files are read from
+// their first sheet.
+func Read(r io.Reader, format Format) (*Table, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(r)
+	case FormatXLSX:
+		return ReadXLSX(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ReadCSV reads a CSV spreadsheet
+func ReadCSV(r io.Reader) (*Table, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return newTable(records, lines)
+}
+
+// ReadXLSX reads the first sheet of an XLSX spreadsheet
+func ReadXLSX(r io.Reader) (*Table, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	records, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]int, len(records))
+	for i := range records {
+		lines[i] = i + 1
+	}
+	return newTable(records, lines)
+}
+
+// newTable builds a table from raw records, the first being the header.
+// Blank rows are skipped.
+func newTable(records [][]string, lines []int) (*Table, error) {
+	if len(records) == 0 {
+		return nil, errors.New("spreadsheet is empty")
+	}
+
+	t := &Table{}
+	names := make([]string, len(records[0]))
+	seen := make(map[string]bool)
+	for i, name := range records[0] {
+		column := normalizeColumn(name)
+		names[i] = column
+		if column == "" {
+			continue
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+		seen[column] = true
+		t.Columns = append(t.Columns, column)
+	}
+
+	for i, record := range records[1:] {
+		row := Row{Line: lines[i+1], Cells: make(map[string]string)}
+		blank := true
+		for j, value := range record {
+			if j >= len(names) || names[j] == "" {
+				continue
+			}
+			row.Cells[names[j]] = value
+			if strings.TrimSpace(value) != "" {
+				blank = false
+			}
+		}
+		if !blank {
+			t.Rows = append(t.Rows, row)
+		}
+	}
+	return t, nil
+}
+
+// normalizeColumn lower-cases a header, replaces spaces with underscores
+// and strips a UTF-8 byte order mark
+func normalizeColumn(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
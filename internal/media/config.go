@@ -0,0 +1,146 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Storage backends
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// Config configures the image store
+type Config struct {
+	// Storage selects the backend, local or s3
+	Storage string
+
+	// Dir is the directory of the local backend
+	Dir string
+	// BaseURL is the prefix of the signed URLs of the local backend
+	BaseURL string
+	// URLSecret signs the URLs of the local backend
+	URLSecret string
+
+	// URLTTL is how long signed URLs stay valid
+	URLTTL time.Duration
+	// MaxUploadSize limits the size of uploaded images in bytes
+	MaxUploadSize int64
+
+	S3 S3Config
+}
+
+// DefaultMaxUploadSize is the size limit of uploaded images unless
+// MEDIA_MAX_UPLOAD_SIZE sets another
+const DefaultMaxUploadSize = 10 << 20
+
+// ConfigFromEnv reads the image store configuration from environment
+// variables. Local URLs are signed with MEDIA_URL_SECRET, which must differ
+// from JWT_SECRET so that a leaked URL signature key cannot forge logins.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Storage:       getEnv("MEDIA_STORAGE", StorageLocal),
+		Dir:           getEnv("MEDIA_DIR", "media"),
+		BaseURL:       getEnv("MEDIA_BASE_URL", "/media"),
+		URLSecret:     os.Getenv("MEDIA_URL_SECRET"),
+		URLTTL:        time.Hour,
+		MaxUploadSize: DefaultMaxUploadSize,
+		S3: S3Config{
+			Endpoint:       os.Getenv("S3_ENDPOINT"),
+			AccessKey:      os.Getenv("S3_ACCESS_KEY"),
+			SecretKey:      os.Getenv("S3_SECRET_KEY"),
+			Bucket:         getEnv("S3_BUCKET", "ayatest-media"),
+			Region:         getEnv("S3_REGION", "us-east-1"),
+			PublicEndpoint: os.Getenv("S3_PUBLIC_ENDPOINT"),
+		},
+	}
+
+	var err error
+	if cfg.URLTTL, err = envDuration("MEDIA_URL_TTL", cfg.URLTTL); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxUploadSize, err = envInt64("MEDIA_MAX_UPLOAD_SIZE", cfg.MaxUploadSize); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.UseSSL, err = envBool("S3_USE_SSL", false); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.PublicUseSSL, err = envBool("S3_PUBLIC_USE_SSL", cfg.S3.UseSSL); err != nil {
+		return cfg, err
+	}
+
+	switch cfg.Storage {
+	case StorageLocal:
+		if cfg.URLSecret == "" {
+			return cfg, fmt.Errorf("MEDIA_URL_SECRET is required when MEDIA_STORAGE is %s", StorageLocal)
+		}
+		if cfg.URLSecret == os.Getenv("JWT_SECRET") {
+			return cfg, fmt.Errorf("MEDIA_URL_SECRET must differ from JWT_SECRET")
+		}
+	case StorageS3:
+		if cfg.S3.Endpoint == "" {
+			return cfg, fmt.Errorf("S3_ENDPOINT is required when MEDIA_STORAGE is %s", StorageS3)
+		}
+	default:
+		return cfg, fmt.Errorf("invalid MEDIA_STORAGE %q: must be %s or %s", cfg.Storage, StorageLocal, StorageS3)
+	}
+	return cfg, nil
+}
+
+// Open creates the store selected by the configuration
+func Open(ctx context.Context, cfg Config) (Store, error) {
+	if cfg.Storage == StorageS3 {
+		return NewS3(ctx, cfg.S3)
+	}
+	return NewLocal(Dir(cfg.Dir), cfg.BaseURL, []byte(cfg.URLSecret)), nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt64 parses an integer environment variable
+func envInt64(key string, fallback int64) (int64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// envBool parses a boolean environment variable such as "true"
+func envBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// envDuration parses a duration environment variable such as "30s"
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,139 @@
+package media
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors reported for local URLs that cannot be served
+var (
+	ErrInvalidSignature = errors.New("invalid media URL signature")
+	ErrExpired          = errors.New("media URL expired")
+)
+
+// Local stores images below a directory and serves them through URLs signed
+// with an HMAC of the image path and expiry
+type Local struct {
+	dir     Dir
+	baseURL string
+	secret  []byte
+}
+
+// NewLocal creates a local store. baseURL is the prefix under which
+// ServeHTTP is mounted.
+func NewLocal(dir Dir, baseURL string, secret []byte) *Local {
+	return &Local{dir: dir, baseURL: baseURL, secret: secret}
+}
+
+// Open opens the file of an image path
+func (l *Local) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return l.dir.Open(name)
+}
+
+// Save writes an image to a file and returns its image path
+func (l *Local) Save(ctx context.Context, name string, r io.Reader) (string, error) {
+	return l.dir.Save(name, r)
+}
+
+// URL returns the signed URL of an image path
+func (l *Local) URL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	if len(l.secret) == 0 {
+		return "", ErrNoSecret
+	}
+	clean, err := cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(ttl).Unix()
+	u := url.URL{Path: clean}
+	return fmt.Sprintf("%s/%s?expires=%d&signature=%s", strings.TrimRight(l.baseURL, "/"), u.EscapedPath(), expires, l.sign(clean, expires)), nil
+}
+
+// Verify checks the signature and expiry of a URL for an image path
+func (l *Local) Verify(name, expires, signature string) error {
+	if len(l.secret) == 0 {
+		return ErrNoSecret
+	}
+	clean, err := cleanPath(name)
+	if err != nil {
+		return err
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, l.mac(clean, exp)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > exp {
+		return ErrExpired
+	}
+	return nil
+}
+
+// ServeHTTP serves the image named by the request path, which must be
+// stripped of the base URL, once its signature is verified
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Path
+	query := r.URL.Query()
+	if err := l.Verify(name, query.Get("expires"), query.Get("signature")); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	file, err := l.dir.path(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Signed URLs may be cached by the browser until they expire, but not by
+	// shared caches
+	exp, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", exp-time.Now().Unix()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// sign returns the hex encoded signature of an image path and expiry
+func (l *Local) sign(name string, expires int64) string {
+	return hex.EncodeToString(l.mac(name, expires))
+}
+
+func (l *Local) mac(name string, expires int64) []byte {
+	h := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(h, "%s\n%d", name, expires)
+	return h.Sum(nil)
+}
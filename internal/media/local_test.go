@@ -0,0 +1,162 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanPath(t *testing.T) {
+	for _, tc := range []struct {
+		name, want string
+	}{
+		{"images/a.png", "images/a.png"},
+		{"/images/a.png", "images/a.png"},
+		{"images//b/../a.png", "images/a.png"},
+		{"../../etc/passwd", "etc/passwd"},
+		{"images/../../../etc/passwd", "etc/passwd"},
+	} {
+		got, err := cleanPath(tc.name)
+		if err != nil || got != tc.want {
+			t.Errorf("cleanPath(%q) = %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+	for _, name := range []string{"", "/", "..", "https://example.com/a.png"} {
+		if _, err := cleanPath(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("cleanPath(%q) = %v, want fs.ErrNotExist", name, err)
+		}
+	}
+}
+
+func TestDirStaysInsideItsDirectory(t *testing.T) {
+	root := t.TempDir()
+	dir := Dir(filepath.Join(root, "media"))
+
+	name, err := dir.Save("../../outside.png", strings.NewReader("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "media", "outside.png")); err != nil {
+		t.Errorf("Save(%q) did not write below the directory: %v", name, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.png")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Save(%q) wrote outside the directory", name)
+	}
+
+	f, err := dir.Open("images/../../outside.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "image" {
+		t.Errorf("Open read %q, want %q", data, "image")
+	}
+}
+
+func TestLocalURLVerifies(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal(Dir(t.TempDir()), "/media/", []byte("secret"))
+
+	raw, err := l.URL(ctx, "/images/a b.png", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/media/images/a b.png" {
+		t.Fatalf("URL path = %q, want %q", u.Path, "/media/images/a b.png")
+	}
+	q := u.Query()
+	name := strings.TrimPrefix(u.Path, "/media")
+	if err := l.Verify(name, q.Get("expires"), q.Get("signature")); err != nil {
+		t.Errorf("Verify of a fresh URL: %v", err)
+	}
+
+	for _, tc := range []struct {
+		what                     string
+		name, expires, signature string
+	}{
+		{"other path", "/images/b.png", q.Get("expires"), q.Get("signature")},
+		{"later expiry", name, q.Get("expires") + "0", q.Get("signature")},
+		{"tampered signature", name, q.Get("expires"), strings.Repeat("0", len(q.Get("signature")))},
+		{"signature not hex", name, q.Get("expires"), "zz"},
+		{"expiry not a number", name, "soon", q.Get("signature")},
+	} {
+		if err := l.Verify(tc.name, tc.expires, tc.signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Verify with %s = %v, want ErrInvalidSignature", tc.what, err)
+		}
+	}
+
+	other := NewLocal(l.dir, "/media", []byte("other secret"))
+	if err := other.Verify(name, q.Get("expires"), q.Get("signature")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another secret = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestLocalURLExpires(t *testing.T) {
+	l := NewLocal(Dir(t.TempDir()), "/media", []byte("secret"))
+	raw, err := l.URL(context.Background(), "images/a.png", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(raw)
+	q := u.Query()
+	if err := l.Verify("images/a.png", q.Get("expires"), q.Get("signature")); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify of an expired URL = %v, want ErrExpired", err)
+	}
+}
+
+func TestLocalNeedsSecret(t *testing.T) {
+	l := NewLocal(Dir(t.TempDir()), "/media", nil)
+	if _, err := l.URL(context.Background(), "images/a.png", time.Hour); !errors.Is(err, ErrNoSecret) {
+		t.Errorf("URL without a secret = %v, want ErrNoSecret", err)
+	}
+	if err := l.Verify("images/a.png", "1", "00"); !errors.Is(err, ErrNoSecret) {
+		t.Errorf("Verify without a secret = %v, want ErrNoSecret", err)
+	}
+}
+
+func TestLocalServeHTTP(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal(Dir(t.TempDir()), "/media", []byte("secret"))
+	if _, err := l.Save(ctx, "images/a.png", strings.NewReader("image")); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := l.URL(ctx, "images/a.png", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.StripPrefix("/media", l)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, raw, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "image" {
+		t.Errorf("GET signed URL = %d %q, want 200 %q", rec.Code, rec.Body.String(), "image")
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "private, max-age=") {
+		t.Errorf("Cache-Control = %q, want private", cc)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/media/images/a.png", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET unsigned URL = %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, raw, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST signed URL = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+// Package media stores the images of questions and options
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store stores images under image paths
+type Store interface {
+	// Open opens the image stored under an image path
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Save stores an image under the given name and returns its image path
+	Save(ctx context.Context, name string, r io.Reader) (string, error)
+	// URL returns a signed URL through which the image can be fetched until
+	// ttl has passed
+	URL(ctx context.Context, name string, ttl time.Duration) (string, error)
+}
+
+// ErrNoSecret is returned when URLs are requested from a local store that
+// has no secret to sign them with
+var ErrNoSecret = errors.New("media URL secret not configured")
+
+// Dir stores images as files below a directory
+type Dir string
+
+// Open opens the file of an image path
+func (d Dir) Open(name string) (io.ReadCloser, error) {
+	file, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(file)
+}
+
+// Save writes an image to a file and returns its image path
+func (d Dir) Save(name string, r io.Reader) (string, error) {
+	file, err := d.path(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", err
+	}
+	return name, f.Close()
+}
+
+// path resolves an image path below the directory
+func (d Dir) path(name string) (string, error) {
+	clean, err := cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(string(d), filepath.FromSlash(clean)), nil
+}
+
+// cleanPath normalizes an image path. URLs and paths that leave the store
+// are reported as not existing.
+func cleanPath(name string) (string, error) {
+	clean := path.Clean("/" + strings.TrimPrefix(name, "/"))[1:]
+	if clean == "" || IsURL(name) {
+		return "", fmt.Errorf("%q: %w", name, os.ErrNotExist)
+	}
+	return clean, nil
+}
+
+// IsURL reports whether an image path is an absolute URL rather than a path
+// in a store. Such paths predate uploads and are served as they are.
+func IsURL(name string) bool {
+	return strings.Contains(name, "://")
+}
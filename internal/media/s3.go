@@ -0,0 +1,125 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// partSize is the size of the parts images of unknown size are uploaded in
+const partSize = 16 << 20
+
+// S3Config configures an S3 compatible object store such as MinIO
+type S3Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+
+	// PublicEndpoint is the host test takers reach the store through, when it
+	// differs from Endpoint. Signed URLs are issued for this host.
+	PublicEndpoint string
+	PublicUseSSL   bool
+}
+
+// S3 stores images as objects in a bucket and serves them through presigned
+// URLs
+type S3 struct {
+	client *minio.Client
+	// public presigns URLs for the public endpoint
+	public *minio.Client
+	bucket string
+}
+
+// NewS3 connects to an object store and creates the bucket if it is missing
+func NewS3(ctx context.Context, cfg S3Config) (*S3, error) {
+	client, err := newS3Client(cfg.Endpoint, cfg.UseSSL, cfg)
+	if err != nil {
+		return nil, err
+	}
+	public := client
+	if cfg.PublicEndpoint != "" {
+		if public, err = newS3Client(cfg.PublicEndpoint, cfg.PublicUseSSL, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+	return &S3{client: client, public: public, bucket: cfg.Bucket}, nil
+}
+
+// newS3Client creates a client for an endpoint. The region is fixed so that
+// presigning never has to look up the bucket location.
+func newS3Client(endpoint string, useSSL bool, cfg S3Config) (*minio.Client, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: useSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client for %s: %w", endpoint, err)
+	}
+	return client, nil
+}
+
+// Open opens the object of an image path
+func (s *S3) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	key, err := cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%q: %w", name, fs.ErrNotExist)
+		}
+		return nil, err
+	}
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+// Save uploads an image and returns its image path
+func (s *S3) Save(ctx context.Context, name string, r io.Reader) (string, error) {
+	key, err := cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	// The size is unknown, so the image is uploaded in parts of partSize
+	_, err = s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		PartSize:    partSize,
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// URL returns a presigned URL of an image path
+func (s *S3) URL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	key, err := cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	u, err := s.public.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,66 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newTestS3 creates a store for a MinIO stand-in without connecting to it;
+// presigning needs no connection as the region is fixed
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+	cfg := S3Config{
+		Endpoint:       "minio:9000",
+		AccessKey:      "access",
+		SecretKey:      "secret",
+		Bucket:         "ayatest-media",
+		Region:         "us-east-1",
+		PublicEndpoint: "media.example.com",
+		PublicUseSSL:   true,
+	}
+	client, err := newS3Client(cfg.Endpoint, cfg.UseSSL, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := newS3Client(cfg.PublicEndpoint, cfg.PublicUseSSL, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &S3{client: client, public: public, bucket: cfg.Bucket}
+}
+
+func TestS3URLIsPresignedForThePublicEndpoint(t *testing.T) {
+	s := newTestS3(t)
+	raw, err := s.URL(context.Background(), "/images/../images/a.png", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "https" || u.Host != "media.example.com" || u.Path != "/ayatest-media/images/a.png" {
+		t.Errorf("URL = %q, want https://media.example.com/ayatest-media/images/a.png", raw)
+	}
+	q := u.Query()
+	if q.Get("X-Amz-Signature") == "" || q.Get("X-Amz-Expires") != "3600" {
+		t.Errorf("URL %q is not presigned for an hour", raw)
+	}
+}
+
+func TestS3RejectsPathsOutsideTheBucket(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+	for _, name := range []string{"", "https://example.com/a.png"} {
+		if _, err := s.URL(ctx, name, time.Hour); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("URL(%q) = %v, want fs.ErrNotExist", name, err)
+		}
+		if _, err := s.Open(ctx, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Open(%q) = %v, want fs.ErrNotExist", name, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders of the accepted image types
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ThumbnailSize is the largest width and height of thumbnails
+const ThumbnailSize = 256
+
+// maxPixels guards against images that are small on disk but decode to
+// huge bitmaps
+const maxPixels = 40_000_000
+
+// Errors reported for rejected uploads
+var (
+	ErrUnsupportedType = errors.New("unsupported image type: must be PNG, JPEG, GIF or WebP")
+	ErrTooLarge        = errors.New("image too large")
+)
+
+// imageTypes maps the accepted content types to file extensions
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Image describes an uploaded image
+type Image struct {
+	Path          string `json:"path"`
+	ThumbnailPath string `json:"thumbnailPath"`
+	ContentType   string `json:"contentType"`
+	Size          int64  `json:"size"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+}
+
+// Upload validates an image, stores it under images/ with a generated name
+// and stores a PNG thumbnail next to it under thumbs/. The content type is
+// sniffed from the data, not taken from the client.
+func Upload(ctx context.Context, store Store, r io.Reader, maxSize int64) (*Image, error) {
+	return upload(ctx, store, r, maxSize, func([]byte) string { return uuid.New().String() })
+}
+
+// UploadByContent uploads an image as Upload does, named after the SHA-256
+// of its data instead, so the same image is stored once however often it
+// is uploaded
+func UploadByContent(ctx context.Context, store Store, r io.Reader, maxSize int64) (*Image, error) {
+	return upload(ctx, store, r, maxSize, func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	})
+}
+
+// upload validates and stores an image with its thumbnail under the name
+// given for its data
+func upload(ctx context.Context, store Store, r io.Reader, maxSize int64, name func(data []byte) string) (*Image, error) {
+	data, contentType, cfg, err := read(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	var thumb bytes.Buffer
+	if err := png.Encode(&thumb, thumbnail(img)); err != nil {
+		return nil, err
+	}
+
+	imgPath, err := store.Save(ctx, "images/"+name(data)+imageTypes[contentType], bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	thumbPath, err := store.Save(ctx, ThumbnailPath(imgPath), &thumb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		Path:          imgPath,
+		ThumbnailPath: thumbPath,
+		ContentType:   contentType,
+		Size:          int64(len(data)),
+		Width:         cfg.Width,
+		Height:        cfg.Height,
+	}, nil
+}
+
+// Validate checks an image as Upload does without storing it
+func Validate(r io.Reader, maxSize int64) error {
+	data, _, _, err := read(r, maxSize)
+	if err != nil {
+		return err
+	}
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	return nil
+}
+
+// read reads an image of at most maxSize bytes and returns it with its
+// sniffed content type and its dimensions, refusing other types and images
+// that decode to too many pixels
+func read(r io.Reader, maxSize int64) ([]byte, string, image.Config, error) {
+	var cfg image.Config
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, "", cfg, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", cfg, fmt.Errorf("%w: larger than %d bytes", ErrTooLarge, maxSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := imageTypes[contentType]; !ok {
+		return nil, "", cfg, ErrUnsupportedType
+	}
+	cfg, _, err = image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", cfg, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", cfg, fmt.Errorf("%w: %dx%d pixels", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+	return data, contentType, cfg, nil
+}
+
+// ThumbnailPath returns the path of the thumbnail of an uploaded image, or
+// an empty string for images that were not uploaded and have none
+func ThumbnailPath(imgPath string) string {
+	name, ok := strings.CutPrefix(imgPath, "images/")
+	if !ok || IsURL(imgPath) {
+		return ""
+	}
+	return "thumbs/" + strings.TrimSuffix(name, path.Ext(name)) + ".png"
+}
+
+// thumbnail scales an image down to fit ThumbnailSize, keeping its aspect
+// ratio. Smaller images keep their size.
+func thumbnail(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > ThumbnailSize || h > ThumbnailSize {
+		if w >= h {
+			w, h = ThumbnailSize, max(1, h*ThumbnailSize/w)
+		} else {
+			w, h = max(1, w*ThumbnailSize/h), ThumbnailSize
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
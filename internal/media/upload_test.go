@@ -0,0 +1,144 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// encodePNG returns a PNG of the given size
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadStoresImageAndThumbnail(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(Dir(t.TempDir()), "/media", []byte("secret"))
+	data := encodePNG(t, 600, 300)
+
+	img, err := Upload(ctx, store, bytes.NewReader(data), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(img.Path, "images/") || !strings.HasSuffix(img.Path, ".png") {
+		t.Errorf("Path = %q, want a generated name under images/", img.Path)
+	}
+	if img.ThumbnailPath != ThumbnailPath(img.Path) {
+		t.Errorf("ThumbnailPath = %q, want %q", img.ThumbnailPath, ThumbnailPath(img.Path))
+	}
+	if img.ContentType != "image/png" || img.Width != 600 || img.Height != 300 || img.Size != int64(len(data)) {
+		t.Errorf("Upload = %+v", img)
+	}
+
+	f, err := store.Open(ctx, img.ThumbnailPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	thumb, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != ThumbnailSize/2 {
+		t.Errorf("thumbnail is %dx%d, want %dx%d", b.Dx(), b.Dy(), ThumbnailSize, ThumbnailSize/2)
+	}
+
+	again, err := Upload(ctx, store, bytes.NewReader(data), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Path == img.Path {
+		t.Errorf("two uploads were both stored as %q", img.Path)
+	}
+}
+
+func TestUploadByContentNamesByData(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(Dir(t.TempDir()), "/media", []byte("secret"))
+
+	a, err := UploadByContent(ctx, store, bytes.NewReader(encodePNG(t, 10, 10)), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := UploadByContent(ctx, store, bytes.NewReader(encodePNG(t, 10, 10)), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := UploadByContent(ctx, store, bytes.NewReader(encodePNG(t, 20, 10)), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Path != b.Path {
+		t.Errorf("the same image was stored as %q and %q", a.Path, b.Path)
+	}
+	if a.Path == c.Path {
+		t.Errorf("different images were both stored as %q", a.Path)
+	}
+}
+
+func TestUploadRejects(t *testing.T) {
+	store := NewLocal(Dir(t.TempDir()), "/media", []byte("secret"))
+	data := encodePNG(t, 10, 10)
+	for _, tc := range []struct {
+		what    string
+		data    []byte
+		maxSize int64
+		want    error
+	}{
+		{"text", []byte("<svg onload=alert(1)></svg>"), 1 << 20, ErrUnsupportedType},
+		{"truncated PNG", data[:len(data)/2], 1 << 20, ErrUnsupportedType},
+		{"PNG header only", []byte("\x89PNG\r\n\x1a\n"), 1 << 20, ErrUnsupportedType},
+		{"image over the size limit", data, int64(len(data)) - 1, ErrTooLarge},
+	} {
+		if _, err := Upload(context.Background(), store, bytes.NewReader(tc.data), tc.maxSize); !errors.Is(err, tc.want) {
+			t.Errorf("Upload of %s = %v, want %v", tc.what, err, tc.want)
+		}
+		if err := Validate(bytes.NewReader(tc.data), tc.maxSize); !errors.Is(err, tc.want) {
+			t.Errorf("Validate of %s = %v, want %v", tc.what, err, tc.want)
+		}
+	}
+	if err := Validate(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Errorf("Validate of a PNG at the size limit: %v", err)
+	}
+}
+
+func TestUploadRejectsHugeDimensions(t *testing.T) {
+	// A PNG header claiming 10000x10000 pixels, far more than maxPixels,
+	// with the checksum of its IHDR chunk fixed up
+	header := encodePNG(t, 1, 1)[:33]
+	binary.BigEndian.PutUint32(header[16:], 10000)
+	binary.BigEndian.PutUint32(header[20:], 10000)
+	binary.BigEndian.PutUint32(header[29:], crc32.ChecksumIEEE(header[12:29]))
+	if _, _, _, err := read(bytes.NewReader(header), 1<<20); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("read of a 10000x10000 image = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestThumbnailPath(t *testing.T) {
+	for _, tc := range []struct {
+		imgPath, want string
+	}{
+		{"images/abc.jpg", "thumbs/abc.png"},
+		{"images/abc.png", "thumbs/abc.png"},
+		{"qti/test/abc.png", ""},
+		{"https://example.com/images/abc.png", ""},
+	} {
+		if got := ThumbnailPath(tc.imgPath); got != tc.want {
+			t.Errorf("ThumbnailPath(%q) = %q, want %q", tc.imgPath, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// QuestionType is how a question is answered and graded
+type QuestionType string
+
+const (
+	// QuestionChoice is answered by selecting options; it is correct when
+	// exactly the correct options are selected
+	QuestionChoice QuestionType = "CHOICE"
+	// QuestionMatching pairs the text of each option with its match text
+	QuestionMatching QuestionType = "MATCHING"
+	// QuestionOrdering puts the options in the order of their positions
+	QuestionOrdering QuestionType = "ORDERING"
+	// QuestionNumeric is answered by a number within the tolerance of the
+	// numeric answer of the question
+	QuestionNumeric QuestionType = "NUMERIC"
+	// QuestionShortText is answered by a text matching one of the correct
+	// options
+	QuestionShortText QuestionType = "SHORT_TEXT"
+	// QuestionFillBlank is answered by a text for each blank, matching one of
+	// the correct options with the position of the blank
+	QuestionFillBlank QuestionType = "FILL_BLANK"
+	// QuestionEssay is answered by a free text scored by a grader on the
+	// rubric of the question. Essays are only asked in teacher products.
+	QuestionEssay QuestionType = "ESSAY"
+)
+
+// Valid reports whether the type is a known one
+func (t QuestionType) Valid() bool {
+	switch t {
+	case QuestionChoice, QuestionMatching, QuestionOrdering, QuestionNumeric, QuestionShortText, QuestionFillBlank, QuestionEssay:
+		return true
+	default:
+		return false
+	}
+}
+
+// MatchPair is the match text given to an option of a matching question
+type MatchPair struct {
+	OptionID  uuid.UUID `json:"option_id"`
+	MatchText string    `json:"match_text"`
+}
+
+// MatchPairs are the pairs of a matching answer, stored as JSON
+type MatchPairs []MatchPair
+
+// Value writes the pairs as JSON
+func (p MatchPairs) Value() (driver.Value, error) {
+	return jsonValue(p)
+}
+
+// Scan reads the pairs from JSON
+func (p *MatchPairs) Scan(src interface{}) error {
+	return scanJSON(src, p)
+}
+
+// OptionIDs are the options of an ordering answer in their order, stored as
+// JSON
+type OptionIDs []uuid.UUID
+
+// Value writes the IDs as JSON
+func (ids OptionIDs) Value() (driver.Value, error) {
+	return jsonValue(ids)
+}
+
+// Scan reads the IDs from JSON
+func (ids *OptionIDs) Scan(src interface{}) error {
+	return scanJSON(src, ids)
+}
+
+// BlankAnswers are the texts of a fill-in-the-blank answer, one per blank in
+// order, stored as JSON
+type BlankAnswers []string
+
+// Value writes the texts as JSON
+func (b BlankAnswers) Value() (driver.Value, error) {
+	return jsonValue(b)
+}
+
+// Scan reads the texts from JSON
+func (b *BlankAnswers) Scan(src interface{}) error {
+	return scanJSON(src, b)
+}
+
+// jsonValue writes an answer as JSON, and a missing answer as NULL
+func jsonValue[T any](v []T) (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// scanJSON reads an answer from JSON
+func scanJSON(src, dst interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(data, dst)
+	case string:
+		return json.Unmarshal([]byte(data), dst)
+	default:
+		return fmt.Errorf("cannot read an answer from %T", src)
+	}
+}
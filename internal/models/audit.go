@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditAction enum
+type AuditAction string
+
+const (
+	AuditCreate   AuditAction = "CREATE"
+	AuditUpdate   AuditAction = "UPDATE"
+	AuditDelete   AuditAction = "DELETE"
+	AuditRestore  AuditAction = "RESTORE"
+	AuditLogin    AuditAction = "LOGIN"
+	AuditStart    AuditAction = "START"
+	AuditAnswer   AuditAction = "ANSWER"
+	AuditComplete AuditAction = "COMPLETE"
+	AuditRegrade  AuditAction = "REGRADE"
+	AuditMerge    AuditAction = "MERGE"
+	AuditReview   AuditAction = "REVIEW"
+	AuditGrade    AuditAction = "GRADE"
+)
+
+// AuditEntity enum
+type AuditEntity string
+
+const (
+	AuditEntityProduct           AuditEntity = "PRODUCT"
+	AuditEntityTest              AuditEntity = "TEST"
+	AuditEntityQuestion          AuditEntity = "QUESTION"
+	AuditEntityOption            AuditEntity = "OPTION"
+	AuditEntitySource            AuditEntity = "SOURCE"
+	AuditEntityUser              AuditEntity = "USER"
+	AuditEntityCompletedTest     AuditEntity = "COMPLETED_TEST"
+	AuditEntityCompletedQuestion AuditEntity = "COMPLETED_QUESTION"
+	AuditEntityTaxonomyNode      AuditEntity = "TAXONOMY_NODE"
+	AuditEntityPassageGroup      AuditEntity = "PASSAGE_GROUP"
+	AuditEntityEssayGrade        AuditEntity = "ESSAY_GRADE"
+)
+
+// AuditEntry records a single change made through a mutation
+type AuditEntry struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key" json:"id"`
+	ActorID    *uuid.UUID      `gorm:"type:uuid;index" json:"actor_id"`
+	Action     AuditAction     `gorm:"size:20" json:"action"`
+	EntityType AuditEntity     `gorm:"size:30" json:"entity_type"`
+	EntityID   uuid.UUID       `gorm:"type:uuid" json:"entity_id"`
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before"`
+	After      json.RawMessage `gorm:"type:jsonb" json:"after"`
+	Diff       json.RawMessage `gorm:"type:jsonb" json:"diff"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (a *AuditEntry) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// AuditLogPage is a page of audit entries with the total number of matches
+type AuditLogPage struct {
+	Entries []*AuditEntry `json:"entries"`
+	Total   int64         `json:"total"`
+}
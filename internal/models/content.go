@@ -0,0 +1,42 @@
+package models
+
+import "github.com/google/uuid"
+
+// ContentFormat is how the texts of a question and its options are written
+type ContentFormat string
+
+const (
+	// ContentPlain is plain text, shown as written
+	ContentPlain ContentFormat = "PLAIN"
+	// ContentMarkdown is Markdown without raw HTML, with LaTeX math
+	ContentMarkdown ContentFormat = "MARKDOWN"
+	// ContentHTML is a safe subset of HTML, with LaTeX math
+	ContentHTML ContentFormat = "HTML"
+)
+
+// Valid reports whether the format is a known one
+func (f ContentFormat) Valid() bool {
+	switch f {
+	case ContentPlain, ContentMarkdown, ContentHTML:
+		return true
+	default:
+		return false
+	}
+}
+
+// RenderedContent is the content of a question and its options with its math
+// rendered to MathML
+type RenderedContent struct {
+	Text    *string           `json:"text"`
+	Text2   *string           `json:"text2"`
+	Text3   *string           `json:"text3"`
+	Options []*RenderedOption `json:"options"`
+}
+
+// RenderedOption is the text and match text of an option with their math
+// rendered to MathML
+type RenderedOption struct {
+	ID        uuid.UUID `json:"id"`
+	Text      string    `json:"text"`
+	MatchText *string   `json:"match_text"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "github.com/google/uuid"
+
+// ContentKind enum
+type ContentKind string
+
+const (
+	ContentProduct  ContentKind = "PRODUCT"
+	ContentTest     ContentKind = "TEST"
+	ContentQuestion ContentKind = "QUESTION"
+	ContentOption   ContentKind = "OPTION"
+)
+
+// DeletionReport describes the content affected by deleting a product, test,
+// question or option, including the entity itself
+type DeletionReport struct {
+	Entity    ContentKind `json:"entity"`
+	ID        uuid.UUID   `json:"id"`
+	Tests     int64       `json:"tests"`
+	Questions int64       `json:"questions"`
+	Options   int64       `json:"options"`
+	Attempts  int64       `json:"attempts"`
+	Deleted   bool        `json:"deleted"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// DuplicateMember is a question of a duplicate group with the number of
+// answers given to it
+type DuplicateMember struct {
+	Question *Question `json:"question"`
+	Answers  int64     `json:"answers"`
+}
+
+// DuplicateGroup is a set of duplicate or near-duplicate questions. Members
+// are ordered by their number of answers, so the first one is the suggested
+// survivor of a merge.
+type DuplicateGroup struct {
+	Members []*DuplicateMember `json:"members"`
+	// Similarity is the lowest estimated similarity linking the group
+	Similarity float64 `json:"similarity"`
+}
+
+// DuplicateReport lists the duplicate groups found among the scanned
+// questions
+type DuplicateReport struct {
+	Groups  []*DuplicateGroup `json:"groups"`
+	Scanned int               `json:"scanned"`
+}
+
+// MergeReport describes duplicate questions merged into a surviving question
+type MergeReport struct {
+	Survivor *Question `json:"survivor"`
+	// Merged are the duplicates as they were before the merge
+	Merged []*Question `json:"merged"`
+	// Answers counts the completed questions repointed to the survivor
+	Answers int64 `json:"answers"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RubricCriterion is a criterion an essay is scored on, worth up to
+// MaxPoints
+type RubricCriterion struct {
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	MaxPoints   int     `json:"max_points"`
+}
+
+// Rubric is the list of criteria of an essay question, stored as JSON
+type Rubric []RubricCriterion
+
+// Value writes the rubric as JSON
+func (r Rubric) Value() (driver.Value, error) {
+	return jsonValue(r)
+}
+
+// Scan reads the rubric from JSON
+func (r *Rubric) Scan(src interface{}) error {
+	return scanJSON(src, r)
+}
+
+// MaxPoints returns the points an essay can get on the rubric
+func (r Rubric) MaxPoints() int {
+	total := 0
+	for _, c := range r {
+		total += c.MaxPoints
+	}
+	return total
+}
+
+// RubricScore is the points given on a criterion of a rubric, by its index
+type RubricScore struct {
+	Criterion int `json:"criterion"`
+	Points    int `json:"points"`
+}
+
+// RubricScores are the points given on each criterion of a rubric, stored
+// as JSON
+type RubricScores []RubricScore
+
+// Value writes the scores as JSON
+func (s RubricScores) Value() (driver.Value, error) {
+	return jsonValue(s)
+}
+
+// Scan reads the scores from JSON
+func (s *RubricScores) Scan(src interface{}) error {
+	return scanJSON(src, s)
+}
+
+// EssayGrade is the score a grader gave an essay answer on the rubric of its
+// question. Points are out of MaxPoints, the total of the rubric.
+type EssayGrade struct {
+	ID                  uuid.UUID    `gorm:"type:uuid;primary_key" json:"id"`
+	CompletedQuestionID uuid.UUID    `gorm:"type:uuid;uniqueIndex" json:"completed_question_id"`
+	GraderID            *uuid.UUID   `gorm:"type:uuid" json:"grader_id"`
+	Scores              RubricScores `gorm:"type:jsonb" json:"scores"`
+	Points              int          `json:"points"`
+	MaxPoints           int          `json:"max_points"`
+	Comment             *string      `json:"comment"`
+	CreatedAt           time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (g *EssayGrade) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
+
+// Credit returns the share of the points of the rubric the essay got
+func (g *EssayGrade) Credit() float64 {
+	if g.MaxPoints <= 0 {
+		return 0
+	}
+	return float64(g.Points) / float64(g.MaxPoints)
+}
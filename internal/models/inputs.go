@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // ProductInput is the input for creating or updating a product
 type ProductInput struct {
@@ -38,7 +42,6 @@ type QuestionInput struct {
 	ImgPath      *string    `json:"img_path"`
 	TaskType     *int       `json:"task_type"`
 	Level        *int       `json:"level"`
-	Status       *int       `json:"status"`
 	Category     *string    `json:"category"`
 	Subcategory  *string    `json:"subcategory"`
 	Theme        *string    `json:"theme"`
@@ -52,6 +55,34 @@ type QuestionInput struct {
 	SubjectID    *int       `json:"subject_id"`
 	SubjectTitle *string    `json:"subject_title"`
 	ClassNumber  *int       `json:"class_number"`
+	// TaxonomyNodeID links the question to a taxonomy node, replacing its
+	// subject title, category, theme and subtheme with the path of the node
+	TaxonomyNodeID *uuid.UUID `json:"taxonomy_node_id"`
+	// ContentFormat is the format of the texts of the question and its
+	// options, plain text by default
+	ContentFormat *ContentFormat `json:"content_format"`
+	// QuestionType is how the question is answered, a choice question by
+	// default. Numeric questions take their key from NumericAnswer and
+	// Tolerance.
+	QuestionType  *QuestionType `json:"question_type"`
+	NumericAnswer *float64      `json:"numeric_answer"`
+	Tolerance     *float64      `json:"tolerance"`
+	// Rubric replaces the criteria essays are scored on
+	Rubric []RubricCriterion `json:"rubric"`
+}
+
+// TaxonomyNodeInput is the input for creating or updating a taxonomy node
+type TaxonomyNodeInput struct {
+	ParentID *uuid.UUID    `json:"parent_id"`
+	Level    TaxonomyLevel `json:"level"`
+	Title    string        `json:"title"`
+}
+
+// PassageGroupInput is the input for creating or updating a passage group
+type PassageGroupInput struct {
+	TestID   uuid.UUID `json:"test_id"`
+	SourceID uuid.UUID `json:"source_id"`
+	Title    *string   `json:"title"`
 }
 
 // OptionInput is the input for creating or updating an option
@@ -60,6 +91,8 @@ type OptionInput struct {
 	Text       string    `json:"text"`
 	ImgPath    *string   `json:"img_path"`
 	IsCorrect  bool      `json:"is_correct"`
+	MatchText  *string   `json:"match_text"`
+	Position   *int      `json:"position"`
 }
 
 // UserInput represents input for creating a user
@@ -82,6 +115,13 @@ type AnswerQuestionInput struct {
 	TestID            uuid.UUID   `json:"test_id"`
 	QuestionID        uuid.UUID   `json:"question_id"`
 	SelectedOptionIDs []uuid.UUID `json:"selected_option_ids"`
+	// Answers to questions other than choice questions, in the field of
+	// their type
+	MatchedPairs     []MatchPair `json:"matched_pairs"`
+	OrderedOptionIDs []uuid.UUID `json:"ordered_option_ids"`
+	NumericAnswer    *float64    `json:"numeric_answer"`
+	TextAnswer       *string     `json:"text_answer"`
+	BlankAnswers     []string    `json:"blank_answers"`
 }
 
 // CompleteTestInput is the input for completing a test
@@ -89,3 +129,192 @@ type CompleteTestInput struct {
 	CompletedTestID uuid.UUID `json:"completed_test_id"`
 	TimeSpent       int       `json:"time_spent"`
 }
+
+// AuditLogFilter narrows the audit log. Nil fields match everything.
+type AuditLogFilter struct {
+	ActorID    *uuid.UUID   `json:"actor_id"`
+	Action     *AuditAction `json:"action"`
+	EntityType *AuditEntity `json:"entity_type"`
+	EntityID   *uuid.UUID   `json:"entity_id"`
+	From       *time.Time   `json:"from"`
+	To         *time.Time   `json:"to"`
+}
+
+// QuestionSearchFilter narrows a question search. Nil fields match
+// everything. LngTitle also selects the text search configuration of the
+// query.
+type QuestionSearchFilter struct {
+	TestID      *uuid.UUID `json:"test_id"`
+	Category    *string    `json:"category"`
+	Theme       *string    `json:"theme"`
+	Level       *int       `json:"level"`
+	SubjectID   *int       `json:"subject_id"`
+	ClassNumber *int       `json:"class_number"`
+	LngTitle    *string    `json:"lng_title"`
+	// TaxonomyNodeID matches the questions of a taxonomy node and the
+	// nodes below it
+	TaxonomyNodeID *uuid.UUID `json:"taxonomy_node_id"`
+}
+
+// DuplicateFilter narrows a duplicate scan. Nil fields match everything.
+type DuplicateFilter struct {
+	ProductID *uuid.UUID `json:"product_id"`
+	TestID    *uuid.UUID `json:"test_id"`
+}
+
+// ReviewQueueFilter narrows the review queue. Status defaults to in review;
+// nil fields match everything.
+type ReviewQueueFilter struct {
+	Status   *QuestionStatus `json:"status"`
+	TestID   *uuid.UUID      `json:"test_id"`
+	AuthorID *uuid.UUID      `json:"author_id"`
+}
+
+// GradingQueueFilter narrows the grading queue. Nil fields match
+// everything; Graded lists graded essays instead of those waiting for a
+// grade.
+type GradingQueueFilter struct {
+	ProductID *uuid.UUID `json:"product_id"`
+	TestID    *uuid.UUID `json:"test_id"`
+	Graded    *bool      `json:"graded"`
+}
+
+// GradeEssayInput is the input for grading an essay answer
+type GradeEssayInput struct {
+	CompletedQuestionID uuid.UUID     `json:"completed_question_id"`
+	Scores              []RubricScore `json:"scores"`
+	Comment             *string       `json:"comment"`
+}
+
+// SortDirection is the direction of a sort
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "ASC"
+	SortDesc SortDirection = "DESC"
+)
+
+// ProductSortField is a field products can be sorted by
+type ProductSortField string
+
+const (
+	ProductSortTitle       ProductSortField = "TITLE"
+	ProductSortDateCreated ProductSortField = "DATE_CREATED"
+)
+
+// ProductSort sorts products, newest first by default
+type ProductSort struct {
+	Field     ProductSortField `json:"field"`
+	Direction *SortDirection   `json:"direction"`
+}
+
+// ProductFilter narrows a list of products. Nil fields match everything;
+// Title matches a part of the title, ignoring case.
+type ProductFilter struct {
+	Title       *string      `json:"title"`
+	ProductType *ProductType `json:"product_type"`
+}
+
+// TestSortField is a field tests can be sorted by
+type TestSortField string
+
+const (
+	TestSortTitle       TestSortField = "TITLE"
+	TestSortDateCreated TestSortField = "DATE_CREATED"
+	TestSortGrade       TestSortField = "GRADE"
+)
+
+// TestSort sorts tests, oldest first by default
+type TestSort struct {
+	Field     TestSortField  `json:"field"`
+	Direction *SortDirection `json:"direction"`
+}
+
+// TestFilter narrows a list of tests. Nil fields match everything; Title
+// matches a part of the title, ignoring case.
+type TestFilter struct {
+	ProductID  *uuid.UUID `json:"product_id"`
+	Title      *string    `json:"title"`
+	Grade      *int       `json:"grade"`
+	IsRequired *bool      `json:"is_required"`
+}
+
+// QuestionSortField is a field questions can be sorted by
+type QuestionSortField string
+
+const (
+	QuestionSortID       QuestionSortField = "ID"
+	QuestionSortText     QuestionSortField = "TEXT"
+	QuestionSortLevel    QuestionSortField = "LEVEL"
+	QuestionSortCategory QuestionSortField = "CATEGORY"
+	QuestionSortTheme    QuestionSortField = "THEME"
+)
+
+// QuestionSort sorts the questions of a test, by ID by default
+type QuestionSort struct {
+	Field     QuestionSortField `json:"field"`
+	Direction *SortDirection    `json:"direction"`
+}
+
+// QuestionFilter narrows the questions of a test. Nil fields match
+// everything.
+type QuestionFilter struct {
+	Category *string         `json:"category"`
+	Theme    *string         `json:"theme"`
+	Level    *int            `json:"level"`
+	Status   *QuestionStatus `json:"status"`
+	TaskType *int            `json:"task_type"`
+	LngTitle *string         `json:"lng_title"`
+	// TaxonomyNodeID matches the questions of a taxonomy node and the
+	// nodes below it
+	TaxonomyNodeID *uuid.UUID `json:"taxonomy_node_id"`
+}
+
+// CompletedTestSortField is a field completed tests can be sorted by
+type CompletedTestSortField string
+
+const (
+	CompletedTestSortCompletedDate CompletedTestSortField = "COMPLETED_DATE"
+	CompletedTestSortScore         CompletedTestSortField = "SCORE"
+)
+
+// CompletedTestSort sorts completed tests, most recent first by default
+type CompletedTestSort struct {
+	Field     CompletedTestSortField `json:"field"`
+	Direction *SortDirection         `json:"direction"`
+}
+
+// CompletedTestFilter narrows the completed tests of a user. Nil fields
+// match everything; From and To bound the completion date.
+type CompletedTestFilter struct {
+	ProductID *uuid.UUID `json:"product_id"`
+	From      *time.Time `json:"from"`
+	To        *time.Time `json:"to"`
+}
+
+// UserSortField is a field users can be sorted by
+type UserSortField string
+
+const (
+	UserSortUsername UserSortField = "USERNAME"
+	UserSortEmail    UserSortField = "EMAIL"
+)
+
+// UserSort sorts users, by username by default
+type UserSort struct {
+	Field     UserSortField  `json:"field"`
+	Direction *SortDirection `json:"direction"`
+}
+
+// UserFilter narrows a list of users. Nil fields match everything; Query
+// matches a part of the username or email, ignoring case.
+type UserFilter struct {
+	Query *string   `json:"query"`
+	Role  *UserRole `json:"role"`
+}
+
+// PageInput selects a page of a list
+type PageInput struct {
+	Limit  *int `json:"limit"`
+	Offset *int `json:"offset"`
+}
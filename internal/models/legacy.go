@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LegacyQuestionMap links a question to the question of the legacy platform
+// it was synchronized from, identified by its detail and language IDs
+type LegacyQuestionMap struct {
+	DetailID   int       `gorm:"primaryKey;autoIncrement:false" json:"detail_id"`
+	LngID      int       `gorm:"primaryKey;autoIncrement:false" json:"lng_id"`
+	QuestionID uuid.UUID `gorm:"type:uuid" json:"question_id"`
+	// Checksum identifies the content of the legacy row last synchronized
+	Checksum string    `gorm:"size:64" json:"checksum"`
+	SyncedAt time.Time `json:"synced_at"`
+}
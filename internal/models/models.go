@@ -17,15 +17,20 @@ const (
 
 // Product represents a test bundle with metadata
 type Product struct {
-	ID           uuid.UUID   `gorm:"type:uuid;primary_key" json:"id"`
-	Title        string      `gorm:"size:200" json:"title"`
-	Description  *string     `json:"description"`
-	Sum          *int        `json:"sum"`
-	Score        *int        `json:"score"`
-	Time         *int        `json:"time"`
-	SubjectLimit *int        `json:"subject_limit"`
-	ProductType  ProductType `gorm:"size:10;default:STUDENT" json:"product_type"`
-	DateCreated  time.Time   `gorm:"autoCreateTime" json:"date_created"`
+	ID           uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	Title        string         `gorm:"size:200" json:"title"`
+	Description  *string        `json:"description"`
+	Sum          *int           `json:"sum"`
+	Score        *int           `json:"score"`
+	Time         *int           `json:"time"`
+	SubjectLimit *int           `json:"subject_limit"`
+	ProductType  ProductType    `gorm:"size:10;default:STUDENT" json:"product_type"`
+	DateCreated  time.Time      `gorm:"autoCreateTime" json:"date_created"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// OwnerID is the user who created the product. Teachers grade the
+	// essays of the teacher products they own.
+	OwnerID *uuid.UUID `gorm:"type:uuid" json:"owner_id"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -38,16 +43,17 @@ func (p *Product) BeforeCreate(tx *gorm.DB) error {
 
 // Test represents a test belonging to a product
 type Test struct {
-	ID                uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	Title             string    `gorm:"size:200" json:"title"`
-	NumberOfQuestions *int      `json:"number_of_questions"`
-	Time              *int      `json:"time"`
-	Score             *int      `json:"score"`
-	ProductID         uuid.UUID `gorm:"type:uuid" json:"product_id"`
-	Product           Product   `gorm:"foreignKey:ProductID" json:"-"`
-	Grade             *int      `json:"grade"`
-	DateCreated       time.Time `gorm:"autoCreateTime" json:"date_created"`
-	IsRequired        bool      `gorm:"default:false" json:"is_required"`
+	ID                uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	Title             string         `gorm:"size:200" json:"title"`
+	NumberOfQuestions *int           `json:"number_of_questions"`
+	Time              *int           `json:"time"`
+	Score             *int           `json:"score"`
+	ProductID         uuid.UUID      `gorm:"type:uuid" json:"product_id"`
+	Product           Product        `gorm:"foreignKey:ProductID" json:"-"`
+	Grade             *int           `json:"grade"`
+	DateCreated       time.Time      `gorm:"autoCreateTime" json:"date_created"`
+	IsRequired        bool           `gorm:"default:false" json:"is_required"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -74,31 +80,54 @@ func (s *Source) BeforeCreate(tx *gorm.DB) error {
 
 // Question represents a question belonging to a test
 type Question struct {
-	ID           uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	TestID       uuid.UUID  `gorm:"type:uuid" json:"test_id"`
-	Test         Test       `gorm:"foreignKey:TestID" json:"-"`
-	Text         *string    `json:"text"`
-	Text2        *string    `json:"text2"`
-	Text3        *string    `json:"text3"`
-	ImgPath      *string    `json:"img_path"`
-	TaskType     *int       `json:"task_type"`
-	Level        *int       `json:"level"`
-	Status       *int       `json:"status"`
-	Category     *string    `gorm:"size:2000" json:"category"`
-	Subcategory  *string    `gorm:"size:2000" json:"subcategory"`
-	Theme        *string    `gorm:"size:2000" json:"theme"`
-	Subtheme     *string    `gorm:"size:2000" json:"subtheme"`
-	Target       *string    `json:"target"`
-	Source       *string    `gorm:"size:2000" json:"source"`
-	SourceTextID *uuid.UUID `gorm:"type:uuid" json:"source_text_id"`
-	SourceText   *Source    `gorm:"foreignKey:SourceTextID" json:"-"`
-	DetailID     *int       `json:"detail_id"`
-	LngID        *int       `json:"lng_id"`
-	LngTitle     *string    `gorm:"size:100" json:"lng_title"`
-	SubjectID    *int       `json:"subject_id"`
-	SubjectTitle *string    `gorm:"size:2000" json:"subject_title"`
-	ClassNumber  *int       `json:"class_number"`
-	Options      []Option   `gorm:"foreignKey:QuestionID" json:"options"`
+	ID           uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	TestID       uuid.UUID      `gorm:"type:uuid" json:"test_id"`
+	Test         Test           `gorm:"foreignKey:TestID" json:"-"`
+	Text         *string        `json:"text"`
+	Text2        *string        `json:"text2"`
+	Text3        *string        `json:"text3"`
+	ImgPath      *string        `json:"img_path"`
+	TaskType     *int           `json:"task_type"`
+	Level        *int           `json:"level"`
+	Status       QuestionStatus `gorm:"not null;default:0" json:"status"`
+	Category     *string        `gorm:"size:2000" json:"category"`
+	Subcategory  *string        `gorm:"size:2000" json:"subcategory"`
+	Theme        *string        `gorm:"size:2000" json:"theme"`
+	Subtheme     *string        `gorm:"size:2000" json:"subtheme"`
+	Target       *string        `json:"target"`
+	Source       *string        `gorm:"size:2000" json:"source"`
+	SourceTextID *uuid.UUID     `gorm:"type:uuid" json:"source_text_id"`
+	SourceText   *Source        `gorm:"foreignKey:SourceTextID" json:"-"`
+	DetailID     *int           `json:"detail_id"`
+	LngID        *int           `json:"lng_id"`
+	LngTitle     *string        `gorm:"size:100" json:"lng_title"`
+	SubjectID    *int           `json:"subject_id"`
+	SubjectTitle *string        `gorm:"size:2000" json:"subject_title"`
+	ClassNumber  *int           `json:"class_number"`
+	Options      []Option       `gorm:"foreignKey:QuestionID" json:"options"`
+	Revision     int            `gorm:"default:0" json:"revision"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// TaxonomyNodeID is the deepest taxonomy node of the question; the
+	// subject title, category, theme and subtheme mirror its path
+	TaxonomyNodeID *uuid.UUID `gorm:"type:uuid" json:"taxonomy_node_id"`
+	// AuthorID is the user who created the question, who submits it for
+	// review and may not approve it
+	AuthorID *uuid.UUID `gorm:"type:uuid" json:"author_id"`
+	// PassageGroupID is the group of the questions sharing the passage of
+	// the question, which has its PassagePosition in it
+	PassageGroupID  *uuid.UUID `gorm:"type:uuid" json:"passage_group_id"`
+	PassagePosition *int       `json:"passage_position"`
+	// ContentFormat is the format of the texts of the question and its
+	// options
+	ContentFormat ContentFormat `gorm:"size:20;not null;default:PLAIN" json:"content_format"`
+	// QuestionType is how the question is answered and graded. Numeric
+	// questions are correct within Tolerance of NumericAnswer.
+	QuestionType  QuestionType `gorm:"size:20;not null;default:CHOICE" json:"question_type"`
+	NumericAnswer *float64     `json:"numeric_answer"`
+	Tolerance     *float64     `json:"tolerance"`
+	// Rubric is the criteria the answers to an essay question are scored on
+	Rubric Rubric `gorm:"type:jsonb" json:"rubric"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -111,12 +140,19 @@ func (q *Question) BeforeCreate(tx *gorm.DB) error {
 
 // Option represents an answer option for a question
 type Option struct {
-	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	QuestionID uuid.UUID `gorm:"type:uuid" json:"question_id"`
-	Question   Question  `gorm:"foreignKey:QuestionID" json:"-"`
-	Text       string    `gorm:"size:2000" json:"text"`
-	ImgPath    *string   `json:"img_path"`
-	IsCorrect  bool      `gorm:"default:false" json:"is_correct"`
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	QuestionID uuid.UUID      `gorm:"type:uuid" json:"question_id"`
+	Question   Question       `gorm:"foreignKey:QuestionID" json:"-"`
+	Text       string         `gorm:"size:2000" json:"text"`
+	ImgPath    *string        `json:"img_path"`
+	IsCorrect  bool           `gorm:"default:false" json:"is_correct"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// MatchText is the text the option is paired with in a matching question
+	MatchText *string `gorm:"size:2000" json:"match_text"`
+	// Position is the place of the option in the order of an ordering
+	// question, or the blank it fills in a fill-in-the-blank question
+	Position *int `json:"position"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -131,8 +167,10 @@ func (o *Option) BeforeCreate(tx *gorm.DB) error {
 type UserRole string
 
 const (
-	RoleUser  UserRole = "USER"
-	RoleAdmin UserRole = "ADMIN"
+	RoleUser     UserRole = "USER"
+	RoleAdmin    UserRole = "ADMIN"
+	RoleReviewer UserRole = "REVIEWER"
+	RoleTeacher  UserRole = "TEACHER"
 )
 
 // User represents a user in the system
@@ -154,16 +192,20 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 
 // CompletedTest represents a test completed by a user
 type CompletedTest struct {
-	ID            uuid.UUID           `gorm:"type:uuid;primary_key" json:"id"`
-	UserID        uuid.UUID           `gorm:"type:uuid" json:"user_id"`
-	User          User                `gorm:"foreignKey:UserID" json:"-"`
-	ProductID     uuid.UUID           `gorm:"type:uuid" json:"product_id"`
-	Product       Product             `gorm:"foreignKey:ProductID" json:"-"`
-	CompletedDate time.Time           `gorm:"autoCreateTime" json:"completed_date"`
-	StartTestTime *time.Time          `json:"start_test_time"`
-	TimeSpent     *int                `json:"time_spent"`
-	Tests         []*Test             `gorm:"many2many:completed_test_tests;" json:"tests"`
-	Questions     []CompletedQuestion `gorm:"foreignKey:CompletedTestID" json:"completed_questions"`
+	ID             uuid.UUID           `gorm:"type:uuid;primary_key" json:"id"`
+	UserID         uuid.UUID           `gorm:"type:uuid" json:"user_id"`
+	User           User                `gorm:"foreignKey:UserID" json:"-"`
+	ProductID      uuid.UUID           `gorm:"type:uuid" json:"product_id"`
+	Product        Product             `gorm:"foreignKey:ProductID" json:"-"`
+	CompletedDate  time.Time           `gorm:"autoCreateTime" json:"completed_date"`
+	StartTestTime  *time.Time          `json:"start_test_time"`
+	TimeSpent      *int                `json:"time_spent"`
+	Score          *int                `json:"score"`
+	CorrectAnswers *int                `json:"correct_answers"`
+	TotalQuestions *int                `json:"total_questions"`
+	CheckedAt      *time.Time          `json:"checked_at"`
+	Tests          []*Test             `gorm:"many2many:completed_test_tests;" json:"tests"`
+	Questions      []CompletedQuestion `gorm:"foreignKey:CompletedTestID" json:"completed_questions"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -184,6 +226,21 @@ type CompletedQuestion struct {
 	QuestionID      *uuid.UUID    `gorm:"type:uuid" json:"question_id"`
 	Question        *Question     `gorm:"foreignKey:QuestionID" json:"-"`
 	SelectedOptions []*Option     `gorm:"many2many:completed_question_selected_options;" json:"selected_options"`
+
+	// QuestionRevision is the revision of the question when it was answered
+	QuestionRevision *int `json:"question_revision"`
+	// PinnedQuestion is the question as it was at QuestionRevision
+	PinnedQuestion *Question `gorm:"-" json:"-"`
+
+	// The answer to a question other than a choice question, in the field
+	// of its type
+	MatchedPairs     MatchPairs   `gorm:"type:jsonb" json:"matched_pairs"`
+	OrderedOptionIDs OptionIDs    `gorm:"type:jsonb" json:"ordered_option_ids"`
+	NumericAnswer    *float64     `json:"numeric_answer"`
+	TextAnswer       *string      `json:"text_answer"`
+	BlankAnswers     BlankAnswers `gorm:"type:jsonb" json:"blank_answers"`
+	// EssayGrade is the grade of an essay answer, nil until it is graded
+	EssayGrade *EssayGrade `gorm:"foreignKey:CompletedQuestionID" json:"essay_grade"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PassageGroup is a set of questions of a test sharing a reading passage.
+// Its questions are drawn together and delivered in their passage order,
+// after the passage.
+type PassageGroup struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	TestID    uuid.UUID `gorm:"type:uuid" json:"test_id"`
+	SourceID  uuid.UUID `gorm:"type:uuid" json:"source_id"`
+	Title     *string   `gorm:"size:200" json:"title"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (g *PassageGroup) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
+
+// AttemptItem is what an attempt delivers at once: a passage with the
+// questions of its group in order, or a single question
+type AttemptItem struct {
+	PassageGroup *PassageGroup `json:"passage_group"`
+	Passage      *Source       `json:"passage"`
+	Questions    []*Question   `json:"questions"`
+}
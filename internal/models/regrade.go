@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Regrade is a rescoring of the attempts that include a test or question, or
+// of a single attempt, against the current answer key
+type Regrade struct {
+	ID              uuid.UUID        `gorm:"type:uuid;primary_key" json:"id"`
+	TestID          *uuid.UUID       `gorm:"type:uuid" json:"test_id"`
+	QuestionID      *uuid.UUID       `gorm:"type:uuid" json:"question_id"`
+	CompletedTestID *uuid.UUID       `gorm:"type:uuid" json:"completed_test_id"`
+	RequestedBy     *uuid.UUID       `gorm:"type:uuid" json:"requested_by"`
+	WorkflowID      string           `gorm:"size:100" json:"workflow_id"`
+	Attempts        int              `json:"attempts"`
+	Changed         int              `json:"changed"`
+	CreatedAt       time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	CompletedAt     *time.Time       `json:"completed_at"`
+	Results         []*RegradeResult `gorm:"foreignKey:RegradeID" json:"results,omitempty"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (r *Regrade) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// RegradeCursor is the position of an attempt in the order a regrade goes
+// through them: by the date the attempt was started, then by ID
+type RegradeCursor struct {
+	CompletedDate time.Time `json:"completed_date"`
+	ID            uuid.UUID `json:"id"`
+}
+
+// RegradeResult is the old and new score of an attempt in a regrade
+type RegradeResult struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	RegradeID       uuid.UUID `gorm:"type:uuid" json:"regrade_id"`
+	CompletedTestID uuid.UUID `gorm:"type:uuid" json:"completed_test_id"`
+	OldScore        *int      `json:"old_score"`
+	NewScore        int       `json:"new_score"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (r *RegradeResult) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Changed reports whether the regrade changed the score
+func (r *RegradeResult) Changed() bool {
+	return r.OldScore == nil || *r.OldScore != r.NewScore
+}
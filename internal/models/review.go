@@ -0,0 +1,135 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QuestionStatus is the editorial state of a question, stored as an integer
+// in its status column
+type QuestionStatus int
+
+const (
+	QuestionDraft QuestionStatus = iota
+	QuestionInReview
+	QuestionApproved
+	QuestionRetired
+)
+
+// questionStatusNames are the names of the statuses by value
+var questionStatusNames = []string{"DRAFT", "IN_REVIEW", "APPROVED", "RETIRED"}
+
+// Valid reports whether the status is a known one
+func (s QuestionStatus) Valid() bool {
+	return s >= 0 && int(s) < len(questionStatusNames)
+}
+
+// String returns the name of the status
+func (s QuestionStatus) String() string {
+	if !s.Valid() {
+		return strconv.Itoa(int(s))
+	}
+	return questionStatusNames[s]
+}
+
+// ParseQuestionStatus parses a status by name, ignoring case, or by number
+func ParseQuestionStatus(value string) (QuestionStatus, error) {
+	value = strings.TrimSpace(value)
+	for i, name := range questionStatusNames {
+		if strings.EqualFold(value, name) {
+			return QuestionStatus(i), nil
+		}
+	}
+	if n, err := strconv.Atoi(value); err == nil && QuestionStatus(n).Valid() {
+		return QuestionStatus(n), nil
+	}
+	return 0, fmt.Errorf("%q is not a question status, use %s", value, strings.Join(questionStatusNames, ", "))
+}
+
+// MarshalGQL writes the status as a GraphQL enum value
+func (s QuestionStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(s.String()))
+}
+
+// UnmarshalGQL reads the status from a GraphQL enum value
+func (s *QuestionStatus) UnmarshalGQL(v interface{}) error {
+	name, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("question status must be a string")
+	}
+	status, err := ParseQuestionStatus(name)
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// ReviewAction enum
+type ReviewAction string
+
+const (
+	// ReviewSubmit sends a draft to the reviewers
+	ReviewSubmit ReviewAction = "SUBMIT"
+	// ReviewApprove approves a question in review, so it is served in tests
+	ReviewApprove ReviewAction = "APPROVE"
+	// ReviewReject sends a question in review back to its author as a draft
+	ReviewReject ReviewAction = "REJECT"
+	// ReviewRetire takes an approved question out of tests
+	ReviewRetire ReviewAction = "RETIRE"
+	// ReviewReopen turns a retired question back into a draft
+	ReviewReopen ReviewAction = "REOPEN"
+	// ReviewComment comments on a question without changing its status
+	ReviewComment ReviewAction = "COMMENT"
+	// ReviewRevise sends an approved question back to review when its
+	// content is edited
+	ReviewRevise ReviewAction = "REVISE"
+)
+
+// QuestionReview is an entry in the review history of a question: a status
+// transition or a comment
+type QuestionReview struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	QuestionID uuid.UUID      `gorm:"type:uuid" json:"question_id"`
+	ActorID    *uuid.UUID     `gorm:"type:uuid" json:"actor_id"`
+	Action     ReviewAction   `gorm:"size:20" json:"action"`
+	FromStatus QuestionStatus `json:"from_status"`
+	ToStatus   QuestionStatus `json:"to_status"`
+	Comment    *string        `json:"comment"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (r *QuestionReview) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// ReviewNotice tells users about a review step of a question: reviewers when
+// a question is submitted, the author once it is decided
+type ReviewNotice struct {
+	QuestionID uuid.UUID      `json:"question_id"`
+	Action     ReviewAction   `json:"action"`
+	Status     QuestionStatus `json:"status"`
+	ActorID    *uuid.UUID     `json:"actor_id"`
+	Comment    *string        `json:"comment"`
+	// Recipients are the users to notify
+	Recipients []uuid.UUID `json:"recipients"`
+}
+
+// AttemptQuestion is a question drawn for an attempt when it was started
+type AttemptQuestion struct {
+	CompletedTestID uuid.UUID `gorm:"type:uuid;primaryKey" json:"completed_test_id"`
+	QuestionID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"question_id"`
+	TestID          uuid.UUID `gorm:"type:uuid" json:"test_id"`
+	// Position orders the questions of a test within the attempt
+	Position int `json:"position"`
+}
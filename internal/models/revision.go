@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QuestionRevision is a snapshot of a question and its options taken after
+// every change, so attempts can be graded against the revision they answered
+type QuestionRevision struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key" json:"id"`
+	QuestionID uuid.UUID       `gorm:"type:uuid" json:"question_id"`
+	Revision   int             `json:"revision"`
+	Snapshot   json.RawMessage `gorm:"type:jsonb" json:"snapshot"`
+	AuthorID   *uuid.UUID      `gorm:"type:uuid" json:"author_id"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime" json:"created_at"`
+
+	// Diff is the change from the previous revision, filled in for history
+	Diff json.RawMessage `gorm:"-" json:"diff,omitempty"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (r *QuestionRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Question decodes the snapshot
+func (r *QuestionRevision) Question() (*Question, error) {
+	var question Question
+	if err := json.Unmarshal(r.Snapshot, &question); err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
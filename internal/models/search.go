@@ -0,0 +1,16 @@
+package models
+
+// QuestionSearchHit is a question matching a search. Snippet holds the
+// matching passages with the matched words wrapped in <mark> tags.
+type QuestionSearchHit struct {
+	Question *Question `json:"question"`
+	Rank     float64   `json:"rank"`
+	Snippet  string    `json:"snippet"`
+}
+
+// QuestionSearchPage is a page of search hits, best match first, with the
+// total number of matches
+type QuestionSearchPage struct {
+	Hits  []*QuestionSearchHit `json:"hits"`
+	Total int64                `json:"total"`
+}
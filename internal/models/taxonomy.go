@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TaxonomyLevel enum
+type TaxonomyLevel string
+
+const (
+	TaxonomySubject  TaxonomyLevel = "SUBJECT"
+	TaxonomyCategory TaxonomyLevel = "CATEGORY"
+	TaxonomyTheme    TaxonomyLevel = "THEME"
+	TaxonomySubtheme TaxonomyLevel = "SUBTHEME"
+)
+
+// TaxonomyLevels lists the levels from the root down
+var TaxonomyLevels = []TaxonomyLevel{TaxonomySubject, TaxonomyCategory, TaxonomyTheme, TaxonomySubtheme}
+
+// Depth returns the position of the level from the root, or -1 for unknown
+// levels
+func (l TaxonomyLevel) Depth() int {
+	for i, level := range TaxonomyLevels {
+		if level == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// TaxonomyNode is a subject, category, theme or subtheme questions are
+// classified by. Nodes of a level may skip levels above them, so a theme can
+// sit directly below a subject.
+type TaxonomyNode struct {
+	ID       uuid.UUID     `gorm:"type:uuid;primary_key" json:"id"`
+	ParentID *uuid.UUID    `gorm:"type:uuid" json:"parent_id"`
+	Level    TaxonomyLevel `gorm:"size:20" json:"level"`
+	Title    string        `gorm:"size:2000" json:"title"`
+	// NormalizedTitle matches differently spelled copies of the title
+	NormalizedTitle string    `gorm:"size:2000" json:"-"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (n *TaxonomyNode) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
+
+// CleanTaxonomyTitle trims a title and collapses its whitespace
+func CleanTaxonomyTitle(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// NormalizeTaxonomyTitle returns the form titles are matched by: cleaned and
+// in lower case
+func NormalizeTaxonomyTitle(title string) string {
+	return strings.ToLower(CleanTaxonomyTitle(title))
+}
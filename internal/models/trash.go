@@ -0,0 +1,17 @@
+package models
+
+// Trash lists soft deleted content that can still be restored
+type Trash struct {
+	Products  []*Product  `json:"products"`
+	Tests     []*Test     `json:"tests"`
+	Questions []*Question `json:"questions"`
+	Options   []*Option   `json:"options"`
+}
+
+// PurgeResult counts the soft deleted rows removed permanently by a purge
+type PurgeResult struct {
+	Products  int64 `json:"products"`
+	Tests     int64 `json:"tests"`
+	Questions int64 `json:"questions"`
+	Options   int64 `json:"options"`
+}
@@ -0,0 +1,223 @@
+// Package pagination pages lists with opaque cursors, following the Relay
+// connection specification. Pages are selected by keyset: a cursor holds the
+// sort key and ID of a row, and the next page starts right after it, so
+// pages stay stable while rows are inserted or deleted.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Page sizes
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Errors reported for invalid page arguments
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrFirstAndLast  = errors.New("first and last cannot be combined")
+	ErrNegativeLimit = errors.New("first and last must not be negative")
+)
+
+// Args selects a page: the first items after a cursor or the last items
+// before one
+type Args struct {
+	First  *int    `json:"first"`
+	After  *string `json:"after"`
+	Last   *int    `json:"last"`
+	Before *string `json:"before"`
+}
+
+// Direction is a sort direction
+type Direction string
+
+const (
+	Asc  Direction = "ASC"
+	Desc Direction = "DESC"
+)
+
+// Order sorts a list by a key. Rows with equal keys are sorted by ID.
+type Order[T any] struct {
+	// Name identifies the order in cursors together with the direction, so
+	// a cursor cannot be used with another order
+	Name string
+	// Column is the SQL expression sorted by. It must never be NULL.
+	Column string
+	// IDColumn is the ID column of the rows
+	IDColumn  string
+	Direction Direction
+	// Key returns the sort key of a row, the value of Column
+	Key func(T) interface{}
+	// ID returns the ID of a row
+	ID func(T) uuid.UUID
+}
+
+// id identifies the order in cursors
+func (o Order[T]) id() string {
+	return o.Name + " " + string(o.Direction)
+}
+
+// PageInfo tells whether there are items beyond the page
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// Edge is an item of a page with its cursor
+type Edge[T any] struct {
+	Cursor string `json:"cursor"`
+	Node   T      `json:"node"`
+}
+
+// Connection is a page of a list. Nodes holds the items of the edges for
+// clients that need no cursors. TotalCount counts the items of all pages.
+type Connection[T any] struct {
+	Edges      []*Edge[T] `json:"edges"`
+	Nodes      []T        `json:"nodes"`
+	PageInfo   PageInfo   `json:"pageInfo"`
+	TotalCount int64      `json:"totalCount"`
+}
+
+// cursor is the content of an encoded cursor
+type cursor struct {
+	Order string      `json:"o"`
+	Key   interface{} `json:"k"`
+	ID    uuid.UUID   `json:"id"`
+}
+
+// Paginate loads the page of db selected by args in the given order. db
+// holds the filters of the list; the associations named by preloads are
+// loaded for the items of the page.
+func Paginate[T any](db *gorm.DB, args Args, order Order[T], preloads ...string) (*Connection[T], error) {
+	limit, reverse, err := limits(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	forward, backward := "> ?", "< ?"
+	if order.Direction == Desc {
+		forward, backward = backward, forward
+	}
+	keyset := fmt.Sprintf("(%s, %s) ", order.Column, order.IDColumn)
+	if args.After != nil {
+		c, err := decode(*args.After, order.id())
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(keyset+forward, []interface{}{c.Key, c.ID})
+	}
+	if args.Before != nil {
+		c, err := decode(*args.Before, order.id())
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(keyset+backward, []interface{}{c.Key, c.ID})
+	}
+
+	// The last items are loaded in reverse order and turned around. One item
+	// more than requested tells whether there are more.
+	dir := order.Direction
+	if reverse {
+		dir = opposite(dir)
+	}
+	for _, preload := range preloads {
+		db = db.Preload(preload)
+	}
+	var items []T
+	err = db.Order(fmt.Sprintf("%s %s, %s %s", order.Column, dir, order.IDColumn, dir)).
+		Limit(limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if reverse {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	conn := &Connection[T]{
+		Edges:      make([]*Edge[T], len(items)),
+		Nodes:      items,
+		TotalCount: total,
+	}
+	for i, item := range items {
+		conn.Edges[i] = &Edge[T]{Cursor: encode(order.id(), order.Key(item), order.ID(item)), Node: item}
+	}
+	if len(items) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(items)-1].Cursor
+	}
+	if reverse {
+		conn.PageInfo.HasPreviousPage = more
+		conn.PageInfo.HasNextPage = args.Before != nil
+	} else {
+		conn.PageInfo.HasNextPage = more
+		conn.PageInfo.HasPreviousPage = args.After != nil
+	}
+	return conn, nil
+}
+
+// limits validates the page size of args and reports whether the last
+// items are selected. Without a size the first DefaultLimit items are
+// selected; sizes are capped at MaxLimit.
+func limits(args Args) (limit int, last bool, err error) {
+	switch {
+	case args.First != nil && args.Last != nil:
+		return 0, false, ErrFirstAndLast
+	case args.First != nil:
+		if *args.First < 0 {
+			return 0, false, ErrNegativeLimit
+		}
+		return min(*args.First, MaxLimit), false, nil
+	case args.Last != nil:
+		if *args.Last < 0 {
+			return 0, false, ErrNegativeLimit
+		}
+		return min(*args.Last, MaxLimit), true, nil
+	}
+	return DefaultLimit, false, nil
+}
+
+func opposite(d Direction) Direction {
+	if d == Desc {
+		return Asc
+	}
+	return Desc
+}
+
+func encode(order string, key interface{}, id uuid.UUID) string {
+	data, _ := json.Marshal(cursor{Order: order, Key: key, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(s, order string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Order != order || c.Key == nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,334 @@
+package qti
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// manifestFile is the name of the manifest in a content package
+const manifestFile = "imsmanifest.xml"
+
+// Export writes a test with its choice questions, options, source passages
+// and images to w as a QTI content package (zip). Images that are not found
+// in the media store are referenced by their original path.
+func (p *Packager) Export(ctx context.Context, testID uuid.UUID, version Version, w io.Writer) error {
+	test, err := p.repos.Tests.Get(ctx, testID)
+	if err != nil {
+		return fmt.Errorf("test %s: %w", testID, err)
+	}
+	questions, err := p.repos.Questions.ListByTest(ctx, testID)
+	if err != nil {
+		return err
+	}
+
+	e := &exporter{
+		ctx:     ctx,
+		version: version,
+		zip:     zip.NewWriter(w),
+		store:   p.images,
+		images:  map[string]string{},
+		used:    map[string]bool{},
+		sources: map[uuid.UUID]bool{},
+	}
+	testFile := "test-" + test.ID.String() + ".xml"
+	testResource := e.resource("test-"+test.ID.String(), version.resourceType("test"), testFile)
+	section := el(version.elem("assessment-section"),
+		"identifier", "section-1",
+		"title", test.Title,
+		"visible", "true",
+	)
+
+	for _, q := range questions {
+		// Items are written with a choice interaction only
+		if q.QuestionType != models.QuestionChoice && q.QuestionType != "" {
+			continue
+		}
+		var source *models.Source
+		if q.SourceTextID != nil {
+			if source, err = p.repos.Questions.GetSource(ctx, *q.SourceTextID); err != nil {
+				return fmt.Errorf("source of question %s: %w", q.ID, err)
+			}
+		}
+		id, err := e.writeItem(q, source)
+		if err != nil {
+			return err
+		}
+		section.add(el(version.elem("assessment-item-ref"), "identifier", id, "href", id+".xml"))
+		testResource.add(el("dependency", "identifierref", id))
+	}
+
+	testPart := el(version.elem("test-part"),
+		"identifier", "part-1",
+		version.attr("navigation-mode"), "nonlinear",
+		version.attr("submission-mode"), "simultaneous",
+	)
+	assessmentTest := el(version.elem("assessment-test"),
+		"xmlns", version.itemNamespace(),
+		"identifier", "test-"+test.ID.String(),
+		"title", test.Title,
+	)
+	if test.Time != nil && *test.Time > 0 {
+		assessmentTest.add(el(version.elem("time-limits"), version.attr("max-time"), strconv.Itoa(*test.Time*60)))
+	}
+	assessmentTest.add(testPart.add(section))
+	if err := e.writeXML(testFile, assessmentTest); err != nil {
+		return err
+	}
+
+	schema := "QTIv2.1 Package"
+	schemaVersion := "1.0.0"
+	if version == Version30 {
+		schema = "QTI Package"
+		schemaVersion = "3.0.0"
+	}
+	manifest := el("manifest", "xmlns", version.manifestNamespace(), "identifier", "manifest-"+test.ID.String()).add(
+		el("metadata").add(
+			el("schema").addText(schema),
+			el("schemaversion").addText(schemaVersion),
+		),
+		el("organizations"),
+		el("resources").add(append([]*node{testResource}, e.resources...)...),
+	)
+	if err := e.writeXML(manifestFile, manifest); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+// exporter writes the files of a content package
+type exporter struct {
+	// ctx is the context of the export, for the media store
+	ctx       context.Context
+	version   Version
+	zip       *zip.Writer
+	store     media.Store
+	resources []*node
+	// images maps image paths to their files in the package
+	images map[string]string
+	used   map[string]bool
+	// sources holds the stimuli written for QTI 3.0
+	sources map[uuid.UUID]bool
+}
+
+// resource creates a manifest resource listing its own file
+func (e *exporter) resource(id, kind, href string) *node {
+	return el("resource", "identifier", id, "type", kind, "href", href).add(el("file", "href", href))
+}
+
+// hasFile reports whether a manifest resource lists a file
+func (n *node) hasFile(href string) bool {
+	for _, c := range n.children {
+		if c.name == "file" && c.attr("href") == href {
+			return true
+		}
+	}
+	return false
+}
+
+// writeXML writes an XML file to the package
+func (e *exporter) writeXML(name string, n *node) error {
+	f, err := e.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	return n.encode(f)
+}
+
+// writeItem writes a question as an assessment item with a choice
+// interaction and returns its identifier
+func (e *exporter) writeItem(q *models.Question, source *models.Source) (string, error) {
+	v := e.version
+	id := "item-" + q.ID.String()
+	resource := e.resource(id, v.resourceType("item"), id+".xml")
+
+	var correct []*node
+	for _, o := range q.Options {
+		if o.IsCorrect {
+			correct = append(correct, el(v.elem("value")).addText("choice-"+o.ID.String()))
+		}
+	}
+	cardinality, maxChoices := "single", "1"
+	if len(correct) > 1 {
+		cardinality, maxChoices = "multiple", "0"
+	}
+	response := el(v.elem("response-declaration"),
+		"identifier", "RESPONSE",
+		"cardinality", cardinality,
+		v.attr("base-type"), "identifier",
+	)
+	if len(correct) > 0 {
+		response.add(el(v.elem("correct-response")).add(correct...))
+	}
+	item := el(v.elem("assessment-item"),
+		"xmlns", v.itemNamespace(),
+		"identifier", id,
+		"title", itemTitle(q),
+		"adaptive", "false",
+		v.attr("time-dependent"), "false",
+	).add(
+		response,
+		el(v.elem("outcome-declaration"), "identifier", "SCORE", "cardinality", "single", v.attr("base-type"), "float"),
+	)
+
+	body := el(v.elem("item-body"))
+	if source != nil {
+		stimulusID := "stimulus-" + source.ID.String()
+		if v == Version30 {
+			if err := e.writeStimulus(stimulusID, source); err != nil {
+				return "", err
+			}
+			item.add(el(v.elem("assessment-stimulus-ref"), "identifier", stimulusID, "href", stimulusID+".xml"))
+			resource.add(el("dependency", "identifierref", stimulusID))
+		} else {
+			body.add(stimulusDiv(stimulusID, source))
+		}
+	}
+	for _, t := range []struct {
+		class string
+		text  *string
+	}{{"text", q.Text}, {"text2", q.Text2}, {"text3", q.Text3}} {
+		if t.text != nil && *t.text != "" {
+			body.add(el("p", "class", t.class).addLines(*t.text))
+		}
+	}
+	if q.ImgPath != nil && *q.ImgPath != "" {
+		img, err := e.image(*q.ImgPath, resource)
+		if err != nil {
+			return "", err
+		}
+		body.add(el("p").add(img))
+	}
+
+	interaction := el(v.elem("choice-interaction"),
+		v.attr("response-identifier"), "RESPONSE",
+		"shuffle", "false",
+		v.attr("max-choices"), maxChoices,
+	)
+	for _, o := range q.Options {
+		choice := el(v.elem("simple-choice"), "identifier", "choice-"+o.ID.String()).addLines(o.Text)
+		if o.ImgPath != nil && *o.ImgPath != "" {
+			img, err := e.image(*o.ImgPath, resource)
+			if err != nil {
+				return "", err
+			}
+			choice.add(img)
+		}
+		interaction.add(choice)
+	}
+	item.add(
+		body.add(interaction),
+		el(v.elem("response-processing"), "template", v.matchCorrect()),
+	)
+
+	if err := e.writeXML(id+".xml", item); err != nil {
+		return "", err
+	}
+	e.resources = append(e.resources, resource)
+	return id, nil
+}
+
+// writeStimulus writes a source passage as a QTI 3.0 assessment stimulus,
+// once per source
+func (e *exporter) writeStimulus(id string, source *models.Source) error {
+	if _, ok := e.sources[source.ID]; ok {
+		return nil
+	}
+	v := e.version
+	stimulus := el(v.elem("assessment-stimulus"),
+		"xmlns", v.itemNamespace(),
+		"identifier", id,
+		"title", id,
+	).add(el(v.elem("stimulus-body")).add(paragraphs(source.Text)...))
+	if err := e.writeXML(id+".xml", stimulus); err != nil {
+		return err
+	}
+	e.sources[source.ID] = true
+	e.resources = append(e.resources, e.resource(id, v.resourceType("stimulus"), id+".xml"))
+	return nil
+}
+
+// image returns an img element for an image path, copying the image into
+// the package and listing it in the resource when the media store has it
+func (e *exporter) image(imgPath string, resource *node) (*node, error) {
+	name, ok := e.images[imgPath]
+	if !ok {
+		r, err := e.store.Open(e.ctx, imgPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			e.images[imgPath] = imgPath
+			return el("img", "src", imgPath, "alt", ""), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %w", imgPath, err)
+		}
+		defer r.Close()
+
+		name = e.imageName(imgPath)
+		f, err := e.zip.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			return nil, fmt.Errorf("image %s: %w", imgPath, err)
+		}
+		e.images[imgPath] = name
+	}
+	if name != imgPath && !resource.hasFile(name) {
+		resource.add(el("file", "href", name))
+	}
+	return el("img", "src", name, "alt", ""), nil
+}
+
+// imageName returns an unused file name in the images folder of the package
+func (e *exporter) imageName(imgPath string) string {
+	base := path.Base(strings.ReplaceAll(imgPath, "\\", "/"))
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	name := "images/" + base
+	for i := 2; e.used[name]; i++ {
+		name = fmt.Sprintf("images/%s-%d%s", stem, i, ext)
+	}
+	e.used[name] = true
+	return name
+}
+
+// stimulusDiv returns a source passage embedded in a QTI 2.1 item body
+func stimulusDiv(id string, source *models.Source) *node {
+	return el("div", "class", "stimulus", "id", id).add(paragraphs(source.Text)...)
+}
+
+// paragraphs splits text into a paragraph per line
+func paragraphs(text string) []*node {
+	var ps []*node
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ps = append(ps, el("p").addText(line))
+		}
+	}
+	return ps
+}
+
+// itemTitle returns a short title for a question
+func itemTitle(q *models.Question) string {
+	title := ""
+	if q.Text != nil {
+		title = strings.Join(strings.Fields(*q.Text), " ")
+	}
+	if r := []rune(title); len(r) > 80 {
+		title = string(r[:77]) + "..."
+	}
+	if title == "" {
+		title = q.ID.String()
+	}
+	return title
+}
@@ -0,0 +1,408 @@
+package qti
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/audit"
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrNoItems is returned for packages without assessment items
+var ErrNoItems = errors.New("package has no assessment items")
+
+// ImportReport is the outcome of importing a content package
+type ImportReport struct {
+	TestID    uuid.UUID     `json:"test_id"`
+	Title     string        `json:"title"`
+	Version   Version       `json:"version"`
+	Questions int           `json:"questions"`
+	Skipped   []SkippedItem `json:"skipped"`
+}
+
+// SkippedItem is an item of a package that could not be imported
+type SkippedItem struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// Import reads a QTI 2.1 or 3.0 content package and creates its test in
+// the product, with a question for every item that has a choice
+// interaction. Choices listed in the correct response become correct
+// options. Images in the package are stored in the media store; the test and
+// its questions are created in one transaction, with their audit entries.
+// The questions are published as created once it commits.
+func (p *Packager) Import(ctx context.Context, productID uuid.UUID, r io.ReaderAt, size int64, authorID *uuid.UUID) (*ImportReport, error) {
+	if _, err := p.repos.Products.Get(ctx, productID); err != nil {
+		return nil, fmt.Errorf("product %s: %w", productID, err)
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("read package: %w", err)
+	}
+	im := &importer{
+		ctx:     ctx,
+		zip:     zr,
+		store:   p.images,
+		testID:  uuid.New(),
+		images:  map[string]string{},
+		sources: map[string]*models.Source{},
+	}
+	manifest, err := im.readXML(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{TestID: im.testID, Version: Version21, Skipped: []SkippedItem{}}
+	test := &models.Test{ID: im.testID, ProductID: productID, Title: "Imported test"}
+
+	// Items are taken in the order of the test, or of the manifest when the
+	// package has no test
+	var itemFiles []string
+	for _, res := range manifest.findAll("resource") {
+		kind := res.attr("type")
+		if strings.HasSuffix(kind, "v3p0") {
+			report.Version = Version30
+		}
+		switch {
+		case strings.HasPrefix(kind, "imsqti_test_") && itemFiles == nil:
+			href := res.attr("href")
+			assessmentTest, err := im.readXML(href)
+			if err != nil {
+				return nil, err
+			}
+			if title := assessmentTest.attr("title"); title != "" {
+				test.Title = title
+			}
+			if limits := assessmentTest.find("time-limits"); limits != nil {
+				if seconds, err := strconv.ParseFloat(limits.attr("max-time"), 64); err == nil && seconds > 0 {
+					minutes := int(math.Ceil(seconds / 60))
+					test.Time = &minutes
+				}
+			}
+			itemFiles = []string{}
+			for _, ref := range assessmentTest.findAll("assessment-item-ref") {
+				itemFiles = append(itemFiles, resolve(href, ref.attr("href")))
+			}
+		}
+	}
+	if itemFiles == nil {
+		for _, res := range manifest.findAll("resource") {
+			if strings.HasPrefix(res.attr("type"), "imsqti_item_") {
+				itemFiles = append(itemFiles, res.attr("href"))
+			}
+		}
+	}
+	if len(itemFiles) == 0 {
+		return nil, ErrNoItems
+	}
+	report.Title = test.Title
+
+	var questions []*models.Question
+	for _, file := range itemFiles {
+		q, reason, err := im.question(file)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			report.Skipped = append(report.Skipped, SkippedItem{Item: file, Reason: reason})
+			continue
+		}
+		questions = append(questions, q)
+	}
+
+	err = p.repos.Transaction(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Tests.Create(ctx, test); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, authorID, models.AuditEntityTest, test.ID, test); err != nil {
+			return err
+		}
+		for _, q := range questions {
+			// Sources are shared by the items of a stimulus and created once
+			if source := q.SourceText; source != nil {
+				if source.ID == uuid.Nil {
+					if err := tx.Questions.CreateSource(ctx, source); err != nil {
+						return err
+					}
+				}
+				q.SourceTextID = &source.ID
+				q.SourceText = nil
+			}
+			q.TestID = test.ID
+			if err := tx.Questions.Create(ctx, q); err != nil {
+				return err
+			}
+			if _, err := tx.Questions.SaveRevision(ctx, q.ID, authorID); err != nil {
+				return err
+			}
+			if err := recordAudit(ctx, tx, authorID, models.AuditEntityQuestion, q.ID, q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		if err := p.publisher.PublishQuestionCreated(q); err != nil {
+			// Log the error but don't fail the import
+			p.logger.Errorw("Failed to publish question created event", "questionID", q.ID, "error", err)
+		}
+	}
+	report.Questions = len(questions)
+	return report, nil
+}
+
+// recordAudit writes the audit entry of a test or question created by an
+// import
+func recordAudit(ctx context.Context, tx *repository.Repositories, authorID *uuid.UUID, entity models.AuditEntity, id uuid.UUID, after interface{}) error {
+	entry, err := audit.NewEntry(authorID, models.AuditCreate, entity, id, nil, after)
+	if err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	return tx.Audit.Create(ctx, entry)
+}
+
+// importer reads the files of a content package
+type importer struct {
+	// ctx is the context of the import, for the media store
+	ctx    context.Context
+	zip    *zip.Reader
+	store  media.Store
+	testID uuid.UUID
+	// images maps files of the package to their stored image paths
+	images map[string]string
+	// sources holds the source passages read so far by stimulus
+	sources map[string]*models.Source
+}
+
+// open opens a file of the package
+func (im *importer) open(name string) (io.ReadCloser, error) {
+	f, err := im.zip.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("package file %s: %w", name, err)
+	}
+	return f, nil
+}
+
+// readXML parses an XML file of the package
+func (im *importer) readXML(name string) (*node, error) {
+	f, err := im.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("package file %s: %w", name, err)
+	}
+	return n, nil
+}
+
+// question reads an assessment item as a question. Items that cannot be
+// represented are reported with the reason they are skipped.
+func (im *importer) question(file string) (*models.Question, string, error) {
+	item, err := im.readXML(file)
+	if err != nil {
+		return nil, "", err
+	}
+	body := item.find("item-body")
+	if body == nil {
+		return nil, "item has no body", nil
+	}
+	interactions := body.findAll("choice-interaction")
+	if len(interactions) != 1 {
+		return nil, fmt.Sprintf("expected one choice interaction, found %d", len(interactions)), nil
+	}
+	interaction := interactions[0]
+
+	correct := map[string]bool{}
+	for _, decl := range item.findAll("response-declaration") {
+		if decl.attr("identifier") != interaction.attr("response-identifier") {
+			continue
+		}
+		if cr := decl.find("correct-response"); cr != nil {
+			for _, value := range cr.findAll("value") {
+				correct[value.innerText()] = true
+			}
+		}
+	}
+
+	q := &models.Question{}
+	if ref := item.find("assessment-stimulus-ref"); ref != nil {
+		source, err := im.stimulus(resolve(file, ref.attr("href")))
+		if err != nil {
+			return nil, "", err
+		}
+		q.SourceText = source
+	}
+
+	var texts []string
+	if err := im.readBody(file, body, q, &texts); err != nil {
+		return nil, "", err
+	}
+	if prompt := interaction.find("prompt"); prompt != nil {
+		if text := prompt.innerText(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) > 0 {
+		text := strings.Join(texts, "\n")
+		q.Text = &text
+	}
+
+	for _, choice := range interaction.findAll("simple-choice") {
+		option := models.Option{
+			Text:      choice.innerText(),
+			IsCorrect: correct[choice.attr("identifier")],
+		}
+		if img := choice.find("img"); img != nil {
+			imgPath, err := im.image(file, img.attr("src"))
+			if err != nil {
+				return nil, "", err
+			}
+			option.ImgPath = &imgPath
+		}
+		q.Options = append(q.Options, option)
+	}
+	if len(q.Options) < 2 {
+		return nil, "choice interaction has fewer than two choices", nil
+	}
+	if q.Text == nil && q.Text2 == nil && q.Text3 == nil && q.ImgPath == nil {
+		return nil, "item has no text or image", nil
+	}
+	return q, "", nil
+}
+
+// readBody reads the blocks of an item body into the question. Blocks with
+// the text2 and text3 classes written by Export fill those fields, embedded
+// stimuli become the source passage and other text is collected in texts.
+func (im *importer) readBody(file string, body *node, q *models.Question, texts *[]string) error {
+	for _, block := range body.children {
+		if block.name == "" || block.is("choice-interaction") {
+			continue
+		}
+		class := " " + block.attr("class") + " "
+		if block.name == "div" && (strings.Contains(class, " stimulus ") || strings.Contains(class, " passage ")) {
+			if q.SourceText == nil {
+				id := block.attr("id")
+				if id == "" {
+					id = file
+				}
+				q.SourceText = im.source(id, blockText(block))
+			}
+			continue
+		}
+		if block.find("choice-interaction") != nil {
+			if err := im.readBody(file, block, q, texts); err != nil {
+				return err
+			}
+			continue
+		}
+		if q.ImgPath == nil {
+			img := block.find("img")
+			if block.name == "img" {
+				img = block
+			}
+			if img != nil {
+				imgPath, err := im.image(file, img.attr("src"))
+				if err != nil {
+					return err
+				}
+				q.ImgPath = &imgPath
+			}
+		}
+		text := block.innerText()
+		if text == "" {
+			continue
+		}
+		switch {
+		case strings.Contains(class, " text2 ") && q.Text2 == nil:
+			q.Text2 = &text
+		case strings.Contains(class, " text3 ") && q.Text3 == nil:
+			q.Text3 = &text
+		default:
+			*texts = append(*texts, text)
+		}
+	}
+	return nil
+}
+
+// stimulus reads a QTI 3.0 assessment stimulus as a source passage
+func (im *importer) stimulus(file string) (*models.Source, error) {
+	if source, ok := im.sources[file]; ok {
+		return source, nil
+	}
+	stimulus, err := im.readXML(file)
+	if err != nil {
+		return nil, err
+	}
+	body := stimulus.find("stimulus-body")
+	if body == nil {
+		body = stimulus
+	}
+	return im.source(file, blockText(body)), nil
+}
+
+// source returns the source passage for a stimulus, shared by all the items
+// that refer to it
+func (im *importer) source(id, text string) *models.Source {
+	if source, ok := im.sources[id]; ok {
+		return source
+	}
+	source := &models.Source{Text: text}
+	im.sources[id] = source
+	return source
+}
+
+// image stores an image of the package and returns its image path. Sources
+// outside the package are kept as they are.
+func (im *importer) image(file, src string) (string, error) {
+	if src == "" || strings.Contains(src, "://") || strings.HasPrefix(src, "data:") {
+		return src, nil
+	}
+	name := resolve(file, src)
+	if imgPath, ok := im.images[name]; ok {
+		return imgPath, nil
+	}
+	f, err := im.zip.Open(name)
+	if err != nil {
+		im.images[name] = src
+		return src, nil
+	}
+	defer f.Close()
+	imgPath, err := im.store.Save(im.ctx, path.Join("qti", im.testID.String(), name), f)
+	if err != nil {
+		return "", fmt.Errorf("image %s: %w", name, err)
+	}
+	im.images[name] = imgPath
+	return imgPath, nil
+}
+
+// blockText returns the text of the blocks of a node, one line per block
+func blockText(n *node) string {
+	var lines []string
+	for _, c := range n.children {
+		if text := c.innerText(); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolve returns the package path of an href relative to a package file
+func resolve(file, href string) string {
+	return strings.TrimPrefix(path.Join(path.Dir(file), href), "/")
+}
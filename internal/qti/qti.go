@@ -0,0 +1,131 @@
+// Package qti exports tests as IMS QTI 2.1 and 3.0 content packages and
+// imports such packages as tests
+package qti
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/events"
+	"github.com/Alan69/ayatest/internal/media"
+	"github.com/Alan69/ayatest/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Version is a QTI specification version
+type Version string
+
+const (
+	Version21 Version = "2.1"
+	Version30 Version = "3.0"
+)
+
+// ErrUnsupportedVersion is returned for QTI versions other than 2.1 and 3.0
+var ErrUnsupportedVersion = errors.New("unsupported QTI version, expected 2.1 or 3.0")
+
+// ParseVersion parses a QTI version, defaulting to 2.1 when s is empty
+func ParseVersion(s string) (Version, error) {
+	switch strings.TrimSpace(s) {
+	case "", "2.1", "2p1":
+		return Version21, nil
+	case "3.0", "3", "3p0":
+		return Version30, nil
+	default:
+		return "", ErrUnsupportedVersion
+	}
+}
+
+// itemNamespace returns the XML namespace of items, tests and stimuli
+func (v Version) itemNamespace() string {
+	if v == Version30 {
+		return "http://www.imsglobal.org/xsd/imsqtiasi_v3p0"
+	}
+	return "http://www.imsglobal.org/xsd/imsqti_v2p1"
+}
+
+// manifestNamespace returns the XML namespace of the package manifest
+func (v Version) manifestNamespace() string {
+	if v == Version30 {
+		return "http://www.imsglobal.org/xsd/qti/qtiv3p0/imscp_v1p1"
+	}
+	return "http://www.imsglobal.org/xsd/imscp_v1p1"
+}
+
+// resourceType returns the manifest resource type of a test, item or
+// stimulus
+func (v Version) resourceType(kind string) string {
+	if v == Version30 {
+		return "imsqti_" + kind + "_xmlv3p0"
+	}
+	return "imsqti_" + kind + "_xmlv2p1"
+}
+
+// matchCorrect returns the response processing template that scores a
+// response against the correct response
+func (v Version) matchCorrect() string {
+	if v == Version30 {
+		return "https://purl.imsglobal.org/spec/qti/v3p0/rptemplates/match_correct.xml"
+	}
+	return "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+}
+
+// elem returns the element name of a QTI element given in its kebab-case
+// form without prefix, such as "choice-interaction"
+func (v Version) elem(name string) string {
+	if v == Version30 {
+		return "qti-" + name
+	}
+	return camel(name)
+}
+
+// attr returns the attribute name of a QTI attribute given in its
+// kebab-case form, such as "response-identifier"
+func (v Version) attr(name string) string {
+	if v == Version30 {
+		return name
+	}
+	return camel(name)
+}
+
+// camel converts a kebab-case name to the camelCase used by QTI 2.1
+func camel(name string) string {
+	parts := strings.Split(name, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// kebab converts an element or attribute name of either version to the
+// kebab-case form without prefix, so "choiceInteraction" and
+// "qti-choice-interaction" both become "choice-interaction"
+func kebab(name string) string {
+	name = strings.TrimPrefix(name, "qti-")
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Packager exports and imports QTI content packages
+type Packager struct {
+	repos     *repository.Repositories
+	images    media.Store
+	publisher events.Publisher
+	logger    *zap.SugaredLogger
+}
+
+// New creates a packager reading and storing images in the media store.
+// Imported questions are published as created through publisher.
+func New(repos *repository.Repositories, images media.Store, publisher events.Publisher, logger *zap.SugaredLogger) *Packager {
+	return &Packager{repos: repos, images: images, publisher: publisher, logger: logger}
+}
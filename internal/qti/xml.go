@@ -0,0 +1,182 @@
+package qti
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// node is an XML element or, when name is empty, a text node
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []*node
+	text     string
+}
+
+// el creates an element with attributes given as name, value pairs
+func el(name string, attrs ...string) *node {
+	n := &node{name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return n
+}
+
+// add appends child elements and returns the node
+func (n *node) add(children ...*node) *node {
+	n.children = append(n.children, children...)
+	return n
+}
+
+// addText appends a text node and returns the node
+func (n *node) addText(text string) *node {
+	n.children = append(n.children, &node{text: text})
+	return n
+}
+
+// attr returns the value of an attribute, matching names of either QTI
+// version
+func (n *node) attr(name string) string {
+	for _, a := range n.attrs {
+		if kebab(a.Name.Local) == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// is reports whether the node is an element with the given kebab-case name
+func (n *node) is(name string) bool {
+	return n.name != "" && kebab(n.name) == name
+}
+
+// find returns the first descendant element with the given kebab-case name
+func (n *node) find(name string) *node {
+	for _, c := range n.children {
+		if c.is(name) {
+			return c
+		}
+		if found := c.find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findAll returns the descendant elements with the given kebab-case name in
+// document order
+func (n *node) findAll(name string) []*node {
+	var found []*node
+	for _, c := range n.children {
+		if c.is(name) {
+			found = append(found, c)
+		}
+		found = append(found, c.findAll(name)...)
+	}
+	return found
+}
+
+// innerText returns the text content of the node. Whitespace is collapsed
+// and line breaks are kept only where the content has a br element.
+func (n *node) innerText() string {
+	lines := []string{""}
+	n.collectText(&lines)
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func (n *node) collectText(lines *[]string) {
+	if n.name == "" {
+		(*lines)[len(*lines)-1] += n.text
+		return
+	}
+	if n.name == "br" {
+		*lines = append(*lines, "")
+	}
+	for _, c := range n.children {
+		c.collectText(lines)
+	}
+}
+
+// addLines appends text to the node with its line breaks as br elements
+func (n *node) addLines(text string) *node {
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			n.add(el("br"))
+		}
+		n.addText(line)
+	}
+	return n
+}
+
+// encode writes the node as an XML document
+func (n *node) encode(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := n.encodeTokens(enc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func (n *node) encodeTokens(enc *xml.Encoder) error {
+	if n.name == "" {
+		return enc.EncodeToken(xml.CharData(n.text))
+	}
+	start := xml.StartElement{Name: xml.Name{Local: n.name}, Attr: n.attrs}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, c := range n.children {
+		if err := c.encodeTokens(enc); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// parse reads an XML document into a node tree. Namespaces are dropped, so
+// elements are matched by their local names.
+func parse(r io.Reader) (*node, error) {
+	dec := xml.NewDecoder(r)
+	root := &node{}
+	stack := []*node{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: a.Name.Local}, Value: a.Value})
+			}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			top.children = append(top.children, &node{text: string(t)})
+		}
+	}
+	for _, c := range root.children {
+		if c.name != "" {
+			return c, nil
+		}
+	}
+	return nil, io.ErrUnexpectedEOF
+}
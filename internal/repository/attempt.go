@@ -0,0 +1,398 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Attempt errors
+var (
+	ErrNoApprovedQuestions  = errors.New("test has no approved questions")
+	ErrQuestionNotDrawn     = errors.New("question was not drawn for this attempt")
+	ErrPassageGroupTooLarge = errors.New("no passage group fits in the number of questions of the test")
+	ErrAnswerType           = errors.New("answer does not fit the type of the question")
+)
+
+// attemptRepo implements AttemptRepo using GORM
+type attemptRepo struct {
+	db *gorm.DB
+}
+
+// NewAttemptRepo creates a new GORM attempt repository
+func NewAttemptRepo(db *gorm.DB) AttemptRepo {
+	return &attemptRepo{db: db}
+}
+
+// PageByUser returns a page of the completed tests of a user matching the
+// filter, with their product and tests
+func (r *attemptRepo) PageByUser(ctx context.Context, userID uuid.UUID, filter models.CompletedTestFilter, sort *models.CompletedTestSort, args pagination.Args) (*pagination.Connection[*models.CompletedTest], error) {
+	order, err := completedTestOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.CompletedTest{}).Where("completed_tests.user_id = ?", userID)
+	if filter.ProductID != nil {
+		db = db.Where("completed_tests.product_id = ?", *filter.ProductID)
+	}
+	if filter.From != nil {
+		db = db.Where("completed_tests.completed_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("completed_tests.completed_date < ?", *filter.To)
+	}
+	return pagination.Paginate(db, args, order, "Product", "Tests")
+}
+
+// completedTestOrder returns the order of a completed test sort
+func completedTestOrder(sort *models.CompletedTestSort) (pagination.Order[*models.CompletedTest], error) {
+	order := pagination.Order[*models.CompletedTest]{
+		IDColumn: "completed_tests.id",
+		ID:       func(ct *models.CompletedTest) uuid.UUID { return ct.ID },
+	}
+	field := models.CompletedTestSortCompletedDate
+	var dir *models.SortDirection
+	if sort != nil {
+		field, dir = sort.Field, sort.Direction
+	}
+	switch field {
+	case models.CompletedTestSortCompletedDate:
+		order.Column = "COALESCE(completed_tests.completed_date, " + zeroTime + ")"
+		order.Key = func(ct *models.CompletedTest) interface{} { return ct.CompletedDate }
+	case models.CompletedTestSortScore:
+		order.Column = "COALESCE(completed_tests.score, 0)"
+		order.Key = func(ct *models.CompletedTest) interface{} { return intKey(ct.Score) }
+	default:
+		return order, fmt.Errorf("%w: field %q", ErrInvalidSort, field)
+	}
+
+	var err error
+	order.Name = string(field)
+	order.Direction, err = sortDirection(dir, pagination.Desc)
+	return order, err
+}
+
+// Get returns a completed test by ID
+func (r *attemptRepo) Get(ctx context.Context, id uuid.UUID) (*models.CompletedTest, error) {
+	var completedTest models.CompletedTest
+	if err := r.db.WithContext(ctx).First(&completedTest, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &completedTest, nil
+}
+
+// GetWithAnswers returns a completed test with its answered questions, the
+// selected options and the options of each question preloaded, along with the
+// question revision each answer is pinned to. Soft deleted content is
+// included so historical attempts are graded as they were taken; grading
+// against the current key leaves out the deleted options.
+func (r *attemptRepo) GetWithAnswers(ctx context.Context, id uuid.UUID) (*models.CompletedTest, error) {
+	db := r.db.WithContext(ctx)
+	var completedTest models.CompletedTest
+	err := db.
+		Preload("Questions.SelectedOptions", unscoped).
+		Preload("Questions.Question", unscoped).
+		Preload("Questions.Question.Options", unscoped).
+		Preload("Questions.EssayGrade").
+		First(&completedTest, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := pinRevisions(db, completedTest.Questions); err != nil {
+		return nil, err
+	}
+	return &completedTest, nil
+}
+
+// Start creates a completed test, links the tests being taken and draws the
+// questions of the attempt in one transaction. Each test contributes its
+// approved questions in random order, as many as its number of questions
+// when that is set. The questions of a passage group are drawn together, in
+// their passage order.
+func (r *attemptRepo) Start(ctx context.Context, completedTest *models.CompletedTest, testIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(completedTest).Error; err != nil {
+			return err
+		}
+
+		var drawn []models.AttemptQuestion
+		for _, testID := range testIDs {
+			var test models.Test
+			if err := tx.First(&test, "id = ?", testID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(completedTest).Association("Tests").Append(&models.Test{ID: testID}); err != nil {
+				return err
+			}
+
+			var questions []drawable
+			err := tx.Model(&models.Question{}).
+				Select("id, passage_group_id").
+				Where("test_id = ? AND status = ?", testID, models.QuestionApproved).
+				Order("passage_position, id").
+				Scan(&questions).Error
+			if err != nil {
+				return err
+			}
+			if len(questions) == 0 {
+				return fmt.Errorf("%w: %s", ErrNoApprovedQuestions, test.Title)
+			}
+			limit := 0
+			if test.NumberOfQuestions != nil && *test.NumberOfQuestions > 0 {
+				limit = *test.NumberOfQuestions
+			}
+			ids := drawQuestions(questions, limit)
+			if len(ids) == 0 {
+				return fmt.Errorf("%w: %s", ErrPassageGroupTooLarge, test.Title)
+			}
+			for _, id := range ids {
+				drawn = append(drawn, models.AttemptQuestion{
+					CompletedTestID: completedTest.ID,
+					QuestionID:      id,
+					TestID:          testID,
+					Position:        len(drawn) + 1,
+				})
+			}
+		}
+
+		return tx.Create(&drawn).Error
+	})
+}
+
+// drawable is an approved question that can be drawn for an attempt
+type drawable struct {
+	ID             uuid.UUID
+	PassageGroupID *uuid.UUID
+}
+
+// drawQuestions picks questions in random order, keeping the questions of a
+// passage group together in their given order. Groups are only drawn whole,
+// so a group not fitting in what is left of the limit is passed over; a limit
+// of 0 draws every question.
+func drawQuestions(questions []drawable, limit int) []uuid.UUID {
+	var units [][]uuid.UUID
+	groups := map[uuid.UUID]int{}
+	for _, q := range questions {
+		if q.PassageGroupID == nil {
+			units = append(units, []uuid.UUID{q.ID})
+			continue
+		}
+		i, ok := groups[*q.PassageGroupID]
+		if !ok {
+			i = len(units)
+			groups[*q.PassageGroupID] = i
+			units = append(units, nil)
+		}
+		units[i] = append(units[i], q.ID)
+	}
+	rand.Shuffle(len(units), func(i, j int) { units[i], units[j] = units[j], units[i] })
+
+	var ids []uuid.UUID
+	for _, unit := range units {
+		if limit > 0 && len(ids)+len(unit) > limit {
+			continue
+		}
+		ids = append(ids, unit...)
+	}
+	return ids
+}
+
+// ListQuestions returns the questions drawn for an attempt with their
+// options, in the order they are delivered, optionally only those of one test
+func (r *attemptRepo) ListQuestions(ctx context.Context, completedTestID uuid.UUID, testID *uuid.UUID) ([]*models.Question, error) {
+	db := r.db.WithContext(ctx).
+		Joins("JOIN attempt_questions aq ON aq.question_id = questions.id").
+		Where("aq.completed_test_id = ?", completedTestID)
+	if testID != nil {
+		db = db.Where("aq.test_id = ?", *testID)
+	}
+
+	var questions []*models.Question
+	if err := db.Preload("Options").Order("aq.position").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// RecordAnswer creates a completed question pinned to the current revision
+// of its question and links the selected options in one transaction. Only
+// questions drawn for the attempt can be answered; attempts started before
+// questions were drawn accept any question. The answer has to be given in
+// the fields of the type of the question.
+func (r *attemptRepo) RecordAnswer(ctx context.Context, completedQuestion *models.CompletedQuestion, optionIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if completedQuestion.QuestionID != nil {
+			var drawn struct {
+				Total    int64
+				Question int64
+			}
+			err := tx.Model(&models.AttemptQuestion{}).
+				Select("count(*) AS total, count(*) FILTER (WHERE question_id = ?) AS question", *completedQuestion.QuestionID).
+				Where("completed_test_id = ?", completedQuestion.CompletedTestID).
+				Scan(&drawn).Error
+			if err != nil {
+				return err
+			}
+			if drawn.Total > 0 && drawn.Question == 0 {
+				return ErrQuestionNotDrawn
+			}
+		}
+
+		if completedQuestion.QuestionID != nil {
+			var question models.Question
+			err := tx.Model(&models.Question{}).Unscoped().Select("revision, question_type").
+				Where("id = ?", *completedQuestion.QuestionID).Limit(1).Find(&question).Error
+			if err != nil {
+				return err
+			}
+			if question.Revision > 0 && completedQuestion.QuestionRevision == nil {
+				completedQuestion.QuestionRevision = &question.Revision
+			}
+			if question.QuestionType != "" && !answerFits(question.QuestionType, completedQuestion, optionIDs) {
+				return ErrAnswerType
+			}
+		}
+
+		if err := tx.Create(completedQuestion).Error; err != nil {
+			return err
+		}
+
+		for _, optionID := range optionIDs {
+			if err := tx.Model(completedQuestion).Association("SelectedOptions").Append(&models.Option{ID: optionID}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// answerFits reports whether an answer is given only in the fields of the
+// type of its question. An empty answer fits every question.
+func answerFits(questionType models.QuestionType, answer *models.CompletedQuestion, optionIDs []uuid.UUID) bool {
+	// Essays are written in the text answer, as short texts are
+	if questionType == models.QuestionEssay {
+		questionType = models.QuestionShortText
+	}
+	given := map[models.QuestionType]bool{
+		models.QuestionChoice:    len(optionIDs) > 0,
+		models.QuestionMatching:  answer.MatchedPairs != nil,
+		models.QuestionOrdering:  answer.OrderedOptionIDs != nil,
+		models.QuestionNumeric:   answer.NumericAnswer != nil,
+		models.QuestionShortText: answer.TextAnswer != nil,
+		models.QuestionFillBlank: answer.BlankAnswers != nil,
+	}
+	for t, ok := range given {
+		if ok && t != questionType {
+			return false
+		}
+	}
+	return true
+}
+
+// Update saves all fields of an existing completed test
+func (r *attemptRepo) Update(ctx context.Context, completedTest *models.CompletedTest) error {
+	return r.db.WithContext(ctx).Omit("Tests", "Questions").Save(completedTest).Error
+}
+
+// SaveScore stores the score of a checked completed test and returns the
+// score it had before, nil if it was never checked
+func (r *attemptRepo) SaveScore(ctx context.Context, id uuid.UUID, score, correctAnswers, totalQuestions int) (*int, error) {
+	var previous *int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var completedTest models.CompletedTest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&completedTest, "id = ?", id).Error; err != nil {
+			return err
+		}
+		previous = completedTest.Score
+
+		return tx.Model(&completedTest).Updates(map[string]interface{}{
+			"score":           score,
+			"correct_answers": correctAnswers,
+			"total_questions": totalQuestions,
+			"checked_at":      time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// ListIDsForRegrade returns the IDs of the finished completed tests in the
+// scope of a regrade, oldest first so attempts finished while the regrade
+// runs come last
+func (r *attemptRepo) ListIDsForRegrade(ctx context.Context, regrade *models.Regrade) ([]uuid.UUID, error) {
+	db, err := r.regradeScope(ctx, regrade)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	if err := db.Order("completed_date, id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// PageForRegrade returns up to limit finished completed tests in the scope of
+// a regrade that come after the cursor, in the order they were started.
+// Attempts started after the regrade was requested are left out, as they are
+// scored against the current key already, so finishing attempts never move
+// the ones a regrade has yet to go through.
+func (r *attemptRepo) PageForRegrade(ctx context.Context, regrade *models.Regrade, after *models.RegradeCursor, limit int) ([]models.RegradeCursor, error) {
+	db, err := r.regradeScope(ctx, regrade)
+	if err != nil {
+		return nil, err
+	}
+
+	startedAt := "COALESCE(completed_date, " + zeroTime + ")"
+	db = db.Where(startedAt+" <= ?", regrade.CreatedAt)
+	if after != nil {
+		db = db.Where("("+startedAt+", id) > (?, ?)", after.CompletedDate, after.ID)
+	}
+
+	var page []models.RegradeCursor
+	err = db.Select(startedAt + " AS completed_date, id").
+		Order(startedAt + ", id").
+		Limit(limit).
+		Scan(&page).Error
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// regradeScope selects the finished and checked completed tests in the scope
+// of a regrade. Attempts whose essays wait for a grade are left out, as they
+// are checked once their essays are graded.
+func (r *attemptRepo) regradeScope(ctx context.Context, regrade *models.Regrade) (*gorm.DB, error) {
+	db := r.db.WithContext(ctx).Model(&models.CompletedTest{}).Where("time_spent IS NOT NULL AND checked_at IS NOT NULL")
+	switch {
+	case regrade.CompletedTestID != nil:
+		db = db.Where("id = ?", *regrade.CompletedTestID)
+	case regrade.QuestionID != nil:
+		db = db.Where("id IN (?)", r.db.Table("completed_questions").
+			Select("completed_test_id").Where("question_id = ?", *regrade.QuestionID))
+	case regrade.TestID != nil:
+		db = db.Where("id IN (?)", r.db.Table("completed_test_tests").
+			Select("completed_test_id").Where("test_id = ?", *regrade.TestID))
+	default:
+		return nil, errors.New("regrade has no scope")
+	}
+	return db, nil
+}
+
+// unscoped is a preload condition that includes soft deleted rows
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
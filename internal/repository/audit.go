@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"gorm.io/gorm"
+)
+
+// auditRepo implements AuditRepo using GORM
+type auditRepo struct {
+	db *gorm.DB
+}
+
+// NewAuditRepo creates a new GORM audit repository
+func NewAuditRepo(db *gorm.DB) AuditRepo {
+	return &auditRepo{db: db}
+}
+
+// Create inserts a new audit entry
+func (r *auditRepo) Create(ctx context.Context, entry *models.AuditEntry) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// List returns the audit entries matching the filter, newest first, along
+// with the total number of matches. A limit of 0 returns every match.
+func (r *auditRepo) List(ctx context.Context, filter models.AuditLogFilter, limit, offset int) ([]*models.AuditEntry, int64, error) {
+	db := filterAudit(r.db.WithContext(ctx).Model(&models.AuditEntry{}), filter)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	db = db.Order("created_at DESC").Offset(offset)
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	var entries []*models.AuditEntry
+	if err := db.Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// Each calls fn with the audit entries matching the filter, newest first,
+// one row at a time, so the log is never held in memory at once. It stops
+// at the first error of fn.
+func (r *auditRepo) Each(ctx context.Context, filter models.AuditLogFilter, fn func(entry *models.AuditEntry) error) error {
+	db := filterAudit(r.db.WithContext(ctx).Model(&models.AuditEntry{}), filter)
+	rows, err := db.Order("created_at DESC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// filterAudit narrows a query of audit entries to those matching the filter
+func filterAudit(db *gorm.DB, filter models.AuditLogFilter) *gorm.DB {
+	if filter.ActorID != nil {
+		db = db.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != nil {
+		db = db.Where("action = ?", *filter.Action)
+	}
+	if filter.EntityType != nil {
+		db = db.Where("entity_type = ?", *filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		db = db.Where("entity_id = ?", *filter.EntityID)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+	return db
+}
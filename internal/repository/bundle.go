@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bundleRepo implements BundleRepo using GORM
+type bundleRepo struct {
+	db *gorm.DB
+}
+
+// NewBundleRepo creates a new GORM bundle repository
+func NewBundleRepo(db *gorm.DB) BundleRepo {
+	return &bundleRepo{db: db}
+}
+
+// Existing returns which of the IDs already exist for a model, soft
+// deleted rows included
+func (r *bundleRepo) Existing(ctx context.Context, model interface{}, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	existing := make(map[uuid.UUID]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	var found []uuid.UUID
+	if err := r.db.WithContext(ctx).Unscoped().Model(model).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// Upsert inserts a product, test or source, or overwrites every column of
+// the row with its ID. Soft deleted rows are restored.
+func (r *bundleRepo) Upsert(ctx context.Context, value interface{}) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, UpdateAll: true}).
+		Omit(clause.Associations).
+		Create(value).Error
+}
+
+// UpsertQuestion inserts or overwrites a question and its options. Options
+// of the question that are not in its list are soft deleted. The revision
+// counter of an existing question is kept.
+func (r *bundleRepo) UpsertQuestion(ctx context.Context, question *models.Question) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, UpdateAll: true}).
+			Omit("Revision", clause.Associations).
+			Create(question).Error
+		if err != nil {
+			return err
+		}
+
+		keep := make([]uuid.UUID, 0, len(question.Options))
+		for i := range question.Options {
+			option := &question.Options[i]
+			option.QuestionID = question.ID
+			err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, UpdateAll: true}).
+				Omit(clause.Associations).
+				Create(option).Error
+			if err != nil {
+				return err
+			}
+			keep = append(keep, option.ID)
+		}
+
+		stale := tx.Where("question_id = ?", question.ID)
+		if len(keep) > 0 {
+			stale = stale.Where("id NOT IN ?", keep)
+		}
+		return stale.Delete(&models.Option{}).Error
+	})
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReferencedByAttemptsError is returned when content cannot be deleted
+// because attempts still reference it and deletion was not forced
+type ReferencedByAttemptsError struct {
+	Report *models.DeletionReport
+}
+
+func (e *ReferencedByAttemptsError) Error() string {
+	return fmt.Sprintf("%s %s is referenced by %d attempts",
+		e.Report.Entity, e.Report.ID, e.Report.Attempts)
+}
+
+// deletionRepo implements DeletionRepo using GORM
+type deletionRepo struct {
+	db *gorm.DB
+}
+
+// NewDeletionRepo creates a new GORM deletion repository
+func NewDeletionRepo(db *gorm.DB) DeletionRepo {
+	return &deletionRepo{db: db}
+}
+
+// subtree holds the IDs of an entity and all content below it
+type subtree struct {
+	Products  []uuid.UUID
+	Tests     []uuid.UUID
+	Questions []uuid.UUID
+	Options   []uuid.UUID
+}
+
+// Report returns the content and attempts that deleting the entity would affect
+func (r *deletionRepo) Report(ctx context.Context, kind models.ContentKind, id uuid.UUID) (*models.DeletionReport, error) {
+	db := r.db.WithContext(ctx)
+	s, err := collectSubtree(db, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	return countReport(db, kind, id, s)
+}
+
+// Delete soft deletes the entity and all content below it in one transaction.
+// A ReferencedByAttemptsError is returned if attempts reference the content
+// and force is false.
+func (r *deletionRepo) Delete(ctx context.Context, kind models.ContentKind, id uuid.UUID, force bool) (*models.DeletionReport, error) {
+	var report *models.DeletionReport
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		s, err := collectSubtree(tx, kind, id)
+		if err != nil {
+			return err
+		}
+		report, err = countReport(tx, kind, id, s)
+		if err != nil {
+			return err
+		}
+		if report.Attempts > 0 && !force {
+			return &ReferencedByAttemptsError{Report: report}
+		}
+
+		// now() is the start of the transaction, so the entity and its
+		// content share one deleted_at and are restored together
+		deleted := []struct {
+			model interface{}
+			ids   []uuid.UUID
+		}{
+			{&models.Option{}, s.Options},
+			{&models.Question{}, s.Questions},
+			{&models.Test{}, s.Tests},
+			{&models.Product{}, s.Products},
+		}
+		for _, d := range deleted {
+			if len(d.ids) == 0 {
+				continue
+			}
+			err := tx.Model(d.model).Where("id IN ?", d.ids).UpdateColumn("deleted_at", gorm.Expr("now()")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		report.Deleted = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// restoreSubtree undeletes a soft deleted entity and the content below it
+// that was deleted along with it, which Delete gave the same deleted_at.
+// Content deleted on its own before keeps its place in the trash.
+// gorm.ErrRecordNotFound is returned if the entity is not deleted.
+func restoreSubtree(tx *gorm.DB, kind models.ContentKind, id uuid.UUID) error {
+	var s subtree
+	var model interface{}
+	switch kind {
+	case models.ContentProduct:
+		model, s.Products = &models.Product{}, []uuid.UUID{id}
+	case models.ContentTest:
+		model, s.Tests = &models.Test{}, []uuid.UUID{id}
+	case models.ContentQuestion:
+		model, s.Questions = &models.Question{}, []uuid.UUID{id}
+	case models.ContentOption:
+		model, s.Options = &models.Option{}, []uuid.UUID{id}
+	default:
+		return fmt.Errorf("unknown content kind %q", kind)
+	}
+
+	var deletedAt []time.Time
+	err := tx.Unscoped().Model(model).Where("id = ? AND deleted_at IS NOT NULL", id).Pluck("deleted_at", &deletedAt).Error
+	if err != nil {
+		return err
+	}
+	if len(deletedAt) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	deletedWith := tx.Unscoped().Where("deleted_at = ?", deletedAt[0]).Session(&gorm.Session{})
+
+	if len(s.Products) > 0 {
+		var ids []uuid.UUID
+		if err := deletedWith.Model(&models.Test{}).Where("product_id IN ?", s.Products).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		s.Tests = append(s.Tests, ids...)
+	}
+	if len(s.Tests) > 0 {
+		var ids []uuid.UUID
+		if err := deletedWith.Model(&models.Question{}).Where("test_id IN ?", s.Tests).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		s.Questions = append(s.Questions, ids...)
+	}
+	if len(s.Questions) > 0 {
+		var ids []uuid.UUID
+		if err := deletedWith.Model(&models.Option{}).Where("question_id IN ?", s.Questions).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		s.Options = append(s.Options, ids...)
+	}
+
+	restored := []struct {
+		model interface{}
+		ids   []uuid.UUID
+	}{
+		{&models.Product{}, s.Products},
+		{&models.Test{}, s.Tests},
+		{&models.Question{}, s.Questions},
+		{&models.Option{}, s.Options},
+	}
+	for _, r := range restored {
+		if len(r.ids) == 0 {
+			continue
+		}
+		if err := tx.Unscoped().Model(r.model).Where("id IN ?", r.ids).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectSubtree finds the entity and the content that is not yet deleted
+// below it. gorm.ErrRecordNotFound is returned if the entity does not exist.
+func collectSubtree(db *gorm.DB, kind models.ContentKind, id uuid.UUID) (*subtree, error) {
+	var s subtree
+	var root []uuid.UUID
+	var err error
+	switch kind {
+	case models.ContentProduct:
+		err = db.Model(&models.Product{}).Where("id = ?", id).Pluck("id", &root).Error
+		s.Products = root
+	case models.ContentTest:
+		err = db.Model(&models.Test{}).Where("id = ?", id).Pluck("id", &root).Error
+		s.Tests = root
+	case models.ContentQuestion:
+		err = db.Model(&models.Question{}).Where("id = ?", id).Pluck("id", &root).Error
+		s.Questions = root
+	case models.ContentOption:
+		err = db.Model(&models.Option{}).Where("id = ?", id).Pluck("id", &root).Error
+		s.Options = root
+	default:
+		return nil, fmt.Errorf("unknown content kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(root) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if len(s.Products) > 0 {
+		var ids []uuid.UUID
+		if err := db.Model(&models.Test{}).Where("product_id IN ?", s.Products).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		s.Tests = append(s.Tests, ids...)
+	}
+	if len(s.Tests) > 0 {
+		var ids []uuid.UUID
+		if err := db.Model(&models.Question{}).Where("test_id IN ?", s.Tests).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		s.Questions = append(s.Questions, ids...)
+	}
+	if len(s.Questions) > 0 {
+		var ids []uuid.UUID
+		if err := db.Model(&models.Option{}).Where("question_id IN ?", s.Questions).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		s.Options = append(s.Options, ids...)
+	}
+	return &s, nil
+}
+
+// countReport counts the content in the subtree and the attempts that
+// reference any of it
+func countReport(db *gorm.DB, kind models.ContentKind, id uuid.UUID, s *subtree) (*models.DeletionReport, error) {
+	report := &models.DeletionReport{
+		Entity:    kind,
+		ID:        id,
+		Tests:     int64(len(s.Tests)),
+		Questions: int64(len(s.Questions)),
+		Options:   int64(len(s.Options)),
+	}
+
+	byTest := db.Table("completed_test_tests").Select("completed_test_id").Where("test_id IN ?", s.Tests)
+	byQuestion := db.Table("completed_questions").Select("completed_test_id").
+		Where("test_id IN ? OR question_id IN ?", s.Tests, s.Questions)
+	byOption := db.Table("completed_questions cq").Select("cq.completed_test_id").
+		Joins("JOIN completed_question_selected_options so ON so.completed_question_id = cq.id").
+		Where("so.option_id IN ?", s.Options)
+
+	err := db.Model(&models.CompletedTest{}).
+		Where("product_id IN ?", s.Products).
+		Or("id IN (?)", byTest).
+		Or("id IN (?)", byQuestion).
+		Or("id IN (?)", byOption).
+		Count(&report.Attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/dedup"
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Merge errors
+var (
+	ErrMergeSurvivor     = errors.New("the surviving question cannot be merged into itself")
+	ErrUnmatchedOption   = errors.New("answered option has no option with the same text in the surviving question")
+	ErrMergeQuestionType = errors.New("questions of different types cannot be merged")
+	ErrMergeAnswerKey    = errors.New("questions with different answer keys cannot be merged")
+	ErrMergeAnsweredBoth = errors.New("an attempt answered both questions")
+)
+
+// duplicateRepo implements DuplicateRepo using GORM
+type duplicateRepo struct {
+	db *gorm.DB
+}
+
+// NewDuplicateRepo creates a new GORM duplicate question repository
+func NewDuplicateRepo(db *gorm.DB) DuplicateRepo {
+	return &duplicateRepo{db: db}
+}
+
+// Scan calls fn with batches of the live questions matching the filter, with
+// their options
+func (r *duplicateRepo) Scan(ctx context.Context, filter models.DuplicateFilter, size int, fn func([]*models.Question) error) error {
+	db := r.db.WithContext(ctx).Model(&models.Question{}).Preload("Options")
+	if filter.TestID != nil {
+		db = db.Where("questions.test_id = ?", *filter.TestID)
+	}
+	if filter.ProductID != nil {
+		db = db.Where("questions.test_id IN (?)",
+			r.db.Model(&models.Test{}).Select("id").Where("product_id = ?", *filter.ProductID))
+	}
+
+	var batch []*models.Question
+	return db.FindInBatches(&batch, size, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// Members returns the given questions with their options and number of
+// answers, most answered first
+func (r *duplicateRepo) Members(ctx context.Context, ids []uuid.UUID) ([]*models.DuplicateMember, error) {
+	var questions []*models.Question
+	if err := r.db.WithContext(ctx).Preload("Options").Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		QuestionID uuid.UUID
+		Answers    int64
+	}
+	err := r.db.WithContext(ctx).Model(&models.CompletedQuestion{}).
+		Select("question_id, count(*) AS answers").
+		Where("question_id IN ?", ids).
+		Group("question_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	answers := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		answers[c.QuestionID] = c.Answers
+	}
+
+	members := make([]*models.DuplicateMember, len(questions))
+	for i, q := range questions {
+		members[i] = &models.DuplicateMember{Question: q, Answers: answers[q.ID]}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Answers != members[j].Answers {
+			return members[i].Answers > members[j].Answers
+		}
+		return members[i].Question.ID.String() < members[j].Question.ID.String()
+	})
+	return members, nil
+}
+
+// Merge merges duplicate questions into a surviving question. Answers to a
+// duplicate are repointed to the survivor, pinned to its current revision,
+// with the options they selected, ordered or matched replaced by the
+// survivor options of the same normalized text. Legacy mappings follow too;
+// the duplicates and their options are then soft deleted. Only questions of
+// the same type and answer key are merged, as their answers would otherwise
+// be graded differently, and not when an attempt answered both.
+func (r *duplicateRepo) Merge(ctx context.Context, survivorID uuid.UUID, duplicateIDs []uuid.UUID) (*models.MergeReport, error) {
+	report := &models.MergeReport{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var survivor models.Question
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&survivor, "id = ?", survivorID).Error
+		if err != nil {
+			return err
+		}
+		var options []models.Option
+		if err := tx.Where("question_id = ?", survivorID).Order("id").Find(&options).Error; err != nil {
+			return err
+		}
+		byText := make(map[string]uuid.UUID, len(options))
+		for _, o := range options {
+			if _, ok := byText[dedup.Normalize(o.Text)]; !ok {
+				byText[dedup.Normalize(o.Text)] = o.ID
+			}
+		}
+		survivor.Options = options
+		var revision *int
+		if survivor.Revision > 0 {
+			revision = &survivor.Revision
+		}
+
+		merged := make(map[uuid.UUID]bool, len(duplicateIDs))
+		for _, id := range duplicateIDs {
+			if id == survivorID {
+				return ErrMergeSurvivor
+			}
+			if merged[id] {
+				continue
+			}
+			merged[id] = true
+
+			duplicate, answers, err := mergeDuplicate(tx, id, &survivor, revision, byText)
+			if err != nil {
+				return err
+			}
+			report.Merged = append(report.Merged, duplicate)
+			report.Answers += answers
+		}
+
+		report.Survivor = &survivor
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// mergeDuplicate merges one duplicate into the survivor and returns the
+// duplicate as it was along with the number of answers repointed
+func mergeDuplicate(tx *gorm.DB, id uuid.UUID, survivor *models.Question, revision *int, byText map[string]uuid.UUID) (*models.Question, int64, error) {
+	survivorID := survivor.ID
+	var duplicate models.Question
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Options").First(&duplicate, "id = ?", id).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if duplicate.QuestionType != survivor.QuestionType {
+		return nil, 0, fmt.Errorf("%w: question %s is %s, not %s", ErrMergeQuestionType, id, duplicate.QuestionType, survivor.QuestionType)
+	}
+	if answerKey(&duplicate) != answerKey(survivor) {
+		return nil, 0, fmt.Errorf("%w: question %s", ErrMergeAnswerKey, id)
+	}
+
+	// An attempt keeps one answer per question, so answers to both questions
+	// cannot be merged without losing one
+	var both int64
+	err = tx.Model(&models.CompletedQuestion{}).
+		Where("question_id = ?", id).
+		Where("completed_test_id IN (?)", tx.Model(&models.CompletedQuestion{}).Select("completed_test_id").Where("question_id = ?", survivorID)).
+		Count(&both).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if both > 0 {
+		return nil, 0, fmt.Errorf("%w: %d attempts answered question %s and %s", ErrMergeAnsweredBoth, both, id, survivorID)
+	}
+
+	// Deleted options may have been answered before their deletion
+	var options []models.Option
+	if err := tx.Unscoped().Where("question_id = ?", id).Find(&options).Error; err != nil {
+		return nil, 0, err
+	}
+	targets := make(map[uuid.UUID]uuid.UUID, len(options))
+	unmatched := make(map[uuid.UUID]string)
+	for _, o := range options {
+		target, ok := byText[dedup.Normalize(o.Text)]
+		if !ok {
+			var selected int64
+			err := tx.Table("completed_question_selected_options").Where("option_id = ?", o.ID).Count(&selected).Error
+			if err != nil {
+				return nil, 0, err
+			}
+			if selected > 0 {
+				return nil, 0, fmt.Errorf("%w: %q of question %s", ErrUnmatchedOption, o.Text, id)
+			}
+			unmatched[o.ID] = o.Text
+			continue
+		}
+		targets[o.ID] = target
+
+		// Answers selecting two options of the same text keep one
+		err := tx.Exec(`
+			INSERT INTO completed_question_selected_options (completed_question_id, option_id)
+			SELECT completed_question_id, ? FROM completed_question_selected_options WHERE option_id = ?
+			ON CONFLICT DO NOTHING`, target, o.ID).Error
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := tx.Exec("DELETE FROM completed_question_selected_options WHERE option_id = ?", o.ID).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := remapAnswerOptions(tx, id, targets, unmatched); err != nil {
+		return nil, 0, err
+	}
+
+	res := tx.Model(&models.CompletedQuestion{}).Where("question_id = ?", id).
+		Updates(map[string]interface{}{"question_id": survivorID, "question_revision": revision})
+	if res.Error != nil {
+		return nil, 0, res.Error
+	}
+	err = tx.Model(&models.LegacyQuestionMap{}).Where("question_id = ?", id).Update("question_id", survivorID).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Attempts that drew the duplicate draw the survivor instead, unless
+	// they drew both
+	err = tx.Exec(`
+		UPDATE attempt_questions SET question_id = ?
+		WHERE question_id = ?
+		AND completed_test_id NOT IN (SELECT completed_test_id FROM attempt_questions WHERE question_id = ?)`,
+		survivorID, id, survivorID).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Exec("DELETE FROM attempt_questions WHERE question_id = ?", id).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Where("question_id = ?", id).Delete(&models.Option{}).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Delete(&models.Question{}, "id = ?", id).Error; err != nil {
+		return nil, 0, err
+	}
+	return &duplicate, res.RowsAffected, nil
+}
+
+// remapAnswerOptions replaces the options of a duplicate in the ordering and
+// matching answers to it by their targets in the survivor
+func remapAnswerOptions(tx *gorm.DB, id uuid.UUID, targets map[uuid.UUID]uuid.UUID, unmatched map[uuid.UUID]string) error {
+	var answers []models.CompletedQuestion
+	err := tx.Select("id", "matched_pairs", "ordered_option_ids").
+		Where("question_id = ?", id).
+		Where("matched_pairs IS NOT NULL OR ordered_option_ids IS NOT NULL").
+		Find(&answers).Error
+	if err != nil {
+		return err
+	}
+
+	remap := func(optionID uuid.UUID) (uuid.UUID, error) {
+		if text, ok := unmatched[optionID]; ok {
+			return uuid.Nil, fmt.Errorf("%w: %q of question %s", ErrUnmatchedOption, text, id)
+		}
+		if target, ok := targets[optionID]; ok {
+			return target, nil
+		}
+		return optionID, nil
+	}
+	for _, answer := range answers {
+		pairs := make(models.MatchPairs, len(answer.MatchedPairs))
+		for i, pair := range answer.MatchedPairs {
+			if pair.OptionID, err = remap(pair.OptionID); err != nil {
+				return err
+			}
+			pairs[i] = pair
+		}
+		ordered := make(models.OptionIDs, len(answer.OrderedOptionIDs))
+		for i, optionID := range answer.OrderedOptionIDs {
+			if ordered[i], err = remap(optionID); err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{}
+		if answer.MatchedPairs != nil {
+			updates["matched_pairs"] = pairs
+		}
+		if answer.OrderedOptionIDs != nil {
+			updates["ordered_option_ids"] = ordered
+		}
+		if err := tx.Model(&models.CompletedQuestion{}).Where("id = ?", answer.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// answerKey describes what an answer to a question is graded against: the
+// options that are correct, matched or ordered, by normalized text, and the
+// numeric answer
+func answerKey(q *models.Question) string {
+	var keys []string
+	for _, o := range q.Options {
+		if !o.IsCorrect && o.MatchText == nil && o.Position == nil {
+			continue
+		}
+		key := fmt.Sprintf("%q %t", dedup.Normalize(o.Text), o.IsCorrect)
+		if o.MatchText != nil {
+			key += fmt.Sprintf(" match %q", dedup.Normalize(*o.MatchText))
+		}
+		if o.Position != nil {
+			key += fmt.Sprintf(" position %d", *o.Position)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if q.NumericAnswer != nil {
+		keys = append(keys, fmt.Sprintf("numeric %g", *q.NumericAnswer))
+	}
+	if q.Tolerance != nil {
+		keys = append(keys, fmt.Sprintf("tolerance %g", *q.Tolerance))
+	}
+	return strings.Join(keys, "\n")
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gradingRepo implements GradingRepo using GORM
+type gradingRepo struct {
+	db *gorm.DB
+}
+
+// NewGradingRepo creates a new GORM essay grading repository
+func NewGradingRepo(db *gorm.DB) GradingRepo {
+	return &gradingRepo{db: db}
+}
+
+// essayAnswers selects the answers to essay questions of finished attempts.
+// The type is the one of the revision an answer was pinned to, as answers are
+// graded on it; revisions from before question types are choice questions.
+func essayAnswers(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.CompletedQuestion{}).
+		Joins("JOIN completed_tests ct ON ct.id = completed_questions.completed_test_id").
+		Joins("JOIN questions q ON q.id = completed_questions.question_id").
+		Joins("LEFT JOIN question_revisions qr ON qr.question_id = completed_questions.question_id AND qr.revision = completed_questions.question_revision").
+		Where("CASE WHEN qr.id IS NULL THEN q.question_type ELSE COALESCE(qr.snapshot->>'question_type', ?) END = ?", models.QuestionChoice, models.QuestionEssay).
+		Where("ct.time_spent IS NOT NULL")
+}
+
+// Queue returns a page of the essay answers of finished attempts waiting for
+// a grade, or those already graded, oldest attempt first, with their
+// question, attempt and grade. A non-nil ownerID limits it to the teacher
+// products of that owner.
+func (r *gradingRepo) Queue(ctx context.Context, ownerID *uuid.UUID, filter models.GradingQueueFilter, args pagination.Args) (*pagination.Connection[*models.CompletedQuestion], error) {
+	order := pagination.Order[*models.CompletedQuestion]{
+		Name:      "COMPLETED_DATE",
+		Column:    "COALESCE(ct.completed_date, " + zeroTime + ")",
+		IDColumn:  "completed_questions.id",
+		Direction: pagination.Asc,
+		Key:       func(cq *models.CompletedQuestion) interface{} { return cq.CompletedTest.CompletedDate },
+		ID:        func(cq *models.CompletedQuestion) uuid.UUID { return cq.ID },
+	}
+
+	db := essayAnswers(r.db.WithContext(ctx))
+	graded := "NOT EXISTS (SELECT 1 FROM essay_grades g WHERE g.completed_question_id = completed_questions.id)"
+	if filter.Graded != nil && *filter.Graded {
+		graded = "EXISTS (SELECT 1 FROM essay_grades g WHERE g.completed_question_id = completed_questions.id)"
+	}
+	db = db.Where(graded)
+	if ownerID != nil {
+		db = db.Joins("JOIN products p ON p.id = ct.product_id").
+			Where("p.owner_id = ? AND p.product_type = ?", *ownerID, models.ProductTypeTeacher)
+	}
+	if filter.ProductID != nil {
+		db = db.Where("ct.product_id = ?", *filter.ProductID)
+	}
+	if filter.TestID != nil {
+		db = db.Where("completed_questions.test_id = ?", *filter.TestID)
+	}
+	return pagination.Paginate(db, args, order, "CompletedTest", "Question", "EssayGrade")
+}
+
+// GetAnswer returns an answered question with its attempt, its question
+// including its options and the revision it was pinned to, and its grade
+func (r *gradingRepo) GetAnswer(ctx context.Context, id uuid.UUID) (*models.CompletedQuestion, error) {
+	db := r.db.WithContext(ctx)
+	var answer models.CompletedQuestion
+	err := db.
+		Preload("CompletedTest").
+		Preload("Question", unscoped).
+		Preload("Question.Options", unscoped).
+		Preload("EssayGrade").
+		First(&answer, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	answers := []models.CompletedQuestion{answer}
+	if err := pinRevisions(db, answers); err != nil {
+		return nil, err
+	}
+	return &answers[0], nil
+}
+
+// SaveGrade creates the grade of an essay answer or replaces the one it has
+func (r *gradingRepo) SaveGrade(ctx context.Context, grade *models.EssayGrade) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.EssayGrade
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, "completed_question_id = ?", grade.CompletedQuestionID).Error
+		switch {
+		case err == nil:
+			grade.ID, grade.CreatedAt = current.ID, current.CreatedAt
+			return tx.Save(grade).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return tx.Create(grade).Error
+		default:
+			return err
+		}
+	})
+}
+
+// CountPending counts the essay answers of an attempt waiting for a grade
+func (r *gradingRepo) CountPending(ctx context.Context, completedTestID uuid.UUID) (int64, error) {
+	var count int64
+	err := essayAnswers(r.db.WithContext(ctx)).
+		Where("completed_questions.completed_test_id = ?", completedTestID).
+		Where("NOT EXISTS (SELECT 1 FROM essay_grades g WHERE g.completed_question_id = completed_questions.id)").
+		Count(&count).Error
+	return count, err
+}
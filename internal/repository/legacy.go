@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// legacyRepo implements LegacyRepo using GORM
+type legacyRepo struct {
+	db *gorm.DB
+}
+
+// NewLegacyRepo creates a new GORM legacy mapping repository
+func NewLegacyRepo(db *gorm.DB) LegacyRepo {
+	return &legacyRepo{db: db}
+}
+
+// GetMap returns the mapping of a legacy question
+func (r *legacyRepo) GetMap(ctx context.Context, detailID, lngID int) (*models.LegacyQuestionMap, error) {
+	var m models.LegacyQuestionMap
+	if err := r.db.WithContext(ctx).First(&m, "detail_id = ? AND lng_id = ?", detailID, lngID).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SaveMap creates or replaces the mapping of a legacy question
+func (r *legacyRepo) SaveMap(ctx context.Context, m *models.LegacyQuestionMap) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "detail_id"}, {Name: "lng_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"question_id", "checksum", "synced_at"}),
+		}).
+		Create(m).Error
+}
+
+// FindQuestions returns the questions carrying a legacy detail and language
+// ID, ordered by ID, with their options
+func (r *legacyRepo) FindQuestions(ctx context.Context, detailID, lngID int) ([]*models.Question, error) {
+	var questions []*models.Question
+	err := r.db.WithContext(ctx).
+		Where("detail_id = ? AND lng_id = ?", detailID, lngID).
+		Order("id").
+		Preload("Options").
+		Find(&questions).Error
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
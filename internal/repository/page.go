@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+)
+
+// ErrInvalidSort is returned for an unknown sort field or direction
+var ErrInvalidSort = errors.New("invalid sort")
+
+// zeroTime is the sort key of rows without a timestamp, the zero time.Time
+// they are loaded as
+const zeroTime = "'0001-01-01 00:00:00+00'"
+
+// sortDirection returns the requested direction, def when none is requested
+func sortDirection(d *models.SortDirection, def pagination.Direction) (pagination.Direction, error) {
+	if d == nil {
+		return def, nil
+	}
+	switch *d {
+	case models.SortAsc:
+		return pagination.Asc, nil
+	case models.SortDesc:
+		return pagination.Desc, nil
+	}
+	return "", fmt.Errorf("%w: direction %q", ErrInvalidSort, *d)
+}
+
+// containsPattern returns an ILIKE pattern matching s anywhere, with the
+// wildcards in s escaped
+func containsPattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
+
+// intKey returns the sort key of a nullable integer column, which sorts
+// NULL as 0
+func intKey(n *int) interface{} {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+// stringKey returns the sort key of a nullable text column, which sorts
+// NULL as the empty string
+func stringKey(s *string) interface{} {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
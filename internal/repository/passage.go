@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Passage group errors
+var (
+	ErrPassageTestNotFound   = errors.New("test of the passage group not found")
+	ErrPassageSourceNotFound = errors.New("passage of the passage group not found")
+	ErrPassageGroupTest      = errors.New("the test of a passage group with questions cannot change")
+	ErrPassageQuestionTest   = errors.New("questions of a passage group must belong to its test")
+	ErrPassageSource         = errors.New("questions of a passage group must use its passage as their source text")
+)
+
+// passageRepo implements PassageRepo using GORM
+type passageRepo struct {
+	db *gorm.DB
+}
+
+// NewPassageRepo creates a new GORM passage group repository
+func NewPassageRepo(db *gorm.DB) PassageRepo {
+	return &passageRepo{db: db}
+}
+
+// ListByTest returns the passage groups of a test, oldest first
+func (r *passageRepo) ListByTest(ctx context.Context, testID uuid.UUID) ([]*models.PassageGroup, error) {
+	var groups []*models.PassageGroup
+	err := r.db.WithContext(ctx).Where("test_id = ?", testID).Order("created_at, id").Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Get returns a passage group by ID
+func (r *passageRepo) Get(ctx context.Context, id uuid.UUID) (*models.PassageGroup, error) {
+	var group models.PassageGroup
+	if err := r.db.WithContext(ctx).First(&group, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Questions returns the questions of a passage group with their options, in
+// their passage order
+func (r *passageRepo) Questions(ctx context.Context, id uuid.UUID) ([]*models.Question, error) {
+	var questions []*models.Question
+	err := r.db.WithContext(ctx).Preload("Options").
+		Where("passage_group_id = ?", id).
+		Order("passage_position, id").
+		Find(&questions).Error
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// Create inserts a new, empty passage group
+func (r *passageRepo) Create(ctx context.Context, group *models.PassageGroup) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := validatePassageGroup(tx, group); err != nil {
+			return err
+		}
+		return tx.Create(group).Error
+	})
+}
+
+// Update saves a passage group. A new passage becomes the source text of its
+// questions; a group with questions stays in its test.
+func (r *passageRepo) Update(ctx context.Context, group *models.PassageGroup) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.PassageGroup
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", group.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := validatePassageGroup(tx, group); err != nil {
+			return err
+		}
+
+		if group.TestID != current.TestID {
+			var questions int64
+			if err := tx.Model(&models.Question{}).Unscoped().Where("passage_group_id = ?", group.ID).Count(&questions).Error; err != nil {
+				return err
+			}
+			if questions > 0 {
+				return ErrPassageGroupTest
+			}
+		}
+		if group.SourceID != current.SourceID {
+			err := tx.Model(&models.Question{}).Unscoped().
+				Where("passage_group_id = ?", group.ID).
+				Update("source_text_id", group.SourceID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(group).Error
+	})
+}
+
+// Delete removes a passage group. Its questions are no longer grouped but
+// keep their source text.
+func (r *passageRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ungroupQuestions(tx.Where("passage_group_id = ?", id)); err != nil {
+			return err
+		}
+		res := tx.Delete(&models.PassageGroup{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// SetQuestions makes the given questions of the test of a passage group its
+// questions, in the given order, and returns them along with the IDs of those
+// whose source text changed. Questions move over from other groups and take
+// the passage as their source text; questions of the group that are not
+// given leave it.
+func (r *passageRepo) SetQuestions(ctx context.Context, id uuid.UUID, questionIDs []uuid.UUID) ([]*models.Question, []uuid.UUID, error) {
+	var questions []*models.Question
+	var moved []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var group models.PassageGroup
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, "id = ?", id).Error
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[uuid.UUID]bool, len(questionIDs))
+		ids := make([]uuid.UUID, 0, len(questionIDs))
+		for _, questionID := range questionIDs {
+			if !seen[questionID] {
+				seen[questionID] = true
+				ids = append(ids, questionID)
+			}
+		}
+
+		if len(ids) > 0 {
+			var inTest int64
+			err := tx.Model(&models.Question{}).Where("id IN ? AND test_id = ?", ids, group.TestID).Count(&inTest).Error
+			if err != nil {
+				return err
+			}
+			if inTest != int64(len(ids)) {
+				return fmt.Errorf("%w: %s", ErrPassageQuestionTest, group.TestID)
+			}
+			err = tx.Model(&models.Question{}).
+				Where("id IN ? AND source_text_id IS DISTINCT FROM ?", ids, group.SourceID).
+				Pluck("id", &moved).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		leaving := tx.Where("passage_group_id = ?", id)
+		if len(ids) > 0 {
+			leaving = leaving.Where("id NOT IN ?", ids)
+		}
+		if err := ungroupQuestions(leaving); err != nil {
+			return err
+		}
+		for i, questionID := range ids {
+			err := tx.Model(&models.Question{}).Where("id = ?", questionID).Updates(map[string]interface{}{
+				"passage_group_id": id,
+				"passage_position": i + 1,
+				"source_text_id":   group.SourceID,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Preload("Options").
+			Where("passage_group_id = ?", id).
+			Order("passage_position, id").
+			Find(&questions).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return questions, moved, nil
+}
+
+// validatePassageGroup checks that the test and passage of a group exist
+func validatePassageGroup(tx *gorm.DB, group *models.PassageGroup) error {
+	var count int64
+	if err := tx.Model(&models.Test{}).Where("id = ?", group.TestID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPassageTestNotFound
+	}
+	if err := tx.Model(&models.Source{}).Where("id = ?", group.SourceID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPassageSourceNotFound
+	}
+	return nil
+}
+
+// ungroupQuestions takes the questions matched by db out of their passage
+// group, trashed questions included
+func ungroupQuestions(db *gorm.DB) error {
+	return db.Model(&models.Question{}).Unscoped().Updates(map[string]interface{}{
+		"passage_group_id": nil,
+		"passage_position": nil,
+	}).Error
+}
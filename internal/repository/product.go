@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// productRepo implements ProductRepo using GORM
+type productRepo struct {
+	db *gorm.DB
+}
+
+// NewProductRepo creates a new GORM product repository
+func NewProductRepo(db *gorm.DB) ProductRepo {
+	return &productRepo{db: db}
+}
+
+// Page returns a page of the products matching the filter
+func (r *productRepo) Page(ctx context.Context, filter models.ProductFilter, sort *models.ProductSort, args pagination.Args) (*pagination.Connection[*models.Product], error) {
+	order, err := productOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.Product{})
+	if filter.Title != nil {
+		db = db.Where("products.title ILIKE ?", containsPattern(*filter.Title))
+	}
+	if filter.ProductType != nil {
+		db = db.Where("products.product_type = ?", *filter.ProductType)
+	}
+	return pagination.Paginate(db, args, order)
+}
+
+// productOrder returns the order of a product sort
+func productOrder(sort *models.ProductSort) (pagination.Order[*models.Product], error) {
+	order := pagination.Order[*models.Product]{
+		IDColumn: "products.id",
+		ID:       func(p *models.Product) uuid.UUID { return p.ID },
+	}
+	field, def := models.ProductSortDateCreated, pagination.Desc
+	var dir *models.SortDirection
+	if sort != nil {
+		field, dir = sort.Field, sort.Direction
+	}
+	switch field {
+	case models.ProductSortTitle:
+		order.Column, def = "COALESCE(products.title, '')", pagination.Asc
+		order.Key = func(p *models.Product) interface{} { return p.Title }
+	case models.ProductSortDateCreated:
+		order.Column = "COALESCE(products.date_created, " + zeroTime + ")"
+		order.Key = func(p *models.Product) interface{} { return p.DateCreated }
+	default:
+		return order, fmt.Errorf("%w: field %q", ErrInvalidSort, field)
+	}
+
+	var err error
+	order.Name = string(field)
+	order.Direction, err = sortDirection(dir, def)
+	return order, err
+}
+
+// Get returns a product by ID
+func (r *productRepo) Get(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	var product models.Product
+	if err := r.db.WithContext(ctx).First(&product, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Create inserts a new product
+func (r *productRepo) Create(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Create(product).Error
+}
+
+// Update saves all fields of an existing product
+func (r *productRepo) Update(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Save(product).Error
+}
+
+// Restore undeletes a soft deleted product and the content deleted with it
+func (r *productRepo) Restore(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return restoreSubtree(tx, models.ContentProduct, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var product models.Product
+	if err := r.db.WithContext(ctx).First(&product, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
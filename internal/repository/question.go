@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// questionRepo implements QuestionRepo using GORM
+type questionRepo struct {
+	db *gorm.DB
+}
+
+// NewQuestionRepo creates a new GORM question repository
+func NewQuestionRepo(db *gorm.DB) QuestionRepo {
+	return &questionRepo{db: db}
+}
+
+// ListByTest returns all questions for a test with their options
+func (r *questionRepo) ListByTest(ctx context.Context, testID uuid.UUID) ([]*models.Question, error) {
+	var questions []*models.Question
+	if err := r.db.WithContext(ctx).Where("test_id = ?", testID).Preload("Options").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// PageByTest returns a page of the questions of a test matching the filter,
+// with their options
+func (r *questionRepo) PageByTest(ctx context.Context, testID uuid.UUID, filter models.QuestionFilter, sort *models.QuestionSort, args pagination.Args) (*pagination.Connection[*models.Question], error) {
+	order, err := questionOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.Question{}).Where("questions.test_id = ?", testID)
+	if filter.Category != nil {
+		db = db.Where("questions.category = ?", *filter.Category)
+	}
+	if filter.Theme != nil {
+		db = db.Where("questions.theme = ?", *filter.Theme)
+	}
+	if filter.Level != nil {
+		db = db.Where("questions.level = ?", *filter.Level)
+	}
+	if filter.Status != nil {
+		db = db.Where("questions.status = ?", *filter.Status)
+	}
+	if filter.TaskType != nil {
+		db = db.Where("questions.task_type = ?", *filter.TaskType)
+	}
+	if filter.LngTitle != nil {
+		db = db.Where("questions.lng_title = ?", *filter.LngTitle)
+	}
+	if filter.TaxonomyNodeID != nil {
+		db = db.Where("questions.taxonomy_node_id IN (?)", taxonomySubtree(r.db, *filter.TaxonomyNodeID))
+	}
+	return pagination.Paginate(db, args, order, "Options")
+}
+
+// questionOrder returns the order of a question sort
+func questionOrder(sort *models.QuestionSort) (pagination.Order[*models.Question], error) {
+	order := pagination.Order[*models.Question]{
+		IDColumn: "questions.id",
+		ID:       func(q *models.Question) uuid.UUID { return q.ID },
+	}
+	field := models.QuestionSortID
+	var dir *models.SortDirection
+	if sort != nil {
+		field, dir = sort.Field, sort.Direction
+	}
+	switch field {
+	case models.QuestionSortID:
+		order.Column = "questions.id"
+		order.Key = func(q *models.Question) interface{} { return q.ID }
+	case models.QuestionSortText:
+		order.Column = "COALESCE(questions.text, '')"
+		order.Key = func(q *models.Question) interface{} { return stringKey(q.Text) }
+	case models.QuestionSortLevel:
+		order.Column = "COALESCE(questions.level, 0)"
+		order.Key = func(q *models.Question) interface{} { return intKey(q.Level) }
+	case models.QuestionSortCategory:
+		order.Column = "COALESCE(questions.category, '')"
+		order.Key = func(q *models.Question) interface{} { return stringKey(q.Category) }
+	case models.QuestionSortTheme:
+		order.Column = "COALESCE(questions.theme, '')"
+		order.Key = func(q *models.Question) interface{} { return stringKey(q.Theme) }
+	default:
+		return order, fmt.Errorf("%w: field %q", ErrInvalidSort, field)
+	}
+
+	var err error
+	order.Name = string(field)
+	order.Direction, err = sortDirection(dir, pagination.Asc)
+	return order, err
+}
+
+// Get returns a question by ID with its options
+func (r *questionRepo) Get(ctx context.Context, id uuid.UUID) (*models.Question, error) {
+	var question models.Question
+	if err := r.db.WithContext(ctx).Preload("Options").First(&question, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// taxonomyColumns are the columns the taxonomy trigger of the questions table
+// may change on write, read back into the saved question
+var taxonomyColumns = clause.Returning{Columns: []clause.Column{
+	{Name: "taxonomy_node_id"}, {Name: "subject_title"}, {Name: "category"}, {Name: "theme"}, {Name: "subtheme"},
+}}
+
+// Create inserts a new question
+func (r *questionRepo) Create(ctx context.Context, question *models.Question) error {
+	return r.db.WithContext(ctx).Clauses(taxonomyColumns).Create(question).Error
+}
+
+// Update saves all fields of an existing question but its review status and
+// author, which only the review changes
+func (r *questionRepo) Update(ctx context.Context, question *models.Question) error {
+	return r.db.WithContext(ctx).Clauses(taxonomyColumns).Omit("Options", "Status", "AuthorID").Save(question).Error
+}
+
+// Restore undeletes a soft deleted question and the options deleted with it
+func (r *questionRepo) Restore(ctx context.Context, id uuid.UUID) (*models.Question, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return restoreSubtree(tx, models.ContentQuestion, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var question models.Question
+	if err := r.db.WithContext(ctx).Preload("Options").First(&question, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// GetOption returns an option by ID
+func (r *questionRepo) GetOption(ctx context.Context, id uuid.UUID) (*models.Option, error) {
+	var option models.Option
+	if err := r.db.WithContext(ctx).First(&option, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &option, nil
+}
+
+// CreateOption inserts a new option
+func (r *questionRepo) CreateOption(ctx context.Context, option *models.Option) error {
+	return r.db.WithContext(ctx).Create(option).Error
+}
+
+// UpdateOption saves all fields of an existing option
+func (r *questionRepo) UpdateOption(ctx context.Context, option *models.Option) error {
+	return r.db.WithContext(ctx).Save(option).Error
+}
+
+// RestoreOption undeletes a soft deleted option
+func (r *questionRepo) RestoreOption(ctx context.Context, id uuid.UUID) (*models.Option, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return restoreSubtree(tx, models.ContentOption, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var option models.Option
+	if err := r.db.WithContext(ctx).First(&option, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &option, nil
+}
+
+// GetSource returns a source by ID
+func (r *questionRepo) GetSource(ctx context.Context, id uuid.UUID) (*models.Source, error) {
+	var source models.Source
+	if err := r.db.WithContext(ctx).First(&source, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// CreateSource inserts a new source
+func (r *questionRepo) CreateSource(ctx context.Context, source *models.Source) error {
+	return r.db.WithContext(ctx).Create(source).Error
+}
+
+// UpdateSource saves all fields of an existing source
+func (r *questionRepo) UpdateSource(ctx context.Context, source *models.Source) error {
+	return r.db.WithContext(ctx).Save(source).Error
+}
+
+// DeleteSource deletes a source by ID
+func (r *questionRepo) DeleteSource(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.Source{}, "id = ?", id).Error
+}
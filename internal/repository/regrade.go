@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// regradeRepo implements RegradeRepo using GORM
+type regradeRepo struct {
+	db *gorm.DB
+}
+
+// NewRegradeRepo creates a new GORM regrade repository
+func NewRegradeRepo(db *gorm.DB) RegradeRepo {
+	return &regradeRepo{db: db}
+}
+
+// Create inserts a new regrade
+func (r *regradeRepo) Create(ctx context.Context, regrade *models.Regrade) error {
+	return r.db.WithContext(ctx).Create(regrade).Error
+}
+
+// Get returns a regrade by ID with its results
+func (r *regradeRepo) Get(ctx context.Context, id uuid.UUID) (*models.Regrade, error) {
+	var regrade models.Regrade
+	err := r.db.WithContext(ctx).
+		Preload("Results", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&regrade, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &regrade, nil
+}
+
+// Update saves all fields of an existing regrade
+func (r *regradeRepo) Update(ctx context.Context, regrade *models.Regrade) error {
+	return r.db.WithContext(ctx).Omit("Results").Save(regrade).Error
+}
+
+// Finish marks a regrade as completed with the number of attempts regraded
+// and how many of them changed score
+func (r *regradeRepo) Finish(ctx context.Context, id uuid.UUID, attempts, changed int) error {
+	return r.db.WithContext(ctx).Model(&models.Regrade{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":     attempts,
+		"changed":      changed,
+		"completed_at": time.Now(),
+	}).Error
+}
+
+// AddResults inserts the results of a batch of regraded attempts and returns
+// those it inserted. Results already recorded for an attempt are kept and
+// left out, so retried batches are harmless.
+func (r *regradeRepo) AddResults(ctx context.Context, results []*models.RegradeResult) ([]*models.RegradeResult, error) {
+	var added []*models.RegradeResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, result := range results {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(result)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				added = append(added, result)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
@@ -0,0 +1,226 @@
+// Package repository provides data access for the domain models
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductRepo provides access to products
+type ProductRepo interface {
+	Page(ctx context.Context, filter models.ProductFilter, sort *models.ProductSort, args pagination.Args) (*pagination.Connection[*models.Product], error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Product, error)
+	Create(ctx context.Context, product *models.Product) error
+	Update(ctx context.Context, product *models.Product) error
+	Restore(ctx context.Context, id uuid.UUID) (*models.Product, error)
+}
+
+// TestRepo provides access to tests
+type TestRepo interface {
+	Page(ctx context.Context, filter models.TestFilter, sort *models.TestSort, args pagination.Args) (*pagination.Connection[*models.Test], error)
+	ListByProduct(ctx context.Context, productID uuid.UUID) ([]*models.Test, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Test, error)
+	GetMany(ctx context.Context, ids []uuid.UUID) ([]*models.Test, error)
+	Create(ctx context.Context, test *models.Test) error
+	Update(ctx context.Context, test *models.Test) error
+	Restore(ctx context.Context, id uuid.UUID) (*models.Test, error)
+}
+
+// QuestionRepo provides access to questions, their revisions and the options and sources they own
+type QuestionRepo interface {
+	ListByTest(ctx context.Context, testID uuid.UUID) ([]*models.Question, error)
+	PageByTest(ctx context.Context, testID uuid.UUID, filter models.QuestionFilter, sort *models.QuestionSort, args pagination.Args) (*pagination.Connection[*models.Question], error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Question, error)
+	Create(ctx context.Context, question *models.Question) error
+	Update(ctx context.Context, question *models.Question) error
+	Restore(ctx context.Context, id uuid.UUID) (*models.Question, error)
+	Search(ctx context.Context, query string, filter models.QuestionSearchFilter, limit, offset int) ([]*models.QuestionSearchHit, int64, error)
+
+	GetOption(ctx context.Context, id uuid.UUID) (*models.Option, error)
+	CreateOption(ctx context.Context, option *models.Option) error
+	UpdateOption(ctx context.Context, option *models.Option) error
+	RestoreOption(ctx context.Context, id uuid.UUID) (*models.Option, error)
+
+	SaveRevision(ctx context.Context, questionID uuid.UUID, authorID *uuid.UUID) (*models.QuestionRevision, error)
+	ListRevisions(ctx context.Context, questionID uuid.UUID) ([]*models.QuestionRevision, error)
+	Revert(ctx context.Context, questionID uuid.UUID, revision int, authorID *uuid.UUID) (*models.Question, error)
+
+	GetSource(ctx context.Context, id uuid.UUID) (*models.Source, error)
+	CreateSource(ctx context.Context, source *models.Source) error
+	UpdateSource(ctx context.Context, source *models.Source) error
+	DeleteSource(ctx context.Context, id uuid.UUID) error
+}
+
+// AttemptRepo provides access to test attempts (completed tests and their answers)
+type AttemptRepo interface {
+	PageByUser(ctx context.Context, userID uuid.UUID, filter models.CompletedTestFilter, sort *models.CompletedTestSort, args pagination.Args) (*pagination.Connection[*models.CompletedTest], error)
+	Get(ctx context.Context, id uuid.UUID) (*models.CompletedTest, error)
+	GetWithAnswers(ctx context.Context, id uuid.UUID) (*models.CompletedTest, error)
+	Start(ctx context.Context, completedTest *models.CompletedTest, testIDs []uuid.UUID) error
+	ListQuestions(ctx context.Context, completedTestID uuid.UUID, testID *uuid.UUID) ([]*models.Question, error)
+	RecordAnswer(ctx context.Context, completedQuestion *models.CompletedQuestion, optionIDs []uuid.UUID) error
+	Update(ctx context.Context, completedTest *models.CompletedTest) error
+	SaveScore(ctx context.Context, id uuid.UUID, score, correctAnswers, totalQuestions int) (*int, error)
+	ListIDsForRegrade(ctx context.Context, regrade *models.Regrade) ([]uuid.UUID, error)
+	PageForRegrade(ctx context.Context, regrade *models.Regrade, after *models.RegradeCursor, limit int) ([]models.RegradeCursor, error)
+}
+
+// UserRepo provides access to users
+type UserRepo interface {
+	Page(ctx context.Context, filter models.UserFilter, sort *models.UserSort, args pagination.Args) (*pagination.Connection[*models.User], error)
+	Get(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	CountByRole(ctx context.Context, role models.UserRole) (int64, error)
+	CountByUsername(ctx context.Context, username string) (int64, error)
+	ListIDsByRole(ctx context.Context, roles ...models.UserRole) ([]uuid.UUID, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error
+}
+
+// TrashRepo provides access to soft deleted content
+type TrashRepo interface {
+	List(ctx context.Context) (*models.Trash, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (*models.PurgeResult, error)
+}
+
+// DeletionRepo deletes content together with everything below it
+type DeletionRepo interface {
+	Report(ctx context.Context, kind models.ContentKind, id uuid.UUID) (*models.DeletionReport, error)
+	Delete(ctx context.Context, kind models.ContentKind, id uuid.UUID, force bool) (*models.DeletionReport, error)
+}
+
+// AuditRepo provides access to the audit log
+type AuditRepo interface {
+	Create(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, filter models.AuditLogFilter, limit, offset int) ([]*models.AuditEntry, int64, error)
+	Each(ctx context.Context, filter models.AuditLogFilter, fn func(entry *models.AuditEntry) error) error
+}
+
+// RegradeRepo provides access to regrades and their results
+type RegradeRepo interface {
+	Create(ctx context.Context, regrade *models.Regrade) error
+	Get(ctx context.Context, id uuid.UUID) (*models.Regrade, error)
+	Update(ctx context.Context, regrade *models.Regrade) error
+	Finish(ctx context.Context, id uuid.UUID, attempts, changed int) error
+	AddResults(ctx context.Context, results []*models.RegradeResult) ([]*models.RegradeResult, error)
+}
+
+// BundleRepo writes content under given IDs for bundle imports
+type BundleRepo interface {
+	Existing(ctx context.Context, model interface{}, ids []uuid.UUID) (map[uuid.UUID]bool, error)
+	Upsert(ctx context.Context, value interface{}) error
+	UpsertQuestion(ctx context.Context, question *models.Question) error
+}
+
+// LegacyRepo provides access to the mapping of legacy questions
+type LegacyRepo interface {
+	GetMap(ctx context.Context, detailID, lngID int) (*models.LegacyQuestionMap, error)
+	SaveMap(ctx context.Context, m *models.LegacyQuestionMap) error
+	FindQuestions(ctx context.Context, detailID, lngID int) ([]*models.Question, error)
+}
+
+// TaxonomyRepo provides access to the taxonomy questions are classified by
+type TaxonomyRepo interface {
+	List(ctx context.Context, parentID *uuid.UUID) ([]*models.TaxonomyNode, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.TaxonomyNode, error)
+	Path(ctx context.Context, id uuid.UUID) ([]*models.TaxonomyNode, error)
+	Create(ctx context.Context, node *models.TaxonomyNode) error
+	Update(ctx context.Context, node *models.TaxonomyNode) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Merge(ctx context.Context, id, into uuid.UUID) (*models.TaxonomyNode, error)
+}
+
+// DuplicateRepo scans questions for duplicates and merges them
+type DuplicateRepo interface {
+	Scan(ctx context.Context, filter models.DuplicateFilter, size int, fn func([]*models.Question) error) error
+	Members(ctx context.Context, ids []uuid.UUID) ([]*models.DuplicateMember, error)
+	Merge(ctx context.Context, survivorID uuid.UUID, duplicateIDs []uuid.UUID) (*models.MergeReport, error)
+}
+
+// ReviewRepo moves questions through the editorial review and keeps its
+// history
+type ReviewRepo interface {
+	Transition(ctx context.Context, questionID uuid.UUID, action models.ReviewAction, actorID *uuid.UUID, comment *string) (*models.QuestionReview, error)
+	Revise(ctx context.Context, question *models.Question, actorID *uuid.UUID) (*models.QuestionReview, error)
+	History(ctx context.Context, questionID uuid.UUID) ([]*models.QuestionReview, error)
+	Queue(ctx context.Context, filter models.ReviewQueueFilter, args pagination.Args) (*pagination.Connection[*models.Question], error)
+}
+
+// PassageRepo provides access to the groups of questions sharing a reading
+// passage
+type PassageRepo interface {
+	ListByTest(ctx context.Context, testID uuid.UUID) ([]*models.PassageGroup, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.PassageGroup, error)
+	Questions(ctx context.Context, id uuid.UUID) ([]*models.Question, error)
+	Create(ctx context.Context, group *models.PassageGroup) error
+	Update(ctx context.Context, group *models.PassageGroup) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	SetQuestions(ctx context.Context, id uuid.UUID, questionIDs []uuid.UUID) ([]*models.Question, []uuid.UUID, error)
+}
+
+// GradingRepo provides access to the essay answers graded by hand and their
+// grades
+type GradingRepo interface {
+	Queue(ctx context.Context, ownerID *uuid.UUID, filter models.GradingQueueFilter, args pagination.Args) (*pagination.Connection[*models.CompletedQuestion], error)
+	GetAnswer(ctx context.Context, id uuid.UUID) (*models.CompletedQuestion, error)
+	SaveGrade(ctx context.Context, grade *models.EssayGrade) error
+	CountPending(ctx context.Context, completedTestID uuid.UUID) (int64, error)
+}
+
+// Repositories bundles the repositories backed by a single database handle
+type Repositories struct {
+	db *gorm.DB
+
+	Products   ProductRepo
+	Tests      TestRepo
+	Questions  QuestionRepo
+	Attempts   AttemptRepo
+	Users      UserRepo
+	Trash      TrashRepo
+	Deletions  DeletionRepo
+	Audit      AuditRepo
+	Regrades   RegradeRepo
+	Bundles    BundleRepo
+	Legacy     LegacyRepo
+	Taxonomy   TaxonomyRepo
+	Duplicates DuplicateRepo
+	Reviews    ReviewRepo
+	Passages   PassageRepo
+	Grading    GradingRepo
+}
+
+// New creates the GORM-backed repositories for the given database handle
+func New(db *gorm.DB) *Repositories {
+	return &Repositories{
+		db:         db,
+		Products:   NewProductRepo(db),
+		Tests:      NewTestRepo(db),
+		Questions:  NewQuestionRepo(db),
+		Attempts:   NewAttemptRepo(db),
+		Users:      NewUserRepo(db),
+		Trash:      NewTrashRepo(db),
+		Deletions:  NewDeletionRepo(db),
+		Audit:      NewAuditRepo(db),
+		Regrades:   NewRegradeRepo(db),
+		Bundles:    NewBundleRepo(db),
+		Legacy:     NewLegacyRepo(db),
+		Taxonomy:   NewTaxonomyRepo(db),
+		Duplicates: NewDuplicateRepo(db),
+		Reviews:    NewReviewRepo(db),
+		Passages:   NewPassageRepo(db),
+		Grading:    NewGradingRepo(db),
+	}
+}
+
+// Transaction runs fn with repositories bound to a single database transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (r *Repositories) Transaction(ctx context.Context, fn func(tx *Repositories) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
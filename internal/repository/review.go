@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/Alan69/ayatest/internal/review"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reviewRepo implements ReviewRepo using GORM
+type reviewRepo struct {
+	db *gorm.DB
+}
+
+// NewReviewRepo creates a new GORM review repository
+func NewReviewRepo(db *gorm.DB) ReviewRepo {
+	return &reviewRepo{db: db}
+}
+
+// Transition takes a review action on a question: its status is moved on and
+// the action is added to its review history. The question is locked, so of
+// two reviewers deciding at once only the first succeeds.
+func (r *reviewRepo) Transition(ctx context.Context, questionID uuid.UUID, action models.ReviewAction, actorID *uuid.UUID, comment *string) (*models.QuestionReview, error) {
+	var entry *models.QuestionReview
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var question models.Question
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&question, "id = ?", questionID).Error
+		if err != nil {
+			return err
+		}
+
+		to, err := review.Next(action, question.Status)
+		if err != nil {
+			return err
+		}
+		if to != question.Status {
+			if err := tx.Model(&question).Update("status", to).Error; err != nil {
+				return err
+			}
+		}
+
+		entry = &models.QuestionReview{
+			QuestionID: questionID,
+			ActorID:    actorID,
+			Action:     action,
+			FromStatus: question.Status,
+			ToStatus:   to,
+			Comment:    comment,
+		}
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Revise sends an approved question back to review after its content was
+// edited, so edited content is not served before a reviewer approves it. It
+// returns the review entry, nil when the question was not approved.
+func (r *reviewRepo) Revise(ctx context.Context, question *models.Question, actorID *uuid.UUID) (*models.QuestionReview, error) {
+	if question.Status != models.QuestionApproved {
+		return nil, nil
+	}
+	entry, err := r.Transition(ctx, question.ID, models.ReviewRevise, actorID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("revise approved question: %w", err)
+	}
+	question.Status = entry.ToStatus
+	return entry, nil
+}
+
+// History returns the review history of a question, oldest first
+func (r *reviewRepo) History(ctx context.Context, questionID uuid.UUID) ([]*models.QuestionReview, error) {
+	var entries []*models.QuestionReview
+	err := r.db.WithContext(ctx).Where("question_id = ?", questionID).Order("created_at, id").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Queue returns a page of the questions in a review status, in review by
+// default, with their options
+func (r *reviewRepo) Queue(ctx context.Context, filter models.ReviewQueueFilter, args pagination.Args) (*pagination.Connection[*models.Question], error) {
+	order, err := questionOrder(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status := models.QuestionInReview
+	if filter.Status != nil {
+		status = *filter.Status
+	}
+	db := r.db.WithContext(ctx).Model(&models.Question{}).Where("questions.status = ?", status)
+	if filter.TestID != nil {
+		db = db.Where("questions.test_id = ?", *filter.TestID)
+	}
+	if filter.AuthorID != nil {
+		db = db.Where("questions.author_id = ?", *filter.AuthorID)
+	}
+	return pagination.Paginate(db, args, order, "Options")
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveRevision bumps the revision of a question and stores a snapshot of it
+// with its current options
+func (r *questionRepo) SaveRevision(ctx context.Context, questionID uuid.UUID, authorID *uuid.UUID) (*models.QuestionRevision, error) {
+	var revision *models.QuestionRevision
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		revision, err = saveRevision(tx, questionID, authorID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// ListRevisions returns the revisions of a question, oldest first
+func (r *questionRepo) ListRevisions(ctx context.Context, questionID uuid.UUID) ([]*models.QuestionRevision, error) {
+	var revisions []*models.QuestionRevision
+	err := r.db.WithContext(ctx).Where("question_id = ?", questionID).Order("revision").Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Revert restores a question and its options to a previous revision. Options
+// added since are soft deleted and the result is saved as a new revision.
+func (r *questionRepo) Revert(ctx context.Context, questionID uuid.UUID, revision int, authorID *uuid.UUID) (*models.Question, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var target models.QuestionRevision
+		if err := tx.Where("question_id = ? AND revision = ?", questionID, revision).First(&target).Error; err != nil {
+			return err
+		}
+		snapshot, err := target.Question()
+		if err != nil {
+			return err
+		}
+
+		var current models.Question
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", questionID).Error; err != nil {
+			return err
+		}
+
+		// The question stays where it is and keeps its review status, only
+		// its content is reverted
+		snapshot.ID = current.ID
+		snapshot.TestID = current.TestID
+		snapshot.Revision = current.Revision
+		snapshot.DeletedAt = current.DeletedAt
+		snapshot.Status = current.Status
+		snapshot.AuthorID = current.AuthorID
+		// It stays in its passage group while it keeps the passage
+		snapshot.PassageGroupID, snapshot.PassagePosition = nil, nil
+		if snapshot.SourceTextID != nil && current.SourceTextID != nil && *snapshot.SourceTextID == *current.SourceTextID {
+			snapshot.PassageGroupID, snapshot.PassagePosition = current.PassageGroupID, current.PassagePosition
+		}
+		// Revisions from before content formats were plain text
+		if snapshot.ContentFormat == "" {
+			snapshot.ContentFormat = models.ContentPlain
+		}
+		options := snapshot.Options
+		snapshot.Options = nil
+		if err := tx.Omit(clause.Associations).Save(snapshot).Error; err != nil {
+			return err
+		}
+
+		keep := make([]uuid.UUID, 0, len(options))
+		for i := range options {
+			option := options[i]
+			option.QuestionID = questionID
+			option.DeletedAt = gorm.DeletedAt{}
+			if err := tx.Unscoped().Omit(clause.Associations).Save(&option).Error; err != nil {
+				return err
+			}
+			keep = append(keep, option.ID)
+		}
+
+		stale := tx.Where("question_id = ?", questionID)
+		if len(keep) > 0 {
+			stale = stale.Where("id NOT IN ?", keep)
+		}
+		if err := stale.Delete(&models.Option{}).Error; err != nil {
+			return err
+		}
+
+		_, err = saveRevision(tx, questionID, authorID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, questionID)
+}
+
+// saveRevision bumps the revision of a question and snapshots it within tx.
+// The update locks the question row so concurrent revisions are serialized.
+func saveRevision(tx *gorm.DB, questionID uuid.UUID, authorID *uuid.UUID) (*models.QuestionRevision, error) {
+	res := tx.Model(&models.Question{}).Where("id = ?", questionID).
+		UpdateColumn("revision", gorm.Expr("revision + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var question models.Question
+	if err := tx.Preload("Options").First(&question, "id = ?", questionID).Error; err != nil {
+		return nil, err
+	}
+	snapshot, err := json.Marshal(&question)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &models.QuestionRevision{
+		QuestionID: questionID,
+		Revision:   question.Revision,
+		Snapshot:   snapshot,
+		AuthorID:   authorID,
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// pinRevisions loads the revision each answered question was pinned to into
+// PinnedQuestion
+func pinRevisions(db *gorm.DB, completedQuestions []models.CompletedQuestion) error {
+	var keys [][]interface{}
+	for _, cq := range completedQuestions {
+		if cq.QuestionID != nil && cq.QuestionRevision != nil {
+			keys = append(keys, []interface{}{*cq.QuestionID, *cq.QuestionRevision})
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var revisions []*models.QuestionRevision
+	if err := db.Where("(question_id, revision) IN ?", keys).Find(&revisions).Error; err != nil {
+		return err
+	}
+	type key struct {
+		questionID uuid.UUID
+		revision   int
+	}
+	pinned := make(map[key]*models.Question, len(revisions))
+	for _, revision := range revisions {
+		question, err := revision.Question()
+		if err != nil {
+			return err
+		}
+		pinned[key{revision.QuestionID, revision.Revision}] = question
+	}
+
+	for i := range completedQuestions {
+		cq := &completedQuestions[i]
+		if cq.QuestionID != nil && cq.QuestionRevision != nil {
+			cq.PinnedQuestion = pinned[key{*cq.QuestionID, *cq.QuestionRevision}]
+		}
+	}
+	return nil
+}
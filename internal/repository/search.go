@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// searchConfigs are the text search configurations question documents are
+// built with, see question_search_config in the migrations
+var searchConfigs = []string{"simple", "russian", "english"}
+
+// headlineOptions configure the snippets of search hits. The texts are HTML
+// escaped before ts_headline runs, so the marks are the only tags of a snippet.
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=8, MaxFragments=3, FragmentDelimiter=" … "`
+
+// Search returns the questions matching a web search style query ("quoted
+// phrases", or, -excluded), best match first, along with the total number
+// of matches. Without a language filter the query is matched in every
+// search configuration.
+func (r *questionRepo) Search(ctx context.Context, query string, filter models.QuestionSearchFilter, limit, offset int) ([]*models.QuestionSearchHit, int64, error) {
+	tsquery, args := searchQuery(query, filter.LngTitle)
+
+	db := r.db.WithContext(ctx).Table("questions").
+		Where("questions.deleted_at IS NULL").
+		Where("questions.search_document @@ "+tsquery, args...)
+	if filter.TestID != nil {
+		db = db.Where("questions.test_id = ?", *filter.TestID)
+	}
+	if filter.Category != nil {
+		db = db.Where("questions.category = ?", *filter.Category)
+	}
+	if filter.Theme != nil {
+		db = db.Where("questions.theme = ?", *filter.Theme)
+	}
+	if filter.Level != nil {
+		db = db.Where("questions.level = ?", *filter.Level)
+	}
+	if filter.SubjectID != nil {
+		db = db.Where("questions.subject_id = ?", *filter.SubjectID)
+	}
+	if filter.ClassNumber != nil {
+		db = db.Where("questions.class_number = ?", *filter.ClassNumber)
+	}
+	if filter.LngTitle != nil {
+		db = db.Where("questions.lng_title = ?", *filter.LngTitle)
+	}
+	if filter.TaxonomyNodeID != nil {
+		db = db.Where("questions.taxonomy_node_id IN (?)", taxonomySubtree(r.db, *filter.TaxonomyNodeID))
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Snippets are only built for the questions of the page
+	page := db.Select("questions.*, ts_rank_cd(questions.search_document, "+tsquery+") AS rank", args...).
+		Order("rank DESC, questions.id").Offset(offset)
+	if limit > 0 {
+		page = page.Limit(limit)
+	}
+	var rows []struct {
+		ID      uuid.UUID
+		Rank    float64
+		Snippet string
+	}
+	err := r.db.WithContext(ctx).Table("(?) AS hits", page).
+		Select("hits.id, hits.rank, ts_headline(question_search_config(hits.lng_title), "+
+			escapeHTML("array_to_string(question_search_text(hits.id, hits.text, hits.text2, hits.text3, hits.source_text_id), ' ')")+", "+
+			tsquery+", ?) AS snippet", append(args, headlineOptions)...).
+		Order("hits.rank DESC, hits.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []*models.QuestionSearchHit{}, total, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var questions []*models.Question
+	if err := r.db.WithContext(ctx).Preload("Options").Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uuid.UUID]*models.Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	hits := make([]*models.QuestionSearchHit, 0, len(rows))
+	for _, row := range rows {
+		if q, ok := byID[row.ID]; ok {
+			hits = append(hits, &models.QuestionSearchHit{Question: q, Rank: row.Rank, Snippet: row.Snippet})
+		}
+	}
+	return hits, total, nil
+}
+
+// escapeHTML returns the SQL expression escaping the HTML special characters
+// of a text expression
+func escapeHTML(expr string) string {
+	return "replace(replace(replace(replace(replace(" + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// searchQuery returns the tsquery expression of a search query and its
+// arguments. A language title selects its configuration; otherwise the
+// queries of all configurations are combined.
+func searchQuery(query string, lngTitle *string) (string, []interface{}) {
+	if lngTitle != nil {
+		return "websearch_to_tsquery(question_search_config(?), ?)", []interface{}{*lngTitle, query}
+	}
+	expr := "("
+	args := make([]interface{}, 0, len(searchConfigs))
+	for i, config := range searchConfigs {
+		if i > 0 {
+			expr += " || "
+		}
+		expr += "websearch_to_tsquery('" + config + "', ?)"
+		args = append(args, query)
+	}
+	return expr + ")", args
+}
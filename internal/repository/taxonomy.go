@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Taxonomy errors
+var (
+	ErrInvalidTaxonomyLevel   = errors.New("taxonomy level must be SUBJECT, CATEGORY, THEME or SUBTHEME")
+	ErrTaxonomyTitleRequired  = errors.New("taxonomy node title is required")
+	ErrTaxonomyParentNotFound = errors.New("taxonomy parent node not found")
+	ErrTaxonomyLevelOrder     = errors.New("taxonomy nodes must be at a deeper level than their parent")
+	ErrTaxonomyNodeExists     = errors.New("a taxonomy node with this title already exists at this place")
+	ErrTaxonomyNodeInUse      = errors.New("taxonomy node has child nodes or questions")
+	ErrTaxonomyMergeLevel     = errors.New("only different taxonomy nodes of the same level can be merged")
+)
+
+// taxonomyRepo implements TaxonomyRepo using GORM
+type taxonomyRepo struct {
+	db *gorm.DB
+}
+
+// NewTaxonomyRepo creates a new GORM taxonomy repository
+func NewTaxonomyRepo(db *gorm.DB) TaxonomyRepo {
+	return &taxonomyRepo{db: db}
+}
+
+// List returns the children of a node, or the root nodes for a nil parent,
+// ordered by title
+func (r *taxonomyRepo) List(ctx context.Context, parentID *uuid.UUID) ([]*models.TaxonomyNode, error) {
+	db := r.db.WithContext(ctx)
+	if parentID != nil {
+		db = db.Where("parent_id = ?", *parentID)
+	} else {
+		db = db.Where("parent_id IS NULL")
+	}
+
+	var nodes []*models.TaxonomyNode
+	if err := db.Order("normalized_title, id").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// Get returns a node by ID
+func (r *taxonomyRepo) Get(ctx context.Context, id uuid.UUID) (*models.TaxonomyNode, error) {
+	var node models.TaxonomyNode
+	if err := r.db.WithContext(ctx).First(&node, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// Path returns a node with its ancestors, from the root down
+func (r *taxonomyRepo) Path(ctx context.Context, id uuid.UUID) ([]*models.TaxonomyNode, error) {
+	var nodes []*models.TaxonomyNode
+	err := r.db.WithContext(ctx).Raw(`
+		WITH RECURSIVE path AS (
+			SELECT n.*, 0 AS depth FROM taxonomy_nodes n WHERE n.id = ?
+			UNION ALL
+			SELECT n.*, p.depth + 1 FROM taxonomy_nodes n JOIN path p ON n.id = p.parent_id
+		)
+		SELECT id, parent_id, level, title, normalized_title, created_at, updated_at
+		FROM path
+		ORDER BY depth DESC`, id).
+		Scan(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return nodes, nil
+}
+
+// Create inserts a new node
+func (r *taxonomyRepo) Create(ctx context.Context, node *models.TaxonomyNode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := validateTaxonomyNode(tx, node); err != nil {
+			return err
+		}
+		return tx.Create(node).Error
+	})
+}
+
+// Update saves a renamed or moved node and copies its new path into the
+// readable taxonomy columns of the questions below it
+func (r *taxonomyRepo) Update(ctx context.Context, node *models.TaxonomyNode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := validateTaxonomyNode(tx, node); err != nil {
+			return err
+		}
+
+		// Children have to stay deeper than the node
+		var children []*models.TaxonomyNode
+		if err := tx.Where("parent_id = ?", node.ID).Find(&children).Error; err != nil {
+			return err
+		}
+		for _, child := range children {
+			if child.Level.Depth() <= node.Level.Depth() {
+				return ErrTaxonomyLevelOrder
+			}
+		}
+
+		if err := tx.Save(node).Error; err != nil {
+			return err
+		}
+		return syncTaxonomyQuestions(tx, node.ID)
+	})
+}
+
+// Delete removes a node without children or questions
+func (r *taxonomyRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var node models.TaxonomyNode
+		if err := tx.First(&node, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		var children, questions int64
+		if err := tx.Model(&models.TaxonomyNode{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Question{}).Where("taxonomy_node_id = ?", id).Count(&questions).Error; err != nil {
+			return err
+		}
+		if children > 0 || questions > 0 {
+			return ErrTaxonomyNodeInUse
+		}
+
+		// Deleted questions still linked to the node are unlinked by the
+		// foreign key and keep their titles
+		return tx.Delete(&node).Error
+	})
+}
+
+// Merge moves the children and questions of a node into another node of the
+// same level and deletes it. Children titled like a child of the target are
+// merged into that child in turn.
+func (r *taxonomyRepo) Merge(ctx context.Context, id, into uuid.UUID) (*models.TaxonomyNode, error) {
+	var target models.TaxonomyNode
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var source models.TaxonomyNode
+		if err := tx.First(&source, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&target, "id = ?", into).Error; err != nil {
+			return err
+		}
+		if source.ID == target.ID || source.Level != target.Level {
+			return ErrTaxonomyMergeLevel
+		}
+
+		if err := mergeTaxonomyNode(tx, &source, &target); err != nil {
+			return err
+		}
+		return syncTaxonomyQuestions(tx, target.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// mergeTaxonomyNode moves the children and questions of source to target and
+// deletes source
+func mergeTaxonomyNode(tx *gorm.DB, source, target *models.TaxonomyNode) error {
+	var children []*models.TaxonomyNode
+	if err := tx.Where("parent_id = ?", source.ID).Find(&children).Error; err != nil {
+		return err
+	}
+	for _, child := range children {
+		var twin models.TaxonomyNode
+		err := tx.Where("parent_id = ? AND level = ? AND normalized_title = ?", target.ID, child.Level, child.NormalizedTitle).
+			Take(&twin).Error
+		switch {
+		case err == nil:
+			if err := mergeTaxonomyNode(tx, child, &twin); err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Model(child).Update("parent_id", target.ID).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+
+	// Soft deleted questions move too, so they are restored into the target
+	err := tx.Exec("UPDATE questions SET taxonomy_node_id = ? WHERE taxonomy_node_id = ?", target.ID, source.ID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Delete(source).Error
+}
+
+// validateTaxonomyNode cleans the title of a node and checks its level
+// against its parent and its title against its siblings
+func validateTaxonomyNode(tx *gorm.DB, node *models.TaxonomyNode) error {
+	if node.Level.Depth() < 0 {
+		return ErrInvalidTaxonomyLevel
+	}
+	node.Title = models.CleanTaxonomyTitle(node.Title)
+	node.NormalizedTitle = models.NormalizeTaxonomyTitle(node.Title)
+	if node.Title == "" {
+		return ErrTaxonomyTitleRequired
+	}
+
+	siblings := tx.Model(&models.TaxonomyNode{}).
+		Where("level = ? AND normalized_title = ?", node.Level, node.NormalizedTitle)
+	if node.ParentID != nil {
+		var parent models.TaxonomyNode
+		if err := tx.First(&parent, "id = ?", *node.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTaxonomyParentNotFound
+			}
+			return err
+		}
+		if parent.Level.Depth() >= node.Level.Depth() {
+			return ErrTaxonomyLevelOrder
+		}
+		siblings = siblings.Where("parent_id = ?", *node.ParentID)
+	} else {
+		siblings = siblings.Where("parent_id IS NULL")
+	}
+	if node.ID != uuid.Nil {
+		siblings = siblings.Where("id <> ?", node.ID)
+	}
+
+	var taken int64
+	if err := siblings.Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrTaxonomyNodeExists
+	}
+	return nil
+}
+
+// taxonomySubtree returns a subquery selecting the IDs of a node and the
+// nodes below it
+func taxonomySubtree(db *gorm.DB, id uuid.UUID) *gorm.DB {
+	return db.Raw(`
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM taxonomy_nodes WHERE id = ?
+			UNION ALL
+			SELECT n.id FROM taxonomy_nodes n JOIN subtree s ON n.parent_id = s.id
+		)
+		SELECT id FROM subtree`, id)
+}
+
+// syncTaxonomyQuestions copies the paths of a node and the nodes below it
+// into the readable taxonomy columns of their questions
+func syncTaxonomyQuestions(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Exec(`
+		UPDATE questions q
+		SET (subject_title, category, theme, subtheme) = (
+			SELECT t.subject, t.category, t.theme, t.subtheme FROM taxonomy_path_titles(q.taxonomy_node_id) AS t
+		)
+		WHERE q.taxonomy_node_id IN (?)`, taxonomySubtree(tx, id)).Error
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// testRepo implements TestRepo using GORM
+type testRepo struct {
+	db *gorm.DB
+}
+
+// NewTestRepo creates a new GORM test repository
+func NewTestRepo(db *gorm.DB) TestRepo {
+	return &testRepo{db: db}
+}
+
+// Page returns a page of the tests matching the filter
+func (r *testRepo) Page(ctx context.Context, filter models.TestFilter, sort *models.TestSort, args pagination.Args) (*pagination.Connection[*models.Test], error) {
+	order, err := testOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.Test{})
+	if filter.ProductID != nil {
+		db = db.Where("tests.product_id = ?", *filter.ProductID)
+	}
+	if filter.Title != nil {
+		db = db.Where("tests.title ILIKE ?", containsPattern(*filter.Title))
+	}
+	if filter.Grade != nil {
+		db = db.Where("tests.grade = ?", *filter.Grade)
+	}
+	if filter.IsRequired != nil {
+		db = db.Where("tests.is_required = ?", *filter.IsRequired)
+	}
+	return pagination.Paginate(db, args, order)
+}
+
+// testOrder returns the order of a test sort
+func testOrder(sort *models.TestSort) (pagination.Order[*models.Test], error) {
+	order := pagination.Order[*models.Test]{
+		IDColumn: "tests.id",
+		ID:       func(t *models.Test) uuid.UUID { return t.ID },
+	}
+	field, def := models.TestSortDateCreated, pagination.Asc
+	var dir *models.SortDirection
+	if sort != nil {
+		field, dir = sort.Field, sort.Direction
+	}
+	switch field {
+	case models.TestSortTitle:
+		order.Column = "COALESCE(tests.title, '')"
+		order.Key = func(t *models.Test) interface{} { return t.Title }
+	case models.TestSortDateCreated:
+		order.Column = "COALESCE(tests.date_created, " + zeroTime + ")"
+		order.Key = func(t *models.Test) interface{} { return t.DateCreated }
+	case models.TestSortGrade:
+		order.Column = "COALESCE(tests.grade, 0)"
+		order.Key = func(t *models.Test) interface{} { return intKey(t.Grade) }
+	default:
+		return order, fmt.Errorf("%w: field %q", ErrInvalidSort, field)
+	}
+
+	var err error
+	order.Name = string(field)
+	order.Direction, err = sortDirection(dir, def)
+	return order, err
+}
+
+// ListByProduct returns the tests of a product
+func (r *testRepo) ListByProduct(ctx context.Context, productID uuid.UUID) ([]*models.Test, error) {
+	var tests []*models.Test
+	if err := r.db.WithContext(ctx).Where("product_id = ?", productID).Order("date_created").Find(&tests).Error; err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// Get returns a test by ID
+func (r *testRepo) Get(ctx context.Context, id uuid.UUID) (*models.Test, error) {
+	var test models.Test
+	if err := r.db.WithContext(ctx).First(&test, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &test, nil
+}
+
+// GetMany returns the tests with the given IDs
+func (r *testRepo) GetMany(ctx context.Context, ids []uuid.UUID) ([]*models.Test, error) {
+	var tests []*models.Test
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&tests).Error; err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// Create inserts a new test
+func (r *testRepo) Create(ctx context.Context, test *models.Test) error {
+	return r.db.WithContext(ctx).Create(test).Error
+}
+
+// Update saves all fields of an existing test
+func (r *testRepo) Update(ctx context.Context, test *models.Test) error {
+	return r.db.WithContext(ctx).Save(test).Error
+}
+
+// Restore undeletes a soft deleted test and the content deleted with it
+func (r *testRepo) Restore(ctx context.Context, id uuid.UUID) (*models.Test, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return restoreSubtree(tx, models.ContentTest, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var test models.Test
+	if err := r.db.WithContext(ctx).First(&test, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &test, nil
+}
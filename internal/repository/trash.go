@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"gorm.io/gorm"
+)
+
+// trashRepo implements TrashRepo using GORM
+type trashRepo struct {
+	db *gorm.DB
+}
+
+// NewTrashRepo creates a new GORM trash repository
+func NewTrashRepo(db *gorm.DB) TrashRepo {
+	return &trashRepo{db: db}
+}
+
+// List returns all soft deleted products, tests, questions and options
+func (r *trashRepo) List(ctx context.Context) (*models.Trash, error) {
+	db := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Session(&gorm.Session{})
+
+	var trash models.Trash
+	if err := db.Find(&trash.Products).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Find(&trash.Tests).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Find(&trash.Questions).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Find(&trash.Options).Error; err != nil {
+		return nil, err
+	}
+	return &trash, nil
+}
+
+// Purge permanently removes content soft deleted before the cutoff, either
+// directly or through a deleted parent. Rows still referenced by an attempt,
+// and parents that still have children, are kept.
+func (r *trashRepo) Purge(ctx context.Context, deletedBefore time.Time) (*models.PurgeResult, error) {
+	var result models.PurgeResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Options of deleted questions, tests or products that were never
+		// selected, of questions that were never answered. The options of an
+		// answered question, selected or not, are needed to show and regrade
+		// the answer.
+		res := tx.Exec(`
+			DELETE FROM options o
+			WHERE NOT EXISTS (SELECT 1 FROM completed_question_selected_options s WHERE s.option_id = o.id)
+			  AND NOT EXISTS (SELECT 1 FROM completed_questions cq WHERE cq.question_id = o.question_id)
+			  AND (o.deleted_at < @cutoff OR EXISTS (
+				SELECT 1 FROM questions q
+				JOIN tests t ON t.id = q.test_id
+				LEFT JOIN products p ON p.id = t.product_id
+				WHERE q.id = o.question_id
+				  AND (q.deleted_at < @cutoff OR t.deleted_at < @cutoff OR p.deleted_at < @cutoff)))`,
+			map[string]interface{}{"cutoff": deletedBefore})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Options = res.RowsAffected
+
+		// Questions that were never answered and have no options left
+		res = tx.Exec(`
+			DELETE FROM questions q
+			WHERE NOT EXISTS (SELECT 1 FROM completed_questions cq WHERE cq.question_id = q.id)
+			  AND NOT EXISTS (SELECT 1 FROM options o WHERE o.question_id = q.id)
+			  AND (q.deleted_at < @cutoff OR EXISTS (
+				SELECT 1 FROM tests t
+				LEFT JOIN products p ON p.id = t.product_id
+				WHERE t.id = q.test_id
+				  AND (t.deleted_at < @cutoff OR p.deleted_at < @cutoff)))`,
+			map[string]interface{}{"cutoff": deletedBefore})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Questions = res.RowsAffected
+
+		// Tests that were never taken and have no questions left
+		res = tx.Exec(`
+			DELETE FROM tests t
+			WHERE NOT EXISTS (SELECT 1 FROM completed_test_tests ctt WHERE ctt.test_id = t.id)
+			  AND NOT EXISTS (SELECT 1 FROM completed_questions cq WHERE cq.test_id = t.id)
+			  AND NOT EXISTS (SELECT 1 FROM questions q WHERE q.test_id = t.id)
+			  AND (t.deleted_at < @cutoff OR EXISTS (
+				SELECT 1 FROM products p WHERE p.id = t.product_id AND p.deleted_at < @cutoff))`,
+			map[string]interface{}{"cutoff": deletedBefore})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Tests = res.RowsAffected
+
+		// Products that were never taken and have no tests left
+		res = tx.Exec(`
+			DELETE FROM products p
+			WHERE p.deleted_at < @cutoff
+			  AND NOT EXISTS (SELECT 1 FROM completed_tests ct WHERE ct.product_id = p.id)
+			  AND NOT EXISTS (SELECT 1 FROM tests t WHERE t.product_id = p.id)`,
+			map[string]interface{}{"cutoff": deletedBefore})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Products = res.RowsAffected
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/pagination"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// userRepo implements UserRepo using GORM
+type userRepo struct {
+	db *gorm.DB
+}
+
+// NewUserRepo creates a new GORM user repository
+func NewUserRepo(db *gorm.DB) UserRepo {
+	return &userRepo{db: db}
+}
+
+// Page returns a page of the users matching the filter
+func (r *userRepo) Page(ctx context.Context, filter models.UserFilter, sort *models.UserSort, args pagination.Args) (*pagination.Connection[*models.User], error) {
+	order, err := userOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.WithContext(ctx).Model(&models.User{})
+	if filter.Query != nil {
+		pattern := containsPattern(*filter.Query)
+		db = db.Where("users.username ILIKE ? OR users.email ILIKE ?", pattern, pattern)
+	}
+	if filter.Role != nil {
+		db = db.Where("users.role = ?", *filter.Role)
+	}
+	return pagination.Paginate(db, args, order)
+}
+
+// userOrder returns the order of a user sort
+func userOrder(sort *models.UserSort) (pagination.Order[*models.User], error) {
+	order := pagination.Order[*models.User]{
+		IDColumn: "users.id",
+		ID:       func(u *models.User) uuid.UUID { return u.ID },
+	}
+	field := models.UserSortUsername
+	var dir *models.SortDirection
+	if sort != nil {
+		field, dir = sort.Field, sort.Direction
+	}
+	switch field {
+	case models.UserSortUsername:
+		order.Column = "COALESCE(users.username, '')"
+		order.Key = func(u *models.User) interface{} { return u.Username }
+	case models.UserSortEmail:
+		order.Column = "COALESCE(users.email, '')"
+		order.Key = func(u *models.User) interface{} { return u.Email }
+	default:
+		return order, fmt.Errorf("%w: field %q", ErrInvalidSort, field)
+	}
+
+	var err error
+	order.Name = string(field)
+	order.Direction, err = sortDirection(dir, pagination.Asc)
+	return order, err
+}
+
+// Get returns a user by ID
+func (r *userRepo) Get(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByUsername returns a user by username
+func (r *userRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create inserts a new user
+func (r *userRepo) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+// CountByRole returns the number of users with the given role
+func (r *userRepo) CountByRole(ctx context.Context, role models.UserRole) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+// CountByUsername returns the number of users with the given username
+func (r *userRepo) CountByUsername(ctx context.Context, username string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	return count, err
+}
+
+// ListIDsByRole returns the IDs of the users with any of the given roles
+func (r *userRepo) ListIDsByRole(ctx context.Context, roles ...models.UserRole) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("role IN ?", roles).Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// UpdateRole changes the role of a user
+func (r *userRepo) UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error {
+	res := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
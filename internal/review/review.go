@@ -0,0 +1,95 @@
+// Package review holds the rules of the editorial review of questions: which
+// status each review action moves a question to and who may take it. Authors
+// write and submit questions; reviewers approve, reject and retire them, but
+// never their own.
+package review
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Review errors
+var (
+	ErrInvalidAction   = errors.New("unknown review action")
+	ErrNotAllowed      = errors.New("not allowed to take this review action")
+	ErrOwnQuestion     = errors.New("authors cannot approve or reject their own questions")
+	ErrCommentRequired = errors.New("a comment is required for this review action")
+	ErrTransition      = errors.New("review action not possible in the current status")
+)
+
+// transition is the status an action is taken from and the status it leads to
+type transition struct {
+	from, to models.QuestionStatus
+}
+
+// transitions maps the actions changing the status of a question. Comments
+// keep the status and are not listed.
+var transitions = map[models.ReviewAction]transition{
+	models.ReviewSubmit:  {from: models.QuestionDraft, to: models.QuestionInReview},
+	models.ReviewApprove: {from: models.QuestionInReview, to: models.QuestionApproved},
+	models.ReviewReject:  {from: models.QuestionInReview, to: models.QuestionDraft},
+	models.ReviewRetire:  {from: models.QuestionApproved, to: models.QuestionRetired},
+	models.ReviewReopen:  {from: models.QuestionRetired, to: models.QuestionDraft},
+	models.ReviewRevise:  {from: models.QuestionApproved, to: models.QuestionInReview},
+}
+
+// Next returns the status a question in the given status moves to when the
+// action is taken
+func Next(action models.ReviewAction, from models.QuestionStatus) (models.QuestionStatus, error) {
+	if action == models.ReviewComment {
+		return from, nil
+	}
+	t, ok := transitions[action]
+	if !ok {
+		return from, ErrInvalidAction
+	}
+	if t.from != from {
+		return from, fmt.Errorf("%w: cannot %s a question that is %s", ErrTransition, action, from)
+	}
+	return t.to, nil
+}
+
+// IsReviewer reports whether a user may review questions
+func IsReviewer(user *models.User) bool {
+	return user.Role == models.RoleReviewer || user.Role == models.RoleAdmin
+}
+
+// IsAuthor reports whether a user wrote a question
+func IsAuthor(user *models.User, q *models.Question) bool {
+	return q.AuthorID != nil && *q.AuthorID == user.ID
+}
+
+// Authorize checks that a user may take the action on a question. A comment
+// is required to reject a question, so its author knows what to change.
+// Revisions follow from edits and are never taken directly.
+func Authorize(action models.ReviewAction, user *models.User, q *models.Question, comment *string) error {
+	author, reviewer, admin := IsAuthor(user, q), IsReviewer(user), user.Role == models.RoleAdmin
+
+	var allowed bool
+	switch action {
+	case models.ReviewSubmit, models.ReviewReopen:
+		allowed = author || admin
+	case models.ReviewApprove, models.ReviewReject:
+		if reviewer && author {
+			return ErrOwnQuestion
+		}
+		allowed = reviewer
+	case models.ReviewRetire:
+		allowed = reviewer
+	case models.ReviewComment:
+		allowed = author || reviewer
+	default:
+		return ErrInvalidAction
+	}
+	if !allowed {
+		return ErrNotAllowed
+	}
+
+	if action == models.ReviewReject && (comment == nil || *comment == "") {
+		return ErrCommentRequired
+	}
+	return nil
+}
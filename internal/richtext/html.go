@@ -0,0 +1,200 @@
+package richtext
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements are the elements kept in HTML content with the attributes
+// kept on them. Other elements are replaced by their content.
+var allowedElements = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.B: nil, atom.Strong: nil, atom.I: nil, atom.Em: nil, atom.U: nil, atom.S: nil,
+	atom.Sub: nil, atom.Sup: nil, atom.Small: nil, atom.Mark: nil,
+	atom.Code: nil, atom.Pre: nil, atom.Blockquote: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Ul: nil, atom.Ol: {"start"}, atom.Li: nil,
+	atom.Table: nil, atom.Caption: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil,
+	atom.Tr: nil, atom.Th: {"colspan", "rowspan"}, atom.Td: {"colspan", "rowspan"},
+	atom.A:   {"href", "title"},
+	atom.Img: {"src", "alt", "title", "width", "height"},
+}
+
+// droppedElements are the elements removed from HTML content together with
+// their content
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Frame: true,
+	atom.Frameset: true, atom.Object: true, atom.Embed: true, atom.Applet: true,
+	atom.Noscript: true, atom.Template: true, atom.Form: true, atom.Input: true,
+	atom.Button: true, atom.Select: true, atom.Textarea: true, atom.Link: true,
+	atom.Meta: true, atom.Base: true, atom.Title: true, atom.Head: true,
+}
+
+// numericAttrs are the attributes holding a number
+var numericAttrs = map[string]bool{"start": true, "colspan": true, "rowspan": true, "width": true, "height": true}
+
+// urlSchemes are the schemes allowed in links; relative URLs are allowed too
+var urlSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// sanitizeHTML reduces HTML content to its safe subset and checks its math
+func sanitizeHTML(text string) (string, error) {
+	root, err := parseHTML(text)
+	if err != nil {
+		return "", err
+	}
+	cleanHTML(root)
+	if err := walkText(root, func(n *html.Node) error {
+		segments, err := splitMath(n.Data, false)
+		if err != nil {
+			return err
+		}
+		return checkMath(segments)
+	}); err != nil {
+		return "", err
+	}
+	return renderChildren(root)
+}
+
+// renderHTML replaces the math of HTML content with MathML
+func renderHTML(text string) (string, error) {
+	root, err := parseHTML(text)
+	if err != nil {
+		return "", err
+	}
+	err = walkText(root, func(n *html.Node) error {
+		segments, err := splitMath(n.Data, false)
+		if err != nil {
+			return err
+		}
+		for _, s := range segments {
+			node := &html.Node{Type: html.TextNode, Data: strings.ReplaceAll(s.text, `\$`, "$")}
+			if s.math {
+				mathML, err := ToMathML(s.text, s.display)
+				if err != nil {
+					return err
+				}
+				node = &html.Node{Type: html.RawNode, Data: mathML}
+			}
+			n.Parent.InsertBefore(node, n)
+		}
+		n.Parent.RemoveChild(n)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return renderChildren(root)
+}
+
+// parseHTML parses HTML content as the children of a div
+func parseHTML(text string) (*html.Node, error) {
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(text), root)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
+
+// renderChildren writes the children of a node as HTML
+func renderChildren(root *html.Node) (string, error) {
+	var b strings.Builder
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// cleanHTML removes what is not in the safe subset from below a node
+func cleanHTML(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.TextNode:
+		case c.Type != html.ElementNode || c.Namespace != "" || droppedElements[c.DataAtom]:
+			// Comments, SVG and MathML go too
+			n.RemoveChild(c)
+		default:
+			cleanHTML(c)
+			if attrs, ok := allowedElements[c.DataAtom]; ok {
+				c.Attr = cleanAttrs(c.Attr, attrs)
+				break
+			}
+			for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+				c.RemoveChild(gc)
+				n.InsertBefore(gc, c)
+			}
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// cleanAttrs keeps the allowed attributes with safe values
+func cleanAttrs(attrs []html.Attribute, allowed []string) []html.Attribute {
+	var kept []html.Attribute
+	for _, a := range attrs {
+		if a.Namespace != "" || !contains(allowed, a.Key) {
+			continue
+		}
+		switch {
+		case a.Key == "href" || a.Key == "src":
+			if !safeURL(a.Val) {
+				continue
+			}
+		case numericAttrs[a.Key]:
+			if a.Val == "" || strings.Trim(a.Val, "0123456789") != "" {
+				continue
+			}
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// contains reports whether a list holds a value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// walkText calls fn with the text nodes below a node that may hold math,
+// which are those outside code
+func walkText(n *html.Node, fn func(*html.Node) error) error {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.TextNode:
+			if err := fn(c); err != nil {
+				return err
+			}
+		case c.Type == html.ElementNode && c.DataAtom != atom.Code && c.DataAtom != atom.Pre:
+			if err := walkText(c, fn); err != nil {
+				return err
+			}
+		}
+		c = next
+	}
+	return nil
+}
+
+// safeURL reports whether a URL is relative or a web or mail address
+func safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || urlSchemes[strings.ToLower(u.Scheme)]
+}
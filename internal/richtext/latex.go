@@ -0,0 +1,473 @@
+package richtext
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDepth bounds the nesting of groups in a formula
+const maxDepth = 50
+
+// symbols are the commands written as operators
+var symbols = map[string]string{
+	"cdot": "⋅", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"le": "≤", "leq": "≤", "ge": "≥", "geq": "≥", "ne": "≠", "neq": "≠",
+	"lt": "&lt;", "gt": "&gt;", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "iff": "⇔",
+	"implies": "⇒", "uparrow": "↑", "downarrow": "↓", "mapsto": "↦",
+	"rightleftharpoons": "⇌", "longrightarrow": "⟶",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆",
+	"supset": "⊃", "supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖",
+	"forall": "∀", "exists": "∃", "neg": "¬", "land": "∧", "lor": "∨",
+	"wedge": "∧", "vee": "∨", "angle": "∠", "perp": "⊥", "parallel": "∥",
+	"mid": "∣", "circ": "∘", "bullet": "∙", "oplus": "⊕", "otimes": "⊗",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "dots": "…", "prime": "′",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "vert": "|", "Vert": "‖",
+	"{": "{", "}": "}", "|": "‖", "%": "%", "$": "$", "#": "#", "&": "&amp;", "_": "_",
+}
+
+// identifiers are the commands written as identifiers
+var identifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "sigma": "σ", "tau": "τ",
+	"upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ",
+	"omega": "ω", "infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "hbar": "ℏ", "ell": "ℓ", "triangle": "△", "degree": "°",
+}
+
+// uprightIdentifiers are the identifiers written upright, such as capital
+// Greek letters
+var uprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+}
+
+// functions are the commands written as upright function names
+var functions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true,
+	"tanh": true, "log": true, "ln": true, "lg": true, "exp": true, "det": true,
+	"gcd": true, "deg": true, "arg": true, "dim": true, "ker": true,
+}
+
+// largeOperators are the commands written as operators with limits above and
+// below them
+var largeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "lim": "lim", "max": "max", "min": "min",
+	"sup": "sup", "inf": "inf", "bigcup": "⋃", "bigcap": "⋂",
+	"int": "∫", "iint": "∬", "oint": "∮",
+}
+
+// accents are the commands putting a mark over or under their argument
+var accents = map[string]string{
+	"vec": "→", "overrightarrow": "→", "hat": "^", "widehat": "^", "bar": "‾",
+	"overline": "‾", "dot": "˙", "ddot": "¨", "tilde": "~", "widetilde": "~",
+	"underline": "_",
+}
+
+// spaces are the spacing commands by their width
+var spaces = map[string]string{
+	",": "0.167em", ":": "0.222em", ";": "0.278em", "!": "-0.167em", " ": "0.25em",
+	"quad": "1em", "qquad": "2em",
+}
+
+// variants are the commands setting the math variant of their argument
+var variants = map[string]string{
+	"mathrm": "normal", "mathbf": "bold", "mathit": "italic", "mathbb": "double-struck",
+	"mathcal": "script", "mathsf": "sans-serif", "boldsymbol": "bold-italic",
+}
+
+// ToMathML converts a LaTeX formula to MathML, as a block when display is
+// set. Formulas use the commands commonly found in tests; others are errors.
+func ToMathML(tex string, display bool) (string, error) {
+	p := &texParser{src: tex}
+	body, err := p.parseRow("")
+	if err != nil {
+		return "", err
+	}
+	if p.peek() != 0 {
+		return "", p.errorf("unexpected }")
+	}
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+	return fmt.Sprintf(`<math xmlns="http://www.w3.org/1998/Math/MathML" display="%s"><semantics><mrow>%s</mrow><annotation encoding="application/x-tex">%s</annotation></semantics></math>`,
+		mode, body, html.EscapeString(tex)), nil
+}
+
+// texParser is a recursive descent parser of a LaTeX formula writing MathML
+type texParser struct {
+	src     string
+	pos     int
+	depth   int
+	variant string
+}
+
+// errorf returns a LaTeX error at the current position
+func (p *texParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrLatex, fmt.Sprintf(format, args...), p.pos)
+}
+
+// skipSpace skips the whitespace before the next token
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek returns the next character without consuming it
+func (p *texParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// parseRow parses atoms up to the end of the formula, a closing brace or the
+// given command, which is left unconsumed
+func (p *texParser) parseRow(until string) (string, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return "", p.errorf("formula nested too deeply")
+	}
+
+	var b strings.Builder
+	for {
+		c := p.peek()
+		if c == 0 {
+			if until != "" {
+				return "", p.errorf(`missing \%s`, until)
+			}
+			return b.String(), nil
+		}
+		if c == '}' {
+			if until != "" {
+				return "", p.errorf(`missing \%s`, until)
+			}
+			return b.String(), nil
+		}
+		if until != "" && strings.HasPrefix(p.src[p.pos:], `\`+until) && !p.letterAt(p.pos+1+len(until)) {
+			return b.String(), nil
+		}
+		atom, err := p.parseScripted()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(atom)
+	}
+}
+
+// letterAt reports whether the character at i is an ASCII letter
+func (p *texParser) letterAt(i int) bool {
+	return i < len(p.src) && isLetter(p.src[i])
+}
+
+// isLetter reports whether c is an ASCII letter
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseScripted parses an atom with its subscript and superscript
+func (p *texParser) parseScripted() (string, error) {
+	base, limits, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+
+	var sub, sup string
+	for {
+		c := p.peek()
+		if c != '_' && c != '^' {
+			break
+		}
+		p.pos++
+		if (c == '_' && sub != "") || (c == '^' && sup != "") {
+			return "", p.errorf("double %c", c)
+		}
+		script, _, err := p.parseAtom()
+		if err != nil {
+			return "", err
+		}
+		if script == "" {
+			return "", p.errorf("missing script after %c", c)
+		}
+		if c == '_' {
+			sub = script
+		} else {
+			sup = script
+		}
+	}
+	for p.peek() == '\'' {
+		p.pos++
+		sup += "<mo>′</mo>"
+	}
+
+	if base == "" {
+		base = "<mrow></mrow>"
+	}
+	under, over, both := "msub", "msup", "msubsup"
+	if limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return fmt.Sprintf("<%s>%s<mrow>%s</mrow><mrow>%s</mrow></%s>", both, base, sub, sup, both), nil
+	case sub != "":
+		return fmt.Sprintf("<%s>%s<mrow>%s</mrow></%s>", under, base, sub, under), nil
+	case sup != "":
+		return fmt.Sprintf("<%s>%s<mrow>%s</mrow></%s>", over, base, sup, over), nil
+	}
+	return base, nil
+}
+
+// parseAtom parses a single character, group or command. limits reports
+// whether the scripts of the atom go above and below it.
+func (p *texParser) parseAtom() (atom string, limits bool, err error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return "", false, p.errorf("unexpected end of formula")
+	case c == '{':
+		p.pos++
+		row, err := p.parseRow("")
+		if err != nil {
+			return "", false, err
+		}
+		if p.peek() != '}' {
+			return "", false, p.errorf("missing }")
+		}
+		p.pos++
+		return "<mrow>" + row + "</mrow>", false, nil
+	case c == '}':
+		return "", false, p.errorf("unexpected }")
+	case c == '\\':
+		return p.parseCommand()
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return "<mn>" + p.src[start:p.pos] + "</mn>", false, nil
+	case c == '&':
+		return "", false, p.errorf("alignment is not supported")
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if unicode.IsLetter(r) {
+		return p.identifier(string(r)), false, nil
+	}
+	return "<mo>" + html.EscapeString(string(r)) + "</mo>", false, nil
+}
+
+// identifier writes an identifier in the current variant
+func (p *texParser) identifier(text string) string {
+	if p.variant != "" {
+		return fmt.Sprintf(`<mi mathvariant="%s">%s</mi>`, p.variant, text)
+	}
+	return "<mi>" + text + "</mi>"
+}
+
+// parseCommand parses a command and its arguments
+func (p *texParser) parseCommand() (atom string, limits bool, err error) {
+	p.pos++
+	if p.pos >= len(p.src) {
+		return "", false, p.errorf(`lone \`)
+	}
+	start := p.pos
+	if isLetter(p.src[p.pos]) {
+		for p.pos < len(p.src) && isLetter(p.src[p.pos]) {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+
+	if s, ok := symbols[name]; ok {
+		return "<mo>" + s + "</mo>", false, nil
+	}
+	if s, ok := identifiers[name]; ok {
+		return p.identifier(s), false, nil
+	}
+	if s, ok := uprightIdentifiers[name]; ok {
+		return `<mi mathvariant="normal">` + s + "</mi>", false, nil
+	}
+	if functions[name] {
+		return "<mi>" + name + "</mi>", false, nil
+	}
+	if s, ok := largeOperators[name]; ok {
+		// Integrals keep their limits at the side
+		return "<mo>" + s + "</mo>", !strings.HasSuffix(name, "int"), nil
+	}
+	if width, ok := spaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`, false, nil
+	}
+	if mark, ok := accents[name]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if name == "underline" {
+			return `<munder accentunder="true">` + arg + `<mo stretchy="true">` + mark + "</mo></munder>", false, nil
+		}
+		return `<mover accent="true">` + arg + "<mo>" + mark + "</mo></mover>", false, nil
+	}
+	if variant, ok := variants[name]; ok {
+		outer := p.variant
+		p.variant = variant
+		arg, err := p.parseArg()
+		p.variant = outer
+		return arg, false, err
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mfrac>" + num + den + "</mfrac>", false, nil
+	case "binom":
+		top, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		bottom, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + "</mfrac><mo>)</mo></mrow>", false, nil
+	case "sqrt":
+		var index string
+		if p.peek() == '[' {
+			p.pos++
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 {
+				return "", false, p.errorf("missing ]")
+			}
+			inner := &texParser{src: p.src[p.pos : p.pos+end], depth: p.depth, variant: p.variant}
+			row, err := inner.parseRow("")
+			if err != nil {
+				return "", false, err
+			}
+			index = "<mrow>" + row + "</mrow>"
+			p.pos += end + 1
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", false, nil
+		}
+		return "<msqrt>" + arg + "</msqrt>", false, nil
+	case "text", "textrm", "mbox", "textit", "textbf":
+		text, err := p.rawArg()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mtext>" + html.EscapeString(text) + "</mtext>", false, nil
+	case "operatorname":
+		text, err := p.rawArg()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mi>" + html.EscapeString(text) + "</mi>", false, nil
+	case "left":
+		open, err := p.delimiter()
+		if err != nil {
+			return "", false, err
+		}
+		row, err := p.parseRow("right")
+		if err != nil {
+			return "", false, err
+		}
+		p.pos += len(`\right`)
+		closing, err := p.delimiter()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mrow>" + open + row + closing + "</mrow>", false, nil
+	case "right":
+		return "", false, p.errorf(`\right without \left`)
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return "", false, nil
+	}
+	return "", false, p.errorf(`unknown command \%s`, name)
+}
+
+// parseArg parses the argument of a command, a group or a single atom
+func (p *texParser) parseArg() (string, error) {
+	if p.peek() == 0 {
+		return "", p.errorf("missing argument")
+	}
+	atom, _, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+	return "<mrow>" + atom + "</mrow>", nil
+}
+
+// rawArg reads the text of a braced argument as written
+func (p *texParser) rawArg() (string, error) {
+	if p.peek() != '{' {
+		return "", p.errorf("missing {")
+	}
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := p.src[p.pos+1 : i]
+				p.pos = i + 1
+				return text, nil
+			}
+		}
+	}
+	return "", p.errorf("missing }")
+}
+
+// delimiter parses the delimiter after \left or \right; a period is no
+// delimiter
+func (p *texParser) delimiter() (string, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return "", p.errorf("missing delimiter")
+	case c == '.':
+		p.pos++
+		return "", nil
+	case c == '\\':
+		atom, _, err := p.parseCommand()
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(atom, "<mo>") {
+			return "", p.errorf("invalid delimiter")
+		}
+		return `<mo stretchy="true">` + strings.TrimPrefix(atom, "<mo>"), nil
+	case strings.IndexByte("()[]|/<>", c) >= 0:
+		p.pos++
+		return `<mo stretchy="true">` + html.EscapeString(string(c)) + "</mo>", nil
+	}
+	return "", p.errorf("invalid delimiter %q", c)
+}
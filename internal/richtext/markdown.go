@@ -0,0 +1,145 @@
+package richtext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlTag matches raw HTML in Markdown: tags, comments, declarations
+	// and processing instructions. Autolinks such as <https://...> are not
+	// tags.
+	htmlTag = regexp.MustCompile(`(?s)<!--.*?-->|<![A-Za-z\[][^>]*>|<\?.*?\?>|</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>`)
+	// inlineLink matches the destination of an inline link or image
+	inlineLink = regexp.MustCompile(`\]\(\s*(<[^>\n]*>|[^\s)]*)`)
+	// referenceLink matches the destination of a link reference definition
+	referenceLink = regexp.MustCompile(`(?m)^ {0,3}\[[^\]\n]+\]:\s*(<[^>\n]*>|\S+)`)
+	// autolink matches a URI autolink
+	autolink = regexp.MustCompile(`<([A-Za-z][A-Za-z0-9+.\-]{1,31}:[^\s<>]*)>`)
+	// angleBrackets are written as commands in Markdown math, so no
+	// formula reads as raw HTML to a renderer without math support
+	angleBrackets = strings.NewReplacer("<", `\lt `, ">", `\gt `)
+)
+
+// sanitizeMarkdown removes raw HTML outside code and math from Markdown and
+// replaces unsafe link destinations
+func sanitizeMarkdown(text string) (string, error) {
+	segments, err := splitMath(text, true)
+	if err != nil {
+		return "", err
+	}
+	if err := checkMath(segments); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, s := range segments {
+		switch {
+		case s.math:
+			b.WriteString(angleBrackets.Replace(s.raw))
+		case s.code:
+			b.WriteString(s.text)
+		default:
+			b.WriteString(sanitizeMarkdownText(s.text))
+		}
+	}
+	return b.String(), nil
+}
+
+// sanitizeMarkdownText sanitizes Markdown text outside code and math. Raw
+// HTML is removed, and every < left over is escaped, so tags nested in or
+// split by others cannot come together once the inner ones are removed.
+func sanitizeMarkdownText(text string) string {
+	text = autolink.ReplaceAllStringFunc(text, func(m string) string {
+		if safeURL(markdownURL(m[1 : len(m)-1])) {
+			return m
+		}
+		return ""
+	})
+	text = htmlTag.ReplaceAllString(text, "")
+	for _, re := range []*regexp.Regexp{inlineLink, referenceLink} {
+		text = replaceGroup(re, text, func(dest string) string {
+			if safeURL(markdownURL(strings.Trim(dest, "<>"))) {
+				return dest
+			}
+			return "#"
+		})
+	}
+	return escapeAngles(text)
+}
+
+// escapeAngles escapes every < of Markdown text as &lt;, except those that
+// open a safe autolink or an angle bracketed link destination
+func escapeAngles(text string) string {
+	keep := make(map[int]bool)
+	for _, m := range autolink.FindAllStringSubmatchIndex(text, -1) {
+		if safeURL(markdownURL(text[m[2]:m[3]])) {
+			keep[m[0]] = true
+		}
+	}
+	for _, re := range []*regexp.Regexp{inlineLink, referenceLink} {
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			if dest := text[m[2]:m[3]]; strings.HasPrefix(dest, "<") && safeURL(markdownURL(strings.Trim(dest, "<>"))) {
+				keep[m[2]] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '<' && !keep[i] {
+			b.WriteString("&lt;")
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// replaceGroup replaces the first group of every match of re
+func replaceGroup(re *regexp.Regexp, text string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(text[last:m[2]])
+		b.WriteString(fn(text[m[2]:m[3]]))
+		last = m[3]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// markdownURL resolves the backslash escapes and entities of a Markdown link
+// destination as a Markdown renderer does
+func markdownURL(dest string) string {
+	var b strings.Builder
+	for i := 0; i < len(dest); i++ {
+		if dest[i] == '\\' && i+1 < len(dest) && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", dest[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(dest[i])
+	}
+	return html.UnescapeString(b.String())
+}
+
+// renderMarkdown replaces the math of Markdown with MathML
+func renderMarkdown(text string) (string, error) {
+	segments, err := splitMath(text, true)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, s := range segments {
+		if !s.math {
+			b.WriteString(s.text)
+			continue
+		}
+		mathML, err := ToMathML(s.text, s.display)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(mathML)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,58 @@
+package richtext
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// rawHTML matches anything a Markdown renderer could read as the start of
+// raw HTML, once autolinks are left out
+var rawHTML = regexp.MustCompile(`<[A-Za-z!?/]`)
+
+func TestSanitizeMarkdownRemovesNestedAndSplitTags(t *testing.T) {
+	for _, text := range []string{
+		"<scr<script>ipt>alert(1)</script>",
+		"<<b>img src=x onerror=alert(1)>",
+		"<<!-- -->script>alert(1)<</b>/script>",
+		"<img\nsrc=x onerror=alert(1)>",
+		"<img src=x onerror=alert(1)",
+		"<java<b>script:alert(1)>",
+		"<<https://example.com>script>alert(1)</script>",
+		"[x](<java<b>script:alert(1)>)",
+	} {
+		got, err := Sanitize(models.ContentMarkdown, text)
+		if err != nil {
+			t.Errorf("Sanitize(%q): %v", text, err)
+			continue
+		}
+		if rawHTML.MatchString(autolink.ReplaceAllString(got, "")) {
+			t.Errorf("Sanitize(%q) = %q, which holds raw HTML", text, got)
+		}
+		again, err := Sanitize(models.ContentMarkdown, got)
+		if err != nil || again != got {
+			t.Errorf("sanitizing %q again gave %q, %v", got, again, err)
+		}
+	}
+}
+
+func TestSanitizeMarkdownKeepsSafeContent(t *testing.T) {
+	for text, want := range map[string]string{
+		"a < b and b > c":                        "a &lt; b and b > c",
+		"see <https://example.com>":              "see <https://example.com>",
+		"[x](<https://example.com/a b>)":         "[x](<https://example.com/a b>)",
+		"[x]: <https://example.com>":             "[x]: <https://example.com>",
+		"$a < b$ and `<b>`":                      `$a \lt  b$ and ` + "`<b>`",
+		"**bold** <b>tag</b> <javascript:alert>": "**bold** tag ",
+	} {
+		got, err := Sanitize(models.ContentMarkdown, text)
+		if err != nil {
+			t.Errorf("Sanitize(%q): %v", text, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
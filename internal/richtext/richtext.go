@@ -0,0 +1,242 @@
+// Package richtext checks, sanitizes and renders the content of questions and
+// options. Besides plain text, content is Markdown without raw HTML or a safe
+// subset of HTML. Both may hold LaTeX math between $...$ or \(...\), and
+// $$...$$ or \[...\] for display math, which can be pre-rendered to MathML.
+package richtext
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+)
+
+// Content errors
+var (
+	ErrInvalidFormat = errors.New("content format must be PLAIN, MARKDOWN or HTML")
+	ErrUnclosedMath  = errors.New("math is not closed")
+	ErrLatex         = errors.New("invalid LaTeX")
+)
+
+// Sanitize checks content written in a format and returns it safe to store
+// and show: raw HTML is removed from Markdown, HTML is reduced to its safe
+// subset and links to other than web and mail addresses are dropped. The
+// math of the content has to be valid. Plain text is returned as written.
+func Sanitize(format models.ContentFormat, text string) (string, error) {
+	switch format {
+	case models.ContentPlain, "":
+		return text, nil
+	case models.ContentMarkdown:
+		return sanitizeMarkdown(text)
+	case models.ContentHTML:
+		return sanitizeHTML(text)
+	default:
+		return "", ErrInvalidFormat
+	}
+}
+
+// Render returns sanitized content with its math rendered to MathML, for
+// clients without a LaTeX renderer. Plain text is returned as written.
+func Render(format models.ContentFormat, text string) (string, error) {
+	switch format {
+	case models.ContentPlain, "":
+		return text, nil
+	case models.ContentMarkdown:
+		return renderMarkdown(text)
+	case models.ContentHTML:
+		return renderHTML(text)
+	default:
+		return "", ErrInvalidFormat
+	}
+}
+
+// SanitizeQuestion sanitizes the texts of a question and of its options in
+// the format of the question. Questions without a format are plain text.
+func SanitizeQuestion(q *models.Question) error {
+	if q.ContentFormat == "" {
+		q.ContentFormat = models.ContentPlain
+	}
+	if !q.ContentFormat.Valid() {
+		return ErrInvalidFormat
+	}
+	texts := []struct {
+		name string
+		text *string
+	}{{"text", q.Text}, {"text2", q.Text2}, {"text3", q.Text3}}
+	for _, t := range texts {
+		if t.text == nil {
+			continue
+		}
+		sanitized, err := Sanitize(q.ContentFormat, *t.text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		*t.text = sanitized
+	}
+	for i := range q.Options {
+		if err := SanitizeOption(q.ContentFormat, &q.Options[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SanitizeOption sanitizes the text and match text of an option in the
+// format of its question
+func SanitizeOption(format models.ContentFormat, o *models.Option) error {
+	sanitized, err := Sanitize(format, o.Text)
+	if err != nil {
+		return fmt.Errorf("option %q: %w", o.Text, err)
+	}
+	o.Text = sanitized
+	if o.MatchText != nil {
+		sanitized, err := Sanitize(format, *o.MatchText)
+		if err != nil {
+			return fmt.Errorf("match text of option %q: %w", o.Text, err)
+		}
+		o.MatchText = &sanitized
+	}
+	return nil
+}
+
+// segment is a part of content: text, code or a formula
+type segment struct {
+	// text is the formula without its delimiters, or the text or code
+	text string
+	// raw is the segment as written, with the delimiters of a formula
+	raw     string
+	math    bool
+	display bool
+	// code is a Markdown code span or block, which holds no math
+	code bool
+}
+
+// mathDelimiters are the delimiters of math other than single dollars, with
+// whether they hold display math
+var mathDelimiters = []struct {
+	open, close string
+	display     bool
+}{
+	{"$$", "$$", true},
+	{`\[`, `\]`, true},
+	{`\(`, `\)`, false},
+}
+
+// splitMath splits content into text and formulas, and Markdown code when
+// code is set. A single dollar opens math when it is followed by a non-space
+// and the next dollar follows a non-space without a digit after it, so
+// prices stay text.
+func splitMath(text string, code bool) ([]segment, error) {
+	var segments []segment
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			segments = append(segments, segment{text: text[start:end], raw: text[start:end]})
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '$':
+			i += 2
+			continue
+		case code && c == '`':
+			run := countRun(text[i:], '`')
+			end := strings.Index(text[i+run:], strings.Repeat("`", run))
+			if end < 0 {
+				i += run
+				continue
+			}
+			flush(i)
+			end += i + 2*run
+			segments = append(segments, segment{text: text[i:end], raw: text[i:end], code: true})
+			i, start = end, end
+			continue
+		}
+
+		matched := false
+		for _, d := range mathDelimiters {
+			if !strings.HasPrefix(text[i:], d.open) {
+				continue
+			}
+			end := strings.Index(text[i+len(d.open):], d.close)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrUnclosedMath, d.open)
+			}
+			flush(i)
+			inner := text[i+len(d.open) : i+len(d.open)+end]
+			next := i + len(d.open) + end + len(d.close)
+			segments = append(segments, segment{text: inner, raw: text[i:next], math: true, display: d.display})
+			i = next
+			start, matched = i, true
+			break
+		}
+		if matched {
+			continue
+		}
+
+		if c == '$' {
+			if end := closingDollar(text, i); end > 0 {
+				flush(i)
+				segments = append(segments, segment{text: text[i+1 : end], raw: text[i : end+1], math: true})
+				i = end + 1
+				start = i
+				continue
+			}
+		}
+		i++
+	}
+	flush(len(text))
+	return segments, nil
+}
+
+// countRun counts the leading characters c of s
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// closingDollar returns the index of the dollar closing the math opened by
+// the dollar at i, or -1 when it opens none
+func closingDollar(text string, i int) int {
+	if i+1 >= len(text) || isSpace(text[i+1]) {
+		return -1
+	}
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '$':
+			// Formulas hold no unescaped dollars, so a dollar that does not
+			// close the math leaves the opening one as text
+			if isSpace(text[j-1]) || j+1 < len(text) && text[j+1] >= '0' && text[j+1] <= '9' {
+				return -1
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// isSpace reports whether c is whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// checkMath checks the formulas of content
+func checkMath(segments []segment) error {
+	for _, s := range segments {
+		if !s.math {
+			continue
+		}
+		if _, err := ToMathML(s.text, s.display); err != nil {
+			return err
+		}
+	}
+	return nil
+}
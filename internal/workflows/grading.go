@@ -0,0 +1,229 @@
+package workflows
+
+import (
+	"math"
+	"strings"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+)
+
+// gradeAttempt scores a completed test loaded with its answers. Each answer
+// is checked against the question revision it was pinned to, unless
+// currentKey is set or no revision was pinned, in which case the current
+// answer key is used. The current key of a regrade leaves out deleted
+// options; unpinned answers keep them, as they were given with them. A
+// graded essay counts for the share of the rubric points it got, and as
+// correct with all of them; ungraded essays count for nothing.
+func gradeAttempt(completedTest *models.CompletedTest, currentKey bool) TestResult {
+	totalQuestions := len(completedTest.Questions)
+	correctAnswers := 0
+	credit := 0.0
+
+	for _, completedQuestion := range completedTest.Questions {
+		question := completedQuestion.Question
+		switch {
+		case currentKey:
+			question = liveOptions(question)
+		case completedQuestion.PinnedQuestion != nil:
+			question = completedQuestion.PinnedQuestion
+		}
+
+		// Skip if the question is nil
+		if question == nil {
+			continue
+		}
+
+		if question.QuestionType == models.QuestionEssay {
+			if grade := completedQuestion.EssayGrade; grade != nil {
+				credit += grade.Credit()
+				if grade.MaxPoints > 0 && grade.Points == grade.MaxPoints {
+					correctAnswers++
+				}
+			}
+			continue
+		}
+		if isCorrectAnswer(question, &completedQuestion) {
+			correctAnswers++
+			credit++
+		}
+	}
+
+	// Calculate the score as a percentage, rounded down
+	score := 0
+	if totalQuestions > 0 {
+		score = int(math.Floor(credit*100/float64(totalQuestions) + 1e-9))
+	}
+
+	return TestResult{
+		Score:          score,
+		TotalQuestions: totalQuestions,
+		CorrectAnswers: correctAnswers,
+	}
+}
+
+// liveOptions returns a copy of a question loaded with its deleted options
+// that only has the options not deleted
+func liveOptions(question *models.Question) *models.Question {
+	if question == nil {
+		return nil
+	}
+	live := *question
+	live.Options = make([]models.Option, 0, len(question.Options))
+	for _, option := range question.Options {
+		if !option.DeletedAt.Valid {
+			live.Options = append(live.Options, option)
+		}
+	}
+	return &live
+}
+
+// isCorrectAnswer grades an answer with the grader of the type of its
+// question
+func isCorrectAnswer(question *models.Question, answer *models.CompletedQuestion) bool {
+	switch question.QuestionType {
+	case models.QuestionMatching:
+		return isCorrectMatching(question, answer.MatchedPairs)
+	case models.QuestionOrdering:
+		return isCorrectOrdering(question, answer.OrderedOptionIDs)
+	case models.QuestionNumeric:
+		return isCorrectNumeric(question, answer.NumericAnswer)
+	case models.QuestionShortText:
+		return answer.TextAnswer != nil && acceptsText(question, nil, *answer.TextAnswer)
+	case models.QuestionFillBlank:
+		return isCorrectFillBlank(question, answer.BlankAnswers)
+	default:
+		// Questions from before question types were choice questions
+		return isCorrect(question, answer.SelectedOptions)
+	}
+}
+
+// isCorrect reports whether the selected options are exactly the correct
+// options of the question
+func isCorrect(question *models.Question, selectedOptions []*models.Option) bool {
+	correct := make(map[uuid.UUID]bool)
+	for _, option := range question.Options {
+		if option.IsCorrect {
+			correct[option.ID] = true
+		}
+	}
+
+	if len(selectedOptions) != len(correct) {
+		return false
+	}
+	for _, selectedOption := range selectedOptions {
+		if !correct[selectedOption.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// isCorrectMatching reports whether every option with a match text is paired
+// with it, and no other option is paired
+func isCorrectMatching(question *models.Question, pairs models.MatchPairs) bool {
+	given := make(map[uuid.UUID]string, len(pairs))
+	for _, pair := range pairs {
+		given[pair.OptionID] = pair.MatchText
+	}
+
+	matched := 0
+	for _, option := range question.Options {
+		text, ok := given[option.ID]
+		if option.MatchText == nil {
+			if ok {
+				return false
+			}
+			continue
+		}
+		if !ok || normalizeText(text) != normalizeText(*option.MatchText) {
+			return false
+		}
+		matched++
+	}
+	return matched > 0 && len(given) == matched && len(pairs) == matched
+}
+
+// isCorrectOrdering reports whether the options are given in the order of
+// their positions, which must be distinct
+func isCorrectOrdering(question *models.Question, ids models.OptionIDs) bool {
+	if len(ids) == 0 || len(ids) != len(question.Options) {
+		return false
+	}
+	options := make(map[uuid.UUID]*models.Option, len(question.Options))
+	for i := range question.Options {
+		options[question.Options[i].ID] = &question.Options[i]
+	}
+
+	previous := 0
+	for i, id := range ids {
+		option, ok := options[id]
+		if !ok || option.Position == nil || i > 0 && *option.Position <= previous {
+			return false
+		}
+		previous = *option.Position
+		delete(options, id)
+	}
+	return true
+}
+
+// isCorrectNumeric reports whether a number is within the tolerance of the
+// numeric answer of the question
+func isCorrectNumeric(question *models.Question, answer *float64) bool {
+	if answer == nil || question.NumericAnswer == nil {
+		return false
+	}
+	tolerance := 0.0
+	if question.Tolerance != nil {
+		tolerance = *question.Tolerance
+	}
+	return math.Abs(*answer-*question.NumericAnswer) <= tolerance
+}
+
+// isCorrectFillBlank reports whether the text of every blank is accepted for
+// it. The blanks of a question are the positions of its correct options.
+func isCorrectFillBlank(question *models.Question, answers models.BlankAnswers) bool {
+	blanks := 0
+	for _, option := range question.Options {
+		if option.IsCorrect && option.Position != nil && *option.Position > blanks {
+			blanks = *option.Position
+		}
+	}
+	if blanks == 0 || len(answers) != blanks {
+		return false
+	}
+	for i, answer := range answers {
+		blank := i + 1
+		if !acceptsText(question, &blank, answer) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptsText reports whether a text matches a correct option of the
+// question, of the given blank when blank is set. Case, surrounding space
+// and repeated spaces do not matter.
+func acceptsText(question *models.Question, blank *int, text string) bool {
+	text = normalizeText(text)
+	if text == "" {
+		return false
+	}
+	for _, option := range question.Options {
+		if !option.IsCorrect {
+			continue
+		}
+		if blank != nil && (option.Position == nil || *option.Position != *blank) {
+			continue
+		}
+		if normalizeText(option.Text) == text {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeText folds the case of a text and collapses its spaces
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
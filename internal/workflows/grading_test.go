@@ -0,0 +1,242 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func str(s string) *string { return &s }
+
+func num(f float64) *float64 { return &f }
+
+func pos(p int) *int { return &p }
+
+// option returns an option with a fixed ID, so answers can refer to it
+func option(id byte, text string, correct bool) models.Option {
+	return models.Option{ID: uuid.UUID{id}, Text: text, IsCorrect: correct}
+}
+
+func TestIsCorrect(t *testing.T) {
+	question := &models.Question{Options: []models.Option{
+		option(1, "a", true), option(2, "b", false), option(3, "c", true),
+	}}
+	for _, tc := range []struct {
+		what     string
+		selected []byte
+		want     bool
+	}{
+		{"every correct option", []byte{1, 3}, true},
+		{"in another order", []byte{3, 1}, true},
+		{"one correct option", []byte{1}, false},
+		{"a wrong option too", []byte{1, 2, 3}, false},
+		{"a wrong option instead", []byte{1, 2}, false},
+		{"nothing", nil, false},
+	} {
+		selected := make([]*models.Option, len(tc.selected))
+		for i, id := range tc.selected {
+			selected[i] = &models.Option{ID: uuid.UUID{id}}
+		}
+		if got := isCorrect(question, selected); got != tc.want {
+			t.Errorf("isCorrect with %s = %v, want %v", tc.what, got, tc.want)
+		}
+	}
+}
+
+func TestIsCorrectMatching(t *testing.T) {
+	capital, river, distractor := option(1, "Astana", false), option(2, "Ishim", false), option(3, "Almaty", false)
+	capital.MatchText, river.MatchText = str("capital"), str("river")
+	question := &models.Question{Options: []models.Option{capital, river, distractor}}
+	pair := func(id byte, text string) models.MatchPair {
+		return models.MatchPair{OptionID: uuid.UUID{id}, MatchText: text}
+	}
+	for _, tc := range []struct {
+		what  string
+		pairs models.MatchPairs
+		want  bool
+	}{
+		{"every pair", models.MatchPairs{pair(2, "river"), pair(1, "capital")}, true},
+		{"case and spaces", models.MatchPairs{pair(1, " Capital "), pair(2, "RIVER")}, true},
+		{"a pair missing", models.MatchPairs{pair(1, "capital")}, false},
+		{"pairs swapped", models.MatchPairs{pair(1, "river"), pair(2, "capital")}, false},
+		{"the distractor paired", models.MatchPairs{pair(1, "capital"), pair(2, "river"), pair(3, "capital")}, false},
+		{"a pair given twice", models.MatchPairs{pair(1, "capital"), pair(2, "river"), pair(2, "river")}, false},
+		{"an unknown option", models.MatchPairs{pair(1, "capital"), pair(2, "river"), pair(9, "capital")}, false},
+		{"nothing", nil, false},
+	} {
+		if got := isCorrectMatching(question, tc.pairs); got != tc.want {
+			t.Errorf("isCorrectMatching with %s = %v, want %v", tc.what, got, tc.want)
+		}
+	}
+
+	if isCorrectMatching(&models.Question{Options: []models.Option{distractor}}, nil) {
+		t.Error("isCorrectMatching of a question without match texts = true, want false")
+	}
+}
+
+func TestIsCorrectOrdering(t *testing.T) {
+	first, second, third := option(1, "first", false), option(2, "second", false), option(3, "third", false)
+	first.Position, second.Position, third.Position = pos(1), pos(2), pos(5)
+	question := &models.Question{Options: []models.Option{third, first, second}}
+	for _, tc := range []struct {
+		what string
+		ids  []byte
+		want bool
+	}{
+		{"the order of the positions", []byte{1, 2, 3}, true},
+		{"two options swapped", []byte{2, 1, 3}, false},
+		{"an option missing", []byte{1, 2}, false},
+		{"an option twice", []byte{1, 2, 2}, false},
+		{"an unknown option", []byte{1, 2, 9}, false},
+		{"nothing", nil, false},
+	} {
+		ids := make(models.OptionIDs, len(tc.ids))
+		for i, id := range tc.ids {
+			ids[i] = uuid.UUID{id}
+		}
+		if got := isCorrectOrdering(question, ids); got != tc.want {
+			t.Errorf("isCorrectOrdering with %s = %v, want %v", tc.what, got, tc.want)
+		}
+	}
+
+	// Options sharing a position have no order to give them in
+	tied := &models.Question{Options: []models.Option{first, second, third}}
+	tied.Options[1].Position = pos(1)
+	for _, ids := range []models.OptionIDs{{{1}, {2}, {3}}, {{2}, {1}, {3}}} {
+		if isCorrectOrdering(tied, ids) {
+			t.Errorf("isCorrectOrdering of options sharing a position with %v = true, want false", ids)
+		}
+	}
+	unpositioned := &models.Question{Options: []models.Option{option(1, "a", false)}}
+	if isCorrectOrdering(unpositioned, models.OptionIDs{{1}}) {
+		t.Error("isCorrectOrdering of an option without a position = true, want false")
+	}
+}
+
+func TestIsCorrectNumeric(t *testing.T) {
+	for _, tc := range []struct {
+		what      string
+		key       *float64
+		tolerance *float64
+		answer    *float64
+		want      bool
+	}{
+		{"the exact answer", num(9.81), nil, num(9.81), true},
+		{"close without a tolerance", num(9.81), nil, num(9.8), false},
+		{"within the tolerance", num(9.81), num(0.05), num(9.77), true},
+		{"at the tolerance", num(10), num(0.5), num(10.5), true},
+		{"beyond the tolerance", num(10), num(0.5), num(10.6), false},
+		{"a negative answer", num(-3), num(0.1), num(3), false},
+		{"no answer", num(1), nil, nil, false},
+		{"no key", nil, nil, num(1), false},
+	} {
+		question := &models.Question{NumericAnswer: tc.key, Tolerance: tc.tolerance}
+		if got := isCorrectNumeric(question, tc.answer); got != tc.want {
+			t.Errorf("isCorrectNumeric with %s = %v, want %v", tc.what, got, tc.want)
+		}
+	}
+}
+
+func TestAcceptsText(t *testing.T) {
+	question := &models.Question{Options: []models.Option{
+		option(1, "Abai Kunanbayuly", true), option(2, "Abai", true), option(3, "Mukhtar Auezov", false),
+	}}
+	for _, tc := range []struct {
+		text string
+		want bool
+	}{
+		{"Abai Kunanbayuly", true},
+		{"  abai   KUNANBAYULY ", true},
+		{"abai", true},
+		{"Mukhtar Auezov", false},
+		{"Abai K.", false},
+		{"", false},
+		{"   ", false},
+	} {
+		if got := acceptsText(question, nil, tc.text); got != tc.want {
+			t.Errorf("acceptsText(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestIsCorrectFillBlank(t *testing.T) {
+	// Blank 1 accepts two spellings; blank 2 one word
+	first, alternative, second, wrong := option(1, "Astana", true), option(2, "Nur-Sultan", true), option(3, "Ishim", true), option(4, "Almaty", false)
+	first.Position, alternative.Position, second.Position, wrong.Position = pos(1), pos(1), pos(2), pos(2)
+	question := &models.Question{Options: []models.Option{second, wrong, alternative, first}}
+	for _, tc := range []struct {
+		what    string
+		answers models.BlankAnswers
+		want    bool
+	}{
+		{"both blanks", models.BlankAnswers{"Astana", "Ishim"}, true},
+		{"the other spelling", models.BlankAnswers{"nur-sultan", " ishim "}, true},
+		{"the blanks swapped", models.BlankAnswers{"Ishim", "Astana"}, false},
+		{"a wrong option", models.BlankAnswers{"Astana", "Almaty"}, false},
+		{"a blank empty", models.BlankAnswers{"Astana", ""}, false},
+		{"a blank missing", models.BlankAnswers{"Astana"}, false},
+		{"a blank too many", models.BlankAnswers{"Astana", "Ishim", "Ishim"}, false},
+		{"nothing", nil, false},
+	} {
+		if got := isCorrectFillBlank(question, tc.answers); got != tc.want {
+			t.Errorf("isCorrectFillBlank with %s = %v, want %v", tc.what, got, tc.want)
+		}
+	}
+
+	// A blank no correct option is positioned at can never be filled
+	gap := &models.Question{Options: []models.Option{first, second}}
+	gap.Options[1].Position = pos(3)
+	if isCorrectFillBlank(gap, models.BlankAnswers{"Astana", "", "Ishim"}) {
+		t.Error("isCorrectFillBlank of a question with a gap between its blanks = true, want false")
+	}
+}
+
+func TestGradeAttempt(t *testing.T) {
+	deleted := option(3, "c", true)
+	deleted.DeletedAt = gorm.DeletedAt{Valid: true}
+	// The current key only has option 1 correct, as option 3 was deleted
+	current := &models.Question{Options: []models.Option{option(1, "a", true), option(2, "b", false), deleted}}
+	// The pinned revision had options 1 and 3 correct
+	pinned := &models.Question{Options: []models.Option{option(1, "a", true), option(2, "b", false), option(3, "c", true)}}
+	essay := &models.Question{QuestionType: models.QuestionEssay}
+
+	attempt := &models.CompletedTest{Questions: []models.CompletedQuestion{
+		{
+			Question:        current,
+			PinnedQuestion:  pinned,
+			SelectedOptions: []*models.Option{{ID: uuid.UUID{1}}, {ID: uuid.UUID{3}}},
+		},
+		{
+			Question:        current,
+			PinnedQuestion:  pinned,
+			SelectedOptions: []*models.Option{{ID: uuid.UUID{1}}},
+		},
+		{Question: essay, EssayGrade: &models.EssayGrade{Points: 3, MaxPoints: 4}},
+		{Question: essay},
+	}}
+
+	for _, tc := range []struct {
+		what         string
+		currentKey   bool
+		score, right int
+	}{
+		// 1 + 0 + 0.75 + 0 of 4
+		{"pinned revisions", false, 43, 1},
+		// 0 + 1 + 0.75 + 0 of 4
+		{"the live options of the current key", true, 43, 1},
+	} {
+		got := gradeAttempt(attempt, tc.currentKey)
+		if got.Score != tc.score || got.CorrectAnswers != tc.right || got.TotalQuestions != 4 {
+			t.Errorf("gradeAttempt against %s = %+v, want score %d with %d of 4 correct", tc.what, got, tc.score, tc.right)
+		}
+	}
+
+	full := &models.CompletedTest{Questions: []models.CompletedQuestion{
+		{Question: essay, EssayGrade: &models.EssayGrade{Points: 4, MaxPoints: 4}},
+	}}
+	if got := gradeAttempt(full, false); got.Score != 100 || got.CorrectAnswers != 1 {
+		t.Errorf("gradeAttempt of an essay with full points = %+v, want score 100 with 1 correct", got)
+	}
+}
@@ -0,0 +1,223 @@
+package workflows
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Regrade batching defaults
+const (
+	// DefaultRegradeBatchSize is the number of attempts checked concurrently
+	DefaultRegradeBatchSize = 50
+	// regradeBatchesPerRun is the number of batches after which the workflow
+	// continues as new to keep its history small
+	regradeBatchesPerRun = 20
+)
+
+// regradeKeysetVersion marks the regrade workflows that page through the
+// attempts by cursor, rather than by offset into a list of them all
+const regradeKeysetVersion = "regrade-keyset"
+
+// RegradeParams contains parameters for the regrade workflow
+type RegradeParams struct {
+	RegradeID uuid.UUID
+	BatchSize int
+	// After, Attempts and Changed carry progress over when continuing as new
+	After    *models.RegradeCursor
+	Attempts int
+	Changed  int
+	// Offset carries progress over in regrades started before the attempts
+	// were paged by cursor
+	Offset int
+}
+
+// RegradeAttempt is the result of checking one attempt during a regrade
+type RegradeAttempt struct {
+	CompletedTestID uuid.UUID
+	Result          TestResult
+}
+
+// RegradeWorkflow rescores the attempts in the scope of a regrade against the
+// current answer key. Attempts are checked in concurrent batches, in the order
+// they were started, and the old and new score of each is recorded.
+func RegradeWorkflow(ctx workflow.Context, params RegradeParams) error {
+	var a *Activities
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Regrade workflow started", "regradeID", params.RegradeID, "attempts", params.Attempts)
+
+	batchSize := params.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultRegradeBatchSize
+	}
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	// Regrades started before paging by cursor keep going through the list
+	// of attempts they started with
+	keyset := workflow.GetVersion(ctx, regradeKeysetVersion, workflow.DefaultVersion, 1) == 1 && params.Offset == 0
+	var ids []uuid.UUID
+	if !keyset {
+		if err := workflow.ExecuteActivity(ctx, a.ListRegradeAttemptsActivity, params.RegradeID).Get(ctx, &ids); err != nil {
+			logger.Error("Failed to list attempts to regrade", "error", err)
+			return err
+		}
+	}
+
+	after, attempts, offset, changed := params.After, params.Attempts, params.Offset, params.Changed
+	for batches := 0; ; batches++ {
+		if !keyset && offset >= len(ids) {
+			attempts = len(ids)
+			break
+		}
+		if batches == regradeBatchesPerRun {
+			return workflow.NewContinueAsNewError(ctx, RegradeWorkflow, RegradeParams{
+				RegradeID: params.RegradeID,
+				BatchSize: batchSize,
+				After:     after,
+				Attempts:  attempts,
+				Changed:   changed,
+				Offset:    offset,
+			})
+		}
+
+		var batch []uuid.UUID
+		if keyset {
+			var page []models.RegradeCursor
+			err := workflow.ExecuteActivity(ctx, a.PageRegradeAttemptsActivity, params.RegradeID, after, batchSize).Get(ctx, &page)
+			if err != nil {
+				logger.Error("Failed to list attempts to regrade", "error", err)
+				return err
+			}
+			if len(page) == 0 {
+				break
+			}
+			for _, cursor := range page {
+				batch = append(batch, cursor.ID)
+			}
+			after = &page[len(page)-1]
+		} else {
+			end := offset + batchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			batch = ids[offset:end]
+			offset = end
+		}
+
+		// Check the attempts of the batch concurrently
+		futures := make([]workflow.Future, len(batch))
+		for i, id := range batch {
+			futures[i] = workflow.ExecuteActivity(ctx, a.CheckTestActivity, id, CheckTestOptions{CurrentKey: true})
+		}
+		results := make([]RegradeAttempt, 0, len(batch))
+		for i, future := range futures {
+			var result TestResult
+			if err := future.Get(ctx, &result); err != nil {
+				logger.Error("Failed to regrade attempt", "completedTestID", batch[i], "error", err)
+				return err
+			}
+			results = append(results, RegradeAttempt{CompletedTestID: batch[i], Result: result})
+		}
+
+		var batchChanged int
+		err := workflow.ExecuteActivity(ctx, a.RecordRegradeResultsActivity, params.RegradeID, results).Get(ctx, &batchChanged)
+		if err != nil {
+			logger.Error("Failed to record regrade results", "error", err)
+			return err
+		}
+		changed += batchChanged
+		attempts += len(batch)
+	}
+
+	err := workflow.ExecuteActivity(ctx, a.FinishRegradeActivity, params.RegradeID, attempts, changed).Get(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to finish regrade", "error", err)
+		return err
+	}
+
+	logger.Info("Regrade workflow completed", "regradeID", params.RegradeID, "attempts", attempts, "changed", changed)
+	return nil
+}
+
+// ListRegradeAttemptsActivity returns the IDs of the attempts in the scope of
+// a regrade, for regrades started before paging by cursor
+func (a *Activities) ListRegradeAttemptsActivity(ctx context.Context, regradeID uuid.UUID) ([]uuid.UUID, error) {
+	regrade, err := a.Regrades.Get(ctx, regradeID)
+	if err != nil {
+		a.Logger.Errorw("Failed to get regrade", "regradeID", regradeID, "error", err)
+		return nil, err
+	}
+	return a.Attempts.ListIDsForRegrade(ctx, regrade)
+}
+
+// PageRegradeAttemptsActivity returns the next attempts in the scope of a
+// regrade after the cursor, up to limit
+func (a *Activities) PageRegradeAttemptsActivity(ctx context.Context, regradeID uuid.UUID, after *models.RegradeCursor, limit int) ([]models.RegradeCursor, error) {
+	regrade, err := a.Regrades.Get(ctx, regradeID)
+	if err != nil {
+		a.Logger.Errorw("Failed to get regrade", "regradeID", regradeID, "error", err)
+		return nil, err
+	}
+	return a.Attempts.PageForRegrade(ctx, regrade, after, limit)
+}
+
+// RecordRegradeResultsActivity stores the old and new score of a batch of
+// regraded attempts, publishes an event for every changed result and returns
+// how many changed. A retried batch only publishes the events of the results
+// it stored itself.
+func (a *Activities) RecordRegradeResultsActivity(ctx context.Context, regradeID uuid.UUID, attempts []RegradeAttempt) (int, error) {
+	results := make([]*models.RegradeResult, 0, len(attempts))
+	changed := 0
+	for _, attempt := range attempts {
+		result := &models.RegradeResult{
+			RegradeID:       regradeID,
+			CompletedTestID: attempt.CompletedTestID,
+			OldScore:        attempt.Result.PreviousScore,
+			NewScore:        attempt.Result.Score,
+		}
+		if result.Changed() {
+			changed++
+		}
+		results = append(results, result)
+	}
+	added, err := a.Regrades.AddResults(ctx, results)
+	if err != nil {
+		a.Logger.Errorw("Failed to save regrade results", "regradeID", regradeID, "error", err)
+		return 0, err
+	}
+
+	for _, result := range added {
+		if !result.Changed() {
+			continue
+		}
+		if err := a.Publisher.PublishResultRegraded(result); err != nil {
+			// Log the error but don't fail the activity
+			a.Logger.Errorw("Failed to publish result regraded event", "error", err)
+		}
+	}
+	return changed, nil
+}
+
+// FinishRegradeActivity marks a regrade as completed
+func (a *Activities) FinishRegradeActivity(ctx context.Context, regradeID uuid.UUID, attempts, changed int) error {
+	if err := a.Regrades.Finish(ctx, regradeID, attempts, changed); err != nil {
+		a.Logger.Errorw("Failed to finish regrade", "regradeID", regradeID, "error", err)
+		return err
+	}
+	a.Logger.Infow("Regrade finished", "regradeID", regradeID, "attempts", attempts, "changed", changed)
+	return nil
+}
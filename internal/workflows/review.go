@@ -0,0 +1,162 @@
+package workflows
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ReviewDecisionSignal is the signal carrying the decision on a question in
+// review to its review workflow
+const ReviewDecisionSignal = "review-decision"
+
+// DefaultReviewReminder is how long a question waits in review before the
+// reviewers are reminded of it, and again after every reminder
+const DefaultReviewReminder = 72 * time.Hour
+
+// ReviewWorkflowID returns the ID of the review workflow of a question
+func ReviewWorkflowID(questionID uuid.UUID) string {
+	return "question-review-" + questionID.String()
+}
+
+// ReviewParams contains parameters for the question review workflow
+type ReviewParams struct {
+	QuestionID uuid.UUID
+	AuthorID   *uuid.UUID
+	// SubmittedBy is the user who submitted the question, who is not
+	// notified of their own submission
+	SubmittedBy *uuid.UUID
+	Comment     *string
+	Reminder    time.Duration
+}
+
+// ReviewDecision is the approval or rejection of a question in review
+type ReviewDecision struct {
+	Action  models.ReviewAction
+	ActorID *uuid.UUID
+	Comment *string
+}
+
+// QuestionReviewWorkflow follows a question from its submission to the
+// decision of a reviewer. The reviewers are notified of the submission and
+// reminded while no decision arrives; the author is told the decision.
+func QuestionReviewWorkflow(ctx workflow.Context, params ReviewParams) error {
+	var a *Activities
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Question review workflow started", "questionID", params.QuestionID)
+
+	reminder := params.Reminder
+	if reminder <= 0 {
+		reminder = DefaultReviewReminder
+	}
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	requested := models.ReviewNotice{
+		QuestionID: params.QuestionID,
+		Action:     models.ReviewSubmit,
+		Status:     models.QuestionInReview,
+		ActorID:    params.SubmittedBy,
+		Comment:    params.Comment,
+	}
+	if err := workflow.ExecuteActivity(ctx, a.NotifyReviewersActivity, requested).Get(ctx, nil); err != nil {
+		logger.Error("Failed to notify reviewers", "error", err)
+	}
+
+	// Wait for the decision, reminding the reviewers every reminder period
+	decisions := workflow.GetSignalChannel(ctx, ReviewDecisionSignal)
+	var decision ReviewDecision
+	for decided := false; !decided; {
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, reminder)
+
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(decisions, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &decision)
+			decided = true
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {
+			if err := f.Get(ctx, nil); err != nil {
+				return
+			}
+			logger.Info("Reminding reviewers", "questionID", params.QuestionID)
+			if err := workflow.ExecuteActivity(ctx, a.NotifyReviewersActivity, requested).Get(ctx, nil); err != nil {
+				logger.Error("Failed to remind reviewers", "error", err)
+			}
+		})
+		selector.Select(ctx)
+		cancelTimer()
+	}
+	logger.Info("Review decision received", "questionID", params.QuestionID, "action", decision.Action)
+
+	status := models.QuestionDraft
+	if decision.Action == models.ReviewApprove {
+		status = models.QuestionApproved
+	}
+	reviewed := models.ReviewNotice{
+		QuestionID: params.QuestionID,
+		Action:     decision.Action,
+		Status:     status,
+		ActorID:    decision.ActorID,
+		Comment:    decision.Comment,
+	}
+	if params.AuthorID != nil {
+		reviewed.Recipients = []uuid.UUID{*params.AuthorID}
+	}
+	if err := workflow.ExecuteActivity(ctx, a.NotifyReviewedActivity, reviewed).Get(ctx, nil); err != nil {
+		logger.Error("Failed to notify the author", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// NotifyReviewersActivity tells the reviewers about a question waiting for
+// review, except the user who submitted it
+func (a *Activities) NotifyReviewersActivity(ctx context.Context, notice models.ReviewNotice) error {
+	reviewers, err := a.Users.ListIDsByRole(ctx, models.RoleReviewer, models.RoleAdmin)
+	if err != nil {
+		a.Logger.Errorw("Failed to list reviewers", "error", err)
+		return err
+	}
+
+	notice.Recipients = nil
+	for _, id := range reviewers {
+		if notice.ActorID == nil || id != *notice.ActorID {
+			notice.Recipients = append(notice.Recipients, id)
+		}
+	}
+
+	if err := a.Publisher.PublishReviewRequested(&notice); err != nil {
+		a.Logger.Errorw("Failed to publish review requested event", "error", err)
+		return err
+	}
+
+	a.Logger.Infow("Reviewers notified", "questionID", notice.QuestionID, "reviewers", len(notice.Recipients))
+	return nil
+}
+
+// NotifyReviewedActivity tells the recipients of a notice, the author of the
+// question, about a review step
+func (a *Activities) NotifyReviewedActivity(ctx context.Context, notice models.ReviewNotice) error {
+	if err := a.Publisher.PublishQuestionReviewed(&notice); err != nil {
+		a.Logger.Errorw("Failed to publish question reviewed event", "error", err)
+		return err
+	}
+
+	a.Logger.Infow("Question review step notified", "questionID", notice.QuestionID, "action", notice.Action)
+	return nil
+}
@@ -5,28 +5,49 @@ import (
 	"time"
 
 	"github.com/Alan69/ayatest/internal/database"
-	"github.com/Alan69/ayatest/internal/models"
+	"github.com/Alan69/ayatest/internal/events"
+	"github.com/Alan69/ayatest/internal/repository"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// Activities holds the dependencies of the test activities. Its methods are
+// registered with the worker as activities.
+type Activities struct {
+	Attempts  repository.AttemptRepo
+	Trash     repository.TrashRepo
+	Regrades  repository.RegradeRepo
+	Users     repository.UserRepo
+	Grading   repository.GradingRepo
+	Publisher events.Publisher
+	Logger    *zap.SugaredLogger
+}
+
 // TestResult represents the result of a test
 type TestResult struct {
 	Score          int
 	TotalQuestions int
 	CorrectAnswers int
+	// PreviousScore is the score stored before this check, nil on the first check
+	PreviousScore *int
+}
+
+// CheckTestOptions controls how CheckTestActivity grades an attempt
+type CheckTestOptions struct {
+	// CurrentKey grades against the current answer key instead of the
+	// question revisions the answers were pinned to
+	CurrentKey bool
 }
 
 // AutoCompleteTestActivity automatically completes a test when the timer expires
-func AutoCompleteTestActivity(ctx context.Context, completedTestID uuid.UUID) error {
-	logger, _ := zap.NewProduction()
-	sugar := logger.Sugar()
-	sugar.Infow("Auto-completing test", "completedTestID", completedTestID)
-
-	// Get the completed test
-	var completedTest models.CompletedTest
-	if err := database.DB.First(&completedTest, "id = ?", completedTestID).Error; err != nil {
-		sugar.Errorw("Failed to get completed test", "error", err)
+func (a *Activities) AutoCompleteTestActivity(ctx context.Context, completedTestID uuid.UUID) error {
+	a.Logger.Infow("Auto-completing test", "completedTestID", completedTestID)
+
+	// Get the completed test from the primary, the attempt was just written
+	ctx = database.WithPrimary(ctx)
+	completedTest, err := a.Attempts.Get(ctx, completedTestID)
+	if err != nil {
+		a.Logger.Errorw("Failed to get completed test", "error", err)
 		return err
 	}
 
@@ -40,106 +61,74 @@ func AutoCompleteTestActivity(ctx context.Context, completedTestID uuid.UUID) er
 	completedTest.TimeSpent = &timeSpent
 
 	// Save the completed test
-	if err := database.DB.Save(&completedTest).Error; err != nil {
-		sugar.Errorw("Failed to save completed test", "error", err)
+	if err := a.Attempts.Update(ctx, completedTest); err != nil {
+		a.Logger.Errorw("Failed to save completed test", "error", err)
 		return err
 	}
 
-	sugar.Infow("Test auto-completed", "completedTestID", completedTestID)
+	a.Logger.Infow("Test auto-completed", "completedTestID", completedTestID)
 	return nil
 }
 
 // SendTestReminderActivity sends a reminder to a user about their ongoing test
-func SendTestReminderActivity(ctx context.Context, completedTestID, userID uuid.UUID) error {
-	logger, _ := zap.NewProduction()
-	sugar := logger.Sugar()
-	sugar.Infow("Sending test reminder", "completedTestID", completedTestID, "userID", userID)
+func (a *Activities) SendTestReminderActivity(ctx context.Context, completedTestID, userID uuid.UUID) error {
+	a.Logger.Infow("Sending test reminder", "completedTestID", completedTestID, "userID", userID)
 
 	// In a real implementation, this would send a notification to the user
 	// For now, we'll just log it
 
-	sugar.Infow("Test reminder sent", "completedTestID", completedTestID, "userID", userID)
+	a.Logger.Infow("Test reminder sent", "completedTestID", completedTestID, "userID", userID)
 	return nil
 }
 
-// CheckTestActivity checks a completed test and calculates the score
-func CheckTestActivity(ctx context.Context, completedTestID uuid.UUID) (TestResult, error) {
-	logger, _ := zap.NewProduction()
-	sugar := logger.Sugar()
-	sugar.Infow("Checking test", "completedTestID", completedTestID)
+// CheckTestActivity checks a completed test, calculates the score and stores it
+func (a *Activities) CheckTestActivity(ctx context.Context, completedTestID uuid.UUID, options CheckTestOptions) (TestResult, error) {
+	a.Logger.Infow("Checking test", "completedTestID", completedTestID)
 
-	// Get the completed test with its questions and selected options
-	var completedTest models.CompletedTest
-	if err := database.DB.Preload("Questions.SelectedOptions").Preload("Questions.Question.Options").First(&completedTest, "id = ?", completedTestID).Error; err != nil {
-		sugar.Errorw("Failed to get completed test", "error", err)
+	// Get the completed test with its questions and selected options from the
+	// primary, the answers were just written
+	ctx = database.WithPrimary(ctx)
+	completedTest, err := a.Attempts.GetWithAnswers(ctx, completedTestID)
+	if err != nil {
+		a.Logger.Errorw("Failed to get completed test", "error", err)
 		return TestResult{}, err
 	}
 
-	// Calculate the score
-	totalQuestions := len(completedTest.Questions)
-	correctAnswers := 0
-
-	for _, completedQuestion := range completedTest.Questions {
-		// Skip if the question is nil
-		if completedQuestion.Question == nil {
-			continue
-		}
-
-		// Get the correct options for the question
-		var correctOptions []models.Option
-		for _, option := range completedQuestion.Question.Options {
-			if option.IsCorrect {
-				correctOptions = append(correctOptions, option)
-			}
-		}
-
-		// Check if the selected options match the correct options
-		if len(completedQuestion.SelectedOptions) == len(correctOptions) {
-			correct := true
-			for _, selectedOption := range completedQuestion.SelectedOptions {
-				found := false
-				for _, correctOption := range correctOptions {
-					if selectedOption.ID == correctOption.ID {
-						found = true
-						break
-					}
-				}
-				if !found {
-					correct = false
-					break
-				}
-			}
-			if correct {
-				correctAnswers++
-			}
-		}
-	}
+	// Grade each answer against the revision of the question it was given,
+	// or against the current answer key when regrading
+	result := gradeAttempt(completedTest, options.CurrentKey)
 
-	// Calculate the score as a percentage
-	score := 0
-	if totalQuestions > 0 {
-		score = (correctAnswers * 100) / totalQuestions
-	}
-
-	result := TestResult{
-		Score:          score,
-		TotalQuestions: totalQuestions,
-		CorrectAnswers: correctAnswers,
+	// Save the score
+	result.PreviousScore, err = a.Attempts.SaveScore(ctx, completedTestID, result.Score, result.CorrectAnswers, result.TotalQuestions)
+	if err != nil {
+		a.Logger.Errorw("Failed to save test score", "error", err)
+		return TestResult{}, err
 	}
 
-	sugar.Infow("Test checked", "completedTestID", completedTestID, "score", score)
+	a.Logger.Infow("Test checked", "completedTestID", completedTestID, "score", result.Score)
 	return result, nil
 }
 
+// CountPendingEssaysActivity counts the essays of a completed test waiting
+// for a grade
+func (a *Activities) CountPendingEssaysActivity(ctx context.Context, completedTestID uuid.UUID) (int64, error) {
+	// Grades were just written
+	ctx = database.WithPrimary(ctx)
+	pending, err := a.Grading.CountPending(ctx, completedTestID)
+	if err != nil {
+		a.Logger.Errorw("Failed to count essays waiting for a grade", "completedTestID", completedTestID, "error", err)
+		return 0, err
+	}
+	return pending, nil
+}
+
 // NotifyTestResultsActivity notifies a user of their test results
-func NotifyTestResultsActivity(ctx context.Context, completedTestID uuid.UUID, result TestResult) error {
-	logger, _ := zap.NewProduction()
-	sugar := logger.Sugar()
-	sugar.Infow("Notifying test results", "completedTestID", completedTestID, "score", result.Score)
+func (a *Activities) NotifyTestResultsActivity(ctx context.Context, completedTestID uuid.UUID, result TestResult) error {
+	a.Logger.Infow("Notifying test results", "completedTestID", completedTestID, "score", result.Score)
 
 	// In a real implementation, this would send a notification to the user
 	// For now, we'll just log it
 
-	sugar.Infow("Test results notified", "completedTestID", completedTestID, "score", result.Score)
+	a.Logger.Infow("Test results notified", "completedTestID", completedTestID, "score", result.Score)
 	return nil
 }
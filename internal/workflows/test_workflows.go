@@ -18,6 +18,25 @@ type TestTimerParams struct {
 	DurationMinutes int
 }
 
+// EssayGradedSignal is the signal telling the auto-check workflow of an
+// attempt that one of its essays was graded, carrying the ID of the answer
+const EssayGradedSignal = "essay-graded"
+
+// AutoCheckWorkflowID returns the ID of the auto-check workflow of an attempt
+func AutoCheckWorkflowID(completedTestID uuid.UUID) string {
+	return "test-autocheck-" + completedTestID.String()
+}
+
+// checkTestOptionsVersion marks the auto-check workflows that pass options to
+// CheckTestActivity. Workflows started before schedule it by name with the
+// attempt only, as their history holds, and get the default options.
+const checkTestOptionsVersion = "check-test-options"
+
+// autoCheckEssaysVersion marks the auto-check workflows that wait for the
+// essays of the attempt to be graded. Workflows started before check the
+// attempt right away, as their history holds.
+const autoCheckEssaysVersion = "auto-check-essays"
+
 // AutoCheckTestParams contains parameters for the auto-check test workflow
 type AutoCheckTestParams struct {
 	CompletedTestID uuid.UUID
@@ -25,6 +44,7 @@ type AutoCheckTestParams struct {
 
 // TestTimerWorkflow is a workflow that tracks the time for a test
 func TestTimerWorkflow(ctx workflow.Context, params TestTimerParams) error {
+	var a *Activities
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Test timer workflow started", "completedTestID", params.CompletedTestID)
 
@@ -73,7 +93,7 @@ func TestTimerWorkflow(ctx workflow.Context, params TestTimerParams) error {
 			},
 		}
 		ctx = workflow.WithActivityOptions(ctx, activityOptions)
-		err = workflow.ExecuteActivity(ctx, AutoCompleteTestActivity, params.CompletedTestID).Get(ctx, nil)
+		err = workflow.ExecuteActivity(ctx, a.AutoCompleteTestActivity, params.CompletedTestID).Get(ctx, nil)
 		if err != nil {
 			logger.Error("Failed to auto-complete test", "error", err)
 		}
@@ -99,7 +119,7 @@ func TestTimerWorkflow(ctx workflow.Context, params TestTimerParams) error {
 			},
 		}
 		ctx = workflow.WithActivityOptions(ctx, activityOptions)
-		err = workflow.ExecuteActivity(ctx, SendTestReminderActivity, params.CompletedTestID, params.UserID).Get(ctx, nil)
+		err = workflow.ExecuteActivity(ctx, a.SendTestReminderActivity, params.CompletedTestID, params.UserID).Get(ctx, nil)
 		if err != nil {
 			logger.Error("Failed to send test reminder", "error", err)
 		}
@@ -122,8 +142,12 @@ func TestTimerWorkflow(ctx workflow.Context, params TestTimerParams) error {
 	return nil
 }
 
-// AutoCheckTestWorkflow is a workflow that automatically checks a completed test
+// AutoCheckTestWorkflow is a workflow that automatically checks a completed
+// test. Attempts with essays are only checked once a grader graded all of
+// them; the workflow waits for a signal after every grade. Grades signalled
+// while the attempt is being checked have it checked again.
 func AutoCheckTestWorkflow(ctx workflow.Context, params AutoCheckTestParams) error {
+	var a *Activities
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Auto-check test workflow started", "completedTestID", params.CompletedTestID)
 
@@ -138,19 +162,52 @@ func AutoCheckTestWorkflow(ctx workflow.Context, params AutoCheckTestParams) err
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
-	var result TestResult
-	err := workflow.ExecuteActivity(ctx, CheckTestActivity, params.CompletedTestID).Get(ctx, &result)
-	if err != nil {
-		logger.Error("Failed to check test", "error", err)
-		return err
-	}
+	withOptions := workflow.GetVersion(ctx, checkTestOptionsVersion, workflow.DefaultVersion, 1) == 1
+	withEssays := workflow.GetVersion(ctx, autoCheckEssaysVersion, workflow.DefaultVersion, 1) == 1
+	graded := workflow.GetSignalChannel(ctx, EssayGradedSignal)
+	for {
+		// Wait until every essay of the attempt is graded
+		var pending int64
+		if withEssays {
+			err := workflow.ExecuteActivity(ctx, a.CountPendingEssaysActivity, params.CompletedTestID).Get(ctx, &pending)
+			if err != nil {
+				logger.Error("Failed to count essays waiting for a grade", "error", err)
+				return err
+			}
+		}
+		if pending > 0 {
+			logger.Info("Waiting for essay grades", "completedTestID", params.CompletedTestID, "pending", pending)
+			var answerID uuid.UUID
+			graded.Receive(ctx, &answerID)
+			logger.Info("Essay graded", "completedTestID", params.CompletedTestID, "completedQuestionID", answerID)
+			continue
+		}
 
-	// Execute activity to notify the user of the results
-	err = workflow.ExecuteActivity(ctx, NotifyTestResultsActivity, params.CompletedTestID, result).Get(ctx, nil)
-	if err != nil {
-		logger.Error("Failed to notify test results", "error", err)
-		return err
-	}
+		var result TestResult
+		var check workflow.Future
+		if withOptions {
+			check = workflow.ExecuteActivity(ctx, a.CheckTestActivity, params.CompletedTestID, CheckTestOptions{})
+		} else {
+			check = workflow.ExecuteActivity(ctx, "CheckTestActivity", params.CompletedTestID)
+		}
+		if err := check.Get(ctx, &result); err != nil {
+			logger.Error("Failed to check test", "error", err)
+			return err
+		}
 
-	return nil
+		// Execute activity to notify the user of the results
+		err := workflow.ExecuteActivity(ctx, a.NotifyTestResultsActivity, params.CompletedTestID, result).Get(ctx, nil)
+		if err != nil {
+			logger.Error("Failed to notify test results", "error", err)
+			return err
+		}
+
+		// Essays regraded while checking are checked again
+		var answerID uuid.UUID
+		if !graded.ReceiveAsync(&answerID) {
+			return nil
+		}
+		for graded.ReceiveAsync(&answerID) {
+		}
+	}
 }
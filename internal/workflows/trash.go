@@ -0,0 +1,67 @@
+package workflows
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alan69/ayatest/internal/models"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// PurgeTrashWorkflowID is the ID of the scheduled trash purge workflow
+const PurgeTrashWorkflowID = "purge-trash"
+
+// PurgeTrashParams contains parameters for the purge trash workflow
+type PurgeTrashParams struct {
+	// Retention is how long soft deleted content is kept before it is purged
+	Retention time.Duration
+}
+
+// PurgeTrashWorkflow permanently removes soft deleted content older than the
+// retention period. It is started with a cron schedule.
+func PurgeTrashWorkflow(ctx workflow.Context, params PurgeTrashParams) error {
+	var a *Activities
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Purge trash workflow started", "retention", params.Retention)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+	var result models.PurgeResult
+	err := workflow.ExecuteActivity(ctx, a.PurgeTrashActivity, params.Retention).Get(ctx, &result)
+	if err != nil {
+		logger.Error("Failed to purge trash", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// PurgeTrashActivity permanently deletes soft deleted content that was deleted
+// before the retention period and is not referenced by any attempt
+func (a *Activities) PurgeTrashActivity(ctx context.Context, retention time.Duration) (*models.PurgeResult, error) {
+	deletedBefore := time.Now().Add(-retention)
+	a.Logger.Infow("Purging trash", "deletedBefore", deletedBefore)
+
+	result, err := a.Trash.Purge(ctx, deletedBefore)
+	if err != nil {
+		a.Logger.Errorw("Failed to purge trash", "error", err)
+		return nil, err
+	}
+
+	a.Logger.Infow("Trash purged",
+		"products", result.Products,
+		"tests", result.Tests,
+		"questions", result.Questions,
+		"options", result.Options,
+	)
+	return result, nil
+}
@@ -8,16 +8,18 @@ import (
 
 // Worker represents a Temporal worker
 type Worker struct {
-	client client.Client
-	worker worker.Worker
-	logger *zap.SugaredLogger
+	client     client.Client
+	worker     worker.Worker
+	activities *Activities
+	logger     *zap.SugaredLogger
 }
 
 // NewWorker creates a new Temporal worker
-func NewWorker(c client.Client, logger *zap.SugaredLogger) *Worker {
+func NewWorker(c client.Client, activities *Activities, logger *zap.SugaredLogger) *Worker {
 	return &Worker{
-		client: c,
-		logger: logger,
+		client:     c,
+		activities: activities,
+		logger:     logger,
 	}
 }
 
@@ -31,12 +33,12 @@ func (w *Worker) Start() error {
 	// Register workflows
 	w.worker.RegisterWorkflow(TestTimerWorkflow)
 	w.worker.RegisterWorkflow(AutoCheckTestWorkflow)
+	w.worker.RegisterWorkflow(PurgeTrashWorkflow)
+	w.worker.RegisterWorkflow(RegradeWorkflow)
+	w.worker.RegisterWorkflow(QuestionReviewWorkflow)
 
 	// Register activities
-	w.worker.RegisterActivity(AutoCompleteTestActivity)
-	w.worker.RegisterActivity(SendTestReminderActivity)
-	w.worker.RegisterActivity(CheckTestActivity)
-	w.worker.RegisterActivity(NotifyTestResultsActivity)
+	w.worker.RegisterActivity(w.activities)
 
 	// Start the worker
 	err := w.worker.Start()